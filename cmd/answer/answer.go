@@ -0,0 +1,84 @@
+package answer
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/devflowinc/uzi/pkg/runner"
+	"github.com/devflowinc/uzi/pkg/state"
+	"github.com/devflowinc/uzi/pkg/status"
+
+	"github.com/charmbracelet/log"
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+var (
+	fs        = flag.NewFlagSet("uzi answer", flag.ExitOnError)
+	asText    = fs.Bool("text", false, "send the answer as typed text followed by Enter instead of picking an option")
+	force     = fs.Bool("force", false, "send the answer even if the agent is not waiting for input")
+	CmdAnswer = &ffcli.Command{
+		Name:       "answer",
+		ShortUsage: "uzi answer [-text] [-force] <agent-name> <choice|text>",
+		ShortHelp:  "Answer the question a waiting agent is asking",
+		LongHelp: `
+Answers the question shown by an agent in the "waiting" status without
+attaching to it. "uzi ls" shows the pending question and its options.
+
+When the question offers options, the answer picks one by its key ("1", "y"),
+its label ("Yes") or a unique prefix of the label. Numbered menu options are
+selected with the key alone; y/n prompts and free-form questions are
+confirmed with Enter. Use -text to type the answer as-is, e.g. to give
+instructions instead of choosing an option.
+`,
+		FlagSet: fs,
+		Exec:    executeAnswer,
+	}
+)
+
+func executeAnswer(ctx context.Context, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("agent name and answer arguments are required")
+	}
+	agentName := args[0]
+	answer := strings.Join(args[1:], " ")
+
+	sm := state.NewStateManager()
+	if sm == nil {
+		return fmt.Errorf("could not initialize state manager")
+	}
+	sessionName, err := sm.FindSessionForAgent(agentName)
+	if err != nil {
+		return err
+	}
+
+	statusManager := status.NewStatusManager(status.DefaultTmuxClient(), status.NewStateAdapter(sm))
+	detailed, err := statusManager.GetDetailedStatus(sessionName)
+	if err != nil {
+		return fmt.Errorf("failed to get the status of %s: %w", agentName, err)
+	}
+	if detailed.Status != status.StatusWaiting && !*force {
+		return fmt.Errorf("agent %s is not waiting for input (status: %s); use -force to send the answer anyway", agentName, detailed.Status)
+	}
+
+	var question status.Question
+	if detailed.Question != nil && !*asText {
+		question = *detailed.Question
+	}
+	keys, err := question.Keys(answer)
+	if err != nil {
+		return fmt.Errorf("%w; use -text to send it as text", err)
+	}
+
+	log.Debug("Answering agent", "session", sessionName, "question", question.Text, "keys", keys)
+	if err := runner.Default().SendKeys(ctx, sessionName, "agent", keys...); err != nil {
+		return fmt.Errorf("failed to send the answer to %s: %w", agentName, err)
+	}
+	if question.Text != "" {
+		fmt.Printf("Answered %s: %s → %s\n", agentName, question.Text, answer)
+	} else {
+		fmt.Printf("Sent %q to %s\n", answer, agentName)
+	}
+	return nil
+}
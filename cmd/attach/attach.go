@@ -0,0 +1,90 @@
+package attach
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/devflowinc/uzi/pkg/runner"
+	"github.com/devflowinc/uzi/pkg/state"
+	"github.com/devflowinc/uzi/pkg/tmux"
+
+	"github.com/charmbracelet/log"
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+var (
+	fs        = flag.NewFlagSet("uzi attach", flag.ExitOnError)
+	CmdAttach = &ffcli.Command{
+		Name:       "attach",
+		ShortUsage: "uzi attach <agent-name>",
+		ShortHelp:  "Attach to the tmux session of the specified agent",
+		FlagSet:    fs,
+		Exec:       executeAttach,
+	}
+)
+
+// AttachSession switches the current tmux client to the given session when
+// running inside the uzi tmux server, and attaches a new client otherwise.
+// From inside the user's own tmux server the new client is nested.
+func AttachSession(ctx context.Context, sessionName string) error {
+	var cmd *exec.Cmd
+	if tmux.InsideUziServer() {
+		cmd = tmux.CommandContext(ctx, "switch-client", "-t", sessionName)
+	} else {
+		cmd = tmux.CommandContext(ctx, "attach-session", "-t", sessionName)
+		cmd.Env = environWithout("TMUX")
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	log.Debug("Attaching to session", "session", sessionName)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to attach to session %s: %w", sessionName, err)
+	}
+	return nil
+}
+
+// requireTmuxRunner fails when agents run under the PTY supervisor, whose
+// windows can be read and typed into but not attached to
+func requireTmuxRunner() error {
+	if name := runner.Default().Name(); name != runner.RunnerTmux {
+		return fmt.Errorf("attaching requires the tmux runner (current runner: %s); use \"uzi logs -f\" to follow an agent", name)
+	}
+	return nil
+}
+
+func environWithout(key string) []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, key+"=") {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+func executeAttach(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("agent name argument is required")
+	}
+	if err := requireTmuxRunner(); err != nil {
+		return err
+	}
+
+	sm := state.NewStateManager()
+	if sm == nil {
+		return fmt.Errorf("could not initialize state manager")
+	}
+
+	sessionName, err := sm.FindSessionForAgent(args[0])
+	if err != nil {
+		return err
+	}
+
+	return AttachSession(ctx, sessionName)
+}
@@ -0,0 +1,255 @@
+package attach
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/devflowinc/uzi/cmd/ls"
+	"github.com/devflowinc/uzi/pkg/state"
+	"github.com/devflowinc/uzi/pkg/status"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+var (
+	switchFs  = flag.NewFlagSet("uzi switch", flag.ExitOnError)
+	noFzf     = switchFs.Bool("no-fzf", false, "use the built-in picker even if fzf is installed")
+	CmdSwitch = &ffcli.Command{
+		Name:       "switch",
+		ShortUsage: "uzi switch [-no-fzf] [query]",
+		ShortHelp:  "Interactively pick an active agent and attach to its session",
+		FlagSet:    switchFs,
+		Exec:       executeSwitch,
+	}
+)
+
+// switchEntry is a single candidate shown in the picker
+type switchEntry struct {
+	session    string
+	agent      string
+	model      string
+	status     string
+	insertions int
+	deletions  int
+	prompt     string
+}
+
+func (e switchEntry) searchText() string {
+	return e.agent + " " + e.model + " " + e.status + " " + e.prompt
+}
+
+func collectSwitchEntries(sm *state.StateManager) ([]switchEntry, error) {
+	activeSessions, err := sm.GetActiveSessionsForRepo()
+	if err != nil {
+		return nil, fmt.Errorf("error getting active sessions: %w", err)
+	}
+
+	statusManager := status.NewStatusManager(status.DefaultTmuxClient(), status.NewStateAdapter(sm))
+
+	var entries []switchEntry
+	for _, session := range activeSessions {
+		agentState, err := sm.GetWorktreeInfo(session)
+		if err != nil {
+			continue
+		}
+
+		st, err := statusManager.GetStatus(session)
+		if err != nil {
+			st = "unknown"
+		}
+		insertions, deletions := ls.GetDiffTotals(session, sm)
+
+		entries = append(entries, switchEntry{
+			session:    session,
+			agent:      state.AgentNameFromSession(session),
+			model:      agentState.Model,
+			status:     st,
+			insertions: insertions,
+			deletions:  deletions,
+			prompt:     agentState.Prompt,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].agent < entries[j].agent
+	})
+
+	return entries, nil
+}
+
+// fuzzyScore reports whether every rune of query appears in target in order,
+// ignoring case and whitespace. Lower scores are better matches.
+func fuzzyScore(query, target string) (int, bool) {
+	queryRunes := []rune(strings.Join(strings.Fields(strings.ToLower(query)), ""))
+	if len(queryRunes) == 0 {
+		return 0, true
+	}
+
+	qi := 0
+	first, last := -1, -1
+	for i, r := range []rune(strings.ToLower(target)) {
+		if r != queryRunes[qi] {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+		last = i
+		qi++
+		if qi == len(queryRunes) {
+			// Prefer tight matches that start early
+			return (last - first) + first, true
+		}
+	}
+	return 0, false
+}
+
+func filterEntries(entries []switchEntry, query string) []switchEntry {
+	type scored struct {
+		entry switchEntry
+		score int
+	}
+
+	var matches []scored
+	for _, entry := range entries {
+		if score, ok := fuzzyScore(query, entry.searchText()); ok {
+			matches = append(matches, scored{entry: entry, score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score < matches[j].score
+	})
+
+	result := make([]switchEntry, len(matches))
+	for i, m := range matches {
+		result[i] = m.entry
+	}
+	return result
+}
+
+func printEntries(w io.Writer, entries []switchEntry) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, entry := range entries {
+		prompt := entry.prompt
+		if len(prompt) > 50 {
+			prompt = prompt[:47] + "..."
+		}
+		fmt.Fprintf(tw, "%d)\t%s\t%s\t%s\t+%d/-%d\t%s\n",
+			i+1, entry.agent, entry.model, entry.status, entry.insertions, entry.deletions, prompt)
+	}
+	tw.Flush()
+}
+
+// pickWithFzf delegates selection to fzf and returns the chosen agent name
+func pickWithFzf(ctx context.Context, entries []switchEntry, query string) (string, error) {
+	var input bytes.Buffer
+	tw := tabwriter.NewWriter(&input, 0, 0, 2, ' ', 0)
+	for _, entry := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t+%d/-%d\t%s\n",
+			entry.agent, entry.model, entry.status, entry.insertions, entry.deletions, entry.prompt)
+	}
+	tw.Flush()
+
+	cmd := exec.CommandContext(ctx, "fzf", "--prompt", "agent> ", "--query", query, "--no-multi")
+	cmd.Stdin = &input
+	cmd.Stderr = os.Stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("no agent selected")
+	}
+
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("no agent selected")
+	}
+	return fields[0], nil
+}
+
+// pickWithPrompt is a minimal line-based picker used when fzf is unavailable.
+// Entering a number selects that entry; any other text narrows the list.
+func pickWithPrompt(in io.Reader, out io.Writer, entries []switchEntry, query string) (string, error) {
+	reader := bufio.NewReader(in)
+	candidates := filterEntries(entries, query)
+
+	for {
+		if len(candidates) == 0 {
+			fmt.Fprintln(out, "No matching agents")
+			candidates = entries
+		}
+		if len(candidates) == 1 && query != "" {
+			return candidates[0].agent, nil
+		}
+
+		printEntries(out, candidates)
+		fmt.Fprint(out, "Select agent (number or filter, empty to cancel): ")
+
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("no agent selected")
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return "", fmt.Errorf("no agent selected")
+		}
+
+		if n, err := strconv.Atoi(line); err == nil {
+			if n >= 1 && n <= len(candidates) {
+				return candidates[n-1].agent, nil
+			}
+			fmt.Fprintf(out, "Invalid selection: %d\n", n)
+			continue
+		}
+
+		query = line
+		candidates = filterEntries(entries, query)
+	}
+}
+
+func executeSwitch(ctx context.Context, args []string) error {
+	if err := requireTmuxRunner(); err != nil {
+		return err
+	}
+
+	sm := state.NewStateManager()
+	if sm == nil {
+		return fmt.Errorf("could not initialize state manager")
+	}
+
+	entries, err := collectSwitchEntries(sm)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no active agent sessions found")
+	}
+
+	query := strings.Join(args, " ")
+
+	var agentName string
+	if _, lookErr := exec.LookPath("fzf"); lookErr == nil && !*noFzf {
+		agentName, err = pickWithFzf(ctx, entries, query)
+	} else {
+		agentName, err = pickWithPrompt(os.Stdin, os.Stdout, entries, query)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.agent == agentName {
+			return AttachSession(ctx, entry.session)
+		}
+	}
+	return fmt.Errorf("no active session found for agent: %s", agentName)
+}
@@ -0,0 +1,72 @@
+package attach
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	tests := []struct {
+		query   string
+		target  string
+		matches bool
+	}{
+		{"", "penelope", true},
+		{"pen", "penelope", true},
+		{"pnl", "penelope", true},
+		{"PEN", "penelope", true},
+		{"pen run", "penelope claude running", true},
+		{"xyz", "penelope", false},
+		{"epp", "penelope", false},
+	}
+
+	for _, tt := range tests {
+		if _, ok := fuzzyScore(tt.query, tt.target); ok != tt.matches {
+			t.Errorf("fuzzyScore(%q, %q) matched = %v, want %v", tt.query, tt.target, ok, tt.matches)
+		}
+	}
+
+	tight, _ := fuzzyScore("pen", "penelope")
+	loose, _ := fuzzyScore("pen", "peter enjoys nothing")
+	if tight >= loose {
+		t.Errorf("expected tight match to score better: tight=%d loose=%d", tight, loose)
+	}
+}
+
+func TestPickWithPrompt(t *testing.T) {
+	entries := []switchEntry{
+		{session: "agent-repo-abc-penelope", agent: "penelope", status: "running"},
+		{session: "agent-repo-abc-gregory", agent: "gregory", status: "ready"},
+		{session: "agent-repo-abc-george", agent: "george", status: "idle"},
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		input string
+		want  string
+	}{
+		{"select by number", "", "2\n", "gregory"},
+		{"unique query selects directly", "pen", "", "penelope"},
+		{"filter then number", "", "ge\n1\n", "george"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			got, err := pickWithPrompt(strings.NewReader(tt.input), &out, entries, tt.query)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	var out bytes.Buffer
+	if _, err := pickWithPrompt(strings.NewReader("\n"), &out, entries, ""); err == nil {
+		t.Error("expected error when selection is cancelled")
+	}
+}
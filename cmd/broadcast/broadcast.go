@@ -4,9 +4,9 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"os/exec"
 	"strings"
 
+	"github.com/devflowinc/uzi/pkg/runner"
 	"github.com/devflowinc/uzi/pkg/state"
 
 	"github.com/charmbracelet/log"
@@ -51,17 +51,18 @@ func executeBroadcast(ctx context.Context, args []string) error {
 
 	fmt.Printf("Broadcasting message to %d agent sessions:\n", len(activeSessions))
 
+	agentRunner := runner.Default()
+
 	// Send message to each session
 	for _, session := range activeSessions {
 		fmt.Printf("\n=== %s ===\n", session)
 
 		// Send the message to the agent window
-		sendKeysCmd := exec.Command("tmux", "send-keys", "-t", session+":agent", message, "Enter")
-		if err := sendKeysCmd.Run(); err != nil {
+		if err := agentRunner.SendKeys(ctx, session, "agent", message, "Enter"); err != nil {
 			log.Error("Failed to send message to session", "session", session, "error", err)
 			continue
 		}
-		exec.Command("tmux", "send-keys", "-t", session+":agent", "Enter").Run()
+		agentRunner.SendKeys(ctx, session, "agent", "Enter")
 	}
 
 	return nil
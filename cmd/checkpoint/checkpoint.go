@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/devflowinc/uzi/pkg/state"
+	"github.com/devflowinc/uzi/pkg/status"
 
 	"github.com/charmbracelet/log"
 	"github.com/peterbourgon/ff/v3/ffcli"
@@ -17,22 +18,36 @@ import (
 
 var (
 	fs            = flag.NewFlagSet("uzi checkpoint", flag.ExitOnError)
+	editMessage   = fs.Bool("e", false, "edit the commit message before committing")
 	CmdCheckpoint = &ffcli.Command{
 		Name:       "checkpoint",
-		ShortUsage: "uzi checkpoint <agent-name> <commit-message>",
+		ShortUsage: "uzi checkpoint [-e] <agent-name> [<commit-message>]",
 		ShortHelp:  "Rebase changes from an agent worktree into the current worktree and commit",
-		FlagSet:    fs,
-		Exec:       executeCheckpoint,
+		LongHelp: `
+Without a commit message, the message is made from the completion report the
+agent wrote to its .uzi-task-completed file: the first line of the summary
+becomes the subject, and the rest of the summary, the test command and
+result, open questions and follow-ups the body. -e opens the message in the
+git editor before committing.
+
+The marker file is added to the repository's info/exclude so it is never
+committed.
+`,
+		FlagSet: fs,
+		Exec:    executeCheckpoint,
 	}
 )
 
 func executeCheckpoint(ctx context.Context, args []string) error {
-	if len(args) < 2 {
-		return fmt.Errorf("agent name and commit message arguments are required")
+	if len(args) < 1 {
+		return fmt.Errorf("agent name argument is required")
 	}
 
 	agentName := args[0]
-	commitMessage := args[1]
+	var commitMessage string
+	if len(args) > 1 {
+		commitMessage = args[1]
+	}
 	log.Debug("Checkpointing changes from agent", "agent", agentName)
 
 	// Get state manager to read from config
@@ -107,6 +122,32 @@ func executeCheckpoint(ctx context.Context, args []string) error {
 		return fmt.Errorf("agent branch does not exist: %s", agentBranchName)
 	}
 
+	// Fill in the commit message from the agent's completion report
+	if commitMessage == "" {
+		report, err := status.ReadReport(sessionState.WorktreePath)
+		if err != nil {
+			log.Warn("Failed to read completion report", "error", err)
+		}
+		if report == nil {
+			report = sessionState.Report
+		}
+		if report == nil {
+			return fmt.Errorf("commit message argument is required: agent %s has not written a completion report", agentName)
+		}
+		commitMessage = status.CommitMessage(report)
+	}
+
+	// Keep the marker file out of the commit, even if an earlier commit
+	// added it
+	if err := status.ExcludeMarkerFile(sessionState.WorktreePath); err != nil {
+		log.Warn("Failed to exclude the marker file", "error", err)
+	}
+	untrackCmd := exec.CommandContext(ctx, "git", "rm", "--cached", "--quiet", "--ignore-unmatch", status.MarkerFile)
+	untrackCmd.Dir = sessionState.WorktreePath
+	if err := untrackCmd.Run(); err != nil {
+		log.Warn("Failed to unstage the marker file", "error", err)
+	}
+
 	// Stage all changes and commit on the agent branch
 	addCmd := exec.CommandContext(ctx, "git", "add", ".")
 	addCmd.Dir = sessionState.WorktreePath
@@ -114,8 +155,13 @@ func executeCheckpoint(ctx context.Context, args []string) error {
 		return fmt.Errorf("error staging changes: %v", err)
 	}
 
-	commitCmd := exec.CommandContext(ctx, "git", "commit", "-am", commitMessage)
+	commitArgs := []string{"commit", "-am", commitMessage}
+	if *editMessage {
+		commitArgs = append(commitArgs, "-e")
+	}
+	commitCmd := exec.CommandContext(ctx, "git", commitArgs...)
 	commitCmd.Dir = sessionState.WorktreePath
+	commitCmd.Stdin = os.Stdin
 	commitCmd.Stdout = os.Stdout
 	commitCmd.Stderr = os.Stderr
 	if err := commitCmd.Run(); err != nil {
@@ -0,0 +1,322 @@
+package grid
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/devflowinc/uzi/cmd/attach"
+	"github.com/devflowinc/uzi/pkg/runner"
+	"github.com/devflowinc/uzi/pkg/state"
+	"github.com/devflowinc/uzi/pkg/status"
+	"github.com/devflowinc/uzi/pkg/tmux"
+
+	"github.com/charmbracelet/log"
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+// GridSession is the tmux session that hosts the overview window
+const GridSession = "uzi-grid"
+
+var (
+	fs          = flag.NewFlagSet("uzi grid", flag.ExitOnError)
+	labelFilter = fs.String("label", "", "only show agents with this label")
+	statusList  = fs.String("status", "", "only show agents with these statuses (e.g., 'running,error')")
+	interval    = fs.Duration("interval", time.Second, "refresh interval of each tile")
+	noAttach    = fs.Bool("no-attach", false, "build the grid without attaching to it")
+	tileSession = fs.String("tile", "", "internal: render a live view of the given session")
+	CmdGrid     = &ffcli.Command{
+		Name:       "grid",
+		ShortUsage: "uzi grid [-label LABEL] [-status STATUS,...] [-interval 1s]",
+		ShortHelp:  "Show a tiled, read-only overview of every active agent",
+		LongHelp: `
+The grid command builds a single tmux window with one tile per active agent.
+Each tile mirrors the agent's "agent" pane and is titled with the agent name
+and status. Tiles are read-only; select a tile and press Enter to jump to
+that agent's session.
+`,
+		FlagSet: fs,
+		Exec:    executeGrid,
+	}
+)
+
+func parseStatuses(s string) map[string]bool {
+	statuses := make(map[string]bool)
+	for _, st := range strings.Split(s, ",") {
+		if st = strings.TrimSpace(st); st != "" {
+			statuses[st] = true
+		}
+	}
+	return statuses
+}
+
+// WithoutGrid returns sessions minus the grid session, which only mirrors
+// agents and must not count as one
+func WithoutGrid(sessions []string) []string {
+	var agents []string
+	for _, session := range sessions {
+		if session != GridSession {
+			agents = append(agents, session)
+		}
+	}
+	return agents
+}
+
+// selectSessions returns the active sessions that match the label and
+// status filters, sorted by agent name
+func selectSessions(sm *state.StateManager, label string, statuses map[string]bool) ([]string, error) {
+	activeSessions, err := sm.GetActiveSessionsForRepo()
+	if err != nil {
+		return nil, fmt.Errorf("error getting active sessions: %w", err)
+	}
+
+	statusManager := status.NewStatusManager(status.DefaultTmuxClient(), status.NewStateAdapter(sm))
+	hasLabel := func(session string) bool {
+		agentState, err := sm.GetWorktreeInfo(session)
+		return err == nil && agentState.HasLabel(label)
+	}
+	return filterSessions(activeSessions, label, statuses, hasLabel, statusManager.GetStatus), nil
+}
+
+// filterSessions keeps the sessions carrying label, when set, whose status
+// is one of statuses, when any, sorted by agent name
+func filterSessions(sessions []string, label string, statuses map[string]bool,
+	hasLabel func(session string) bool, statusOf func(session string) (string, error)) []string {
+	var selected []string
+	for _, session := range WithoutGrid(sessions) {
+		if label != "" && !hasLabel(session) {
+			continue
+		}
+		if len(statuses) > 0 {
+			st, err := statusOf(session)
+			if err != nil || !statuses[st] {
+				continue
+			}
+		}
+		selected = append(selected, session)
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		return state.AgentNameFromSession(selected[i]) < state.AgentNameFromSession(selected[j])
+	})
+	return selected
+}
+
+func tileCommand(executable, session string) string {
+	// Pin the socket and runner so the tile reads the same agents regardless of its cwd
+	return fmt.Sprintf("%s=%s %s=%s exec %s grid -tile %s -interval %s",
+		tmux.SocketEnv, tmux.ShellQuote(tmux.Socket()),
+		runner.RunnerEnv, tmux.ShellQuote(runner.Default().Name()),
+		tmux.ShellQuote(executable), tmux.ShellQuote(session), interval.String())
+}
+
+// tileCommands returns the tmux commands that create one tile per session:
+// the first starts the grid session and the others split its window
+func tileCommands(executable string, sessions []string) [][]string {
+	var commands [][]string
+	for i, session := range sessions {
+		if i == 0 {
+			commands = append(commands, []string{"new-session", "-d", "-s", GridSession, "-n", "grid",
+				"-x", "240", "-y", "60", tileCommand(executable, session)})
+		} else {
+			commands = append(commands, []string{"split-window", "-t", GridSession + ":grid", tileCommand(executable, session)})
+		}
+	}
+	return commands
+}
+
+// buildGrid replaces the grid session with a fresh window holding one tile
+// per session
+func buildGrid(ctx context.Context, sessions []string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("could not resolve uzi executable: %w", err)
+	}
+
+	if tmux.HasSession(GridSession) {
+		if err := tmux.CommandContext(ctx, "kill-session", "-t", GridSession).Run(); err != nil {
+			return fmt.Errorf("failed to replace grid session: %w", err)
+		}
+	}
+
+	target := GridSession + ":grid"
+	for i, args := range tileCommands(executable, sessions) {
+		if output, err := tmux.CommandContext(ctx, args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to create tile for %s: %s", sessions[i], strings.TrimSpace(string(output)))
+		}
+
+		// Re-tile after every split so there is always room for the next one
+		if err := tmux.CommandContext(ctx, "select-layout", "-t", target, "tiled").Run(); err != nil {
+			log.Debug("Error applying tiled layout", "error", err)
+		}
+	}
+
+	options := [][]string{
+		{"set-option", "-w", "-t", target, "pane-border-status", "top"},
+		{"set-option", "-w", "-t", target, "pane-border-format", " #{pane_title} "},
+		{"set-option", "-t", GridSession, "mouse", "on"},
+	}
+	for _, option := range options {
+		if err := tmux.CommandContext(ctx, option...).Run(); err != nil {
+			log.Debug("Error setting grid option", "option", option, "error", err)
+		}
+	}
+
+	return nil
+}
+
+func executeGrid(ctx context.Context, args []string) error {
+	if *tileSession != "" {
+		return runTile(ctx, *tileSession)
+	}
+
+	sm := state.NewStateManager()
+	if sm == nil {
+		return fmt.Errorf("could not initialize state manager")
+	}
+
+	sessions, err := selectSessions(sm, *labelFilter, parseStatuses(*statusList))
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		return fmt.Errorf("no matching agent sessions found")
+	}
+
+	if err := buildGrid(ctx, sessions); err != nil {
+		return err
+	}
+
+	if *noAttach {
+		fmt.Printf("Grid with %d agent(s) ready in tmux session %s\n", len(sessions), GridSession)
+		return nil
+	}
+	return attach.AttachSession(ctx, GridSession)
+}
+
+// paneSize returns the size of the pane the tile runs in
+func paneSize() (int, int) {
+	pane := os.Getenv("TMUX_PANE")
+	if pane == "" {
+		return 80, 24
+	}
+	output, err := tmux.Command("display-message", "-p", "-t", pane, "#{pane_width} #{pane_height}").Output()
+	if err != nil {
+		return 80, 24
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) != 2 {
+		return 80, 24
+	}
+	width, _ := strconv.Atoi(fields[0])
+	height, _ := strconv.Atoi(fields[1])
+	if width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// fitContent keeps the last height lines of content, ignoring trailing blank
+// lines, and truncates each line to width runes
+func fitContent(content string, width, height int) []string {
+	lines := strings.Split(strings.TrimRight(content, "\n "), "\n")
+	if len(lines) > height {
+		lines = lines[len(lines)-height:]
+	}
+	for i, line := range lines {
+		line = strings.TrimRight(line, " ")
+		if utf8.RuneCountInString(line) > width {
+			line = string([]rune(line)[:width])
+		}
+		lines[i] = line
+	}
+	return lines
+}
+
+func setTileTitle(title string) {
+	pane := os.Getenv("TMUX_PANE")
+	if pane == "" {
+		return
+	}
+	tmux.Command("select-pane", "-t", pane, "-T", title).Run()
+}
+
+// runTile mirrors the agent pane of session until the context ends or the
+// session goes away. Pressing Enter in the tile switches to the session.
+func runTile(ctx context.Context, session string) error {
+	agentRunner := runner.Default()
+
+	// Keep typed characters from corrupting the mirror
+	stty := exec.Command("stty", "-echo")
+	stty.Stdin = os.Stdin
+	stty.Run()
+
+	go func() {
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			if _, err := reader.ReadString('\n'); err != nil {
+				return
+			}
+			// Agents under the PTY supervisor have no tmux session to jump to
+			if agentRunner.Name() == runner.RunnerTmux {
+				tmux.Command("switch-client", "-t", session).Run()
+			}
+		}
+	}()
+
+	agentName := state.AgentNameFromSession(session)
+	statusManager := status.NewStatusManager(status.DefaultTmuxClient(), status.NewStateAdapter(state.NewStateManager()))
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	fmt.Print("\033[?25l")
+	defer fmt.Print("\033[?25h")
+
+	for {
+		if !agentRunner.HasSession(session) {
+			setTileTitle(agentName + " [gone]")
+			fmt.Print("\033[H\033[J")
+			fmt.Printf("%s: session has ended\n", agentName)
+			<-ctx.Done()
+			return nil
+		}
+
+		st, err := statusManager.GetStatus(session)
+		if err != nil {
+			st = "unknown"
+		}
+		setTileTitle(fmt.Sprintf("%s [%s]", agentName, st))
+
+		content, err := agentRunner.ReadScreen(ctx, session, "agent")
+		if err == nil {
+			width, height := paneSize()
+			var buf bytes.Buffer
+			buf.WriteString("\033[H")
+			for i, line := range fitContent(content, width, height) {
+				if i > 0 {
+					buf.WriteString("\n")
+				}
+				buf.WriteString(line)
+				buf.WriteString("\033[K")
+			}
+			buf.WriteString("\033[J")
+			os.Stdout.Write(buf.Bytes())
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
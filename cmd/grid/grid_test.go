@@ -0,0 +1,142 @@
+package grid
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/devflowinc/uzi/pkg/runner"
+	"github.com/devflowinc/uzi/pkg/tmux"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv(tmux.SocketEnv, "uzi-test")
+	os.Setenv(runner.RunnerEnv, runner.RunnerTmux)
+	os.Exit(m.Run())
+}
+
+func TestTileCommand(t *testing.T) {
+	got := tileCommand("/opt/uzi's bin/uzi", "agent-repo-abc-penelope")
+	want := `UZI_TMUX_SOCKET='uzi-test' UZI_RUNNER='tmux' exec '/opt/uzi'\''s bin/uzi' grid -tile 'agent-repo-abc-penelope' -interval 1s`
+	if got != want {
+		t.Errorf("tileCommand() = %q, want %q", got, want)
+	}
+}
+
+func TestTileCommands(t *testing.T) {
+	sessions := []string{"agent-repo-abc-gregory", "agent-repo-abc-penelope", "agent-repo-abc-sam"}
+	commands := tileCommands("/usr/bin/uzi", sessions)
+	if len(commands) != len(sessions) {
+		t.Fatalf("tileCommands() returned %d commands, want %d", len(commands), len(sessions))
+	}
+
+	want := []string{"new-session", "-d", "-s", GridSession, "-n", "grid", "-x", "240", "-y", "60",
+		tileCommand("/usr/bin/uzi", sessions[0])}
+	if !reflect.DeepEqual(commands[0], want) {
+		t.Errorf("first command = %q, want %q", commands[0], want)
+	}
+	for i, session := range sessions[1:] {
+		want := []string{"split-window", "-t", GridSession + ":grid", tileCommand("/usr/bin/uzi", session)}
+		if !reflect.DeepEqual(commands[i+1], want) {
+			t.Errorf("command %d = %q, want %q", i+1, commands[i+1], want)
+		}
+	}
+
+	if commands := tileCommands("/usr/bin/uzi", nil); len(commands) != 0 {
+		t.Errorf("tileCommands() without sessions = %q, want none", commands)
+	}
+}
+
+func TestFilterSessions(t *testing.T) {
+	sessions := []string{
+		"agent-repo-abc-sam",
+		"agent-repo-abc-gregory",
+		GridSession,
+		"agent-repo-abc-penelope",
+		"agent-repo-abc-mia",
+	}
+	labels := map[string]bool{"agent-repo-abc-sam": true, "agent-repo-abc-penelope": true}
+	statuses := map[string]string{
+		"agent-repo-abc-sam":      "running",
+		"agent-repo-abc-gregory":  "error",
+		"agent-repo-abc-penelope": "ready",
+	}
+	hasLabel := func(session string) bool { return labels[session] }
+	statusOf := func(session string) (string, error) {
+		if st, ok := statuses[session]; ok {
+			return st, nil
+		}
+		return "", fmt.Errorf("no status for %s", session)
+	}
+
+	tests := []struct {
+		name     string
+		label    string
+		statuses map[string]bool
+		want     []string
+	}{
+		{
+			name: "no filters sorts by agent name",
+			want: []string{"agent-repo-abc-gregory", "agent-repo-abc-mia", "agent-repo-abc-penelope", "agent-repo-abc-sam"},
+		},
+		{
+			name:  "label",
+			label: "frontend",
+			want:  []string{"agent-repo-abc-penelope", "agent-repo-abc-sam"},
+		},
+		{
+			name:     "statuses skip unknown status",
+			statuses: map[string]bool{"running": true, "error": true},
+			want:     []string{"agent-repo-abc-gregory", "agent-repo-abc-sam"},
+		},
+		{
+			name:     "label and status",
+			label:    "frontend",
+			statuses: map[string]bool{"ready": true},
+			want:     []string{"agent-repo-abc-penelope"},
+		},
+		{
+			name:     "nothing matches",
+			statuses: map[string]bool{"waiting": true},
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filterSessions(sessions, tt.label, tt.statuses, hasLabel, statusOf)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterSessions() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithoutGrid(t *testing.T) {
+	if got := WithoutGrid([]string{GridSession}); len(got) != 0 {
+		t.Errorf("WithoutGrid() = %q, want no sessions", got)
+	}
+	got := WithoutGrid([]string{"agent-repo-abc-sam", GridSession, "uzi-grid-2"})
+	want := []string{"agent-repo-abc-sam", "uzi-grid-2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WithoutGrid() = %q, want %q", got, want)
+	}
+}
+
+func TestParseStatuses(t *testing.T) {
+	got := parseStatuses(" running, error,,")
+	want := map[string]bool{"running": true, "error": true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseStatuses() = %v, want %v", got, want)
+	}
+}
+
+func TestFitContent(t *testing.T) {
+	content := "one\ntwo   \nthree is long\nfour\n\n  \n"
+	got := fitContent(content, 5, 3)
+	want := []string{"two", "three", "four"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fitContent() = %q, want %q", got, want)
+	}
+}
@@ -9,7 +9,10 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/devflowinc/uzi/cmd/grid"
+	"github.com/devflowinc/uzi/pkg/runner"
 	"github.com/devflowinc/uzi/pkg/state"
+	"github.com/devflowinc/uzi/pkg/tmux"
 
 	"github.com/charmbracelet/log"
 	"github.com/peterbourgon/ff/v3/ffcli"
@@ -30,15 +33,13 @@ var (
 func killSession(ctx context.Context, sessionName, agentName string, sm *state.StateManager) error {
 	log.Debug("Deleting tmux session and git worktree", "session", sessionName, "agent", agentName)
 
-	// Kill tmux session if it exists
-	checkSession := exec.CommandContext(ctx, "tmux", "has-session", "-t", sessionName)
-	if err := checkSession.Run(); err == nil {
-		// Session exists, kill it
-		killCmd := exec.CommandContext(ctx, "tmux", "kill-session", "-t", sessionName)
-		if err := killCmd.Run(); err != nil {
-			log.Error("Error killing tmux session", "session", sessionName, "error", err)
+	// Stop the agent session if it is still running
+	agentRunner := runner.Default()
+	if agentRunner.HasSession(sessionName) {
+		if err := agentRunner.Stop(ctx, sessionName); err != nil {
+			log.Error("Error stopping agent session", "session", sessionName, "error", err)
 		} else {
-			log.Debug("Killed tmux session", "session", sessionName)
+			log.Debug("Stopped agent session", "session", sessionName)
 		}
 	}
 
@@ -141,6 +142,20 @@ func killAll(ctx context.Context, sm *state.StateManager) error {
 	}
 
 	fmt.Printf("Successfully deleted %d agent(s)\n", killedCount)
+
+	// Tear down the tmux server or PTY supervisor once no uzi sessions
+	// remain. The grid only mirrors agents, so it goes with them.
+	agentRunner := runner.Default()
+	if remaining, err := agentRunner.ListSessions(); err == nil && len(grid.WithoutGrid(remaining)) == 0 {
+		if tmux.HasSession(grid.GridSession) {
+			if err := tmux.CommandContext(ctx, "kill-session", "-t", grid.GridSession).Run(); err != nil {
+				log.Debug("Error killing grid session", "error", err)
+			}
+		}
+		if err := agentRunner.Shutdown(ctx); err != nil {
+			log.Debug("Error shutting down runner", "runner", agentRunner.Name(), "error", err)
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,184 @@
+package logs
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/devflowinc/uzi/pkg/state"
+	"github.com/devflowinc/uzi/pkg/transcript"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+var (
+	fs          = flag.NewFlagSet("uzi logs", flag.ExitOnError)
+	follow      = fs.Bool("f", false, "follow the transcript as it grows")
+	windowName  = fs.String("window", "agent", "window to read (agent or dev)")
+	grepPattern = fs.String("grep", "", "only show lines matching this regular expression")
+	pipePath    = fs.String("pipe", "", "internal: write stdin to the given transcript file")
+	CmdLogs     = &ffcli.Command{
+		Name:       "logs",
+		ShortUsage: "uzi logs <agent-name> [-f] [-window dev] [-grep pattern]",
+		ShortHelp:  "Show the persistent transcript of an agent",
+		LongHelp: `
+Every agent window is streamed to ~/.local/share/uzi/logs/<session>/<window>.log
+with ANSI escape sequences removed. Transcripts are rotated as they grow and
+are kept after "uzi kill", so they can be read after the agent is gone.
+`,
+		FlagSet: fs,
+		Exec:    executeLogs,
+	}
+)
+
+// tmuxWindow maps the -window flag to the tmux window name
+func tmuxWindow(name string) string {
+	if name == "dev" {
+		return "uzi-dev"
+	}
+	return name
+}
+
+func resolveSession(agentName string) (string, error) {
+	if sm := state.NewStateManager(); sm != nil {
+		if session, err := sm.FindSessionForAgent(agentName); err == nil {
+			return session, nil
+		}
+	}
+	return transcript.FindSession(agentName)
+}
+
+// copyLines writes the lines of r that match re to w
+func copyLines(w io.Writer, r io.Reader, re *regexp.Regexp) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if re != nil && !re.MatchString(line) {
+			continue
+		}
+		fmt.Fprintln(w, line)
+	}
+	return scanner.Err()
+}
+
+// followFile polls path for new data until ctx is cancelled. A file that
+// shrinks or is replaced has been rotated and is read again from the start.
+func followFile(ctx context.Context, w io.Writer, path string, offset int64, re *regexp.Regexp) error {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	var partial string
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.Size() < offset {
+			offset = 0
+		}
+		if info.Size() == offset {
+			continue
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		file.Seek(offset, io.SeekStart)
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			continue
+		}
+		offset += int64(len(data))
+
+		// Hold back an incomplete trailing line until it is finished
+		chunk := partial + string(data)
+		lastNewline := strings.LastIndexByte(chunk, '\n')
+		if lastNewline < 0 {
+			partial = chunk
+			continue
+		}
+		partial = chunk[lastNewline+1:]
+
+		if err := copyLines(w, strings.NewReader(chunk[:lastNewline+1]), re); err != nil {
+			return err
+		}
+	}
+}
+
+func executeLogs(ctx context.Context, args []string) error {
+	if *pipePath != "" {
+		return transcript.Pipe(os.Stdin, *pipePath)
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("agent name argument is required")
+	}
+
+	// Allow flags after the agent name, e.g. "uzi logs penelope -f"
+	agentName := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	var re *regexp.Regexp
+	if *grepPattern != "" {
+		var err error
+		re, err = regexp.Compile(*grepPattern)
+		if err != nil {
+			return fmt.Errorf("invalid grep pattern: %w", err)
+		}
+	}
+
+	sessionName, err := resolveSession(agentName)
+	if err != nil {
+		return err
+	}
+
+	path, err := transcript.Path(sessionName, tmuxWindow(*windowName))
+	if err != nil {
+		return err
+	}
+
+	files := transcript.Files(path)
+	if len(files) == 0 && !*follow {
+		return fmt.Errorf("no %s transcript found for agent: %s", *windowName, agentName)
+	}
+
+	var offset int64
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		err = copyLines(os.Stdout, f, re)
+		if file == path {
+			if info, statErr := f.Stat(); statErr == nil {
+				offset = info.Size()
+			}
+		}
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if !*follow {
+		return nil
+	}
+	return followFile(ctx, os.Stdout, path, offset, re)
+}
@@ -0,0 +1,206 @@
+package ls
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/devflowinc/uzi/pkg/listing"
+	"github.com/devflowinc/uzi/pkg/status"
+)
+
+// column is one field that can be selected with -columns
+type column struct {
+	header string
+	value  func(r listing.Record) string
+}
+
+// columns maps the names accepted by -columns to their rendering
+var columns = map[string]column{
+	"agent":   {"AGENT", func(r listing.Record) string { return r.Name }},
+	"session": {"SESSION", func(r listing.Record) string { return r.Session }},
+	"model":   {"MODEL", func(r listing.Record) string { return r.Model }},
+	"status":  {"STATUS", func(r listing.Record) string { return formatStatus(r.Status) }},
+	"time":    {"TIME", formatStatusTime},
+	"stuck": {"STUCK", func(r listing.Record) string {
+		if r.Stuck {
+			return "yes"
+		}
+		return "-"
+	}},
+	"diff": {"DIFF", func(r listing.Record) string {
+		return fmt.Sprintf("\033[32m+%d\033[0m/\033[31m-%d\033[0m", r.Insertions, r.Deletions)
+	}},
+	"ahead":  {"AHEAD", func(r listing.Record) string { return fmt.Sprint(r.Ahead) }},
+	"behind": {"BEHIND", func(r listing.Record) string { return fmt.Sprint(r.Behind) }},
+	"conflict": {"CONFLICT", func(r listing.Record) string {
+		if r.Conflicts {
+			return "\033[31myes\033[0m"
+		}
+		if r.ConflictsUnknown {
+			return "?"
+		}
+		return "-"
+	}},
+	"drift": {"DRIFT", func(r listing.Record) string {
+		return formatDrift(r.Ahead, r.Behind, r.Conflicts, r.ConflictsUnknown)
+	}},
+	"files":    {"FILES", func(r listing.Record) string { return fmt.Sprint(len(r.Files)) }},
+	"addr":     {"ADDR", func(r listing.Record) string { return r.URL }},
+	"worktree": {"WORKTREE", func(r listing.Record) string { return orDash(r.Worktree) }},
+	"branch":   {"BRANCH", func(r listing.Record) string { return orDash(r.Branch) }},
+	"base":     {"BASE", func(r listing.Record) string { return orDash(r.BaseRef) }},
+	"labels":   {"LABELS", func(r listing.Record) string { return orDash(strings.Join(r.Labels, ",")) }},
+	"created":  {"CREATED", func(r listing.Record) string { return formatTime(r.CreatedAt) }},
+	"updated":  {"UPDATED", func(r listing.Record) string { return formatTime(r.UpdatedAt) }},
+	"repo":     {"REPO", func(r listing.Record) string { return orDash(r.Repo) }},
+	"prompt":   {"PROMPT", func(r listing.Record) string { return r.Prompt }},
+	"question": {"QUESTION", formatQuestion},
+	"cpu":      {"CPU", func(r listing.Record) string { return formatCPU(r, r.CPUPercent) }},
+	"mem":      {"MEM", func(r listing.Record) string { return formatMemory(r, r.RSSBytes) }},
+	"dev-cpu":  {"DEV CPU", func(r listing.Record) string { return formatCPU(r, r.DevCPU) }},
+	"dev-mem":  {"DEV MEM", func(r listing.Record) string { return formatMemory(r, r.DevRSSBytes) }},
+}
+
+// columnNames lists the valid -columns entries in a stable order for help
+// and error messages
+var columnNames = []string{
+	"agent", "session", "model", "status", "time", "stuck", "diff", "ahead",
+	"behind", "conflict", "drift", "files", "addr", "worktree", "branch",
+	"base", "labels", "created", "updated", "repo", "prompt", "question", "cpu",
+	"mem", "dev-cpu", "dev-mem",
+}
+
+// formatDrift renders commits ahead of and behind the base, flagging a
+// merge that would conflict in red and "conflict?" when git could not check
+func formatDrift(ahead, behind int, conflicts, unknown bool) string {
+	drift := fmt.Sprintf("↑%d ↓%d", ahead, behind)
+	if conflicts {
+		drift += " \033[31mconflict\033[0m"
+	} else if unknown {
+		drift += " conflict?"
+	}
+	return drift
+}
+
+// formatStatusTime renders how long the agent has been in its status, e.g.
+// "running for 12m" or "ready since 3m"
+func formatStatusTime(r listing.Record) string {
+	if r.StatusSince == nil {
+		return "-"
+	}
+	return status.DescribeDuration(r.Status, time.Duration(r.StatusSeconds)*time.Second)
+}
+
+// formatQuestion renders the pending question of a waiting agent with its
+// options, e.g. "Do you want to proceed? [1. Yes / 2. No]"
+func formatQuestion(r listing.Record) string {
+	if r.Question == "" {
+		return "-"
+	}
+	question := status.Question{Text: r.Question}
+	for _, option := range r.Options {
+		question.Options = append(question.Options, status.Option{Key: option.Key, Label: option.Label})
+	}
+	// Numbered options are not part of the question text, y/n ones are
+	question.Menu = len(r.Options) > 0 && r.Options[0].Key >= "0" && r.Options[0].Key <= "9"
+	return question.String()
+}
+
+// formatElapsed renders the time since t, e.g. "12m" or "3h5m"
+func formatElapsed(t time.Time) string {
+	return status.FormatDuration(time.Since(t))
+}
+
+// formatCPU renders CPU use as a percentage of one core; dead agents have
+// no processes to measure
+func formatCPU(r listing.Record, percent float64) string {
+	if r.Status == status.StatusDead {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", percent)
+}
+
+// formatMemory renders resident memory with a binary unit
+func formatMemory(r listing.Record, bytes uint64) string {
+	if r.Status == status.StatusDead {
+		return "-"
+	}
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%dB", bytes)
+	}
+	value, suffix := float64(bytes)/unit, "K"
+	for _, next := range []string{"M", "G", "T"} {
+		if value < unit {
+			break
+		}
+		value, suffix = value/unit, next
+	}
+	return fmt.Sprintf("%.1f%s", value, suffix)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// parseColumns validates a comma-separated -columns value
+func parseColumns(value string) ([]string, error) {
+	names := splitList(value)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("-columns needs at least one of: %s", strings.Join(columnNames, ", "))
+	}
+	for _, name := range names {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("unknown column %q (want %s)", name, strings.Join(columnNames, ", "))
+		}
+	}
+	return names, nil
+}
+
+// parseFormat compiles a -format template. Like docker's --format, the
+// escapes \t and \n may be written literally on the command line.
+func parseFormat(format string) (*template.Template, error) {
+	format = strings.NewReplacer(`\t`, "\t", `\n`, "\n").Replace(format)
+	tmpl, err := template.New("format").Funcs(template.FuncMap{
+		"join": strings.Join,
+	}).Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -format template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// writeColumns prints the selected columns of every record as a table
+func writeColumns(w io.Writer, records []listing.Record, names []string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	cells := make([]string, len(names))
+	for i, name := range names {
+		cells[i] = columns[name].header
+	}
+	fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	for _, record := range records {
+		for i, name := range names {
+			cells[i] = columns[name].value(record)
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+// writeTemplate executes tmpl once per record, each on its own line
+func writeTemplate(w io.Writer, records []listing.Record, tmpl *template.Template) error {
+	for _, record := range records {
+		if err := tmpl.Execute(w, record); err != nil {
+			return fmt.Errorf("error executing -format template: %w", err)
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package ls
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/devflowinc/uzi/pkg/collector"
+	"github.com/devflowinc/uzi/pkg/state"
+
+	"github.com/charmbracelet/log"
+)
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// agentFilter builds the filter selected by the command-line flags
+func agentFilter() collector.Filter {
+	return collector.Filter{
+		Statuses:    splitList(*statusFilter),
+		Model:       *modelFilter,
+		Label:       *labelFilter,
+		StuckOnly:   *stuckOnly,
+		ChangedOnly: *changedOnly,
+	}
+}
+
+// filterAndSort applies the filter and sort flags to collected agents
+func filterAndSort(agents []collector.Agent) ([]collector.Agent, error) {
+	agents = agentFilter().Apply(agents)
+	if err := collector.Sort(agents, *sortKey, *reverseSort); err != nil {
+		return nil, err
+	}
+	return agents, nil
+}
+
+// collectAgents inspects the given sessions and applies the filter and sort
+// flags to the result
+func collectAgents(stateManager *state.StateManager, activeSessions []string) ([]collector.Agent, error) {
+	agents, err := agentCollector.Collect(stateManager, activeSessions)
+	if err != nil {
+		return nil, err
+	}
+	return filterAndSort(agents)
+}
+
+// loadAgents inspects the agents ls should show before filtering: the live
+// sessions of the current repository, or with -a every agent in the state
+// file, including those whose session has died
+func loadAgents(stateManager *state.StateManager) ([]collector.Agent, error) {
+	var agents []collector.Agent
+	if *allSessions {
+		all, err := agentCollector.CollectAll(stateManager)
+		if err != nil {
+			return nil, err
+		}
+		agents = all
+	} else {
+		activeSessions, err := stateManager.GetActiveSessionsForRepo()
+		if err != nil {
+			return nil, fmt.Errorf("error getting active sessions: %w", err)
+		}
+		if len(activeSessions) == 0 {
+			return nil, nil
+		}
+		if agents, err = agentCollector.Collect(stateManager, activeSessions); err != nil {
+			return nil, err
+		}
+	}
+
+	if wantsUsage() {
+		// Usage stays empty where /proc cannot be read
+		if err := agentCollector.MeasureUsage(context.Background(), agents); err != nil {
+			log.Debug("Could not measure agent resource usage", "error", err)
+		}
+	}
+	return agents, nil
+}
+
+// usageColumns are the -columns entries that show CPU or memory use
+var usageColumns = map[string]bool{"cpu": true, "mem": true, "dev-cpu": true, "dev-mem": true}
+
+// wantsUsage reports whether the output shows CPU or memory use, which
+// costs a short sampling delay on the first listing
+func wantsUsage() bool {
+	if *outputFormat != "text" {
+		return true
+	}
+	for _, name := range splitList(*columnList) {
+		if usageColumns[name] {
+			return true
+		}
+	}
+	return strings.Contains(*formatFlag, "CPU") || strings.Contains(*formatFlag, "RSS")
+}
+
+// repoGroup is the agents of one repository
+type repoGroup struct {
+	repo   string
+	agents []collector.Agent
+}
+
+// groupByRepo splits agents by repository, keeping their order within each
+// group; groups are ordered by repository URL
+func groupByRepo(agents []collector.Agent) []repoGroup {
+	index := make(map[string]int)
+	var groups []repoGroup
+	for _, agent := range agents {
+		repo := agent.State.GitRepo
+		if repo == "" {
+			repo = "(no remote)"
+		}
+		i, ok := index[repo]
+		if !ok {
+			i = len(groups)
+			index[repo] = i
+			groups = append(groups, repoGroup{repo: repo})
+		}
+		groups[i].agents = append(groups[i].agents, agent)
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].repo < groups[j].repo })
+	return groups
+}
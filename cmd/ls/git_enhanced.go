@@ -1,10 +1,11 @@
 package ls
 
 import (
-	"bytes"
 	"fmt"
 	"os/exec"
 	"strings"
+
+	"github.com/devflowinc/uzi/pkg/gitdiff"
 )
 
 type GitDiffDetails struct {
@@ -43,87 +44,29 @@ func parseGitNameStatus(output string) []GitDiffDetails {
 	return details
 }
 
-func getGitDiffDetails(worktreePath string) ([]GitDiffDetails, error) {
-	// Stage all changes
-	stageCmd := exec.Command("git", "add", "-A", ".")
-	stageCmd.Dir = worktreePath
-	if err := stageCmd.Run(); err != nil {
-		return nil, fmt.Errorf("failed to stage changes: %w", err)
+// toDiffDetails converts a worktree diff to per-file details
+func toDiffDetails(summary *gitdiff.Summary) []GitDiffDetails {
+	details := make([]GitDiffDetails, 0, len(summary.Files))
+	for _, file := range summary.Files {
+		details = append(details, GitDiffDetails{
+			FilePath:   file.Path,
+			Status:     file.Status,
+			Insertions: file.Insertions,
+			Deletions:  file.Deletions,
+			IsBinary:   file.IsBinary,
+		})
 	}
+	return details
+}
 
-	// Get name-status
-	nameStatusCmd := getGitDiffNameStatusCommand()
-	nameStatusCmd.Dir = worktreePath
-
-	var nameStatusOut bytes.Buffer
-	nameStatusCmd.Stdout = &nameStatusOut
-
-	if err := nameStatusCmd.Run(); err != nil {
-		// Reset staged changes before returning error
-		resetCmd := exec.Command("git", "reset", "HEAD")
-		resetCmd.Dir = worktreePath
-		resetCmd.Run()
-		return nil, fmt.Errorf("failed to get name-status: %w", err)
-	}
-
-	// Parse name-status output
-	details := parseGitNameStatus(nameStatusOut.String())
-
-	// Get detailed stats for each file
-	for i := range details {
-		if details[i].Status == "D" {
-			// For deleted files, we need to get stats differently
-			cmd := exec.Command("git", "diff", "--cached", "--numstat", "HEAD", "--", details[i].FilePath)
-			cmd.Dir = worktreePath
-
-			var out bytes.Buffer
-			cmd.Stdout = &out
-
-			if err := cmd.Run(); err == nil {
-				output := strings.TrimSpace(out.String())
-				if output != "" {
-					parts := strings.Fields(output)
-					if len(parts) >= 3 {
-						if parts[0] == "-" && parts[1] == "-" {
-							details[i].IsBinary = true
-						} else {
-							fmt.Sscanf(parts[0], "%d", &details[i].Insertions)
-							fmt.Sscanf(parts[1], "%d", &details[i].Deletions)
-						}
-					}
-				}
-			}
-		} else {
-			// For added/modified files
-			cmd := exec.Command("git", "diff", "--cached", "--numstat", "HEAD", "--", details[i].FilePath)
-			cmd.Dir = worktreePath
-
-			var out bytes.Buffer
-			cmd.Stdout = &out
-
-			if err := cmd.Run(); err == nil {
-				output := strings.TrimSpace(out.String())
-				if output != "" {
-					parts := strings.Fields(output)
-					if len(parts) >= 3 {
-						if parts[0] == "-" && parts[1] == "-" {
-							details[i].IsBinary = true
-						} else {
-							fmt.Sscanf(parts[0], "%d", &details[i].Insertions)
-							fmt.Sscanf(parts[1], "%d", &details[i].Deletions)
-						}
-					}
-				}
-			}
-		}
+// getGitDiffDetails returns the uncommitted changes in the worktree, file by
+// file, without touching the worktree's index
+func getGitDiffDetails(worktreePath string) ([]GitDiffDetails, error) {
+	summary, err := gitdiff.Worktree(worktreePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff worktree: %w", err)
 	}
-
-	// Reset staged changes
-	resetCmd := exec.Command("git", "reset", "HEAD")
-	resetCmd.Dir = worktreePath
-	resetCmd.Run()
-
-	return details, nil
+	return toDiffDetails(summary), nil
 }
 
 func getGitDiffNameStatusCommand() *exec.Cmd {
@@ -1,21 +1,21 @@
 package ls
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
-	"regexp"
-	"sort"
 	"strings"
 	"text/tabwriter"
 	"time"
 
+	"github.com/devflowinc/uzi/pkg/collector"
 	"github.com/devflowinc/uzi/pkg/config"
+	"github.com/devflowinc/uzi/pkg/gitdiff"
+	"github.com/devflowinc/uzi/pkg/listing"
+	"github.com/devflowinc/uzi/pkg/runner"
 	"github.com/devflowinc/uzi/pkg/state"
 	"github.com/devflowinc/uzi/pkg/status"
 
@@ -25,15 +25,74 @@ import (
 var (
 	fs           = flag.NewFlagSet("uzi ls", flag.ExitOnError)
 	configPath   = fs.String("config", config.GetDefaultConfigPath(), "path to config file")
-	allSessions  = fs.Bool("a", false, "show all sessions including inactive")
-	watchMode    = fs.Bool("w", false, "watch mode - refresh output every second")
+	allSessions  = fs.Bool("a", false, "list agents from every repository, including dead sessions")
+	watchMode    = fs.Bool("w", false, "watch mode - redraw whenever agents change")
 	detailedMode = fs.Bool("d", false, "show detailed information")
+	outputFormat = fs.String("o", "text", "output format: text, json, yaml or tsv")
+	statusFilter = fs.String("status", "", "only show agents with these comma-separated statuses, e.g. running,error")
+	modelFilter  = fs.String("model", "", "only show agents running this model")
+	labelFilter  = fs.String("label", "", "only show agents carrying this label")
+	stuckOnly    = fs.Bool("stuck", false, "only show agents that appear stuck")
+	changedOnly  = fs.Bool("changed-only", false, "only show agents with uncommitted changes")
+	interval     = fs.Duration("interval", 5*time.Second, "watch mode: refresh at least this often, even without file or session events")
+	sortKey      = fs.String("sort", collector.SortUpdated, "sort by name, status, diff, created or updated")
+	reverseSort  = fs.Bool("reverse", false, "reverse the sort order")
+	columnList   = fs.String("columns", "", "comma-separated columns to print, e.g. agent,status,diff")
+	formatFlag   = fs.String("format", "", "Go template printed once per agent, e.g. '{{.Name}}\\t{{.Status}}'")
 	CmdLs        = &ffcli.Command{
 		Name:       "ls",
-		ShortUsage: "uzi ls [-a] [-w] [-d]",
+		ShortUsage: "uzi ls [-a] [-w [-interval d]] [-d] [-o text|json|yaml|tsv] [-status s,...] [-sort key [-reverse]] [-columns c,... | -format tmpl]",
 		ShortHelp:  "List active agent sessions",
-		FlagSet:    fs,
-		Exec:       executeLs,
+		LongHelp: `
+With -o json, yaml or tsv, ls prints one record per agent for use by scripts.
+The records follow a versioned schema (currently 1) that is described in the
+README; fields are only added within a version.
+
+-status, -model, -label, -stuck and -changed-only narrow the list and apply to
+every output format. -sort orders agents by name, status (errors first), diff
+(largest first), created or updated (newest first, the default).
+
+-columns picks the table columns from: agent, session, model, status, time,
+stuck, diff, ahead, behind, conflict, drift, files, addr, worktree, branch,
+base, labels, created, updated, repo, prompt, question, cpu, mem, dev-cpu,
+dev-mem.
+
+Agents blocked on a question or a permission prompt are shown as "waiting",
+with the question in place of the prompt; answer them with "uzi answer".
+
+The state of the repository's "uzi auto" watcher is shown below the agents,
+or next to each repository with -a.
+
+-d shows the summary from the completion report of finished agents in place
+of their prompt, and for agents in the error status the error category (crash,
+api or unknown) with the first line of the error.
+
+uzi records every status change it observes in state.json. time shows how
+long an agent has been in its status, e.g. "running for 12m" or "ready since
+3m", and -d shows it next to the status.
+
+cpu and mem add up the processes started from each agent's window, found by
+walking /proc from the window's shell; dev-cpu and dev-mem do the same for the
+uzi-dev window. CPU is averaged over a quarter-second sample, or over the time
+since the last redraw with -w. Usage is measured only when one of these
+columns is shown and for -o json, yaml and tsv.
+
+-w redraws when state.json, an agent worktree, a transcript or a session
+changes (at most twice a second), and at least every -interval.
+
+-a lists every agent in the state file instead of the live sessions of the
+current repository, grouped by repository. Agents whose session has ended
+are shown as "dead"; use -status dead to find them.
+
+DRIFT shows the commits an agent branch is ahead of its base branch (↑) and
+the commits the base gained since the fork (↓), plus "conflict" when
+"git merge-tree" finds that merging the current base tip would conflict, or
+"conflict?" when git is older than 2.38 and cannot check.
+-format instead executes a text/template once per agent with the fields of a
+json record, e.g. '{{.Name}}\t{{.Status}}\t{{join .Labels ","}}'.
+`,
+		FlagSet: fs,
+		Exec:    executeLs,
 	}
 )
 
@@ -53,45 +112,21 @@ func getGitDiffTotals(sessionName string, stateManager *state.StateManager) (int
 		return 0, 0
 	}
 
-	shellCmdString := "git add -A . && git diff --cached --shortstat HEAD && git reset HEAD > /dev/null"
-
-	cmd := exec.Command("sh", "-c", shellCmdString)
-	cmd.Dir = sessionState.WorktreePath
-
-	var out bytes.Buffer
-	var stderr bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
+	summary, err := gitdiff.Worktree(sessionState.WorktreePath)
+	if err != nil {
 		return 0, 0
 	}
+	return summary.Insertions, summary.Deletions
+}
 
-	output := out.String()
-
-	insertions := 0
-	deletions := 0
-
-	insRe := regexp.MustCompile(`(\d+) insertion(?:s)?\(\+\)`)
-	delRe := regexp.MustCompile(`(\d+) deletion(?:s)?\(\-\)`)
-
-	if m := insRe.FindStringSubmatch(output); len(m) > 1 {
-		fmt.Sscanf(m[1], "%d", &insertions)
-	}
-	if m := delRe.FindStringSubmatch(output); len(m) > 1 {
-		fmt.Sscanf(m[1], "%d", &deletions)
-	}
-
-	return insertions, deletions
+// GetDiffTotals returns the number of inserted and deleted lines in the
+// worktree of the given session
+func GetDiffTotals(sessionName string, stateManager *state.StateManager) (int, int) {
+	return getGitDiffTotals(sessionName, stateManager)
 }
 
 func getPaneContent(sessionName string) (string, error) {
-	cmd := exec.Command("tmux", "capture-pane", "-t", sessionName+":agent", "-p")
-	output, err := cmd.Output()
-	if err != nil {
-		return "", err
-	}
-	return string(output), nil
+	return runner.Default().ReadScreen(context.Background(), sessionName, "agent")
 }
 
 func getAgentStatus(sessionName string, hasWorked bool) string {
@@ -134,8 +169,12 @@ func formatStatus(st string) string {
 		return "\033[33mrunning\033[0m" // Orange/Yellow - 実行中
 	case status.StatusMerged:
 		return "\033[36mmerged\033[0m" // Cyan - マージ済み
+	case status.StatusWaiting:
+		return "\033[35mwaiting\033[0m" // Magenta - 入力待ち
 	case status.StatusError:
 		return "\033[31merror\033[0m" // Red - エラー
+	case status.StatusDead:
+		return "\033[90mdead\033[0m" // Gray - セッション終了
 	default:
 		return st
 	}
@@ -197,37 +236,63 @@ type sessionInfo struct {
 	state state.AgentState
 }
 
-func printDetailedSessionsToWriter(w io.Writer, stateManager *state.StateManager, activeSessions []string) error {
-	// Load all states to sort by UpdatedAt
-	states := make(map[string]state.AgentState)
-	if data, err := os.ReadFile(stateManager.GetStatePath()); err == nil {
-		if err := json.Unmarshal(data, &states); err != nil {
-			return fmt.Errorf("error parsing state file: %w", err)
-		}
+// agentCollector is shared by every refresh so unchanged worktrees are not
+// diffed again in watch mode
+var agentCollector = collector.New(0)
+
+// collectedStatus returns the status and icon of a collected agent. Agents
+// whose marker file reports completion are recorded as having worked.
+func collectedStatus(agent collector.Agent, stateManager *state.StateManager) (string, string) {
+	if agent.Dead {
+		return status.StatusDead, "💀"
+	}
+	if agent.StatusErr != nil {
+		return "error", "❌"
 	}
 
-	// Create a slice of sessions with their states for sorting
-	var sessions []sessionInfo
-	for _, sessionName := range activeSessions {
-		if state, ok := states[sessionName]; ok {
-			sessions = append(sessions, sessionInfo{name: sessionName, state: state})
-		}
+	detailed := agent.Status
+	// マーカーファイルによる自動完了マーク（後方互換性のため）
+	if detailed.Status == status.StatusReady && !agent.State.HasWorked {
+		stateManager.MarkWorkCompleted(agent.Session)
 	}
 
-	// Sort by UpdatedAt (most recent first)
-	sort.Slice(sessions, func(i, j int) bool {
-		return sessions[i].state.UpdatedAt.After(sessions[j].state.UpdatedAt)
-	})
+	// stuck状態の場合は警告アイコンに変更
+	icon := detailed.Icon
+	if detailed.IsStuck {
+		icon = "⚠️"
+	}
+	return detailed.Status, icon
+}
+
+// agentPrompt returns what the prompt column shows: the pending question for
+// waiting agents and the prompt they were started with otherwise
+func agentPrompt(agent collector.Agent) string {
+	if agent.StatusErr == nil && !agent.Dead && agent.Status.Status == status.StatusWaiting && agent.Status.Question != nil {
+		return "\033[35m?\033[0m " + agent.Status.Question.String()
+	}
+	return agent.State.Prompt
+}
+
+func printDetailedSessionsToWriter(w io.Writer, stateManager *state.StateManager, activeSessions []string) error {
+	// Collect status and diffs of all agents concurrently, filtered and
+	// sorted by the command-line flags
+	agents, err := collectAgents(stateManager, activeSessions)
+	if err != nil {
+		return err
+	}
+	return writeDetailedSessions(w, stateManager, agents)
+}
 
+func writeDetailedSessions(w io.Writer, stateManager *state.StateManager, agents []collector.Agent) error {
 	// Print header with columns
-	fmt.Fprintf(w, "%-25s %-12s %-15s %-15s %-15s %s\n",
-		"AGENT", "STATUS", "DIFF", "FILES (+/~/-)", "LAST CHANGE", "PROMPT / ERROR")
-	fmt.Fprintln(w, strings.Repeat("-", 100))
+	fmt.Fprintf(w, "%-25s %-12s %-10s %-15s %-15s %-15s %s\n",
+		"AGENT", "STATUS", "FOR", "DIFF", "FILES (+/~/-)", "LAST CHANGE", "PROMPT / SUMMARY / ERROR")
+	fmt.Fprintln(w, strings.Repeat("-", 111))
 
 	// Print sessions
-	for _, session := range sessions {
-		sessionName := session.name
-		state := session.state
+	for _, agent := range agents {
+		sessionName := agent.Session
+		state := agent.State
 
 		// Extract agent name from session name
 		parts := strings.Split(sessionName, "-")
@@ -237,13 +302,18 @@ func printDetailedSessionsToWriter(w io.Writer, stateManager *state.StateManager
 		}
 
 		// Get detailed status with icon
-		status, icon := getDetailedAgentStatus(sessionName, state.HasWorked, state.UpdatedAt)
+		status, icon := collectedStatus(agent, stateManager)
 
-		// Get git diff details
+		// Get git diff details and totals from the collected worktree diff
 		var fileStats string
 		var lastChangedFile string
-		diffDetails, err := getGitDiffDetails(state.WorktreePath)
-		if err == nil && len(diffDetails) > 0 {
+		var insertions, deletions int
+		var diffDetails []GitDiffDetails
+		if agent.Diff != nil {
+			insertions, deletions = agent.Diff.Insertions, agent.Diff.Deletions
+			diffDetails = toDiffDetails(agent.Diff)
+		}
+		if len(diffDetails) > 0 {
 			// Count file changes by type
 			added := 0
 			modified := 0
@@ -276,8 +346,6 @@ func printDetailedSessionsToWriter(w io.Writer, stateManager *state.StateManager
 			lastChangedFile = "-"
 		}
 
-		// Get diff totals
-		insertions, deletions := getGitDiffTotals(sessionName, stateManager)
 		diffStr := fmt.Sprintf("\033[32m+%d\033[0m/\033[31m-%d\033[0m", insertions, deletions)
 
 		// Format agent info with model
@@ -289,6 +357,12 @@ func printDetailedSessionsToWriter(w io.Writer, stateManager *state.StateManager
 		// Format status with icon
 		statusStr := fmt.Sprintf("%s %s", icon, status)
 
+		// Time in the current status, from the recorded history
+		statusFor := "-"
+		if history := state.StatusHistory; history != nil && !agent.Dead && history.Current == status {
+			statusFor = formatElapsed(history.Since)
+		}
+
 		// Format last change time or file
 		var lastChangeDisplay string
 		if lastChangedFile != "-" {
@@ -297,19 +371,23 @@ func printDetailedSessionsToWriter(w io.Writer, stateManager *state.StateManager
 			lastChangeDisplay = formatLastChange(state.UpdatedAt)
 		}
 
-		// Truncate prompt if too long
-		prompt := state.Prompt
-		if status == "error" && strings.Contains(prompt, "Error:") {
-			// Show error message
-			prompt = strings.TrimSpace(prompt)
+		// Truncate prompt if too long; finished agents show the summary of
+		// their completion report and failed agents the classified error
+		prompt := agentPrompt(agent)
+		if report := state.Report; report != nil && (status == "ready" || status == "merged") {
+			prompt = "✓ " + strings.ReplaceAll(report.Summary, "\n", " ")
+		}
+		if last := state.LastError; last != nil && status == "error" {
+			line, _, _ := strings.Cut(last.Excerpt, "\n")
+			prompt = fmt.Sprintf("✗ [%s] %s", last.Category, strings.TrimSpace(line))
 		}
 		if len(prompt) > 40 {
 			prompt = prompt[:37] + "..."
 		}
 
 		// Print row
-		fmt.Fprintf(w, "%-25s %-12s %-15s %-15s %-15s %s\n",
-			agentInfo, statusStr, diffStr, fileStats, lastChangeDisplay, prompt)
+		fmt.Fprintf(w, "%-25s %-12s %-10s %-15s %-15s %-15s %s\n",
+			agentInfo, statusStr, statusFor, diffStr, fileStats, lastChangeDisplay, prompt)
 	}
 
 	return nil
@@ -320,41 +398,30 @@ func printDetailedSessions(stateManager *state.StateManager, activeSessions []st
 }
 
 func printSessionsToWriter(w io.Writer, stateManager *state.StateManager, activeSessions []string, detailed bool) error {
-	// Load all states to sort by UpdatedAt
-	states := make(map[string]state.AgentState)
-	if data, err := os.ReadFile(stateManager.GetStatePath()); err == nil {
-		if err := json.Unmarshal(data, &states); err != nil {
-			return fmt.Errorf("error parsing state file: %w", err)
-		}
-	}
-
-	// Create a slice of sessions with their states for sorting
-	var sessions []sessionInfo
-	for _, sessionName := range activeSessions {
-		if state, ok := states[sessionName]; ok {
-			sessions = append(sessions, sessionInfo{name: sessionName, state: state})
-		}
+	// Collect status and diffs of all agents concurrently, filtered and
+	// sorted by the command-line flags
+	agents, err := collectAgents(stateManager, activeSessions)
+	if err != nil {
+		return err
 	}
+	return writeSessions(w, stateManager, agents, detailed)
+}
 
-	// Sort by UpdatedAt (most recent first)
-	sort.Slice(sessions, func(i, j int) bool {
-		return sessions[i].state.UpdatedAt.After(sessions[j].state.UpdatedAt)
-	})
-
+func writeSessions(w io.Writer, stateManager *state.StateManager, agents []collector.Agent, detailed bool) error {
 	// Long format with tabwriter for alignment
 	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
 
 	// Print header
 	if detailed {
-		fmt.Fprintf(tw, "AGENT\tMODEL\tSTATUS    DIFF\tADDR\tWORKTREE\tUPDATED\tPROMPT\n")
+		fmt.Fprintf(tw, "AGENT\tMODEL\tSTATUS    DIFF\tDRIFT\tADDR\tWORKTREE\tUPDATED\tPROMPT\n")
 	} else {
-		fmt.Fprintf(tw, "AGENT\tMODEL\tSTATUS    DIFF\tADDR\tPROMPT\n")
+		fmt.Fprintf(tw, "AGENT\tMODEL\tSTATUS    DIFF\tDRIFT\tADDR\tPROMPT\n")
 	}
 
 	// Print sessions
-	for _, session := range sessions {
-		sessionName := session.name
-		state := session.state
+	for _, agent := range agents {
+		sessionName := agent.Session
+		state := agent.State
 
 		// Extract agent name from session name
 		parts := strings.Split(sessionName, "-")
@@ -363,8 +430,14 @@ func printSessionsToWriter(w io.Writer, stateManager *state.StateManager, active
 			agentName = strings.Join(parts[3:], "-")
 		}
 
-		status := getAgentStatus(sessionName, state.HasWorked)
-		insertions, deletions := getGitDiffTotals(sessionName, stateManager)
+		status, _ := collectedStatus(agent, stateManager)
+		if agent.StatusErr != nil && !agent.Dead {
+			status = "unknown"
+		}
+		var insertions, deletions int
+		if agent.Diff != nil {
+			insertions, deletions = agent.Diff.Insertions, agent.Diff.Deletions
+		}
 
 		// Format diff stats with colors
 		var changes string
@@ -375,6 +448,12 @@ func printSessionsToWriter(w io.Writer, stateManager *state.StateManager, active
 			changes = fmt.Sprintf("\033[32m+%d\033[0m/\033[31m-%d\033[0m", insertions, deletions)
 		}
 
+		// Commits ahead of and behind the base branch
+		drift := "-"
+		if agent.Drift != nil {
+			drift = formatDrift(agent.Drift.Ahead, agent.Drift.Behind, agent.Drift.Conflicts, agent.Drift.ConflictsUnknown)
+		}
+
 		// Get model name, default to "unknown" if empty (for backward compatibility)
 		model := state.Model
 		if model == "" {
@@ -395,24 +474,26 @@ func printSessionsToWriter(w io.Writer, stateManager *state.StateManager, active
 			}
 			updatedTime := formatTime(state.UpdatedAt)
 
-			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
 				agentName,
 				model,
 				formatStatus(status),
 				changes,
+				drift,
 				addr,
 				worktreePath,
 				updatedTime,
-				state.Prompt,
+				agentPrompt(agent),
 			)
 		} else {
-			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
 				agentName,
 				model,
 				formatStatus(status),
 				changes,
+				drift,
 				addr,
-				state.Prompt,
+				agentPrompt(agent),
 			)
 		}
 	}
@@ -425,87 +506,129 @@ func printSessions(stateManager *state.StateManager, activeSessions []string, de
 	return printSessionsToWriter(os.Stdout, stateManager, activeSessions, detailed)
 }
 
+// textPrinter returns the function that renders the text view selected by
+// -columns, -format or -d
+func textPrinter(stateManager *state.StateManager) (func(w io.Writer, agents []collector.Agent) error, error) {
+	switch {
+	case *columnList != "" && *formatFlag != "":
+		return nil, fmt.Errorf("-columns and -format cannot be combined")
+	case *columnList != "":
+		names, err := parseColumns(*columnList)
+		if err != nil {
+			return nil, err
+		}
+		return func(w io.Writer, agents []collector.Agent) error {
+			return writeColumns(w, toRecords(agents), names)
+		}, nil
+	case *formatFlag != "":
+		tmpl, err := parseFormat(*formatFlag)
+		if err != nil {
+			return nil, err
+		}
+		return func(w io.Writer, agents []collector.Agent) error {
+			return writeTemplate(w, toRecords(agents), tmpl)
+		}, nil
+	case *detailedMode:
+		return func(w io.Writer, agents []collector.Agent) error {
+			return writeDetailedSessions(w, stateManager, agents)
+		}, nil
+	default:
+		return func(w io.Writer, agents []collector.Agent) error {
+			return writeSessions(w, stateManager, agents, false)
+		}, nil
+	}
+}
+
+// writeListing collects the agents to show and renders them with printText.
+// With -a every agent in the state file is listed, grouped by repository.
+// The state of the uzi auto watcher follows the agents, or the heading of
+// each repository with -a. It returns the agents it inspected, before
+// filtering.
+func writeListing(w io.Writer, stateManager *state.StateManager, printText func(w io.Writer, agents []collector.Agent) error) ([]collector.Agent, error) {
+	loaded, err := loadAgents(stateManager)
+	if err != nil {
+		return nil, err
+	}
+	// Templates are for scripts, so they get no watcher line or group headings
+	var watcher *listing.Watcher
+	if !*allSessions && *formatFlag == "" {
+		watcher = watcherStatus(stateManager.CurrentRepo())
+	}
+	if len(loaded) == 0 {
+		if *allSessions {
+			fmt.Fprintln(w, "No sessions found")
+		} else {
+			fmt.Fprintln(w, "No active sessions found")
+		}
+		if watcher != nil {
+			fmt.Fprintln(w, formatWatcher(watcher))
+		}
+		return loaded, nil
+	}
+
+	agents, err := filterAndSort(append([]collector.Agent(nil), loaded...))
+	if err != nil {
+		return loaded, err
+	}
+	if !*allSessions || *formatFlag != "" {
+		if err := printText(w, agents); err != nil {
+			return loaded, err
+		}
+		if watcher != nil {
+			fmt.Fprintf(w, "\n%s\n", formatWatcher(watcher))
+		}
+		return loaded, nil
+	}
+
+	for i, group := range groupByRepo(agents) {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		heading := fmt.Sprintf("\033[1m%s\033[0m", group.repo)
+		if watcher := watcherStatus(group.agents[0].State.GitRepo); watcher != nil {
+			heading += "  " + formatWatcher(watcher)
+		}
+		fmt.Fprintln(w, heading)
+		if err := printText(w, group.agents); err != nil {
+			return loaded, err
+		}
+	}
+	return loaded, nil
+}
+
 func executeLs(ctx context.Context, args []string) error {
 	stateManager := state.NewStateManager()
 	if stateManager == nil {
 		return fmt.Errorf("failed to create state manager")
 	}
 
-	if *watchMode {
-		// Watch mode - refresh every 5 seconds to reduce flicker
-		ticker := time.NewTicker(5 * time.Second)
-		defer ticker.Stop()
-
-		// カーソルを非表示にする
-		fmt.Print("\033[?25l")
-		// 終了時にカーソルを再表示
-		defer fmt.Print("\033[?25h")
-
-		// Initial display
-		activeSessions, err := stateManager.GetActiveSessionsForRepo()
-		if err != nil {
-			return fmt.Errorf("error getting active sessions: %w", err)
-		}
+	if !collector.IsSortKey(*sortKey) {
+		return fmt.Errorf("unknown sort key %q (want %s)", *sortKey, strings.Join(collector.SortKeys, ", "))
+	}
 
-		// バッファを使用して初回表示
-		var buf bytes.Buffer
-		if len(activeSessions) == 0 {
-			buf.WriteString("No active sessions found\n")
-		} else {
-			if *detailedMode {
-				printDetailedSessionsToWriter(&buf, stateManager, activeSessions)
-			} else {
-				printSessionsToWriter(&buf, stateManager, activeSessions, false)
-			}
+	if *outputFormat != "text" {
+		if !listing.IsFormat(*outputFormat) {
+			return fmt.Errorf("unknown output format %q (want text, json, yaml or tsv)", *outputFormat)
 		}
-		fmt.Print(buf.String())
-
-		// Watch loop
-		for {
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-ticker.C:
-				// カーソルをホーム位置に移動
-				fmt.Print("\033[H")
-				
-				// バッファに出力を蓄積
-				var buf bytes.Buffer
-				
-				// セッション情報を取得
-				activeSessions, err := stateManager.GetActiveSessionsForRepo()
-				if err != nil {
-					buf.WriteString(fmt.Sprintf("Error getting active sessions: %v\n", err))
-				} else if len(activeSessions) == 0 {
-					buf.WriteString("No active sessions found\n")
-				} else {
-					if *detailedMode {
-						printDetailedSessionsToWriter(&buf, stateManager, activeSessions)
-					} else {
-						printSessionsToWriter(&buf, stateManager, activeSessions, false)
-					}
-				}
-				
-				// カーソルをホーム位置から、バッファの内容を一度に出力し、残りをクリア
-				fmt.Print(buf.String())
-				fmt.Print("\033[J")
-			}
+		if *watchMode {
+			return fmt.Errorf("-o %s cannot be combined with -w", *outputFormat)
 		}
-	} else {
-		// Single run mode
-		activeSessions, err := stateManager.GetActiveSessionsForRepo()
-		if err != nil {
-			return fmt.Errorf("error getting active sessions: %w", err)
+		if *columnList != "" || *formatFlag != "" {
+			return fmt.Errorf("-columns and -format only apply to -o text")
 		}
+		return printRecords(os.Stdout, *outputFormat, stateManager)
+	}
 
-		if len(activeSessions) == 0 {
-			fmt.Println("No active sessions found")
-			return nil
-		}
+	printText, err := textPrinter(stateManager)
+	if err != nil {
+		return err
+	}
 
-		if *detailedMode {
-			return printDetailedSessions(stateManager, activeSessions)
-		}
-		return printSessions(stateManager, activeSessions, false)
+	if *watchMode {
+		return runWatch(ctx, stateManager, printText)
 	}
+
+	// Single run mode
+	_, err = writeListing(os.Stdout, stateManager, printText)
+	return err
 }
@@ -0,0 +1,159 @@
+package ls
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/devflowinc/uzi/pkg/collector"
+	"github.com/devflowinc/uzi/pkg/daemon"
+	"github.com/devflowinc/uzi/pkg/listing"
+	"github.com/devflowinc/uzi/pkg/state"
+	"github.com/devflowinc/uzi/pkg/status"
+
+	"github.com/charmbracelet/log"
+)
+
+// toRecords converts collected agents to listing records
+func toRecords(agents []collector.Agent) []listing.Record {
+	now := time.Now()
+	records := make([]listing.Record, 0, len(agents))
+	for _, agent := range agents {
+		agentState := agent.State
+
+		record := listing.Record{
+			Name:         state.AgentNameFromSession(agent.Session),
+			Session:      agent.Session,
+			Model:        agentState.Model,
+			Repo:         agentState.GitRepo,
+			Port:         agentState.Port,
+			Worktree:     agentState.WorktreePath,
+			Branch:       agentState.BranchName,
+			BaseRef:      agentState.BranchFrom,
+			Labels:       agentState.Labels,
+			Prompt:       agentState.Prompt,
+			CreatedAt:    agentState.CreatedAt,
+			UpdatedAt:    agentState.UpdatedAt,
+			LastWorkedAt: agentState.LastWorkedAt,
+			LastMergedAt: agentState.LastMergedAt,
+		}
+		if record.Model == "" {
+			record.Model = "unknown"
+		}
+		if agentState.Port != 0 {
+			record.URL = fmt.Sprintf("http://localhost:%d", agentState.Port)
+		}
+
+		record.Status = collector.StatusOf(agent)
+		if agent.StatusErr == nil {
+			record.Stuck = agent.Status.IsStuck
+			if question := agent.Status.Question; question != nil && record.Status == status.StatusWaiting {
+				record.Question = question.Text
+				for _, option := range question.Options {
+					record.Options = append(record.Options, listing.Option{Key: option.Key, Label: option.Label})
+				}
+			}
+		}
+
+		if agent.Diff != nil {
+			record.Insertions, record.Deletions = agent.Diff.Insertions, agent.Diff.Deletions
+			for _, file := range agent.Diff.Files {
+				record.Files = append(record.Files, listing.File{
+					Path:       file.Path,
+					Status:     file.Status,
+					Insertions: file.Insertions,
+					Deletions:  file.Deletions,
+					Binary:     file.IsBinary,
+				})
+			}
+		}
+
+		if agent.Drift != nil {
+			record.Ahead, record.Behind = agent.Drift.Ahead, agent.Drift.Behind
+			record.Conflicts = agent.Drift.Conflicts
+			record.ConflictsUnknown = agent.Drift.ConflictsUnknown
+		}
+
+		if agent.Usage != nil {
+			record.CPUPercent = math.Round(agent.Usage.Agent.CPUPercent*10) / 10
+			record.RSSBytes = agent.Usage.Agent.RSS
+			record.DevCPU = math.Round(agent.Usage.Dev.CPUPercent*10) / 10
+			record.DevRSSBytes = agent.Usage.Dev.RSS
+		}
+
+		if history := agentState.StatusHistory; history != nil {
+			record.Flips = history.Flips
+			record.RunningSeconds = math.Round(history.Seconds[status.StatusRunning])
+			// The current interval of a dead agent ended at an unknown time
+			if !agent.Dead && history.Current == record.Status {
+				since := history.Since
+				record.StatusSince = &since
+				record.StatusSeconds = math.Round(now.Sub(since).Seconds())
+				record.RunningSeconds = math.Round(status.TimeInStatus(history, status.StatusRunning, now).Seconds())
+			}
+		}
+
+		if last := agentState.LastError; last != nil && record.Status == status.StatusError {
+			record.Error = &listing.Error{Category: last.Category, Excerpt: last.Excerpt, At: last.At}
+		}
+
+		if report := agentState.Report; report != nil {
+			record.Report = &listing.Report{
+				Summary:       report.Summary,
+				FilesTouched:  report.FilesTouched,
+				OpenQuestions: report.OpenQuestions,
+				FollowUps:     report.FollowUps,
+				CompletedAt:   report.CompletedAt,
+			}
+			if tests := report.Tests; tests != nil {
+				record.Report.Tests = &listing.Tests{Command: tests.Command, Result: tests.Result, Details: tests.Details}
+			}
+		}
+
+		records = append(records, record)
+	}
+	return records
+}
+
+// printRecords writes the agents that pass the filter flags in a
+// machine-readable format
+func printRecords(w io.Writer, format string, stateManager *state.StateManager) error {
+	agents, err := loadAgents(stateManager)
+	if err != nil {
+		return err
+	}
+	agents, err = filterAndSort(agents)
+	if err != nil {
+		return err
+	}
+	doc := listing.NewDocument(toRecords(agents))
+	doc.Watcher = watcherStatus(stateManager.CurrentRepo())
+	return listing.Write(w, format, doc)
+}
+
+// watcherStatus returns the state of the uzi auto watcher of a repository,
+// or nil when it cannot be determined, e.g. without a remote
+func watcherStatus(repo string) *listing.Watcher {
+	d, err := daemon.For(repo)
+	if err != nil {
+		return nil
+	}
+	status, err := d.Status()
+	if err != nil {
+		log.Debug("Could not read the uzi auto pidfile", "path", d.PIDPath, "error", err)
+		return nil
+	}
+	if !status.Running {
+		return &listing.Watcher{}
+	}
+	return &listing.Watcher{Running: true, PID: status.PID, Since: &status.Since}
+}
+
+// formatWatcher describes the watcher for the text views
+func formatWatcher(watcher *listing.Watcher) string {
+	if watcher.Running {
+		return fmt.Sprintf("uzi auto: \033[32mrunning\033[0m (pid %d, up %s)", watcher.PID, formatElapsed(*watcher.Since))
+	}
+	return "uzi auto: \033[90mnot running\033[0m (start it with \"uzi auto start\")"
+}
@@ -0,0 +1,135 @@
+package ls
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/devflowinc/uzi/pkg/collector"
+	"github.com/devflowinc/uzi/pkg/fswatch"
+	"github.com/devflowinc/uzi/pkg/gitdiff"
+	"github.com/devflowinc/uzi/pkg/runner"
+	"github.com/devflowinc/uzi/pkg/state"
+	"github.com/devflowinc/uzi/pkg/transcript"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	// settleDelay lets a burst of file events finish before redrawing
+	settleDelay = 100 * time.Millisecond
+	// minRedrawGap caps the redraw rate while agents write continuously
+	minRedrawGap = 500 * time.Millisecond
+)
+
+// watchPaths returns everything whose changes should trigger a redraw: the
+// uzi data directory (state.json and the runner events file), the transcript
+// directories, and the directories and git directory of every worktree
+func watchPaths(stateManager *state.StateManager, agents []collector.Agent) []string {
+	paths := []string{filepath.Dir(stateManager.GetStatePath())}
+	if events, err := runner.EventsPath(); err == nil {
+		paths = append(paths, filepath.Dir(events))
+	}
+	logsDir, logsErr := transcript.Dir()
+	if logsErr == nil {
+		paths = append(paths, logsDir)
+	}
+
+	for _, agent := range agents {
+		if logsErr == nil {
+			paths = append(paths, filepath.Join(logsDir, agent.Session))
+		}
+		if agent.State.WorktreePath == "" {
+			continue
+		}
+		paths = append(paths, fswatch.Tree(agent.State.WorktreePath, fswatch.DefaultTreeLimit)...)
+		if gitDir, err := gitdiff.GitDir(agent.State.WorktreePath); err == nil {
+			paths = append(paths, gitDir)
+		}
+	}
+	return paths
+}
+
+// runWatch redraws the listing whenever a watched file or session changes,
+// throttled to one redraw per minRedrawGap, and at least every -interval.
+// Without file watching support it falls back to redrawing every -interval.
+func runWatch(ctx context.Context, stateManager *state.StateManager, printText func(w io.Writer, agents []collector.Agent) error) error {
+	if *interval <= 0 {
+		return fmt.Errorf("-interval must be positive")
+	}
+
+	var events <-chan struct{}
+	watcher, err := fswatch.New()
+	if err != nil {
+		log.Debug("File watching unavailable, polling instead", "error", err, "interval", *interval)
+	} else {
+		defer watcher.Close()
+		events = watcher.Events
+	}
+
+	// カーソルを非表示にする
+	fmt.Print("\033[?25l")
+	// 終了時にカーソルを再表示
+	defer fmt.Print("\033[?25h")
+
+	// バッファを使用して初回表示
+	var buf bytes.Buffer
+	agents, err := writeListing(&buf, stateManager, printText)
+	if err != nil {
+		return err
+	}
+	fmt.Print(buf.String())
+
+	redraw := func() {
+		// バッファに出力を蓄積
+		var buf bytes.Buffer
+		loaded, err := writeListing(&buf, stateManager, printText)
+		if err != nil {
+			buf.WriteString(fmt.Sprintf("Error listing sessions: %v\n", err))
+		} else {
+			agents = loaded
+		}
+
+		// カーソルをホーム位置に移動し、バッファの内容を一度に出力して残りをクリア
+		fmt.Print("\033[H")
+		fmt.Print(buf.String())
+		fmt.Print("\033[J")
+	}
+
+	sync := func() {
+		if watcher == nil {
+			return
+		}
+		// New directories appear and worktrees come and go between
+		// redraws; missing paths are simply skipped
+		watcher.Sync(watchPaths(stateManager, agents))
+	}
+	sync()
+
+	timer := time.NewTimer(*interval)
+	defer timer.Stop()
+	lastDraw := time.Now()
+	pending := false
+
+	// Watch loop
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-events:
+			if !pending {
+				pending = true
+				timer.Reset(max(settleDelay, minRedrawGap-time.Since(lastDraw)))
+			}
+		case <-timer.C:
+			redraw()
+			sync()
+			lastDraw = time.Now()
+			pending = false
+			timer.Reset(*interval)
+		}
+	}
+}
@@ -15,8 +15,8 @@ var (
 	fs          = flag.NewFlagSet("uzi notify", flag.ExitOnError)
 	sessionName = fs.String("session", "", "session name")
 	agentName   = fs.String("agent", "", "agent name")
-	notifType   = fs.String("type", "complete", "notification type (complete, error, progress)")
-	port        = fs.Int("port", 9999, "manager notification port")
+	notifType   = fs.String("type", "complete", "notification type (complete, error, progress, waiting)")
+	port        = fs.Int("port", notification.DefaultPort, "manager notification port")
 	CmdNotify   = &ffcli.Command{
 		Name:       "notify",
 		ShortUsage: "uzi notify --session=SESSION --agent=AGENT --type=TYPE [message]",
@@ -54,6 +54,8 @@ func executeNotify(ctx context.Context, args []string) error {
 		err = client.NotifyError(message, fmt.Errorf("agent error"))
 	case "progress":
 		err = client.NotifyProgress(message, 50) // Default to 50% progress
+	case "waiting":
+		err = client.NotifyWaiting(message, nil)
 	default:
 		return fmt.Errorf("unknown notification type: %s", *notifType)
 	}
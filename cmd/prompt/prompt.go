@@ -14,7 +14,10 @@ import (
 
 	"github.com/devflowinc/uzi/pkg/agents"
 	"github.com/devflowinc/uzi/pkg/config"
+	"github.com/devflowinc/uzi/pkg/runner"
 	"github.com/devflowinc/uzi/pkg/state"
+	"github.com/devflowinc/uzi/pkg/status"
+	"github.com/devflowinc/uzi/pkg/transcript"
 
 	"github.com/charmbracelet/log"
 	"github.com/peterbourgon/ff/v3/ffcli"
@@ -29,9 +32,10 @@ var (
 	fs         = flag.NewFlagSet("uzi prompt", flag.ExitOnError)
 	agentsFlag = fs.String("agents", "claude:1", "agents to run with their commands and counts (e.g., 'claude:1,codex:2'). Use 'random' as agent name to select a random agent name.")
 	configPath = fs.String("config", config.GetDefaultConfigPath(), "path to config file")
+	labelsFlag = fs.String("labels", "", "comma-separated labels to attach to the agents (e.g., 'backend,auth')")
 	CmdPrompt  = &ffcli.Command{
 		Name:       "prompt",
-		ShortUsage: "uzi prompt --agents=AGENT:COUNT[,AGENT:COUNT...] prompt text...",
+		ShortUsage: "uzi prompt --agents=AGENT:COUNT[,AGENT:COUNT...] [--labels=LABEL,...] prompt text...",
 		ShortHelp:  "Run the prompt command with specified agents and counts",
 		FlagSet:    fs,
 		Exec:       executePrompt,
@@ -74,6 +78,17 @@ func parseAgents(agentsStr string) (map[string]AgentConfig, error) {
 	return agentConfigs, nil
 }
 
+// parseLabels splits a comma-separated label list, dropping empty entries
+func parseLabels(labelsStr string) []string {
+	var labels []string
+	for _, label := range strings.Split(labelsStr, ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
 // isPortAvailable checks if a port is available for use
 func isPortAvailable(port int) bool {
 	address := fmt.Sprintf(":%d", port)
@@ -108,6 +123,17 @@ func findAvailablePort(startPort, endPort int, assignedPorts []int) (int, error)
 	return 0, fmt.Errorf("no available ports in range %d-%d", startPort, endPort)
 }
 
+// startTranscript streams a window into its persistent transcript
+func startTranscript(ctx context.Context, agentRunner runner.Runner, sessionName, window string) {
+	path, err := transcript.Path(sessionName, window)
+	if err == nil {
+		err = agentRunner.Transcribe(ctx, sessionName, window, path)
+	}
+	if err != nil {
+		log.Warn("Failed to start transcript", "session", sessionName, "window", window, "error", err)
+	}
+}
+
 func executePrompt(ctx context.Context, args []string) error {
 	if len(args) == 0 {
 		return fmt.Errorf("prompt argument is required")
@@ -129,6 +155,11 @@ func executePrompt(ctx context.Context, args []string) error {
 	promptText := strings.Join(args, " ")
 	log.Debug("Running prompt command", "prompt", promptText)
 
+	// Sessions run on the dedicated uzi tmux server or the PTY supervisor
+	agentRunner := runner.Default()
+
+	labels := parseLabels(*labelsFlag)
+
 	// Track assigned ports to prevent collisions between iterations
 	var assignedPorts []int
 
@@ -211,6 +242,11 @@ func executePrompt(ctx context.Context, args []string) error {
 				continue
 			}
 
+			// Never commit the completion marker the agent writes
+			if err := status.ExcludeMarkerFile(worktreePath); err != nil {
+				log.Warn("Failed to exclude the marker file", "error", err)
+			}
+
 			// Copy CLAUDE-WORKER.md to the worktree as CLAUDE.md
 			workerMdPath := filepath.Join(filepath.Dir(os.Args[0]), "CLAUDE-WORKER.md")
 			targetMdPath := filepath.Join(worktreePath, "CLAUDE.md")
@@ -224,37 +260,26 @@ func executePrompt(ctx context.Context, args []string) error {
 				}
 			}
 
-			// Create tmux session
-			cmd = fmt.Sprintf("tmux new-session -d -s %s -c %s", sessionName, worktreePath)
-			cmdExec = exec.CommandContext(ctx, "sh", "-c", cmd)
-			if err := cmdExec.Run(); err != nil {
-				log.Error("Error creating tmux session", "command", cmd, "error", err)
+			// Create the agent session; its first window is named "agent"
+			if err := agentRunner.Start(ctx, sessionName, worktreePath); err != nil {
+				log.Error("Error creating agent session", "session", sessionName, "runner", agentRunner.Name(), "error", err)
 				continue
 			}
 
-			// Rename the first window to "agent"
-			renameCmd := fmt.Sprintf("tmux rename-window -t %s:0 agent", sessionName)
-			renameExec := exec.CommandContext(ctx, "sh", "-c", renameCmd)
-			if err := renameExec.Run(); err != nil {
-				log.Error("Error renaming tmux window", "command", renameCmd, "error", err)
-				continue
-			}
+			// Stream the agent window into its persistent transcript
+			startTranscript(ctx, agentRunner, sessionName, "agent")
 
 			// Create uzi-dev pane and run dev command if configured
 			if cfg.DevCommand == nil || *cfg.DevCommand == "" || cfg.PortRange == nil || *cfg.PortRange == "" {
 				// Hit enter in the agent pane
-				hitEnterCmd := fmt.Sprintf("tmux send-keys -t %s:agent C-m", sessionName)
-				hitEnterExec := exec.CommandContext(ctx, "sh", "-c", hitEnterCmd)
-				if err := hitEnterExec.Run(); err != nil {
-					log.Error("Error hitting enter in tmux", "command", hitEnterCmd, "error", err)
+				if err := agentRunner.SendKeys(ctx, sessionName, "agent", "C-m"); err != nil {
+					log.Error("Error hitting enter in agent window", "session", sessionName, "error", err)
 				}
 
-				// Always run send-keys command to the agent pane
-				tmuxCmd := fmt.Sprintf("tmux send-keys -t %s:agent '%s \"%%s\"' C-m", sessionName, commandToUse)
-				tmuxCmdExec := exec.CommandContext(ctx, "sh", "-c", fmt.Sprintf(tmuxCmd, promptText))
-				tmuxCmdExec.Dir = worktreePath
-				if err := tmuxCmdExec.Run(); err != nil {
-					log.Error("Error sending keys to tmux", "command", tmuxCmd, "error", err)
+				// Always send the agent command to the agent pane
+				agentCmd := agents.LaunchCommand(commandToUse, promptText)
+				if err := agentRunner.SendKeys(ctx, sessionName, "agent", agentCmd, "C-m"); err != nil {
+					log.Error("Error sending keys to agent window", "command", agentCmd, "error", err)
 					continue
 				}
 
@@ -263,6 +288,10 @@ func executePrompt(ctx context.Context, args []string) error {
 				if stateManager != nil {
 					if err := stateManager.SaveState(promptText, branchName, sessionName, worktreePath, commandToUse); err != nil {
 						log.Error("Error saving state", "error", err)
+					} else if len(labels) > 0 {
+						if err := stateManager.SetLabels(sessionName, labels); err != nil {
+							log.Error("Error saving labels", "error", err)
+						}
 					}
 				}
 				continue
@@ -291,31 +320,27 @@ func executePrompt(ctx context.Context, args []string) error {
 			devCmd := strings.Replace(devCmdTemplate, "$PORT", strconv.Itoa(selectedPort), 1)
 
 			// Create new window named uzi-dev
-			newWindowCmd := fmt.Sprintf("tmux new-window -t %s -n uzi-dev -c %s", sessionName, worktreePath)
-			newWindowExec := exec.CommandContext(ctx, "sh", "-c", newWindowCmd)
-			if err := newWindowExec.Run(); err != nil {
-				log.Error("Error creating new tmux window for dev server", "command", newWindowCmd, "error", err)
+			if err := agentRunner.NewWindow(ctx, sessionName, "uzi-dev", worktreePath); err != nil {
+				log.Error("Error creating new window for dev server", "session", sessionName, "error", err)
 				continue
 			}
 
+			startTranscript(ctx, agentRunner, sessionName, "uzi-dev")
+
 			// Send dev command to the new window
-			sendDevCmd := fmt.Sprintf("tmux send-keys -t %s:uzi-dev '%s' C-m", sessionName, devCmd)
-			sendDevCmdExec := exec.CommandContext(ctx, "sh", "-c", sendDevCmd)
-			if err := sendDevCmdExec.Run(); err != nil {
-				log.Error("Error sending dev command to tmux", "command", sendDevCmd, "error", err)
+			if err := agentRunner.SendKeys(ctx, sessionName, "uzi-dev", devCmd, "C-m"); err != nil {
+				log.Error("Error sending dev command", "command", devCmd, "error", err)
 			}
 
 			// Hit enter in the agent pane
-			hitEnterCmd := fmt.Sprintf("tmux send-keys -t %s:agent C-m", sessionName)
-			hitEnterExec := exec.CommandContext(ctx, "sh", "-c", hitEnterCmd)
-			if err := hitEnterExec.Run(); err != nil {
-				log.Error("Error hitting enter in tmux", "command", hitEnterCmd, "error", err)
+			if err := agentRunner.SendKeys(ctx, sessionName, "agent", "C-m"); err != nil {
+				log.Error("Error hitting enter in agent window", "session", sessionName, "error", err)
 			}
 
 			assignedPorts = append(assignedPorts, selectedPort)
 
 			// Clear marker file if exists
-			markerPath := filepath.Join(worktreePath, ".uzi-task-completed")
+			markerPath := filepath.Join(worktreePath, status.MarkerFile)
 			if _, err := os.Stat(markerPath); err == nil {
 				if err := os.Remove(markerPath); err != nil {
 					log.Warn("Failed to remove marker file", "path", markerPath, "error", err)
@@ -324,12 +349,10 @@ func executePrompt(ctx context.Context, args []string) error {
 				}
 			}
 
-			// Always run send-keys command to the agent pane
-			tmuxCmd := fmt.Sprintf("tmux send-keys -t %s:agent '%s \"%%s\"' C-m", sessionName, commandToUse)
-			tmuxCmdExec := exec.CommandContext(ctx, "sh", "-c", fmt.Sprintf(tmuxCmd, promptText))
-			tmuxCmdExec.Dir = worktreePath
-			if err := tmuxCmdExec.Run(); err != nil {
-				log.Error("Error sending keys to tmux", "command", tmuxCmd, "error", err)
+			// Always send the agent command to the agent pane
+			agentCmd := agents.LaunchCommand(commandToUse, promptText)
+			if err := agentRunner.SendKeys(ctx, sessionName, "agent", agentCmd, "C-m"); err != nil {
+				log.Error("Error sending keys to agent window", "command", agentCmd, "error", err)
 				continue
 			}
 
@@ -338,6 +361,10 @@ func executePrompt(ctx context.Context, args []string) error {
 			if stateManager != nil {
 				if err := stateManager.SaveStateWithPort(promptText, branchName, sessionName, worktreePath, commandToUse, selectedPort); err != nil {
 					log.Error("Error saving state", "error", err)
+				} else if len(labels) > 0 {
+					if err := stateManager.SetLabels(sessionName, labels); err != nil {
+						log.Error("Error saving labels", "error", err)
+					}
 				}
 			}
 		}
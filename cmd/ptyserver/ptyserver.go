@@ -0,0 +1,48 @@
+package ptyserver
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/devflowinc/uzi/pkg/runner"
+	"github.com/devflowinc/uzi/pkg/runner/supervisor"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+var (
+	fs           = flag.NewFlagSet("uzi pty-server", flag.ExitOnError)
+	socketPath   = fs.String("socket", "", "unix socket to listen on (default ~/.local/share/uzi/pty.sock)")
+	CmdPtyServer = &ffcli.Command{
+		Name:       "pty-server",
+		ShortUsage: "uzi pty-server [-socket path]",
+		ShortHelp:  "Run the PTY supervisor used by the pty runner",
+		LongHelp: `
+The PTY supervisor owns the pseudo-terminals of agents started with the pty
+runner. It is started automatically when needed and exits once its last
+session has ended, so running it by hand is only useful for debugging.
+`,
+		FlagSet: fs,
+		Exec:    executePtyServer,
+	}
+)
+
+func executePtyServer(ctx context.Context, args []string) error {
+	path := *socketPath
+	if path == "" {
+		var err error
+		path, err = runner.PTYSocketPath()
+		if err != nil {
+			return fmt.Errorf("could not resolve socket path: %w", err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return supervisor.New(path).Serve(ctx)
+}
@@ -9,6 +9,8 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/devflowinc/uzi/pkg/runner"
+
 	"github.com/charmbracelet/log"
 	"github.com/peterbourgon/ff/v3/ffcli"
 )
@@ -18,7 +20,7 @@ var (
 	CmdReset = &ffcli.Command{
 		Name:       "reset",
 		ShortUsage: "uzi reset",
-		ShortHelp:  "Delete all data stored in ~/.local/share/uzi",
+		ShortHelp:  "Stop all uzi sessions and delete all data stored in ~/.local/share/uzi",
 		FlagSet:    fs,
 		Exec:       executeReset,
 	}
@@ -55,6 +57,11 @@ func executeReset(ctx context.Context, args []string) error {
 		return nil
 	}
 
+	// Stop every uzi session along with the tmux server or PTY supervisor
+	if err := runner.Default().Shutdown(ctx); err != nil {
+		log.Debug("No uzi runner to stop", "error", err)
+	}
+
 	// Remove the entire uzi data directory
 	if err := os.RemoveAll(uziDataPath); err != nil {
 		log.Error("Error removing uzi data directory", "path", uziDataPath, "error", err)
@@ -1,13 +1,14 @@
 package run
 
 import (
-	"bytes"
 	"context"
 	"flag"
 	"fmt"
-	"os/exec"
 	"strings"
+	"time"
+
 	"github.com/devflowinc/uzi/pkg/config"
+	"github.com/devflowinc/uzi/pkg/runner"
 	"github.com/devflowinc/uzi/pkg/state"
 
 	"github.com/charmbracelet/log"
@@ -55,36 +56,36 @@ func executeRun(ctx context.Context, args []string) error {
 
 	fmt.Printf("Running command '%s' in %d agent sessions:\n", command, len(activeSessions))
 
+	agentRunner := runner.Default()
+
 	// Execute command in each session
 	for _, session := range activeSessions {
 		fmt.Printf("\n=== %s ===\n", session)
 
-		// Create a new window without specifying name or target to get next unused index
-		// Use -P to print the window info in format session:index
-		newWindowCmd := exec.Command("tmux", "new-window", "-t", session, "-P", "-F", "#{window_index}", "-c", "#{session_path}")
-		windowIndexBytes, err := newWindowCmd.Output()
+		worktreeInfo, err := sm.GetWorktreeInfo(session)
 		if err != nil {
-			log.Error("Failed to create new window", "session", session, "error", err)
+			log.Error("Failed to get worktree info", "session", session, "error", err)
 			continue
 		}
 
-		windowIndex := strings.TrimSpace(string(windowIndexBytes))
-		windowTarget := session + ":" + windowIndex
+		// Give every run its own window so earlier output is left untouched
+		window := fmt.Sprintf("run-%d", time.Now().UnixNano())
+		if err := agentRunner.NewWindow(ctx, session, window, worktreeInfo.WorktreePath); err != nil {
+			log.Error("Failed to create new window", "session", session, "error", err)
+			continue
+		}
 
-		sendKeysCmd := exec.Command("tmux", "send-keys", "-t", windowTarget, command, "Enter")
-		if err := sendKeysCmd.Run(); err != nil {
-			log.Error("Failed to send command ", command, " tosession", session, "error", err)
+		if err := agentRunner.SendKeys(ctx, session, window, command, "Enter"); err != nil {
+			log.Error("Failed to send command", "command", command, "session", session, "error", err)
 			continue
 		}
 
-		// Capture the output from the pane
-		captureCmd := exec.Command("tmux", "capture-pane", "-t", windowTarget, "-p")
-		var captureOut bytes.Buffer
-		captureCmd.Stdout = &captureOut
-		if err := captureCmd.Run(); err != nil {
+		// Capture the output from the window
+		screen, err := agentRunner.ReadScreen(ctx, session, window)
+		if err != nil {
 			log.Error("Failed to capture output", "session", session, "error", err)
 		} else {
-			output := strings.TrimSpace(captureOut.String())
+			output := strings.TrimSpace(screen)
 			if output != "" {
 				fmt.Println(output)
 			}
@@ -92,9 +93,8 @@ func executeRun(ctx context.Context, args []string) error {
 
 		// If delete flag is set, kill the window after capturing output
 		if *deletePanel {
-			killWindowCmd := exec.Command("tmux", "kill-window", "-t", windowTarget)
-			if err := killWindowCmd.Run(); err != nil {
-				log.Error("Failed to kill window", "session", session, "window", windowTarget, "error", err)
+			if err := agentRunner.KillWindow(ctx, session, window); err != nil {
+				log.Error("Failed to kill window", "session", session, "window", window, "error", err)
 			}
 		}
 	}
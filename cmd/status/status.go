@@ -0,0 +1,246 @@
+package status
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/devflowinc/uzi/pkg/config"
+	"github.com/devflowinc/uzi/pkg/runner"
+	"github.com/devflowinc/uzi/pkg/state"
+	"github.com/devflowinc/uzi/pkg/status"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+var (
+	testFs     = flag.NewFlagSet("uzi status test", flag.ExitOnError)
+	configPath = testFs.String("config", config.GetDefaultConfigPath(), "path to config file")
+	screenPath = testFs.String("screen", "", "test the rules against this file instead of the agent's screen (- for stdin)")
+	modelFlag  = testFs.String("model", "", "use the profile of this command instead of the agent's")
+	cmdTest    = &ffcli.Command{
+		Name:       "test",
+		ShortUsage: "uzi status test [-screen file] [-model command] [<agent-name>]",
+		ShortHelp:  "Show which status rule matches an agent's screen",
+		LongHelp: `
+Reads the agent's screen and tries every rule of its status profile in
+evaluation order, showing which lines each rule looked at and which rule
+decided the status. Errors are classified as crash, api, tool, build or
+unknown; tool and build failures are skipped and do not decide the status.
+Rules come from statusRules in uzi.yaml, falling back to the built-in rules.
+
+With -screen the rules are tried against saved screen text, e.g. the output
+of "tmux capture-pane -p", and the agent name is optional; -model picks the
+profile when there is no agent.
+`,
+		FlagSet: testFs,
+		Exec:    executeTest,
+	}
+
+	fs        = flag.NewFlagSet("uzi status", flag.ExitOnError)
+	CmdStatus = &ffcli.Command{
+		Name:        "status",
+		ShortUsage:  "uzi status test [<agent-name>]",
+		ShortHelp:   "Inspect agent status detection",
+		FlagSet:     fs,
+		Subcommands: []*ffcli.Command{cmdTest},
+		Exec: func(ctx context.Context, args []string) error {
+			return fmt.Errorf("missing subcommand (usage: uzi status test [<agent-name>])")
+		},
+	}
+)
+
+// screenClient serves a fixed screen to the status manager
+type screenClient struct {
+	screen string
+}
+
+func (c *screenClient) GetPaneContent(sessionName string) (string, error) {
+	return c.screen, nil
+}
+
+func executeTest(ctx context.Context, args []string) error {
+	if len(args) == 0 && *screenPath == "" {
+		return fmt.Errorf("agent name argument is required without -screen")
+	}
+	// Allow flags after the agent name, e.g. "uzi status test penelope -model codex"
+	if len(args) > 0 {
+		if err := testFs.Parse(args[1:]); err != nil {
+			return err
+		}
+		args = args[:1]
+	}
+
+	rules, err := status.LoadRules(*configPath)
+	if err != nil {
+		return err
+	}
+
+	var sessionName string
+	var agentState state.AgentState
+	var sm *state.StateManager
+	if len(args) > 0 {
+		sm = state.NewStateManager()
+		if sm == nil {
+			return fmt.Errorf("could not initialize state manager")
+		}
+		if sessionName, err = sm.FindSessionForAgent(args[0]); err != nil {
+			return err
+		}
+		states, err := sm.LoadStates()
+		if err != nil {
+			return err
+		}
+		agentState = states[sessionName]
+	}
+
+	var screen string
+	switch {
+	case *screenPath == "-":
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read screen from stdin: %w", err)
+		}
+		screen = string(data)
+	case *screenPath != "":
+		data, err := os.ReadFile(*screenPath)
+		if err != nil {
+			return fmt.Errorf("failed to read screen: %w", err)
+		}
+		screen = string(data)
+	default:
+		screen, err = runner.Default().ReadScreen(ctx, sessionName, "agent")
+		if err != nil {
+			return fmt.Errorf("failed to read the screen of %s: %w", args[0], err)
+		}
+	}
+
+	model := agentState.Model
+	if *modelFlag != "" {
+		model = *modelFlag
+	}
+	profile := rules.ProfileFor(model)
+
+	if len(args) > 0 {
+		fmt.Printf("Agent:    %s (%s)\n", args[0], sessionName)
+	}
+	fmt.Printf("Command:  %s\n", orDash(model))
+	fmt.Printf("Profile:  %s\n", profile.Name)
+	if profile.Stuck.After > 0 {
+		fmt.Printf("Stuck:    running with no %s activity for %s\n", strings.Join(profile.Stuck.Signals, ", "), profile.Stuck.After)
+	} else {
+		fmt.Printf("Stuck:    off\n")
+	}
+	if profile.Restart.Enabled() {
+		fmt.Printf("Restart:  %s, at most %d times, first after %s\n", profile.Restart.Policy, profile.Restart.MaxRetries, profile.Restart.Backoff)
+	} else {
+		fmt.Printf("Restart:  never\n")
+	}
+	if profile.Nudge.Enabled() {
+		fmt.Printf("Nudge:    idle for %s, at most %d times\n", profile.Nudge.After, profile.Nudge.MaxNudges)
+	} else {
+		fmt.Printf("Nudge:    off\n")
+	}
+
+	results := profile.Explain(screen)
+	decided := -1
+	for i, result := range results {
+		if result.Decides() {
+			decided = i
+			break
+		}
+	}
+	if err := writeResults(os.Stdout, results, decided); err != nil {
+		return err
+	}
+	fmt.Println()
+
+	if decided < 0 {
+		fmt.Println("No rule matched; the status falls through to the merged, ready and idle checks.")
+	} else {
+		result := results[decided]
+		fmt.Printf("Rule %q matched line %d: %s\n", result.Rule.Name, result.LineNumber, strings.TrimSpace(result.Line))
+		if result.Error != nil {
+			fmt.Printf("Error:    %s\n", result.Error.Category)
+		}
+		if result.Rule.Priority <= status.FallbackPriority {
+			fmt.Printf("Its priority is %d or lower, so a merged or ready agent is reported as such instead.\n", status.FallbackPriority)
+		}
+	}
+
+	// With an agent, show the status uzi ls reports for this screen
+	if sm != nil {
+		states := &modelOverride{StateManager: status.NewStateAdapter(sm), model: *modelFlag}
+		statusManager := status.NewStatusManagerWithRules(&screenClient{screen: screen}, states, rules)
+		st, err := statusManager.GetStatus(sessionName)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Status:   %s\n", st)
+	} else if decided >= 0 {
+		fmt.Printf("Status:   %s\n", results[decided].Rule.Status)
+	}
+	return nil
+}
+
+// modelOverride makes -model take effect in the status manager, which picks
+// the profile from the agent's recorded command
+type modelOverride struct {
+	status.StateManager
+	model string
+}
+
+func (m *modelOverride) GetWorktreeInfo(sessionName string) (*status.AgentState, error) {
+	agentState, err := m.StateManager.GetWorktreeInfo(sessionName)
+	if err != nil || m.model == "" {
+		return agentState, err
+	}
+	overridden := *agentState
+	overridden.Model = m.model
+	return &overridden, nil
+}
+
+func writeResults(w io.Writer, results []status.RuleResult, decided int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\n  #\tRULE\tPRIORITY\tLINES\tSTATUS\tRESULT")
+	for i, result := range results {
+		marker := " "
+		if i == decided {
+			marker = "→"
+		}
+		lines := "all"
+		if result.Rule.Lines > 0 {
+			lines = strconv.Itoa(result.Rule.Lines)
+		}
+		outcome := fmt.Sprintf("no match in %d lines", result.Scanned)
+		if result.Matched {
+			outcome = fmt.Sprintf("line %d: %s", result.LineNumber, truncate(strings.TrimSpace(result.Line), 60))
+			if result.Error != nil && !result.Error.Fatal() {
+				// Tool and build failures are the agent's work, not its error
+				outcome += fmt.Sprintf(" (%s failure, skipped)", result.Error.Category)
+			}
+		}
+		fmt.Fprintf(tw, "%s %d\t%s\t%d\t%s\t%s\t%s\n", marker, i+1, result.Rule.Name, result.Rule.Priority, lines, result.Rule.Status, outcome)
+	}
+	return tw.Flush()
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
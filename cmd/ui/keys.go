@@ -0,0 +1,102 @@
+package ui
+
+import "unicode/utf8"
+
+// keyKind identifies a key that is not a plain character
+type keyKind int
+
+const (
+	keyRune keyKind = iota
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyPgUp
+	keyPgDn
+	keyHome
+	keyEnd
+	keyEnter
+	keyTab
+	keyBackspace
+	keyEscape
+	keyCtrlC
+)
+
+// key is a single key press
+type key struct {
+	kind keyKind
+	r    rune
+}
+
+// csiKeys maps the final byte of "ESC [ x" and "ESC O x" sequences
+var csiKeys = map[byte]keyKind{
+	'A': keyUp,
+	'B': keyDown,
+	'C': keyRight,
+	'D': keyLeft,
+	'H': keyHome,
+	'F': keyEnd,
+}
+
+// tildeKeys maps the number of "ESC [ n ~" sequences
+var tildeKeys = map[string]keyKind{
+	"1": keyHome,
+	"4": keyEnd,
+	"5": keyPgUp,
+	"6": keyPgDn,
+	"7": keyHome,
+	"8": keyEnd,
+}
+
+// parseKeys splits raw terminal input into key presses. A lone escape byte
+// at the end of the input is the Escape key.
+func parseKeys(input []byte) []key {
+	var keys []key
+	for len(input) > 0 {
+		b := input[0]
+		switch {
+		case b == 0x1b:
+			if len(input) >= 3 && (input[1] == '[' || input[1] == 'O') {
+				if kind, ok := csiKeys[input[2]]; ok {
+					keys = append(keys, key{kind: kind})
+					input = input[3:]
+					continue
+				}
+				// ESC [ <digits> ~
+				end := 2
+				for end < len(input) && input[end] >= '0' && input[end] <= '9' {
+					end++
+				}
+				if end < len(input) && input[end] == '~' {
+					if kind, ok := tildeKeys[string(input[2:end])]; ok {
+						keys = append(keys, key{kind: kind})
+					}
+					input = input[end+1:]
+					continue
+				}
+			}
+			keys = append(keys, key{kind: keyEscape})
+			input = input[1:]
+		case b == '\r' || b == '\n':
+			keys = append(keys, key{kind: keyEnter})
+			input = input[1:]
+		case b == '\t':
+			keys = append(keys, key{kind: keyTab})
+			input = input[1:]
+		case b == 0x7f || b == 0x08:
+			keys = append(keys, key{kind: keyBackspace})
+			input = input[1:]
+		case b == 0x03:
+			keys = append(keys, key{kind: keyCtrlC})
+			input = input[1:]
+		case b < 0x20:
+			// Other control characters are ignored
+			input = input[1:]
+		default:
+			r, size := utf8.DecodeRune(input)
+			keys = append(keys, key{kind: keyRune, r: r})
+			input = input[size:]
+		}
+	}
+	return keys
+}
@@ -0,0 +1,451 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/devflowinc/uzi/pkg/collector"
+	"github.com/devflowinc/uzi/pkg/state"
+	"github.com/devflowinc/uzi/pkg/status"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// mode is what the keyboard currently drives
+type mode int
+
+const (
+	modeNormal mode = iota
+	modeInput
+	modeConfirm
+)
+
+// actionKind is a side effect requested by a key press
+type actionKind int
+
+const (
+	actNone actionKind = iota
+	actQuit
+	actAttach
+	actBroadcast
+	actCheckpoint
+	actKill
+	actRetry
+	actOpen
+)
+
+// action is carried out by the main loop, outside the model
+type action struct {
+	kind     actionKind
+	sessions []string
+	text     string
+}
+
+// detail is the diff and transcript tail of one agent
+type detail struct {
+	session string
+	patch   string
+	tail    string
+}
+
+// model is the dashboard state. It has no side effects: key presses return
+// actions and render only produces text, which keeps it testable.
+type model struct {
+	agents   []collector.Agent
+	selected int
+	marked   map[string]bool
+
+	mode    mode
+	pending action // the action waiting for input or confirmation
+	prompt  string
+	input   []rune
+
+	message    string
+	detail     detail
+	diffScroll int
+	runner     string
+}
+
+func newModel(runnerName string) *model {
+	return &model{marked: make(map[string]bool), runner: runnerName}
+}
+
+// setAgents replaces the agent list, keeping the selection on the same
+// session and dropping marks of agents that are gone
+func (m *model) setAgents(agents []collector.Agent) {
+	current := m.selectedSession()
+	m.agents = agents
+	m.selected = 0
+	live := make(map[string]bool, len(agents))
+	for i, agent := range agents {
+		live[agent.Session] = true
+		if agent.Session == current {
+			m.selected = i
+		}
+	}
+	for session := range m.marked {
+		if !live[session] {
+			delete(m.marked, session)
+		}
+	}
+}
+
+// setDetail shows the diff and transcript of an agent, keeping the scroll
+// position while the same agent stays selected
+func (m *model) setDetail(d detail) {
+	if d.session != m.detail.session {
+		m.diffScroll = 0
+	}
+	m.detail = d
+}
+
+func (m *model) selectedSession() string {
+	if m.selected < 0 || m.selected >= len(m.agents) {
+		return ""
+	}
+	return m.agents[m.selected].Session
+}
+
+func (m *model) selectedAgent() (collector.Agent, bool) {
+	if m.selected < 0 || m.selected >= len(m.agents) {
+		return collector.Agent{}, false
+	}
+	return m.agents[m.selected], true
+}
+
+// targets returns the marked sessions in display order, or the selected
+// session when nothing is marked
+func (m *model) targets() []string {
+	var sessions []string
+	for _, agent := range m.agents {
+		if m.marked[agent.Session] {
+			sessions = append(sessions, agent.Session)
+		}
+	}
+	if len(sessions) == 0 && m.selectedSession() != "" {
+		sessions = []string{m.selectedSession()}
+	}
+	return sessions
+}
+
+// describe names the agents of sessions for prompts and messages
+func describe(sessions []string) string {
+	if len(sessions) == 1 {
+		return state.AgentNameFromSession(sessions[0])
+	}
+	return fmt.Sprintf("%d agents", len(sessions))
+}
+
+// handleKey updates the model for one key press and returns the action it
+// asks for, if any
+func (m *model) handleKey(k key) action {
+	switch m.mode {
+	case modeInput:
+		return m.handleInput(k)
+	case modeConfirm:
+		m.mode = modeNormal
+		if k.kind == keyRune && (k.r == 'y' || k.r == 'Y') {
+			return m.pending
+		}
+		m.message = "Cancelled"
+		return action{}
+	}
+
+	m.message = ""
+	switch {
+	case k.kind == keyCtrlC || k.kind == keyRune && k.r == 'q':
+		return action{kind: actQuit}
+	case k.kind == keyDown || k.kind == keyRune && k.r == 'j':
+		m.selected = min(m.selected+1, len(m.agents)-1)
+	case k.kind == keyUp || k.kind == keyRune && k.r == 'k':
+		m.selected = max(m.selected-1, 0)
+	case k.kind == keyHome || k.kind == keyRune && k.r == 'g':
+		m.selected = 0
+	case k.kind == keyEnd || k.kind == keyRune && k.r == 'G':
+		m.selected = max(len(m.agents)-1, 0)
+	case k.kind == keyPgDn || k.kind == keyRune && k.r == 'J':
+		m.diffScroll += 10
+	case k.kind == keyPgUp || k.kind == keyRune && k.r == 'K':
+		m.diffScroll = max(m.diffScroll-10, 0)
+	}
+	if k.kind != keyRune && k.kind != keyEnter {
+		return action{}
+	}
+
+	session := m.selectedSession()
+	if session == "" {
+		return action{}
+	}
+	switch {
+	case k.kind == keyEnter || k.r == 'a':
+		return action{kind: actAttach, sessions: []string{session}}
+	case k.r == ' ':
+		if m.marked[session] {
+			delete(m.marked, session)
+		} else {
+			m.marked[session] = true
+		}
+		m.selected = min(m.selected+1, len(m.agents)-1)
+	case k.r == '*':
+		if len(m.marked) > 0 {
+			m.marked = make(map[string]bool)
+		} else {
+			for _, agent := range m.agents {
+				m.marked[agent.Session] = true
+			}
+		}
+	case k.r == 'b':
+		targets := m.targets()
+		m.startInput(action{kind: actBroadcast, sessions: targets}, fmt.Sprintf("Broadcast to %s: ", describe(targets)))
+	case k.r == 'c':
+		m.startInput(action{kind: actCheckpoint, sessions: []string{session}}, fmt.Sprintf("Checkpoint %s, commit message: ", describe([]string{session})))
+		// Start from the subject of the agent's completion report
+		if agent, _ := m.selectedAgent(); agent.State.Report != nil {
+			subject, _, _ := strings.Cut(status.CommitMessage(agent.State.Report), "\n")
+			m.input = []rune(subject)
+		}
+	case k.r == 'x':
+		targets := m.targets()
+		m.confirm(action{kind: actKill, sessions: targets}, fmt.Sprintf("Kill %s? This deletes the worktree. (y/n)", describe(targets)))
+	case k.r == 'r':
+		targets := m.targets()
+		m.confirm(action{kind: actRetry, sessions: targets}, fmt.Sprintf("Interrupt %s and rerun the original prompt? (y/n)", describe(targets)))
+	case k.r == 'o':
+		agent, _ := m.selectedAgent()
+		if agent.State.Port == 0 {
+			m.message = "No dev server for " + describe([]string{session})
+			return action{}
+		}
+		return action{kind: actOpen, sessions: []string{session}, text: fmt.Sprintf("http://localhost:%d", agent.State.Port)}
+	}
+	return action{}
+}
+
+func (m *model) startInput(pending action, prompt string) {
+	m.mode = modeInput
+	m.pending = pending
+	m.prompt = prompt
+	m.input = nil
+}
+
+func (m *model) confirm(pending action, prompt string) {
+	m.mode = modeConfirm
+	m.pending = pending
+	m.prompt = prompt
+}
+
+func (m *model) handleInput(k key) action {
+	switch k.kind {
+	case keyRune:
+		m.input = append(m.input, k.r)
+	case keyBackspace:
+		if len(m.input) > 0 {
+			m.input = m.input[:len(m.input)-1]
+		}
+	case keyEscape, keyCtrlC:
+		m.mode = modeNormal
+		m.message = "Cancelled"
+	case keyEnter:
+		m.mode = modeNormal
+		text := strings.TrimSpace(string(m.input))
+		if text == "" {
+			m.message = "Cancelled"
+			return action{}
+		}
+		pending := m.pending
+		pending.text = text
+		return pending
+	}
+	return action{}
+}
+
+// statusColors are the ANSI colors used by "uzi ls" for each status
+var statusColors = map[string]string{
+	status.StatusIdle:    "34",
+	status.StatusReady:   "32",
+	status.StatusRunning: "33",
+	status.StatusWaiting: "35",
+	status.StatusMerged:  "36",
+	status.StatusError:   "31",
+}
+
+// fit truncates or pads s to exactly width terminal cells
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	s = strings.ReplaceAll(s, "\t", "    ")
+	return runewidth.FillRight(runewidth.Truncate(s, width, "…"), width)
+}
+
+// rule draws a horizontal line of width cells with a title
+func rule(title string, width int) string {
+	head := runewidth.Truncate("── "+title+" ", max(width, 0), "")
+	return head + strings.Repeat("─", max(width-runewidth.StringWidth(head), 0))
+}
+
+func style(codes, s string) string {
+	return "\033[" + codes + "m" + s + "\033[0m"
+}
+
+// render draws the whole screen as exactly height lines of width cells
+func (m *model) render(width, height int) []string {
+	lines := make([]string, 0, height)
+	add := func(line string) {
+		if len(lines) < height {
+			lines = append(lines, line)
+		}
+	}
+
+	header := fmt.Sprintf(" uzi ui · %d agents · %s runner", len(m.agents), m.runner)
+	if len(m.marked) > 0 {
+		header += fmt.Sprintf(" · %d marked", len(m.marked))
+	}
+	add(style("7", fit(header, width)))
+
+	// Agent table, scrolled to keep the selection visible
+	tableRows := min(max(len(m.agents), 1), max(3, (height-4)/3))
+	add(style("1", fit(fmt.Sprintf("   %-16s %-9s %-12s %-12s %-22s %s", "AGENT", "STATUS", "DIFF", "DRIFT", "ADDR", "PROMPT"), width)))
+	if len(m.agents) == 0 {
+		add(fit("   No active sessions found", width))
+	}
+	offset := max(0, min(m.selected-tableRows+1, len(m.agents)-tableRows))
+	for i := offset; i < len(m.agents) && i < offset+tableRows; i++ {
+		add(m.renderRow(i, width))
+	}
+
+	// Diff and transcript panes side by side
+	paneTop := len(lines)
+	// A short terminal leaves no room for the panes below the table
+	paneRows := max(height-paneTop-2, 0)
+	leftWidth := width / 2
+	rightWidth := width - leftWidth - 1
+	name := "-"
+	if session := m.selectedSession(); session != "" {
+		name = state.AgentNameFromSession(session)
+	}
+	add(style("2", rule("diff: "+name, leftWidth)+"┬"+rule("transcript", rightWidth)))
+
+	patch := strings.Split(strings.TrimRight(m.detail.patch, "\n"), "\n")
+	if m.detail.patch == "" {
+		patch = []string{"(no uncommitted changes)"}
+	}
+	if m.diffScroll > max(len(patch)-1, 0) {
+		m.diffScroll = max(len(patch)-1, 0)
+	}
+	patch = patch[m.diffScroll:]
+	tail := strings.Split(strings.TrimRight(m.detail.tail, "\n"), "\n")
+	if len(tail) > paneRows {
+		tail = tail[len(tail)-paneRows:]
+	}
+	for row := 0; row < paneRows; row++ {
+		var left, right string
+		if row < len(patch) {
+			left = diffLine(fit(patch[row], leftWidth))
+		} else {
+			left = fit("", leftWidth)
+		}
+		if row < len(tail) {
+			right = fit(tail[row], rightWidth)
+		} else {
+			right = fit("", rightWidth)
+		}
+		add(left + style("2", "│") + right)
+	}
+
+	for len(lines) < height-1 {
+		add("")
+	}
+	add(m.footer(width))
+	return lines
+}
+
+func (m *model) renderRow(i, width int) string {
+	agent := m.agents[i]
+	marker := ' '
+	if m.marked[agent.Session] {
+		marker = '*'
+	}
+	cursor := ' '
+	if i == m.selected {
+		cursor = '>'
+	}
+
+	st := collector.StatusOf(agent)
+	if agent.StatusErr == nil && agent.Status.IsStuck {
+		st += "!"
+	}
+	diff := "-"
+	if agent.Diff != nil {
+		diff = fmt.Sprintf("+%d/-%d", agent.Diff.Insertions, agent.Diff.Deletions)
+	}
+	drift := "-"
+	if agent.Drift != nil {
+		drift = fmt.Sprintf("↑%d ↓%d", agent.Drift.Ahead, agent.Drift.Behind)
+		if agent.Drift.Conflicts {
+			drift += " ✗"
+		} else if agent.Drift.ConflictsUnknown {
+			drift += " ?"
+		}
+	}
+	addr := "-"
+	if agent.State.Port != 0 {
+		addr = fmt.Sprintf("http://localhost:%d", agent.State.Port)
+	}
+
+	// Waiting agents show what they are asking and failed agents their
+	// error instead of their prompt
+	prompt := agent.State.Prompt
+	if agent.StatusErr == nil && collector.StatusOf(agent) == status.StatusWaiting && agent.Status.Question != nil {
+		prompt = "? " + agent.Status.Question.String()
+	}
+	if last := agent.State.LastError; last != nil && collector.StatusOf(agent) == status.StatusError {
+		prompt = fmt.Sprintf("✗ [%s] %s", last.Category, last.Excerpt)
+	}
+
+	row := fmt.Sprintf("%c%c %s %s %s %s %s %s", cursor, marker,
+		fit(state.AgentNameFromSession(agent.Session), 16), fit(st, 9), fit(diff, 12), fit(drift, 12), fit(addr, 22),
+		strings.ReplaceAll(prompt, "\n", " "))
+	row = fit(row, width)
+	if i == m.selected {
+		return style("7", row)
+	}
+	if color, ok := statusColors[collector.StatusOf(agent)]; ok {
+		// Color just the status column, which starts after the name
+		prefix := runewidth.Truncate(row, 20, "")
+		rest := strings.TrimPrefix(row, prefix)
+		statusCell := runewidth.Truncate(rest, 9, "")
+		return prefix + style(color, statusCell) + strings.TrimPrefix(rest, statusCell)
+	}
+	return row
+}
+
+// diffLine colors a line of a unified diff
+func diffLine(line string) string {
+	switch {
+	case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"), strings.HasPrefix(line, "diff "):
+		return style("1", line)
+	case strings.HasPrefix(line, "+"):
+		return style("32", line)
+	case strings.HasPrefix(line, "-"):
+		return style("31", line)
+	case strings.HasPrefix(line, "@@"):
+		return style("36", line)
+	}
+	return line
+}
+
+const helpText = " ↑↓ select  space mark  a attach  b broadcast  c checkpoint  x kill  r retry  o open  J/K scroll diff  q quit"
+
+func (m *model) footer(width int) string {
+	switch m.mode {
+	case modeInput:
+		return fit(m.prompt+string(m.input)+"█", width)
+	case modeConfirm:
+		return style("33", fit(m.prompt, width))
+	}
+	if m.message != "" {
+		return style("1", fit(" "+m.message, width))
+	}
+	return style("2", fit(helpText, width))
+}
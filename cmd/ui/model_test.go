@@ -0,0 +1,216 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/devflowinc/uzi/pkg/collector"
+	"github.com/devflowinc/uzi/pkg/state"
+	"github.com/devflowinc/uzi/pkg/status"
+
+	"github.com/mattn/go-runewidth"
+)
+
+func TestParseKeys(t *testing.T) {
+	got := parseKeys([]byte("j\x1b[A\x1bOB\x1b[5~\r\x7f\x03é\x1b"))
+	want := []key{
+		{kind: keyRune, r: 'j'},
+		{kind: keyUp},
+		{kind: keyDown},
+		{kind: keyPgUp},
+		{kind: keyEnter},
+		{kind: keyBackspace},
+		{kind: keyCtrlC},
+		{kind: keyRune, r: 'é'},
+		{kind: keyEscape},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseKeys() = %v, want %v", got, want)
+	}
+}
+
+func testModel() *model {
+	m := newModel("tmux")
+	m.setAgents([]collector.Agent{
+		{Session: "agent-proj-abc123-alice", State: state.AgentState{Model: "claude", Prompt: "fix it", Port: 3000},
+			Status: status.DetailedStatus{Status: status.StatusRunning}},
+		{Session: "agent-proj-abc123-bob", State: state.AgentState{Model: "codex", Prompt: "test it"},
+			Status: status.DetailedStatus{Status: status.StatusReady}},
+		{Session: "agent-proj-abc123-carol", State: state.AgentState{Model: "claude", Prompt: "doc it"},
+			Status: status.DetailedStatus{Status: status.StatusIdle}},
+	})
+	return m
+}
+
+func press(m *model, input string) action {
+	var last action
+	for _, k := range parseKeys([]byte(input)) {
+		if act := m.handleKey(k); act.kind != actNone {
+			last = act
+		}
+	}
+	return last
+}
+
+func TestNavigationAndMarks(t *testing.T) {
+	m := testModel()
+	press(m, "jjj")
+	if m.selected != 2 {
+		t.Errorf("selected = %d after moving past the end, want 2", m.selected)
+	}
+	press(m, "g")
+	if m.selected != 0 {
+		t.Errorf("selected = %d after g, want 0", m.selected)
+	}
+
+	// Space marks and moves down; targets follow display order
+	press(m, "j  ")
+	if got := m.targets(); !reflect.DeepEqual(got, []string{"agent-proj-abc123-bob", "agent-proj-abc123-carol"}) {
+		t.Errorf("targets() = %v", got)
+	}
+
+	// Marks and selection survive a refresh that drops an agent
+	m.setAgents(m.agents[1:])
+	if m.selectedSession() != "agent-proj-abc123-carol" || len(m.marked) != 2 {
+		t.Errorf("after refresh selected %q with %d marks", m.selectedSession(), len(m.marked))
+	}
+	m.setAgents(m.agents[:1])
+	if m.selectedSession() != "agent-proj-abc123-bob" || len(m.marked) != 1 {
+		t.Errorf("after removal selected %q with %d marks", m.selectedSession(), len(m.marked))
+	}
+}
+
+func TestBroadcastInput(t *testing.T) {
+	m := testModel()
+	press(m, " ")
+	press(m, " ")
+
+	act := press(m, "bhellp\x7fo\r")
+	if act.kind != actBroadcast || act.text != "hello" {
+		t.Fatalf("broadcast action = %+v", act)
+	}
+	if len(act.sessions) != 2 {
+		t.Errorf("broadcast to %v, want the two marked agents", act.sessions)
+	}
+	if m.mode != modeNormal {
+		t.Error("still in input mode after enter")
+	}
+
+	if act := press(m, "bhi\x1b"); act.kind != actNone || m.message != "Cancelled" {
+		t.Errorf("escape gave %+v, message %q", act, m.message)
+	}
+}
+
+func TestCheckpointPrefillsReport(t *testing.T) {
+	m := testModel()
+	m.agents[1].State.Report = &state.CompletionReport{Summary: "Add tests\nCovers the parser."}
+
+	if act := press(m, "c\r"); act.kind != actNone {
+		t.Errorf("checkpoint without a message = %+v", act)
+	}
+	act := press(m, "jc!\r")
+	if act.kind != actCheckpoint || act.text != "Add tests!" {
+		t.Errorf("checkpoint = %+v, want the report subject", act)
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	m := testModel()
+	if act := press(m, "xn"); act.kind != actNone {
+		t.Errorf("kill without confirmation returned %+v", act)
+	}
+	act := press(m, "xy")
+	if act.kind != actKill || !reflect.DeepEqual(act.sessions, []string{"agent-proj-abc123-alice"}) {
+		t.Errorf("confirmed kill = %+v", act)
+	}
+	if act := press(m, "ry"); act.kind != actRetry {
+		t.Errorf("confirmed retry = %+v", act)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	m := testModel()
+	if act := press(m, "o"); act.kind != actOpen || act.text != "http://localhost:3000" {
+		t.Errorf("open = %+v", act)
+	}
+	if act := press(m, "jo"); act.kind != actNone || !strings.Contains(m.message, "No dev server") {
+		t.Errorf("open without a port = %+v, message %q", act, m.message)
+	}
+}
+
+func TestRender(t *testing.T) {
+	m := testModel()
+	m.setDetail(detail{
+		session: "agent-proj-abc123-alice",
+		patch:   "diff --git a/x b/x\n+added 日本語\n-removed\n",
+		tail:    "line one\nline\ttwo\n",
+	})
+
+	for _, size := range [][2]int{{80, 24}, {120, 40}, {40, 10}} {
+		lines := m.render(size[0], size[1])
+		if len(lines) != size[1] {
+			t.Fatalf("render(%d, %d) returned %d lines", size[0], size[1], len(lines))
+		}
+		for i, line := range lines {
+			if w := runewidth.StringWidth(stripStyles(line)); w > size[0] {
+				t.Errorf("render(%d, %d) line %d is %d cells wide: %q", size[0], size[1], i, w, line)
+			}
+		}
+	}
+
+	screen := stripStyles(strings.Join(m.render(100, 24), "\n"))
+	for _, want := range []string{"alice", "running", "+added 日本語", "line    two", "diff: alice"} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("screen does not contain %q:\n%s", want, screen)
+		}
+	}
+
+	empty := newModel("pty")
+	if screen := stripStyles(strings.Join(empty.render(80, 24), "\n")); !strings.Contains(screen, "No active sessions found") {
+		t.Errorf("empty screen:\n%s", screen)
+	}
+}
+
+func TestRenderTinyTerminal(t *testing.T) {
+	m := testModel()
+	m.setDetail(detail{session: "agent-proj-abc123-alice", patch: "+x\n", tail: "one\ntwo\nthree\n"})
+	for height := 0; height <= 6; height++ {
+		if lines := m.render(40, height); len(lines) != height {
+			t.Errorf("render(40, %d) returned %d lines", height, len(lines))
+		}
+	}
+}
+
+// stripStyles removes the SGR sequences render adds
+func stripStyles(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == 0x1b {
+			for i < len(s) && s[i] != 'm' {
+				i++
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func TestTailFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.log")
+	os.WriteFile(path, []byte("first line\n\x1b[32mgreen\x1b[0m\nlast\n"), 0644)
+
+	if got := tailFile(path, 1024); got != "first line\ngreen\nlast\n" {
+		t.Errorf("tailFile() = %q", got)
+	}
+	// A partial first line is dropped
+	if got := tailFile(path, 10); got != "last\n" {
+		t.Errorf("tailFile() with a small window = %q", got)
+	}
+	if got := tailFile(filepath.Join(t.TempDir(), "missing"), 1024); got != "" {
+		t.Errorf("tailFile() of a missing file = %q", got)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !linux && !darwin
+
+package ui
+
+import (
+	"errors"
+	"os"
+)
+
+var errUnsupported = errors.New("uzi ui is only supported on linux and macOS")
+
+func rawMode(fd int) (restore func() error, err error) {
+	return nil, errUnsupported
+}
+
+func termSize(fd int) (int, int, error) {
+	return 0, 0, errUnsupported
+}
+
+func notifyResize(ch chan<- os.Signal) {}
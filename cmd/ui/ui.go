@@ -0,0 +1,470 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devflowinc/uzi/cmd/attach"
+	"github.com/devflowinc/uzi/pkg/agents"
+	"github.com/devflowinc/uzi/pkg/collector"
+	"github.com/devflowinc/uzi/pkg/gitdiff"
+	"github.com/devflowinc/uzi/pkg/runner"
+	"github.com/devflowinc/uzi/pkg/state"
+	"github.com/devflowinc/uzi/pkg/transcript"
+
+	"github.com/charmbracelet/log"
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+var (
+	fs       = flag.NewFlagSet("uzi ui", flag.ExitOnError)
+	interval = fs.Duration("interval", 2*time.Second, "how often to refresh agent status")
+	CmdUI    = &ffcli.Command{
+		Name:       "ui",
+		ShortUsage: "uzi ui [-interval 2s]",
+		ShortHelp:  "Interactive dashboard for managing agents",
+		LongHelp: `
+Shows the agents of the current repository with live status, the diff of the
+selected agent and the tail of its transcript.
+
+Keys:
+  j/k, arrows   select an agent          space   mark or unmark an agent
+  a, enter      attach (tmux runner)     *       mark or unmark all agents
+  b             broadcast a message to the marked agents, or the selected one
+  c             checkpoint the selected agent with a commit message
+  x             kill the marked agents, or the selected one
+  r             interrupt the agent and rerun its original prompt
+  o             open the dev server in a browser
+  J/K, pgdn/up  scroll the diff          q       quit
+`,
+		FlagSet: fs,
+		Exec:    executeUI,
+	}
+)
+
+// transcriptTailBytes is how much of the end of a transcript is read for the
+// transcript pane
+const transcriptTailBytes = 32 * 1024
+
+var (
+	// retryPoll is how often retry checks whether the agent has exited
+	retryPoll = 200 * time.Millisecond
+	// retryInterrupt is how long retry waits before interrupting again
+	retryInterrupt = time.Second
+	// retryTimeout is how long retry waits for the shell before giving up
+	retryTimeout = 5 * time.Second
+)
+
+// snapshot is the result of one background refresh
+type snapshot struct {
+	agents []collector.Agent
+	detail detail
+	err    error
+}
+
+// terminal owns the screen and keyboard while the dashboard runs. The key
+// reader holds mu during each read, so suspend can take the terminal away
+// from it for "tmux attach".
+type terminal struct {
+	fd      int
+	restore func() error
+	mu      sync.Mutex
+}
+
+func (t *terminal) enter() error {
+	restore, err := rawMode(t.fd)
+	if err != nil {
+		return err
+	}
+	t.restore = restore
+	// Alternate screen, hidden cursor
+	fmt.Fprint(os.Stdout, "\033[?1049h\033[?25l")
+	return nil
+}
+
+func (t *terminal) leave() {
+	fmt.Fprint(os.Stdout, "\033[?25h\033[?1049l")
+	if t.restore != nil {
+		t.restore()
+	}
+}
+
+// suspend hands the terminal to fn and takes it back afterwards
+func (t *terminal) suspend(fn func() error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.leave()
+	fnErr := fn()
+	if err := t.enter(); err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// readKeys sends key presses to keys until ctx is cancelled. Raw mode makes
+// reads time out, which gives suspend a chance to take the terminal.
+func (t *terminal) readKeys(ctx context.Context, keys chan<- []key) {
+	buf := make([]byte, 256)
+	for ctx.Err() == nil {
+		t.mu.Lock()
+		n, err := os.Stdin.Read(buf)
+		t.mu.Unlock()
+		if n > 0 {
+			select {
+			case keys <- parseKeys(buf[:n]):
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return
+		}
+	}
+}
+
+func (t *terminal) draw(m *model) {
+	width, height, err := termSize(t.fd)
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+	var b strings.Builder
+	b.WriteString("\033[H")
+	for i, line := range m.render(width, height) {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(line)
+		b.WriteString("\033[K")
+	}
+	os.Stdout.WriteString(b.String())
+}
+
+// dashboard ties the model to the terminal and to the agents it controls
+type dashboard struct {
+	sm        *state.StateManager
+	runner    runner.Runner
+	collector *collector.Collector
+	term      *terminal
+	model     *model
+}
+
+// load collects the agents of the current repository and the diff and
+// transcript of the one named by session, or of the first agent
+func (d *dashboard) load(session string) snapshot {
+	sessions, err := d.sm.GetActiveSessionsForRepo()
+	if err != nil {
+		return snapshot{err: fmt.Errorf("error getting active sessions: %w", err)}
+	}
+	var list []collector.Agent
+	if len(sessions) > 0 {
+		list, err = d.collector.Collect(d.sm, sessions)
+		if err != nil {
+			return snapshot{err: err}
+		}
+	}
+
+	snap := snapshot{agents: list}
+	selected := -1
+	for i, agent := range list {
+		if agent.Session == session {
+			selected = i
+		}
+	}
+	if selected < 0 && len(list) > 0 {
+		selected = 0
+	}
+	if selected >= 0 {
+		snap.detail = loadDetail(list[selected])
+	}
+	return snap
+}
+
+func loadDetail(agent collector.Agent) detail {
+	d := detail{session: agent.Session}
+	if agent.State.WorktreePath != "" {
+		patch, err := gitdiff.Patch(agent.State.WorktreePath)
+		if err != nil {
+			patch = fmt.Sprintf("error reading diff: %v", err)
+		}
+		d.patch = patch
+	}
+	if path, err := transcript.Path(agent.Session, "agent"); err == nil {
+		d.tail = tailFile(path, transcriptTailBytes)
+	}
+	return d
+}
+
+// tailFile returns the last complete lines within the final size bytes of
+// path
+func tailFile(path string, size int64) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return ""
+	}
+	offset := max(info.Size()-size, 0)
+	data := make([]byte, info.Size()-offset)
+	n, _ := file.ReadAt(data, offset)
+	text := string(data[:n])
+	if offset > 0 {
+		// Drop the partial first line
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			text = text[i+1:]
+		}
+	}
+
+	return transcript.StripANSI(text)
+}
+
+func executeUI(ctx context.Context, args []string) error {
+	sm := state.NewStateManager()
+	if sm == nil {
+		return fmt.Errorf("could not initialize state manager")
+	}
+
+	term := &terminal{fd: int(os.Stdin.Fd())}
+	if err := term.enter(); err != nil {
+		return fmt.Errorf("uzi ui needs an interactive terminal: %w", err)
+	}
+	defer term.leave()
+
+	agentRunner := runner.Default()
+	d := &dashboard{
+		sm:        sm,
+		runner:    agentRunner,
+		collector: collector.New(collector.DefaultWorkers),
+		term:      term,
+		model:     newModel(agentRunner.Name()),
+	}
+	return d.run(ctx)
+}
+
+func (d *dashboard) run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	keys := make(chan []key)
+	go d.term.readKeys(ctx, keys)
+
+	// Refreshes run in the background so slow git commands never block
+	// the keyboard; only the latest request matters
+	requests := make(chan string, 1)
+	snapshots := make(chan snapshot)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case session := <-requests:
+				snap := d.load(session)
+				select {
+				case snapshots <- snap:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	refresh := func() {
+		select {
+		case <-requests:
+		default:
+		}
+		requests <- d.model.selectedSession()
+	}
+
+	resize := make(chan os.Signal, 1)
+	notifyResize(resize)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	refresh()
+	for {
+		d.term.draw(d.model)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-resize:
+		case <-ticker.C:
+			refresh()
+		case snap := <-snapshots:
+			if snap.err != nil {
+				d.model.message = snap.err.Error()
+				continue
+			}
+			d.model.setAgents(snap.agents)
+			if snap.detail.session == d.model.selectedSession() {
+				d.model.setDetail(snap.detail)
+			}
+		case pressed := <-keys:
+			previous := d.model.selectedSession()
+			for _, k := range pressed {
+				act := d.model.handleKey(k)
+				if act.kind == actQuit {
+					return nil
+				}
+				if act.kind != actNone {
+					d.model.message = d.perform(ctx, act)
+					refresh()
+				}
+			}
+			if d.model.selectedSession() != previous {
+				refresh()
+			}
+		}
+	}
+}
+
+// perform carries out an action and returns the message to show for it
+func (d *dashboard) perform(ctx context.Context, act action) string {
+	name := describe(act.sessions)
+	switch act.kind {
+	case actAttach:
+		if d.runner.Name() != runner.RunnerTmux {
+			return fmt.Sprintf("Attaching requires the tmux runner (current runner: %s)", d.runner.Name())
+		}
+		err := d.term.suspend(func() error {
+			return attach.AttachSession(ctx, act.sessions[0])
+		})
+		if err != nil {
+			return err.Error()
+		}
+		return "Detached from " + name
+
+	case actBroadcast:
+		failed := 0
+		for _, session := range act.sessions {
+			if err := d.runner.SendKeys(ctx, session, "agent", act.text, "Enter"); err != nil {
+				log.Debug("Failed to send message to session", "session", session, "error", err)
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Sprintf("Failed to send the message to %d of %s", failed, name)
+		}
+		return "Sent the message to " + name
+
+	case actCheckpoint:
+		d.showBusy("Checkpointing " + name + "…")
+		agentName := state.AgentNameFromSession(act.sessions[0])
+		if err := runUzi(ctx, "checkpoint", agentName, act.text); err != nil {
+			return err.Error()
+		}
+		return "Checkpointed " + name
+
+	case actKill:
+		d.showBusy("Killing " + name + "…")
+		for _, session := range act.sessions {
+			if err := runUzi(ctx, "kill", state.AgentNameFromSession(session)); err != nil {
+				return err.Error()
+			}
+		}
+		return "Killed " + name
+
+	case actRetry:
+		states := make(map[string]state.AgentState)
+		for _, agent := range d.model.agents {
+			states[agent.Session] = agent.State
+		}
+		for _, session := range act.sessions {
+			agentState := states[session]
+			if agentState.Model == "" || agentState.Prompt == "" {
+				return "No saved command and prompt for " + describe([]string{session})
+			}
+			if err := d.retry(ctx, session, agentState); err != nil {
+				return err.Error()
+			}
+		}
+		return "Restarted " + name
+
+	case actOpen:
+		if err := openURL(act.text); err != nil {
+			return err.Error()
+		}
+		return "Opened " + act.text
+	}
+	return ""
+}
+
+// retry interrupts the agent command and runs it again with its prompt.
+// Claude only exits on a second Ctrl-C, so the agent is interrupted until
+// the shell is back in the foreground of its window; the command is typed
+// only then, never into the agent's own input.
+func (d *dashboard) retry(ctx context.Context, session string, agentState state.AgentState) error {
+	deadline := time.Now().Add(retryTimeout)
+	var interrupted time.Time
+	for {
+		current, err := d.runner.CurrentCommand(ctx, session, "agent")
+		if err != nil {
+			return fmt.Errorf("could not tell whether %s exited: %w", describe([]string{session}), err)
+		}
+		if runner.IsShell(current) {
+			break
+		}
+		now := time.Now()
+		if now.After(deadline) {
+			return fmt.Errorf("%s is still running in %s; stop it and retry", current, describe([]string{session}))
+		}
+		if now.Sub(interrupted) >= retryInterrupt {
+			if err := d.runner.SendKeys(ctx, session, "agent", "C-c"); err != nil {
+				return fmt.Errorf("failed to interrupt %s: %w", session, err)
+			}
+			interrupted = now
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryPoll):
+		}
+	}
+
+	command := agents.LaunchCommand(agentState.Model, agentState.Prompt)
+	if err := d.runner.SendKeys(ctx, session, "agent", command, "C-m"); err != nil {
+		return fmt.Errorf("failed to restart %s: %w", session, err)
+	}
+	return nil
+}
+
+// showBusy draws a message before a slow action blocks the loop
+func (d *dashboard) showBusy(message string) {
+	d.model.message = message
+	d.term.draw(d.model)
+}
+
+// runUzi runs another uzi command and turns its output into the error
+func runUzi(ctx context.Context, args ...string) error {
+	output, err := exec.CommandContext(ctx, os.Args[0], args...).CombinedOutput()
+	if err != nil {
+		lines := strings.Split(strings.TrimSpace(transcript.StripANSI(string(output))), "\n")
+		return fmt.Errorf("uzi %s failed: %s", args[0], lines[len(lines)-1])
+	}
+	return nil
+}
+
+func openURL(url string) error {
+	opener := "xdg-open"
+	if runtime.GOOS == "darwin" {
+		opener = "open"
+	}
+	cmd := exec.Command(opener, url)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to open %s: %w", url, err)
+	}
+	go cmd.Wait()
+	return nil
+}
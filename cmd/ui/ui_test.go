@@ -0,0 +1,82 @@
+package ui
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devflowinc/uzi/pkg/runner"
+	"github.com/devflowinc/uzi/pkg/state"
+)
+
+// fakeRunner runs an agent that exits after a number of Ctrl-Cs
+type fakeRunner struct {
+	runner.Runner
+	mu sync.Mutex
+	// exitAfter is how many Ctrl-Cs the agent needs to exit; 0 never exits
+	exitAfter  int
+	interrupts int
+	sent       [][]string
+}
+
+func (f *fakeRunner) SendKeys(ctx context.Context, session, window string, keys ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if slices.Equal(keys, []string{"C-c"}) {
+		f.interrupts++
+	}
+	f.sent = append(f.sent, keys)
+	return nil
+}
+
+func (f *fakeRunner) CurrentCommand(ctx context.Context, session, window string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.exitAfter > 0 && f.interrupts >= f.exitAfter {
+		return "bash", nil
+	}
+	return "claude", nil
+}
+
+func fastRetry(t *testing.T) {
+	poll, interrupt, timeout := retryPoll, retryInterrupt, retryTimeout
+	retryPoll, retryInterrupt, retryTimeout = time.Millisecond, 5*time.Millisecond, 200*time.Millisecond
+	t.Cleanup(func() { retryPoll, retryInterrupt, retryTimeout = poll, interrupt, timeout })
+}
+
+func TestRetryWaitsForShell(t *testing.T) {
+	fastRetry(t)
+	fake := &fakeRunner{exitAfter: 2}
+	d := &dashboard{runner: fake}
+	agentState := state.AgentState{Model: "claude", Prompt: "fix the bug"}
+
+	if err := d.retry(context.Background(), "agent-repo-abc-john", agentState); err != nil {
+		t.Fatalf("retry() error = %v", err)
+	}
+	if len(fake.sent) != 3 || !slices.Equal(fake.sent[0], []string{"C-c"}) || !slices.Equal(fake.sent[1], []string{"C-c"}) {
+		t.Fatalf("sent %q, want two interrupts and the command", fake.sent)
+	}
+	if last := fake.sent[2]; len(last) != 2 || !strings.HasPrefix(last[0], "claude") || last[1] != "C-m" {
+		t.Errorf("command = %q", last)
+	}
+}
+
+func TestRetryGivesUpWithoutShell(t *testing.T) {
+	fastRetry(t)
+	fake := &fakeRunner{}
+	d := &dashboard{runner: fake}
+	agentState := state.AgentState{Model: "claude", Prompt: "fix the bug"}
+
+	err := d.retry(context.Background(), "agent-repo-abc-john", agentState)
+	if err == nil || !strings.Contains(err.Error(), "still running in john") {
+		t.Fatalf("retry() error = %v", err)
+	}
+	for _, keys := range fake.sent {
+		if !slices.Equal(keys, []string{"C-c"}) {
+			t.Errorf("typed %q into the running agent", keys)
+		}
+	}
+}
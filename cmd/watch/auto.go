@@ -4,248 +4,525 @@ import (
 	"bytes"
 	"context"
 	"crypto/sha256"
-	"flag"
+	"encoding/hex"
 	"fmt"
 	"os"
-	"os/exec"
-	"os/signal"
-	"strings"
+	"path/filepath"
 	"sync"
-	"syscall"
 	"time"
 
+	"github.com/devflowinc/uzi/pkg/agents"
+	"github.com/devflowinc/uzi/pkg/autorespond"
+	"github.com/devflowinc/uzi/pkg/collector"
+	"github.com/devflowinc/uzi/pkg/notification"
+	"github.com/devflowinc/uzi/pkg/runner"
 	"github.com/devflowinc/uzi/pkg/state"
+	"github.com/devflowinc/uzi/pkg/status"
+	"github.com/devflowinc/uzi/pkg/tmux"
 
 	"github.com/charmbracelet/log"
-	"github.com/peterbourgon/ff/v3/ffcli"
 )
 
+const (
+	// checkInterval is how often every watched screen is read
+	checkInterval = 500 * time.Millisecond
+	// refreshInterval is how often the list of active sessions is reloaded
+	refreshInterval = 5 * time.Second
+	// errorBackoff is how long a session is left alone after its screen
+	// could not be read
+	errorBackoff = 2 * time.Second
+	// exitConfirm is how long the shell must stay in the foreground of the
+	// agent window before the agent counts as exited; it also covers the
+	// moment between a launch being typed and the agent starting
+	exitConfirm = 5 * time.Second
+)
+
+// ScreenClient reads and types into agent windows and tells whether the
+// agent still runs in them; runner.Default() in production and a fake in
+// tests
+type ScreenClient interface {
+	ReadScreen(ctx context.Context, session, window string) (string, error)
+	SendKeys(ctx context.Context, session, window string, keys ...string) error
+	CurrentCommand(ctx context.Context, session, window string) (string, error)
+}
+
+// SessionSource lists the sessions to watch and the state of their agents,
+// and records the restarts and nudges of agents
+type SessionSource interface {
+	GetActiveSessionsForRepo() ([]string, error)
+	GetWorktreeInfo(sessionName string) (*state.AgentState, error)
+	RecordRestart(sessionName string, restart state.AgentRestart) error
+	RecordNudge(sessionName string, nudge state.AgentNudge) error
+	UpdateStatusHistories(update func(sessionName string, history *state.StatusHistory) (state.StatusHistory, bool)) error
+	SaveAgentErrors(errs map[string]state.AgentError) error
+}
+
+// AgentWatcher runs one worker per active session. Start owns the set of
+// workers: it starts a worker for each new session, cancels and waits for
+// the workers of sessions that went away, and fans a single ticker out to
+// all of them.
 type AgentWatcher struct {
-	stateManager    *state.StateManager
-	watchedSessions map[string]*SessionMonitor
-	mu              sync.RWMutex
-	quit            chan bool
+	sessions SessionSource
+	screens  ScreenClient
+	policy   *autorespond.Policy
+	audit    *autorespond.Audit
+	// rules holds the status rules and the restart and nudge policies of
+	// each agent command
+	rules *status.Rules
+	// notify tells the manager about a prompt left for a human
+	notify func(sessionName, prompt, reason string) error
+	// notifyError tells the manager about an agent that the watcher gave
+	// up on: it exited too often or stayed idle after every nudge
+	notifyError func(sessionName, message string) error
+	// announce tells the manager that an agent started waiting for input
+	// or failed
+	announce func(sessionName string, st status.DetailedStatus) error
+
+	checkInterval   time.Duration
+	refreshInterval time.Duration
+	exitConfirm     time.Duration
+
+	mu      sync.Mutex
+	workers map[string]*sessionWorker
+	wg      sync.WaitGroup
+}
+
+// sessionWorker watches one session. Its fields other than ticks, cancel
+// and done are only touched by the worker's own goroutine.
+type sessionWorker struct {
+	session string
+	// ticks has room for one tick, so a worker busy with a slow screen
+	// skips ticks instead of queueing them
+	ticks  chan struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	screenHash  []byte
+	lastChanged time.Time
+	retryAt     time.Time
+	failing     bool
+	// handled identifies the prompt last answered or escalated by its rule
+	// and a hash of the screen down to it. It is acted on once, not on every
+	// check while it stays on the screen.
+	handled string
+	// exitedAt is when the shell was first seen in the foreground of the
+	// agent window, zero while the agent runs
+	exitedAt time.Time
+	// exitHandled is set once an exit has been left alone, so that it is
+	// logged and notified once
+	exitHandled bool
 }
 
-type SessionMonitor struct {
-	sessionName    string
-	prevOutputHash []byte
-	lastUpdated    time.Time
-	updateCount    int
-	noUpdateCount  int
+func NewAgentWatcher(policy *autorespond.Policy, rules *status.Rules) *AgentWatcher {
+	return newAgentWatcher(policy, rules, state.NewStateManager(), runner.Default())
 }
 
-func NewAgentWatcher() *AgentWatcher {
+func newAgentWatcher(policy *autorespond.Policy, rules *status.Rules, sessions SessionSource, screens ScreenClient) *AgentWatcher {
 	return &AgentWatcher{
-		stateManager:    state.NewStateManager(),
-		watchedSessions: make(map[string]*SessionMonitor),
-		quit:            make(chan bool),
+		sessions:        sessions,
+		screens:         screens,
+		policy:          policy,
+		audit:           autorespond.NewAudit(policy.AuditPath),
+		rules:           rules,
+		notify:          notifyEscalation,
+		notifyError:     notifyError,
+		announce:        collector.Announce,
+		checkInterval:   checkInterval,
+		refreshInterval: refreshInterval,
+		exitConfirm:     exitConfirm,
+		workers:         make(map[string]*sessionWorker),
 	}
 }
 
-func (aw *AgentWatcher) hashContent(content []byte) []byte {
-	hash := sha256.Sum256(content)
-	return hash[:]
+func notifyEscalation(sessionName, prompt, reason string) error {
+	client := notification.NewNotificationClient(notification.DefaultPort, sessionName, state.AgentNameFromSession(sessionName))
+	return client.NotifyEscalation(prompt, reason)
 }
 
-func (aw *AgentWatcher) capturePaneContent(sessionName string) (string, error) {
-	cmd := exec.Command("tmux", "capture-pane", "-t", sessionName+":agent", "-p")
-	output, err := cmd.Output()
-	if err != nil {
-		return "", err
-	}
-	return string(output), nil
+func notifyError(sessionName, message string) error {
+	client := notification.NewNotificationClient(notification.DefaultPort, sessionName, state.AgentNameFromSession(sessionName))
+	return client.NotifyError(message, nil)
 }
 
-func (aw *AgentWatcher) sendKeys(sessionName string, keys string) error {
-	cmd := exec.Command("tmux", "send-keys", "-t", sessionName+":agent", keys)
-	return cmd.Run()
-}
+// Start watches the active sessions until ctx is cancelled, and returns
+// once every worker has stopped
+func (aw *AgentWatcher) Start(ctx context.Context) {
+	log.Info("Starting Agent Watcher")
 
-func (aw *AgentWatcher) tapEnter(sessionName string) error {
-	return aw.sendKeys(sessionName, "Enter")
+	checkTicker := time.NewTicker(aw.checkInterval)
+	defer checkTicker.Stop()
+	refreshTicker := time.NewTicker(aw.refreshInterval)
+	defer refreshTicker.Stop()
+
+	if err := aw.refresh(ctx); err != nil {
+		log.Error("Failed initial session refresh", "error", err)
+	}
+	for {
+		select {
+		case <-checkTicker.C:
+			aw.tick()
+		case <-refreshTicker.C:
+			if err := aw.refresh(ctx); err != nil {
+				log.Error("Failed to refresh active sessions", "error", err)
+			}
+		case <-ctx.Done():
+			log.Info("Shutting down Agent Watcher")
+			aw.mu.Lock()
+			for _, w := range aw.workers {
+				w.cancel()
+			}
+			aw.mu.Unlock()
+			aw.wg.Wait()
+			return
+		}
+	}
 }
 
-func (aw *AgentWatcher) hasUpdated(sessionName string) (bool, bool, error) {
-	content, err := aw.capturePaneContent(sessionName)
+// refresh starts workers for new sessions and stops the workers of sessions
+// that are no longer active. A stopped worker has exited before refresh
+// returns, so a session that comes back never has two workers.
+func (aw *AgentWatcher) refresh(ctx context.Context) error {
+	activeSessions, err := aw.sessions.GetActiveSessionsForRepo()
 	if err != nil {
-		return false, false, err
+		return fmt.Errorf("failed to get active sessions: %w", err)
+	}
+	active := make(map[string]bool, len(activeSessions))
+	for _, sessionName := range activeSessions {
+		active[sessionName] = true
 	}
 
-	// Check for specific prompts that need auto-enter
-	hasPrompt := false
-
-	// Check for Claude trust prompt
-	if strings.Contains(content, "Do you trust the files in this folder?") {
-		hasPrompt = true
+	aw.mu.Lock()
+	var stopped []*sessionWorker
+	for sessionName, w := range aw.workers {
+		if !active[sessionName] {
+			log.Info("Session no longer active, stopping watch", "session", sessionName)
+			w.cancel()
+			delete(aw.workers, sessionName)
+			stopped = append(stopped, w)
+		}
 	}
+	for _, sessionName := range activeSessions {
+		if _, ok := aw.workers[sessionName]; ok || ctx.Err() != nil {
+			continue
+		}
+		workerCtx, cancel := context.WithCancel(ctx)
+		w := &sessionWorker{
+			session: sessionName,
+			ticks:   make(chan struct{}, 1),
+			cancel:  cancel,
+			done:    make(chan struct{}),
+		}
+		aw.workers[sessionName] = w
+		aw.wg.Add(1)
+		go aw.run(workerCtx, w)
+	}
+	aw.mu.Unlock()
 
-	// Check for general continuation prompts
-	if strings.Contains(content, "Press Enter to continue") ||
-		strings.Contains(content, "Continue? (Y/n)") ||
-		strings.Contains(content, "Do you want to proceed?") ||
-		(strings.Contains(content, "Allow command") && !strings.Contains(content, "Thinking")) ||
-		strings.Contains(content, "Do you want to") ||
-		strings.Contains(content, "Proceed? (y/N)") {
-		hasPrompt = true
+	for _, w := range stopped {
+		<-w.done
 	}
+	return nil
+}
 
+// tick wakes every worker that is not still busy with the previous tick
+func (aw *AgentWatcher) tick() {
 	aw.mu.Lock()
-	monitor, exists := aw.watchedSessions[sessionName]
-	if !exists {
-		// First time monitoring this session
-		aw.watchedSessions[sessionName] = &SessionMonitor{
-			sessionName:    sessionName,
-			prevOutputHash: aw.hashContent([]byte(content)),
-			lastUpdated:    time.Now(),
-			updateCount:    0,
-			noUpdateCount:  0,
-		}
-		aw.mu.Unlock()
-		return false, hasPrompt, nil
-	}
-
-	// Compare current content hash with previous
-	currentHash := aw.hashContent([]byte(content))
-	if !bytes.Equal(currentHash, monitor.prevOutputHash) {
-		monitor.prevOutputHash = currentHash
-		monitor.lastUpdated = time.Now()
-		monitor.updateCount++
-		monitor.noUpdateCount = 0
-		aw.mu.Unlock()
-		return true, hasPrompt, nil
-	}
-
-	monitor.noUpdateCount++
-	aw.mu.Unlock()
-	return false, hasPrompt, nil
+	defer aw.mu.Unlock()
+	for _, w := range aw.workers {
+		select {
+		case w.ticks <- struct{}{}:
+		default:
+		}
+	}
 }
 
-func (aw *AgentWatcher) watchSession(sessionName string) {
-	log.Info("Starting to watch session", "session", sessionName)
+func (aw *AgentWatcher) run(ctx context.Context, w *sessionWorker) {
+	defer aw.wg.Done()
+	defer close(w.done)
+	log.Info("Starting to watch session", "session", w.session)
 
 	for {
 		select {
-		case <-aw.quit:
+		case <-ctx.Done():
+			log.Debug("Stopped watching session", "session", w.session)
 			return
-		default:
-			updated, hasPrompt, err := aw.hasUpdated(sessionName)
-			if err != nil {
-				log.Error("Error checking session update", "session", sessionName, "error", err)
-				time.Sleep(2 * time.Second)
-				continue
-			}
-
-			if updated {
-				log.Debug("Session updated", "session", sessionName)
-			} else {
-				// Check if session has no prompt and hasn't updated in 3 cycles
-			}
+		case <-w.ticks:
+			aw.check(ctx, w)
+		}
+	}
+}
 
-			if hasPrompt {
-				log.Info("Auto-pressing Enter for prompt", "session", sessionName)
-				if err := aw.tapEnter(sessionName); err != nil {
-					log.Error("Failed to send Enter", "session", sessionName, "error", err)
-				} else {
-					log.Info("Successfully sent Enter", "session", sessionName)
-				}
-			}
+// check reads the session's screen once and acts on it
+func (aw *AgentWatcher) check(ctx context.Context, w *sessionWorker) {
+	now := time.Now()
+	if now.Before(w.retryAt) {
+		return
+	}
+	content, err := aw.screens.ReadScreen(ctx, w.session, "agent")
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		// Log the first failure in a row; the session may just be ending
+		if !w.failing {
+			log.Error("Error checking session update", "session", w.session, "error", err)
+		}
+		w.failing = true
+		w.retryAt = now.Add(errorBackoff)
+		return
+	}
+	w.failing = false
 
-			time.Sleep(500 * time.Millisecond) // Check every 500ms
+	hash := sha256.Sum256([]byte(content))
+	if !bytes.Equal(hash[:], w.screenHash) {
+		if w.screenHash != nil {
+			log.Debug("Session updated", "session", w.session)
 		}
+		w.screenHash = hash[:]
+		w.lastChanged = now
 	}
-}
 
-func (aw *AgentWatcher) refreshActiveSessions() error {
-	activeSessions, err := aw.stateManager.GetActiveSessionsForRepo()
+	agentState, err := aw.sessions.GetWorktreeInfo(w.session)
 	if err != nil {
-		return fmt.Errorf("failed to get active sessions: %w", err)
+		log.Debug("No state for session, auto-response skipped", "session", w.session, "error", err)
+		return
 	}
+	prompted := aw.respond(ctx, w, agentState, content)
+	aw.track(w, agentState, content, prompted, now)
+	aw.supervise(ctx, w, agentState)
+	aw.nudge(ctx, w, agentState, content, now)
+}
 
-	// Stop watching sessions that are no longer active
-	aw.mu.Lock()
-	for sessionName := range aw.watchedSessions {
-		found := false
-		for _, activeSession := range activeSessions {
-			if activeSession == sessionName {
-				found = true
-				break
-			}
+// respond answers a prompt on the screen according to the policy, or
+// escalates it to a human when the prompt looks dangerous. Either way the
+// prompt is remembered until it leaves the screen, so that echoed keys or
+// output below it do not get it answered twice. The screen above the prompt
+// is part of what is remembered: an identical prompt for the next command
+// is answered even when no prompt-free screen was seen in between. It
+// reports whether the screen shows a prompt the policy has a rule for.
+func (aw *AgentWatcher) respond(ctx context.Context, w *sessionWorker, agentState *state.AgentState, content string) bool {
+	decision, ok := aw.policy.Decide(content, autorespond.Agent{Model: agentState.Model, Worktree: agentState.WorktreePath})
+
+	var key string
+	if ok {
+		sum := sha256.Sum256([]byte(decision.Context))
+		key = decision.Rule.Name + "\x00" + hex.EncodeToString(sum[:])
+	}
+	if w.handled == key {
+		return ok
+	}
+	w.handled = key
+	if !ok {
+		return false
+	}
+
+	entry := autorespond.Entry{
+		Session:  w.session,
+		Agent:    state.AgentNameFromSession(w.session),
+		Worktree: agentState.WorktreePath,
+		Rule:     decision.Rule.Name,
+		Prompt:   decision.Prompt,
+	}
+	if decision.Escalate != "" {
+		log.Warn("Prompt left for a human", "session", w.session, "prompt", decision.Prompt, "reason", decision.Escalate)
+		entry.Action, entry.Reason = autorespond.ActionEscalate, decision.Escalate
+		if err := aw.notify(w.session, decision.Prompt, decision.Escalate); err != nil {
+			log.Debug("Could not send escalation notification", "session", w.session, "error", err)
 		}
-		if !found {
-			log.Info("Session no longer active, stopping watch", "session", sessionName)
-			delete(aw.watchedSessions, sessionName)
+	} else {
+		log.Info("Answering prompt", "session", w.session, "rule", decision.Rule.Name, "keys", decision.Keys)
+		entry.Action, entry.Keys = autorespond.ActionSend, decision.Keys
+		if err := aw.screens.SendKeys(ctx, w.session, "agent", decision.Keys...); err != nil {
+			log.Error("Failed to send keys", "session", w.session, "error", err)
+			entry.Error = err.Error()
 		}
 	}
-	aw.mu.Unlock()
+	if err := aw.audit.Record(entry); err != nil {
+		log.Error("Failed to write audit log", "path", aw.audit.Path(), "error", err)
+	}
+	return true
+}
 
-	// Start watching new active sessions
-	for _, sessionName := range activeSessions {
-		aw.mu.RLock()
-		_, exists := aw.watchedSessions[sessionName]
-		aw.mu.RUnlock()
-		if !exists {
-			go aw.watchSession(sessionName)
+// track records the agent's status in its status history, as ls and ui do,
+// so that transitions are recorded and announced while neither is running.
+// The history is updated under the state file's lock, so a transition is
+// announced once, by whichever uzi process records it. A waiting agent is
+// not announced when its prompt was answered or escalated by respond.
+func (aw *AgentWatcher) track(w *sessionWorker, agentState *state.AgentState, content string, prompted bool, now time.Time) {
+	detailed := status.Classify(aw.rules, content, &status.AgentState{
+		WorktreePath: agentState.WorktreePath,
+		UpdatedAt:    agentState.UpdatedAt,
+		IsMerged:     agentState.LastMergedAt != nil,
+		Model:        agentState.Model,
+	})
+	histories, changed, err := status.RecordStatuses(aw.sessions, map[string]string{w.session: detailed.Status}, now)
+	if err != nil {
+		log.Debug("Failed to record status", "session", w.session, "error", err)
+		return
+	}
+	if class := detailed.Error; class != nil {
+		if last := agentState.LastError; last == nil || last.Category != class.Category || last.Excerpt != class.Excerpt {
+			errs := map[string]state.AgentError{w.session: {Category: class.Category, Excerpt: class.Excerpt, At: now}}
+			if err := aw.sessions.SaveAgentErrors(errs); err != nil {
+				log.Debug("Failed to save agent error", "session", w.session, "error", err)
+			}
 		}
 	}
 
-	return nil
+	if !changed[w.session] {
+		return
+	}
+	switch histories[w.session].Current {
+	case status.StatusError:
+	case status.StatusWaiting:
+		if prompted {
+			return
+		}
+	default:
+		return
+	}
+	log.Info("Agent needs attention", "session", w.session, "status", detailed.Status)
+	if err := aw.announce(w.session, detailed); err != nil {
+		log.Debug("Could not send status notification", "session", w.session, "error", err)
+	}
 }
 
-func (aw *AgentWatcher) Start() {
-	log.Info("Starting Agent Watcher")
+// supervise relaunches the agent in its worktree when its process has
+// exited and the restart policy of its profile allows it. Restarts are
+// recorded in the agent's state, which also counts them against the
+// policy's maximum across watcher restarts.
+func (aw *AgentWatcher) supervise(ctx context.Context, w *sessionWorker, agentState *state.AgentState) {
+	policy := aw.rules.ProfileFor(agentState.Model).Restart
+	if !policy.Enabled() {
+		return
+	}
+	command, err := aw.screens.CurrentCommand(ctx, w.session, "agent")
+	if err != nil {
+		log.Debug("Could not get the command of the agent window", "session", w.session, "error", err)
+		return
+	}
+	now := time.Now()
+	if !runner.IsShell(command) {
+		w.exitedAt, w.exitHandled = time.Time{}, false
+		return
+	}
+	if w.exitedAt.IsZero() {
+		w.exitedAt = now
+	}
+	restarts := len(agentState.Restarts)
+	if w.exitHandled || now.Sub(w.exitedAt) < aw.exitConfirm+policy.Delay(restarts) {
+		return
+	}
 
-	// Set up signal handling for graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	completed := false
+	if _, err := os.Stat(filepath.Join(agentState.WorktreePath, status.MarkerFile)); err == nil {
+		completed = true
+	}
+	if !policy.Allows(completed) {
+		log.Info("Agent exited after completing its task", "session", w.session, "restart", policy.Policy)
+		w.exitHandled = true
+		return
+	}
+	if restarts >= policy.MaxRetries {
+		log.Warn("Agent exited, restart limit reached", "session", w.session, "restarts", restarts)
+		w.exitHandled = true
+		message := fmt.Sprintf("Agent exited and was not restarted: it was already restarted %d times", restarts)
+		if err := aw.notifyError(w.session, message); err != nil {
+			log.Debug("Could not send error notification", "session", w.session, "error", err)
+		}
+		return
+	}
 
-	// Refresh active sessions periodically
-	refreshTicker := time.NewTicker(5 * time.Second)
-	defer refreshTicker.Stop()
+	reason := "exited without a completion report"
+	if completed {
+		reason = "exited after completing its task"
+	}
+	prompt := policy.ResumePrompt
+	if prompt == "" {
+		prompt = agentState.Prompt
+	}
+	launch := "cd " + tmux.ShellQuote(agentState.WorktreePath) + " && " + agents.LaunchCommand(agentState.Model, prompt)
+	log.Info("Restarting agent", "session", w.session, "reason", reason, "attempt", restarts+1, "max", policy.MaxRetries)
+	if err := aw.screens.SendKeys(ctx, w.session, "agent", launch, "C-m"); err != nil {
+		log.Error("Failed to restart agent", "session", w.session, "error", err)
+		// Try again after another backoff
+		w.exitedAt = now
+		return
+	}
+	if err := aw.sessions.RecordRestart(w.session, state.AgentRestart{At: now, Reason: reason, Prompt: prompt}); err != nil {
+		log.Error("Failed to record restart", "session", w.session, "error", err)
+	}
+	w.exitedAt = time.Time{}
+}
 
-	go func() {
-		for {
-			select {
-			case <-refreshTicker.C:
-				if err := aw.refreshActiveSessions(); err != nil {
-					log.Error("Failed to refresh active sessions", "error", err)
-				}
-			case <-aw.quit:
-				return
-			}
+// nudge sends a follow-up message to an agent whose screen has been idle
+// and unchanged for the nudge period of its profile without it writing its
+// task marker file. Once the agent has had the maximum number of nudges,
+// the next idle period is reported as an error instead, once. Nudges and
+// the escalation are recorded in the agent's state.
+func (aw *AgentWatcher) nudge(ctx context.Context, w *sessionWorker, agentState *state.AgentState, content string, now time.Time) {
+	profile := aw.rules.ProfileFor(agentState.Model)
+	policy := profile.Nudge
+	idle := now.Sub(w.lastChanged)
+	if !policy.Enabled() || idle < policy.After {
+		return
+	}
+	if result, ok := profile.Match(content); ok && result.Rule.Status != status.StatusIdle {
+		return
+	}
+	if _, err := os.Stat(filepath.Join(agentState.WorktreePath, status.MarkerFile)); err == nil {
+		return
+	}
+	// A message typed into the shell of an agent that exited would run as
+	// a command
+	command, err := aw.screens.CurrentCommand(ctx, w.session, "agent")
+	if err != nil || runner.IsShell(command) {
+		return
+	}
+	// Whatever happens, wait a full period before looking again
+	w.lastChanged = now
+
+	nudges, escalated := 0, false
+	for _, n := range agentState.Nudges {
+		if !n.Escalated {
+			nudges++
 		}
-	}()
+		escalated = n.Escalated
+	}
+	idle = idle.Round(time.Second)
 
-	// Initial refresh
-	if err := aw.refreshActiveSessions(); err != nil {
-		log.Error("Failed initial session refresh", "error", err)
+	if nudges >= policy.MaxNudges {
+		if escalated {
+			return
+		}
+		message := fmt.Sprintf("Agent has been idle for %s without completing its task after %d nudges", idle, nudges)
+		log.Warn("Agent still idle, nudge limit reached", "session", w.session, "nudges", nudges)
+		if err := aw.notifyError(w.session, message); err != nil {
+			log.Debug("Could not send error notification", "session", w.session, "error", err)
+		}
+		if err := aw.sessions.RecordNudge(w.session, state.AgentNudge{At: now, Message: message, Escalated: true}); err != nil {
+			log.Error("Failed to record nudge", "session", w.session, "error", err)
+		}
+		return
 	}
 
-	// Wait for signal
-	<-sigChan
-	log.Info("Shutting down Agent Watcher")
-	close(aw.quit)
-}
-
-var CmdWatch = &ffcli.Command{
-	Name:       "auto",
-	ShortUsage: "uzi auto",
-	ShortHelp:  "Automatically manage active agent sessions",
-	LongHelp: `
-The auto command monitors all active agent sessions in the current repository
-and automatically presses Enter when it detects prompts that require user input,
-such as trust prompts or continuation confirmations. It can also handle other
-automated tasks in the future.
-
-This is useful for hands-free operation of multiple agents.
-`,
-	FlagSet: func() *flag.FlagSet {
-		fs := flag.NewFlagSet("auto", flag.ExitOnError)
-		return fs
-	}(),
-	Exec: func(ctx context.Context, args []string) error {
-		watcher := NewAgentWatcher()
-		watcher.Start()
-		return nil
-	},
+	message, err := policy.Message(status.NudgeData{
+		Agent:     state.AgentNameFromSession(w.session),
+		Prompt:    agentState.Prompt,
+		Nudge:     nudges + 1,
+		MaxNudges: policy.MaxNudges,
+		Idle:      idle,
+	})
+	if err != nil {
+		log.Error("Failed to render nudge message", "session", w.session, "error", err)
+		return
+	}
+	log.Info("Nudging idle agent", "session", w.session, "idle", idle, "nudge", nudges+1, "max", policy.MaxNudges)
+	if err := aw.screens.SendKeys(ctx, w.session, "agent", message, "Enter"); err != nil {
+		log.Error("Failed to nudge agent", "session", w.session, "error", err)
+		return
+	}
+	if err := aw.sessions.RecordNudge(w.session, state.AgentNudge{At: now, Message: message}); err != nil {
+		log.Error("Failed to record nudge", "session", w.session, "error", err)
+	}
 }
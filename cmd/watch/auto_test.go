@@ -0,0 +1,689 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devflowinc/uzi/pkg/autorespond"
+	"github.com/devflowinc/uzi/pkg/config"
+	"github.com/devflowinc/uzi/pkg/state"
+	"github.com/devflowinc/uzi/pkg/status"
+)
+
+// fakeTmux serves fixed screens and records the keys sent to each session
+type fakeTmux struct {
+	mu      sync.Mutex
+	screens map[string]string
+	// commands are the foreground commands of agent windows; "claude"
+	// when unset
+	commands map[string]string
+	sent     map[string][][]string
+	reads    map[string]int
+	// active counts the ReadScreen calls in progress per session
+	active  map[string]int
+	overlap bool
+	delay   time.Duration
+}
+
+func newFakeTmux() *fakeTmux {
+	return &fakeTmux{screens: map[string]string{}, commands: map[string]string{}, sent: map[string][][]string{}, reads: map[string]int{}, active: map[string]int{}}
+}
+
+func (f *fakeTmux) ReadScreen(ctx context.Context, session, window string) (string, error) {
+	f.mu.Lock()
+	f.reads[session]++
+	f.active[session]++
+	if f.active[session] > 1 {
+		f.overlap = true
+	}
+	screen, ok := f.screens[session]
+	delay := f.delay
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		f.active[session]--
+		f.mu.Unlock()
+	}()
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	if !ok {
+		return "", errors.New("can't find session")
+	}
+	return screen, nil
+}
+
+func (f *fakeTmux) SendKeys(ctx context.Context, session, window string, keys ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent[session] = append(f.sent[session], keys)
+	return nil
+}
+
+func (f *fakeTmux) CurrentCommand(ctx context.Context, session, window string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if command, ok := f.commands[session]; ok {
+		return command, nil
+	}
+	return "claude", nil
+}
+
+func (f *fakeTmux) setCommand(session, command string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands[session] = command
+}
+
+func (f *fakeTmux) setScreen(session, screen string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.screens[session] = screen
+}
+
+func (f *fakeTmux) sentTo(session string) [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.sent[session])
+}
+
+func (f *fakeTmux) readsOf(session string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.reads[session]
+}
+
+// fakeSessions is a SessionSource whose active sessions can change
+type fakeSessions struct {
+	mu       sync.Mutex
+	active   []string
+	worktree string
+	restarts []state.AgentRestart
+	nudges   []state.AgentNudge
+	// histories and errors are the status histories and errors recorded
+	// per session
+	histories map[string]*state.StatusHistory
+	errors    map[string]state.AgentError
+}
+
+func (f *fakeSessions) GetActiveSessionsForRepo() ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.active), nil
+}
+
+func (f *fakeSessions) GetWorktreeInfo(sessionName string) (*state.AgentState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	worktree := f.worktree
+	if worktree == "" {
+		worktree = "/work/" + sessionName
+	}
+	agentState := &state.AgentState{Model: "claude", Prompt: "fix the bug", WorktreePath: worktree, Restarts: slices.Clone(f.restarts), Nudges: slices.Clone(f.nudges)}
+	agentState.StatusHistory = f.histories[sessionName]
+	if agentErr, ok := f.errors[sessionName]; ok {
+		agentState.LastError = &agentErr
+	}
+	return agentState, nil
+}
+
+func (f *fakeSessions) UpdateStatusHistories(update func(sessionName string, history *state.StatusHistory) (state.StatusHistory, bool)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.histories == nil {
+		f.histories = make(map[string]*state.StatusHistory)
+	}
+	for _, session := range f.active {
+		if history, ok := update(session, f.histories[session]); ok {
+			f.histories[session] = &history
+		}
+	}
+	return nil
+}
+
+func (f *fakeSessions) SaveAgentErrors(errs map[string]state.AgentError) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.errors == nil {
+		f.errors = make(map[string]state.AgentError)
+	}
+	for session, agentErr := range errs {
+		f.errors[session] = agentErr
+	}
+	return nil
+}
+
+func (f *fakeSessions) history(session string) *state.StatusHistory {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.histories[session]
+}
+
+func (f *fakeSessions) RecordRestart(sessionName string, restart state.AgentRestart) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.restarts = append(f.restarts, restart)
+	return nil
+}
+
+func (f *fakeSessions) RecordNudge(sessionName string, nudge state.AgentNudge) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nudges = append(f.nudges, nudge)
+	return nil
+}
+
+func (f *fakeSessions) recordedNudges() []state.AgentNudge {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.nudges)
+}
+
+func (f *fakeSessions) restartCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.restarts)
+}
+
+func (f *fakeSessions) set(active ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.active = active
+}
+
+func testWatcher(t *testing.T, screens *fakeTmux, sessions *fakeSessions) *AgentWatcher {
+	policy, err := autorespond.Compile(config.AutoRespond{Audit: filepath.Join(t.TempDir(), "audit.jsonl")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	aw := newAgentWatcher(policy, status.DefaultRules(), sessions, screens)
+	aw.checkInterval = 2 * time.Millisecond
+	aw.refreshInterval = time.Hour
+	aw.exitConfirm = 10 * time.Millisecond
+	aw.notify = func(sessionName, prompt, reason string) error { return nil }
+	aw.notifyError = func(sessionName, message string) error { return nil }
+	aw.announce = func(sessionName string, st status.DetailedStatus) error { return nil }
+	return aw
+}
+
+// announcements records the statuses the watcher announces
+type announcements struct {
+	mu       sync.Mutex
+	statuses []status.DetailedStatus
+}
+
+func (a *announcements) record(sessionName string, st status.DetailedStatus) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.statuses = append(a.statuses, st)
+	return nil
+}
+
+func (a *announcements) list() []status.DetailedStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return slices.Clone(a.statuses)
+}
+
+// startWatcher runs the watcher until the test ends
+func startWatcher(t *testing.T, aw *AgentWatcher) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		aw.Start(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// waitFor polls cond until it holds or a second has passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func auditEntries(t *testing.T, aw *AgentWatcher) []string {
+	data, err := os.ReadFile(aw.audit.Path())
+	if err != nil {
+		return nil
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestWatcherAnswersPromptOnce(t *testing.T) {
+	screens, sessions := newFakeTmux(), &fakeSessions{}
+	sessions.set("agent-repo-abc-john")
+	screens.setScreen("agent-repo-abc-john", "│ Do you want to proceed? │\n│ ❯ 1. Yes │\n")
+	aw := testWatcher(t, screens, sessions)
+	startWatcher(t, aw)
+
+	waitFor(t, "the answer", func() bool { return len(screens.sentTo("agent-repo-abc-john")) > 0 })
+	// The prompt stays on the screen while the keys echo below it
+	screens.setScreen("agent-repo-abc-john", "│ Do you want to proceed? │\n│ ❯ 1. Yes │\n\n")
+	reads := screens.readsOf("agent-repo-abc-john")
+	waitFor(t, "more checks", func() bool { return screens.readsOf("agent-repo-abc-john") > reads+5 })
+	if sent := screens.sentTo("agent-repo-abc-john"); len(sent) != 1 || !slices.Equal(sent[0], []string{"Enter"}) {
+		t.Fatalf("sent %q, want one Enter", sent)
+	}
+
+	// A new prompt after the first one went away is answered again
+	screens.setScreen("agent-repo-abc-john", "✻ Working… (esc to interrupt)")
+	reads = screens.readsOf("agent-repo-abc-john")
+	waitFor(t, "more checks", func() bool { return screens.readsOf("agent-repo-abc-john") > reads+2 })
+	screens.setScreen("agent-repo-abc-john", "│ Do you want to proceed? │\n")
+	waitFor(t, "the second answer", func() bool { return len(screens.sentTo("agent-repo-abc-john")) == 2 })
+
+	if entries := auditEntries(t, aw); len(entries) != 2 || !strings.Contains(entries[0], `"action":"send"`) {
+		t.Errorf("audit log = %q", entries)
+	}
+}
+
+func TestWatcherAnswersRepeatedPrompt(t *testing.T) {
+	screens, sessions := newFakeTmux(), &fakeSessions{}
+	sessions.set("agent-repo-abc-john")
+	prompt := "│ Bash command │\n│   go test ./... │\n│ Do you want to proceed? │\n│ ❯ 1. Yes │\n"
+	screens.setScreen("agent-repo-abc-john", prompt)
+	aw := testWatcher(t, screens, sessions)
+	startWatcher(t, aw)
+
+	waitFor(t, "the answer", func() bool { return len(screens.sentTo("agent-repo-abc-john")) == 1 })
+	// The agent runs the command and asks again before a check sees the
+	// screen without a prompt
+	screens.setScreen("agent-repo-abc-john", "● Bash(go test ./...)\n  ⎿  ok\n"+prompt)
+	waitFor(t, "the second answer", func() bool { return len(screens.sentTo("agent-repo-abc-john")) == 2 })
+
+	reads := screens.readsOf("agent-repo-abc-john")
+	waitFor(t, "more checks", func() bool { return screens.readsOf("agent-repo-abc-john") > reads+5 })
+	if sent := screens.sentTo("agent-repo-abc-john"); len(sent) != 2 {
+		t.Errorf("sent %q, want two answers", sent)
+	}
+}
+
+func TestWatcherEscalatesDeniedPrompt(t *testing.T) {
+	screens, sessions := newFakeTmux(), &fakeSessions{}
+	sessions.set("agent-repo-abc-john")
+	screens.setScreen("agent-repo-abc-john", "│   rm -rf build │\n│ Do you want to proceed? │\n")
+	aw := testWatcher(t, screens, sessions)
+	var mu sync.Mutex
+	var escalations []string
+	aw.notify = func(sessionName, prompt, reason string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		escalations = append(escalations, reason)
+		return nil
+	}
+	startWatcher(t, aw)
+
+	waitFor(t, "the escalation", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(escalations) > 0
+	})
+	reads := screens.readsOf("agent-repo-abc-john")
+	waitFor(t, "more checks", func() bool { return screens.readsOf("agent-repo-abc-john") > reads+5 })
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(escalations) != 1 || !strings.Contains(escalations[0], "rm -rf") {
+		t.Errorf("escalations = %q", escalations)
+	}
+	if sent := screens.sentTo("agent-repo-abc-john"); len(sent) != 0 {
+		t.Errorf("sent %q to a denied prompt", sent)
+	}
+	if entries := auditEntries(t, aw); len(entries) != 1 || !strings.Contains(entries[0], `"action":"escalate"`) {
+		t.Errorf("audit log = %q", entries)
+	}
+}
+
+func TestWatcherOneWorkerPerSession(t *testing.T) {
+	screens, sessions := newFakeTmux(), &fakeSessions{}
+	screens.setScreen("a", "idle")
+	screens.setScreen("b", "idle")
+	aw := testWatcher(t, screens, sessions)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sessions.set("a", "b")
+	aw.refresh(ctx)
+	aw.refresh(ctx)
+	first := aw.workers["a"]
+	if len(aw.workers) != 2 {
+		t.Fatalf("%d workers after two refreshes, want 2", len(aw.workers))
+	}
+
+	// a disappears: its worker has exited by the time refresh returns
+	sessions.set("b")
+	aw.refresh(ctx)
+	select {
+	case <-first.done:
+	default:
+		t.Fatal("worker of a removed session is still running")
+	}
+
+	// a comes back with a fresh worker
+	sessions.set("a", "b")
+	aw.refresh(ctx)
+	if aw.workers["a"] == first || len(aw.workers) != 2 {
+		t.Errorf("workers = %v", aw.workers)
+	}
+
+	cancel()
+	aw.wg.Wait()
+}
+
+func TestWatcherSkipsTicksWhileBusy(t *testing.T) {
+	screens, sessions := newFakeTmux(), &fakeSessions{}
+	screens.delay = 20 * time.Millisecond
+	sessions.set("slow")
+	screens.setScreen("slow", "idle")
+	aw := testWatcher(t, screens, sessions)
+	startWatcher(t, aw)
+
+	time.Sleep(100 * time.Millisecond)
+	// 50 ticks fired, but a read takes 20ms
+	if reads := screens.readsOf("slow"); reads > 7 {
+		t.Errorf("%d reads in 100ms of 20ms reads; ticks were queued", reads)
+	}
+	screens.mu.Lock()
+	defer screens.mu.Unlock()
+	if screens.overlap {
+		t.Error("a session's screen was read by two workers at once")
+	}
+}
+
+func TestWatcherStopsOnCancel(t *testing.T) {
+	screens, sessions := newFakeTmux(), &fakeSessions{}
+	sessions.set("a", "missing")
+	screens.setScreen("a", "idle")
+	aw := testWatcher(t, screens, sessions)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		aw.Start(ctx)
+		close(done)
+	}()
+	waitFor(t, "the first check", func() bool { return screens.readsOf("a") > 0 })
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Start did not return after cancel")
+	}
+
+	reads := screens.readsOf("a")
+	time.Sleep(20 * time.Millisecond)
+	if screens.readsOf("a") != reads {
+		t.Error("a worker kept reading after Start returned")
+	}
+	// A session whose screen cannot be read is retried after a backoff
+	if n := screens.readsOf("missing"); n != 1 {
+		t.Errorf("missing session read %d times, want 1 within the backoff", n)
+	}
+}
+
+// restartWatcher is a watcher whose claude profile restarts agents
+func restartWatcher(t *testing.T, screens *fakeTmux, sessions *fakeSessions, restart config.RestartConfig) *AgentWatcher {
+	aw := testWatcher(t, screens, sessions)
+	rules, err := status.CompileRules(map[string]config.StatusProfile{"claude": {Restart: restart}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	aw.rules = rules
+	return aw
+}
+
+func TestWatcherRestartsExitedAgent(t *testing.T) {
+	screens, sessions := newFakeTmux(), &fakeSessions{}
+	sessions.set("a")
+	screens.setScreen("a", "$ ")
+	screens.setCommand("a", "bash")
+	aw := restartWatcher(t, screens, sessions, config.RestartConfig{Policy: status.RestartOnFailure, MaxRetries: 2, Backoff: "20ms"})
+	var mu sync.Mutex
+	var exits []string
+	aw.notifyError = func(sessionName, message string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		exits = append(exits, message)
+		return nil
+	}
+	startWatcher(t, aw)
+
+	waitFor(t, "the restart", func() bool { return sessions.restartCount() == 1 })
+	sent := screens.sentTo("a")
+	if len(sent) != 1 || !slices.Equal(sent[0], []string{`cd '/work/a' && claude "fix the bug"`, "C-m"}) {
+		t.Fatalf("sent %q, want the launch command", sent)
+	}
+	sessions.mu.Lock()
+	if restart := sessions.restarts[0]; restart.Reason != "exited without a completion report" || restart.Prompt != "fix the bug" {
+		t.Errorf("recorded %+v", restart)
+	}
+	sessions.mu.Unlock()
+
+	// The agent keeps exiting: one more restart, then a notification
+	waitFor(t, "the exit notification", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(exits) > 0
+	})
+	reads := screens.readsOf("a")
+	waitFor(t, "more checks", func() bool { return screens.readsOf("a") > reads+10 })
+	if n := sessions.restartCount(); n != 2 {
+		t.Errorf("%d restarts, want maxRetries 2", n)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(exits) != 1 {
+		t.Errorf("exit notifications = %q", exits)
+	}
+}
+
+func TestWatcherLeavesRunningOrCompletedAgents(t *testing.T) {
+	screens, sessions := newFakeTmux(), &fakeSessions{worktree: t.TempDir()}
+	sessions.set("running", "done")
+	screens.setScreen("running", "✻ Working… (esc to interrupt)")
+	screens.setScreen("done", "$ ")
+	screens.setCommand("done", "zsh")
+	os.WriteFile(filepath.Join(sessions.worktree, status.MarkerFile), []byte("done"), 0644)
+	aw := restartWatcher(t, screens, sessions, config.RestartConfig{Policy: status.RestartOnFailure, Backoff: "0"})
+	startWatcher(t, aw)
+
+	waitFor(t, "checks", func() bool { return screens.readsOf("done") > 20 && screens.readsOf("running") > 20 })
+	if n := sessions.restartCount(); n != 0 {
+		t.Errorf("%d restarts of agents that are running or completed their task", n)
+	}
+}
+
+func TestWatcherRestartWithResumePrompt(t *testing.T) {
+	screens, sessions := newFakeTmux(), &fakeSessions{worktree: t.TempDir()}
+	sessions.set("a")
+	screens.setScreen("a", "$ ")
+	screens.setCommand("a", "sh")
+	os.WriteFile(filepath.Join(sessions.worktree, status.MarkerFile), []byte("done"), 0644)
+	aw := restartWatcher(t, screens, sessions, config.RestartConfig{Policy: status.RestartAlways, Backoff: "0", ResumePrompt: "Continue the task"})
+	startWatcher(t, aw)
+
+	waitFor(t, "the restart", func() bool { return sessions.restartCount() > 0 })
+	if sent := screens.sentTo("a"); len(sent) == 0 || !strings.HasSuffix(sent[0][0], `claude "Continue the task"`) {
+		t.Errorf("sent %q, want the resume prompt", sent)
+	}
+	sessions.mu.Lock()
+	defer sessions.mu.Unlock()
+	if sessions.restarts[0].Reason != "exited after completing its task" {
+		t.Errorf("recorded %+v", sessions.restarts[0])
+	}
+}
+
+// nudgeWatcher is a watcher whose claude profile nudges idle agents
+func nudgeWatcher(t *testing.T, screens *fakeTmux, sessions *fakeSessions, nudge config.NudgeConfig) *AgentWatcher {
+	aw := testWatcher(t, screens, sessions)
+	rules, err := status.CompileRules(map[string]config.StatusProfile{"claude": {Nudge: nudge}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	aw.rules = rules
+	return aw
+}
+
+func TestWatcherNudgesIdleAgent(t *testing.T) {
+	screens, sessions := newFakeTmux(), &fakeSessions{worktree: t.TempDir()}
+	sessions.set("agent-repo-abc-john")
+	screens.setScreen("agent-repo-abc-john", "● I changed the parser.\n\n> ")
+	aw := nudgeWatcher(t, screens, sessions, config.NudgeConfig{After: "30ms", MaxNudges: 2, Message: "{{.Agent}} ({{.Nudge}}/{{.MaxNudges}}): continue with {{.Prompt}}"})
+	var mu sync.Mutex
+	var errs []string
+	aw.notifyError = func(sessionName, message string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, message)
+		return nil
+	}
+	startWatcher(t, aw)
+
+	waitFor(t, "the first nudge", func() bool { return len(screens.sentTo("agent-repo-abc-john")) == 1 })
+	if sent := screens.sentTo("agent-repo-abc-john"); !slices.Equal(sent[0], []string{"john (1/2): continue with fix the bug", "Enter"}) {
+		t.Fatalf("sent %q", sent)
+	}
+
+	// The agent does not react: a second nudge, then an error notification
+	waitFor(t, "the escalation", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(errs) > 0
+	})
+	time.Sleep(100 * time.Millisecond)
+	if sent := screens.sentTo("agent-repo-abc-john"); len(sent) != 2 {
+		t.Errorf("sent %q, want maxNudges 2", sent)
+	}
+	nudges := sessions.recordedNudges()
+	if len(nudges) != 3 || nudges[0].Escalated || !nudges[2].Escalated || nudges[1].Message != "john (2/2): continue with fix the bug" {
+		t.Errorf("recorded %+v", nudges)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) != 1 || !strings.Contains(errs[0], "after 2 nudges") {
+		t.Errorf("error notifications = %q", errs)
+	}
+}
+
+func TestWatcherDoesNotNudgeBusyOrFinishedAgents(t *testing.T) {
+	screens, sessions := newFakeTmux(), &fakeSessions{}
+	done := &fakeSessions{worktree: t.TempDir()}
+	os.WriteFile(filepath.Join(done.worktree, status.MarkerFile), []byte("done"), 0644)
+	tests := []struct {
+		name     string
+		sessions *fakeSessions
+		screen   string
+		command  string
+	}{
+		{"running", sessions, "✻ Working… (esc to interrupt)", ""},
+		{"waiting", sessions, "Should I also update the docs?", ""},
+		{"exited", sessions, "$ ", "bash"},
+		{"completed", done, "> ", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.sessions.set(tt.name)
+			screens.setScreen(tt.name, tt.screen)
+			if tt.command != "" {
+				screens.setCommand(tt.name, tt.command)
+			}
+			aw := nudgeWatcher(t, screens, tt.sessions, config.NudgeConfig{After: "1ms"})
+			startWatcher(t, aw)
+
+			waitFor(t, "checks", func() bool { return screens.readsOf(tt.name) > 20 })
+			if sent := screens.sentTo(tt.name); len(sent) != 0 {
+				t.Errorf("sent %q", sent)
+			}
+		})
+	}
+}
+
+func TestWatcherAnnouncesWaitingAndErrorOnce(t *testing.T) {
+	screens, sessions := newFakeTmux(), &fakeSessions{worktree: t.TempDir()}
+	sessions.set("agent-repo-abc-john")
+	screens.setScreen("agent-repo-abc-john", "✻ Working… (esc to interrupt)")
+	aw := testWatcher(t, screens, sessions)
+	var announced announcements
+	aw.announce = announced.record
+	startWatcher(t, aw)
+
+	waitFor(t, "running to be recorded", func() bool {
+		h := sessions.history("agent-repo-abc-john")
+		return h != nil && h.Current == status.StatusRunning
+	})
+
+	screens.setScreen("agent-repo-abc-john", "Should I also update the docs?\n> ")
+	waitFor(t, "the waiting announcement", func() bool { return len(announced.list()) == 1 })
+	reads := screens.readsOf("agent-repo-abc-john")
+	waitFor(t, "more checks", func() bool { return screens.readsOf("agent-repo-abc-john") > reads+5 })
+	if got := announced.list(); len(got) != 1 || got[0].Status != status.StatusWaiting ||
+		got[0].Question == nil || got[0].Question.Text != "Should I also update the docs?" {
+		t.Fatalf("announced %+v, want one waiting question", got)
+	}
+
+	screens.setScreen("agent-repo-abc-john", "  ⎿  API Error: 529 {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\"}}\n> ")
+	waitFor(t, "the error announcement", func() bool { return len(announced.list()) == 2 })
+	reads = screens.readsOf("agent-repo-abc-john")
+	waitFor(t, "more checks", func() bool { return screens.readsOf("agent-repo-abc-john") > reads+5 })
+	got := announced.list()
+	if len(got) != 2 || got[1].Status != status.StatusError || got[1].Error == nil || got[1].Error.Category != status.ErrorAPI {
+		t.Fatalf("announced %+v, want a waiting question and an api error", got)
+	}
+	if h := sessions.history("agent-repo-abc-john"); h == nil || h.Current != status.StatusError || len(h.Transitions) != 2 {
+		t.Errorf("history = %+v, want running, waiting, error", h)
+	}
+	sessions.mu.Lock()
+	agentErr := sessions.errors["agent-repo-abc-john"]
+	sessions.mu.Unlock()
+	if agentErr.Category != status.ErrorAPI {
+		t.Errorf("saved error = %+v", agentErr)
+	}
+}
+
+func TestWatcherLeavesRecordedOrAnsweredPrompts(t *testing.T) {
+	screens, sessions := newFakeTmux(), &fakeSessions{worktree: t.TempDir()}
+	sessions.set("agent-repo-abc-john", "agent-repo-abc-mary")
+	// Another uzi process already recorded and announced john's question
+	sessions.histories = map[string]*state.StatusHistory{
+		"agent-repo-abc-john": {Current: status.StatusWaiting, Since: time.Now()},
+	}
+	screens.setScreen("agent-repo-abc-john", "Should I also update the docs?\n> ")
+	// mary's permission prompt is answered by the watcher itself
+	screens.setScreen("agent-repo-abc-mary", "│ Do you want to proceed? │\n│ ❯ 1. Yes │\n")
+	aw := testWatcher(t, screens, sessions)
+	var announced announcements
+	aw.announce = announced.record
+	startWatcher(t, aw)
+
+	waitFor(t, "the answer", func() bool { return len(screens.sentTo("agent-repo-abc-mary")) == 1 })
+	reads := screens.readsOf("agent-repo-abc-john")
+	waitFor(t, "more checks", func() bool { return screens.readsOf("agent-repo-abc-john") > reads+5 })
+	if got := announced.list(); len(got) != 0 {
+		t.Errorf("announced %+v, want nothing", got)
+	}
+	if h := sessions.history("agent-repo-abc-mary"); h == nil || h.Current != status.StatusWaiting {
+		t.Errorf("mary's history = %+v, want the prompt recorded as waiting", h)
+	}
+}
@@ -0,0 +1,203 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/devflowinc/uzi/pkg/autorespond"
+	"github.com/devflowinc/uzi/pkg/config"
+	"github.com/devflowinc/uzi/pkg/daemon"
+	"github.com/devflowinc/uzi/pkg/state"
+	"github.com/devflowinc/uzi/pkg/status"
+
+	"github.com/charmbracelet/log"
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+var (
+	startFs  = flag.NewFlagSet("uzi auto start", flag.ExitOnError)
+	cmdStart = &ffcli.Command{
+		Name:       "start",
+		ShortUsage: "uzi auto start",
+		ShortHelp:  "Start the watcher of this repository in the background",
+		FlagSet:    startFs,
+		Exec:       executeStart,
+	}
+
+	stopFs      = flag.NewFlagSet("uzi auto stop", flag.ExitOnError)
+	stopTimeout = stopFs.Duration("timeout", 10*time.Second, "how long to wait for the watcher to exit")
+	cmdStop     = &ffcli.Command{
+		Name:       "stop",
+		ShortUsage: "uzi auto stop [-timeout d]",
+		ShortHelp:  "Stop the background watcher of this repository",
+		FlagSet:    stopFs,
+		Exec:       executeStop,
+	}
+
+	statusFs  = flag.NewFlagSet("uzi auto status", flag.ExitOnError)
+	cmdStatus = &ffcli.Command{
+		Name:       "status",
+		ShortUsage: "uzi auto status",
+		ShortHelp:  "Show whether the watcher of this repository is running",
+		FlagSet:    statusFs,
+		Exec:       executeStatus,
+	}
+
+	fs       = flag.NewFlagSet("uzi auto", flag.ExitOnError)
+	CmdWatch = &ffcli.Command{
+		Name:       "auto",
+		ShortUsage: "uzi auto [start | stop | status]",
+		ShortHelp:  "Automatically manage active agent sessions",
+		LongHelp: `
+The auto command monitors all active agent sessions in the current repository
+and answers prompts that require user input, such as trust prompts, permission
+prompts and continuation confirmations, following the autoRespond rules in
+uzi.yaml (Enter for the built-in ones).
+
+Prompts whose command matches a deny pattern (rm -rf, git push --force,
+git reset --hard, sudo, ...) or that mention a path outside the agent's
+worktree are never answered: they are logged and sent as an escalated
+"waiting" notification for a human to handle.
+
+When the agent process exits (a crash, running out of memory, /exit) and
+its window drops back to the shell, the watcher relaunches it in the same
+worktree if the restart policy of its statusRules profile says so
+("never" by default). Each restart is recorded in the agent's state.
+
+An agent that stays idle without writing its .uzi-task-completed marker for
+the nudge period of its profile (off by default) is sent a follow-up message
+asking it to continue. After the profile's maximum number of nudges it is
+reported with an error notification instead. Nudges are recorded in the
+agent's state as well.
+
+Every key sent and every escalation is appended to an audit log, by default
+~/.local/share/uzi/auto-audit.jsonl.
+
+Without a subcommand the watcher runs in the foreground until interrupted.
+"uzi auto start" runs it in the background with its output in a log file,
+"uzi auto stop" shuts it down and "uzi auto status" reports on it (exiting
+with status 1 when it is not running). Each repository has at most one
+watcher; its pidfile and log are kept in ~/.local/share/uzi/auto.
+
+This is useful for hands-free operation of multiple agents.
+`,
+		FlagSet:     fs,
+		Subcommands: []*ffcli.Command{cmdStart, cmdStop, cmdStatus},
+		Exec:        executeAuto,
+	}
+)
+
+// currentDaemon returns the watcher of the repository in the current directory
+func currentDaemon() (*daemon.Daemon, error) {
+	sm := state.NewStateManager()
+	if sm == nil {
+		return nil, fmt.Errorf("could not initialize state manager")
+	}
+	return daemon.For(sm.CurrentRepo())
+}
+
+// executeAuto runs the watcher in the foreground until it is interrupted or
+// stopped with "uzi auto stop". It holds the repository's pidfile so that
+// no two watchers answer the same prompt.
+func executeAuto(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unknown subcommand %q (want start, stop or status)", args[0])
+	}
+	policy, err := autorespond.Load(config.GetDefaultConfigPath())
+	if err != nil {
+		return fmt.Errorf("invalid autoRespond configuration: %w", err)
+	}
+	rules, err := status.LoadRules(config.GetDefaultConfigPath())
+	if err != nil {
+		return fmt.Errorf("invalid statusRules configuration: %w", err)
+	}
+	d, err := currentDaemon()
+	if err != nil {
+		return err
+	}
+	release, err := d.Acquire()
+	if err != nil {
+		if errors.Is(err, daemon.ErrRunning) {
+			return fmt.Errorf("%w; stop it with \"uzi auto stop\"", err)
+		}
+		return err
+	}
+	defer release()
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Info("Auto-response policy loaded", "rules", len(policy.Rules), "deny", len(policy.Deny), "audit", policy.AuditPath)
+	NewAgentWatcher(policy, rules).Start(ctx)
+	return nil
+}
+
+func executeStart(ctx context.Context, args []string) error {
+	// Report configuration mistakes here rather than in the log file
+	if _, err := autorespond.Load(config.GetDefaultConfigPath()); err != nil {
+		return fmt.Errorf("invalid autoRespond configuration: %w", err)
+	}
+	if _, err := status.LoadRules(config.GetDefaultConfigPath()); err != nil {
+		return fmt.Errorf("invalid statusRules configuration: %w", err)
+	}
+	d, err := currentDaemon()
+	if err != nil {
+		return err
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("could not resolve uzi executable: %w", err)
+	}
+
+	pid, err := d.Start(exec.Command(executable, "auto"))
+	if errors.Is(err, daemon.ErrRunning) {
+		fmt.Printf("uzi auto is already running (pid %d)\n", pid)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("uzi auto started (pid %d), logging to %s\n", pid, d.LogPath)
+	return nil
+}
+
+func executeStop(ctx context.Context, args []string) error {
+	d, err := currentDaemon()
+	if err != nil {
+		return err
+	}
+	pid, err := d.Stop(*stopTimeout)
+	if errors.Is(err, daemon.ErrNotRunning) {
+		fmt.Println("uzi auto is not running")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("uzi auto stopped (pid %d)\n", pid)
+	return nil
+}
+
+func executeStatus(ctx context.Context, args []string) error {
+	d, err := currentDaemon()
+	if err != nil {
+		return err
+	}
+	status, err := d.Status()
+	if err != nil {
+		return err
+	}
+	if !status.Running {
+		return daemon.ErrNotRunning
+	}
+	fmt.Printf("uzi auto is running (pid %d) since %s\n", status.PID, status.Since.Format(time.DateTime))
+	fmt.Printf("Log: %s\n", d.LogPath)
+	return nil
+}
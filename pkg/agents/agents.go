@@ -1,6 +1,7 @@
 package agents
 
 import (
+	"fmt"
 	"math/rand"
 	"strings"
 	"time"
@@ -131,4 +132,10 @@ func GetRandomAgent() string {
 	agents := strings.Split(strings.TrimSpace(AgentNames), "\n")
 	rand.Seed(time.Now().UnixNano())
 	return agents[rand.Intn(len(agents))]
-}
\ No newline at end of file
+}
+
+// LaunchCommand returns the line typed into an agent window to start the
+// agent command with its prompt
+func LaunchCommand(command, prompt string) string {
+	return fmt.Sprintf("%s \"%s\"", command, prompt)
+}
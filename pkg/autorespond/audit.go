@@ -0,0 +1,81 @@
+package autorespond
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Audit actions
+const (
+	ActionSend     = "send"
+	ActionEscalate = "escalate"
+)
+
+// Entry is one line of the audit log
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Session  string    `json:"session"`
+	Agent    string    `json:"agent"`
+	Worktree string    `json:"worktree,omitempty"`
+	Rule     string    `json:"rule"`
+	Action   string    `json:"action"`
+	Prompt   string    `json:"prompt"`
+	Keys     []string  `json:"keys,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	// Error is set when the keys could not be sent
+	Error string `json:"error,omitempty"`
+}
+
+// Audit appends entries to a JSON Lines file
+type Audit struct {
+	path string
+	mu   sync.Mutex
+}
+
+// DefaultAuditPath returns auto-audit.jsonl in the uzi data directory
+func DefaultAuditPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".local", "share", "uzi", "auto-audit.jsonl"), nil
+}
+
+// NewAudit returns an audit log that writes to path
+func NewAudit(path string) *Audit {
+	return &Audit{path: path}
+}
+
+// Path returns the file the audit log writes to
+func (a *Audit) Path() string {
+	return a.path
+}
+
+// Record appends an entry. The file is opened for every entry so that it
+// can be rotated or removed while uzi auto runs.
+func (a *Audit) Record(entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	file, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
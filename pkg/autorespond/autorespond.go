@@ -0,0 +1,337 @@
+// Package autorespond decides which agent prompts "uzi auto" answers on its
+// own and which it leaves to a human, and keeps an audit log of every key it
+// sends.
+package autorespond
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/devflowinc/uzi/pkg/config"
+	"github.com/devflowinc/uzi/pkg/status"
+)
+
+// DefaultLines is how many non-blank lines at the bottom of the screen the
+// rules and deny checks look at unless configured otherwise
+const DefaultLines = 20
+
+// defaultRules answer the prompts the watcher used to confirm with Enter.
+// Claude's permission prompts preselect "Yes", so Enter approves them.
+var defaultRules = []config.AutoRule{
+	{Name: "trust", Pattern: `Do you trust the files in this folder\?`},
+	{Name: "permission", Pattern: `Do you want to .*\?`},
+	{Name: "allow-command", Pattern: `^\W*Allow command`, Unless: `Thinking`},
+	{Name: "continue", Pattern: `Press Enter to continue|Continue\? \(Y/n\)`},
+	{Name: "proceed", Pattern: `Proceed\? \(y/N\)`, Keys: []string{"y", "Enter"}},
+}
+
+// defaultDeny are commands that are never approved automatically, whatever
+// the configuration says. The rm pattern takes the recursive and force
+// flags combined ("-rf") or apart, in any order ("-r -f", "--force -R").
+var defaultDeny = []string{
+	`\brm\s+(?:[^\s;&|]+\s+)*(?:-[a-zA-Z]*[rR][a-zA-Z]*f|-[a-zA-Z]*f[a-zA-Z]*[rR]|` +
+		`(?:-[a-zA-Z]*[rR][a-zA-Z]*|--recursive)\s+(?:[^\s;&|]+\s+)*(?:-[a-zA-Z]*f|--force)|` +
+		`(?:-[a-zA-Z]*f[a-zA-Z]*|--force)\s+(?:[^\s;&|]+\s+)*(?:-[a-zA-Z]*[rR]|--recursive))`,
+	`\bgit\s+push\b.*(?:\s--force\b|\s--force-with-lease\b|\s-f\b|\s\+\S)`,
+	`\bgit\s+reset\s+--hard\b`,
+	`\bgit\s+clean\s+-[a-zA-Z]*f`,
+	`\bgit\s+(?:branch\s+-D|checkout\s+--\s+\.|restore\s+\.)`,
+	`\bsudo\s`,
+	`\b(?:mkfs|shutdown|reboot)\b`,
+	`\bdd\s+if=`,
+	`\bchmod\s+-R\s+777\b`,
+	`\b(?:curl|wget)\b.*\|\s*(?:ba|z)?sh\b`,
+	`(?i)\bdrop\s+(?:table|database|schema)\b`,
+}
+
+// pathPattern finds absolute, home-relative and parent-relative paths in a
+// line. A path must follow whitespace, a quote, "=" or "(" so that URLs
+// ("https://...") are not taken for paths.
+var pathPattern = regexp.MustCompile(`(?:^|[\s'"=(])((?:~|\.\.)?/[^\s'"()<>|&;,]*)`)
+
+// boxEdge matches the top and bottom of the frame Claude draws around
+// prompts, and rules inside it
+var boxEdge = regexp.MustCompile(`^[\s╭╮╰╯┌┐└┘├┤─━═]*$`)
+
+// Rule answers one kind of prompt
+type Rule struct {
+	Name     string
+	Pattern  *regexp.Regexp
+	Keys     []string
+	Lines    int
+	Unless   *regexp.Regexp
+	Profiles []string
+}
+
+// Policy is the compiled autoRespond section of uzi.yaml
+type Policy struct {
+	Rules      []Rule
+	Deny       []*regexp.Regexp
+	AllowPaths []string
+	// AuditPath is the file every automatic keystroke is appended to
+	AuditPath string
+}
+
+// Agent is what the policy needs to know about the agent behind a screen
+type Agent struct {
+	// Model is the command the agent was started with; it selects the rules
+	// that are limited to some profiles
+	Model string
+	// Worktree is the agent's working directory. Prompts that mention paths
+	// outside of it are escalated. Empty skips the path check.
+	Worktree string
+}
+
+// Decision is what to do about a prompt found on an agent's screen
+type Decision struct {
+	Rule *Rule
+	// Prompt is the line the rule matched
+	Prompt string
+	// Context is the screen down to and including the prompt line. The
+	// same prompt shown again for the next command has the command and the
+	// output of the previous one above it, so its Context differs.
+	Context string
+	// Keys are sent to the agent; nil when the prompt is escalated
+	Keys []string
+	// Escalate says why the prompt is left to a human, if it is
+	Escalate string
+}
+
+// DefaultPolicy returns the built-in rules and deny patterns
+func DefaultPolicy() *Policy {
+	policy, err := Compile(config.AutoRespond{})
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in auto-response rules: %v", err))
+	}
+	return policy
+}
+
+// Compile validates an autoRespond configuration and compiles its patterns
+func Compile(cfg config.AutoRespond) (*Policy, error) {
+	lines := cfg.Lines
+	if lines < 0 {
+		return nil, fmt.Errorf("autoRespond: lines must not be negative")
+	}
+	if lines == 0 {
+		lines = DefaultLines
+	}
+
+	rules := cfg.Rules
+	if len(rules) == 0 {
+		rules = defaultRules
+	}
+	policy := &Policy{}
+	for i, rule := range rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if rule.Pattern == "" {
+			return nil, fmt.Errorf("autoRespond rule %s: pattern is required", name)
+		}
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("autoRespond rule %s: %w", name, err)
+		}
+		compiled := Rule{Name: name, Pattern: pattern, Keys: rule.Keys, Lines: rule.Lines, Profiles: rule.Profiles}
+		if len(compiled.Keys) == 0 {
+			compiled.Keys = []string{"Enter"}
+		}
+		if compiled.Lines < 0 {
+			return nil, fmt.Errorf("autoRespond rule %s: lines must not be negative", name)
+		}
+		if compiled.Lines == 0 {
+			compiled.Lines = lines
+		}
+		if rule.Unless != "" {
+			if compiled.Unless, err = regexp.Compile(rule.Unless); err != nil {
+				return nil, fmt.Errorf("autoRespond rule %s: unless: %w", name, err)
+			}
+		}
+		policy.Rules = append(policy.Rules, compiled)
+	}
+
+	for _, deny := range append(slices.Clone(defaultDeny), cfg.Deny...) {
+		pattern, err := regexp.Compile(deny)
+		if err != nil {
+			return nil, fmt.Errorf("autoRespond deny pattern %q: %w", deny, err)
+		}
+		policy.Deny = append(policy.Deny, pattern)
+	}
+
+	for _, path := range cfg.AllowPaths {
+		if !filepath.IsAbs(path) {
+			return nil, fmt.Errorf("autoRespond allowPaths: %q is not an absolute path", path)
+		}
+		policy.AllowPaths = append(policy.AllowPaths, filepath.Clean(path))
+	}
+
+	policy.AuditPath = cfg.Audit
+	if policy.AuditPath == "" {
+		path, err := DefaultAuditPath()
+		if err != nil {
+			return nil, err
+		}
+		policy.AuditPath = path
+	}
+	return policy, nil
+}
+
+// Load reads the autoRespond section of a config file. A missing file or
+// section gives the built-in policy.
+func Load(configPath string) (*Policy, error) {
+	cfg, err := config.LoadConfig(configPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return Compile(config.AutoRespond{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", configPath, err)
+	}
+	if cfg.AutoRespond == nil {
+		return Compile(config.AutoRespond{})
+	}
+	return Compile(*cfg.AutoRespond)
+}
+
+// Decide finds the first rule that answers a prompt on the screen and checks
+// the lines the rule looked at against the deny patterns and the worktree.
+// It returns false when no rule applies.
+func (p *Policy) Decide(screen string, agent Agent) (Decision, bool) {
+	lines := screenLines(screen)
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if !rule.appliesTo(agent.Model) {
+			continue
+		}
+		start := max(len(lines)-rule.Lines, 0)
+		window := lines[start:]
+		prompt, index, ok := rule.match(window)
+		if !ok {
+			continue
+		}
+		decision := Decision{
+			Rule:    rule,
+			Prompt:  prompt,
+			Context: strings.Join(lines[:start+index+1], "\n"),
+		}
+		if reason := p.check(window, agent.Worktree); reason != "" {
+			decision.Escalate = reason
+		} else {
+			decision.Keys = rule.Keys
+		}
+		return decision, true
+	}
+	return Decision{}, false
+}
+
+func (r *Rule) appliesTo(model string) bool {
+	return len(r.Profiles) == 0 || slices.Contains(r.Profiles, status.ProfileName(model))
+}
+
+// match returns the line the rule's pattern matched and its index, unless
+// its Unless pattern matches one of the lines too
+func (r *Rule) match(lines []string) (string, int, bool) {
+	prompt, index, found := "", 0, false
+	for i, line := range lines {
+		if r.Unless != nil && r.Unless.MatchString(line) {
+			return "", 0, false
+		}
+		if !found && r.Pattern.MatchString(line) {
+			prompt, index, found = strings.TrimSpace(line), i, true
+		}
+	}
+	return prompt, index, found
+}
+
+// check returns why the lines must not be answered automatically, or ""
+func (p *Policy) check(lines []string, worktree string) string {
+	for _, line := range append(slices.Clone(lines), joinWrapped(lines)...) {
+		for _, deny := range p.Deny {
+			if match := deny.FindString(line); match != "" {
+				return fmt.Sprintf("%q matches deny pattern %s", strings.TrimSpace(match), deny)
+			}
+		}
+	}
+	if worktree == "" {
+		return ""
+	}
+	for _, line := range lines {
+		for _, m := range pathPattern.FindAllStringSubmatch(line, -1) {
+			if path, outside := p.outside(m[1], worktree); outside {
+				return fmt.Sprintf("%s is outside the worktree", path)
+			}
+		}
+	}
+	return ""
+}
+
+// joinWrapped undoes the wrapping of long commands, which the screen capture
+// keeps as separate lines. It strips the box borders and joins each run of
+// lines between box edges and blank lines with spaces, for wraps between
+// words, and each pair of neighbouring lines without one, for wraps inside
+// a word.
+func joinWrapped(lines []string) []string {
+	var joined, block []string
+	flush := func() {
+		if len(block) > 1 {
+			joined = append(joined, strings.Join(block, " "))
+		}
+		block = nil
+	}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		line = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, "│"), "│"))
+		if line == "" || boxEdge.MatchString(line) {
+			flush()
+			continue
+		}
+		if len(block) > 0 {
+			joined = append(joined, block[len(block)-1]+line)
+		}
+		block = append(block, line)
+	}
+	flush()
+	return joined
+}
+
+// outside resolves a path found on the screen and reports whether it lies
+// outside the worktree and the allowed paths. Single-component absolute
+// paths are skipped, since they are far more often slash commands ("/help")
+// than files.
+func (p *Policy) outside(path, worktree string) (string, bool) {
+	path = strings.TrimRight(path, ".?!:")
+	switch {
+	case strings.HasPrefix(path, "~/"):
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return path, true
+		}
+		path = filepath.Join(home, path[2:])
+	case strings.HasPrefix(path, "../"):
+		path = filepath.Join(worktree, path)
+	case strings.Count(strings.TrimRight(path, "/"), "/") < 2:
+		return "", false
+	}
+	path = filepath.Clean(path)
+	for _, dir := range append([]string{filepath.Clean(worktree)}, p.AllowPaths...) {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return "", false
+		}
+	}
+	return path, true
+}
+
+// screenLines splits a screen into lines without the trailing blank ones,
+// which tmux pads the screen with
+func screenLines(screen string) []string {
+	lines := strings.Split(strings.ReplaceAll(screen, "\r\n", "\n"), "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,243 @@
+package autorespond
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/devflowinc/uzi/pkg/config"
+)
+
+const worktree = "/home/u/.local/share/uzi/worktrees/john"
+
+func permission(command string) string {
+	return "╭──────────────╮\n│ Bash command │\n│   " + command + " │\n│ Do you want to proceed? │\n│ ❯ 1. Yes │\n│   2. No │\n╰──────────────╯\n\n\n"
+}
+
+// wrapped is a permission prompt whose command wraps inside the box
+func wrapped(lines ...string) string {
+	box := "╭──────────────╮\n│ Bash command │\n"
+	for _, line := range lines {
+		box += "│   " + line + " │\n"
+	}
+	return box + "│ Do you want to proceed? │\n│ ❯ 1. Yes │\n│   2. No │\n╰──────────────╯\n"
+}
+
+func TestDefaultPolicy(t *testing.T) {
+	t.Setenv("HOME", "/home/u")
+	policy := DefaultPolicy()
+	agent := Agent{Model: "claude", Worktree: worktree}
+	tests := []struct {
+		name     string
+		screen   string
+		rule     string
+		keys     []string
+		escalate string
+	}{
+		{"trust prompt", "Do you trust the files in this folder?\n❯ 1. Yes, proceed\n", "trust", []string{"Enter"}, ""},
+		{"safe command", permission("go test ./..."), "permission", []string{"Enter"}, ""},
+		{"proceed defaults to no", "Proceed? (y/N)", "proceed", []string{"y", "Enter"}, ""},
+		{"allow command while thinking", "Allow command `ls`?\nThinking...", "", nil, ""},
+		{"allow command", "  Allow command `ls`?", "allow-command", []string{"Enter"}, ""},
+		{"no prompt", "✻ Working… (esc to interrupt)\n", "", nil, ""},
+		{"rm -rf", permission("rm -rf build"), "permission", nil, "rm -rf"},
+		{"rm -fr", permission("rm -fr build"), "permission", nil, "rm -fr"},
+		{"rm with split flags", permission("rm -r -f build"), "permission", nil, "rm -r -f"},
+		{"rm with flags after the operand", permission("rm -R build --force"), "permission", nil, "rm -R build --force"},
+		{"rm force then recursive", permission("rm -v --force -r build"), "permission", nil, "rm -v --force -r"},
+		{"rm long flags", permission("rm --recursive --force build"), "permission", nil, "rm --recursive --force"},
+		{"rm recursive only", permission("rm -r build"), "permission", []string{"Enter"}, ""},
+		{"rm force only", permission("rm -f build.log"), "permission", []string{"Enter"}, ""},
+		{"rm then another command", permission("rm -r build && git commit -f"), "permission", []string{"Enter"}, ""},
+		{"wrapped force push", wrapped("git push origin feature/a-very-long-branch-name-that-wraps", "--force"), "permission", nil, "git push"},
+		{"force push wrapped inside a word", wrapped("git push origin feature/long --for", "ce"), "permission", nil, "git push"},
+		{"wrapped rm", wrapped("rm -r", "-f build"), "permission", nil, "rm -r -f"},
+		{"wrapped plain push", wrapped("git push origin feature/a-very-long-branch-name-that-wraps", "--set-upstream"), "permission", []string{"Enter"}, ""},
+		{"force push", permission("git push --force origin main"), "permission", nil, "git push"},
+		{"short force push", permission("git push -f"), "permission", nil, "git push"},
+		{"plain push", permission("git push origin feature"), "permission", []string{"Enter"}, ""},
+		{"hard reset", permission("git reset --hard HEAD~1"), "permission", nil, "git reset --hard"},
+		{"sudo", permission("sudo apt install jq"), "permission", nil, "sudo"},
+		{"path in the worktree", permission("cat " + worktree + "/main.go"), "permission", []string{"Enter"}, ""},
+		{"absolute path outside", permission("cat /etc/passwd"), "permission", nil, "/etc/passwd is outside the worktree"},
+		{"home path", permission("cat ~/.ssh/id_rsa"), "permission", nil, "/home/u/.ssh/id_rsa is outside the worktree"},
+		{"parent path", permission("cp ../other/main.go ."), "permission", nil, "/home/u/.local/share/uzi/worktrees/other/main.go is outside the worktree"},
+		{"parent path back inside", permission("cat ../john/main.go"), "permission", []string{"Enter"}, ""},
+		{"url is not a path", permission("curl -s https://example.com/api/v1"), "permission", []string{"Enter"}, ""},
+		{"slash command is not a path", "> /help for help\n" + permission("ls"), "permission", []string{"Enter"}, ""},
+		{"edit prompt", "Do you want to make this edit to " + worktree + "/main.go?\n❯ 1. Yes\n", "permission", []string{"Enter"}, ""},
+		{"old dangerous output is escalated", "rm -rf old\n" + permission("ls"), "permission", nil, "rm -rf"},
+		{"dangerous output out of range", "rm -rf old\n" + strings.Repeat("line\n", 25) + permission("ls"), "permission", []string{"Enter"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, ok := policy.Decide(tt.screen, agent)
+			if tt.rule == "" {
+				if ok {
+					t.Fatalf("rule %s matched, want none", decision.Rule.Name)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("no rule matched, want %s", tt.rule)
+			}
+			if decision.Rule.Name != tt.rule {
+				t.Errorf("rule = %s, want %s", decision.Rule.Name, tt.rule)
+			}
+			if !slices.Equal(decision.Keys, tt.keys) {
+				t.Errorf("keys = %q, want %q", decision.Keys, tt.keys)
+			}
+			if tt.escalate == "" && decision.Escalate != "" {
+				t.Errorf("escalated: %s", decision.Escalate)
+			}
+			if !strings.Contains(decision.Escalate, tt.escalate) {
+				t.Errorf("escalate = %q, want it to contain %q", decision.Escalate, tt.escalate)
+			}
+		})
+	}
+}
+
+func TestDecisionContext(t *testing.T) {
+	t.Setenv("HOME", "/home/u")
+	policy := DefaultPolicy()
+	agent := Agent{Model: "claude", Worktree: worktree}
+
+	first, _ := policy.Decide(permission("go test ./..."), agent)
+	if !strings.HasSuffix(first.Context, "│ Do you want to proceed? │") {
+		t.Errorf("Context = %q, want it to end at the prompt line", first.Context)
+	}
+	if !strings.Contains(first.Context, "go test ./...") {
+		t.Errorf("Context = %q, want the command above the prompt", first.Context)
+	}
+
+	// Keys echoed below the prompt leave the context alone
+	echoed, _ := policy.Decide(permission("go test ./...")+"1\n", agent)
+	if echoed.Context != first.Context {
+		t.Errorf("Context changed by output below the prompt: %q", echoed.Context)
+	}
+
+	// The same prompt for the next command has the first run above it
+	next, _ := policy.Decide("● Bash(go test ./...)\n  ⎿  ok\n"+permission("go test ./..."), agent)
+	if next.Prompt != first.Prompt || next.Context == first.Context {
+		t.Errorf("next prompt = %q with context %q, want the same prompt in a new context", next.Prompt, next.Context)
+	}
+}
+
+func TestPolicyConfig(t *testing.T) {
+	policy, err := Compile(config.AutoRespond{
+		Lines: 5,
+		Rules: []config.AutoRule{
+			{Name: "codex-approve", Pattern: `Allow Codex to run`, Keys: []string{"a"}, Profiles: []string{"codex"}},
+			{Name: "menu", Pattern: `^Select an option`, Keys: []string{"2", "Enter"}, Unless: `Working`},
+		},
+		Deny:       []string{`\bnpm\s+publish\b`},
+		AllowPaths: []string{"/tmp"},
+		Audit:      "/var/log/uzi-audit.jsonl",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.AuditPath != "/var/log/uzi-audit.jsonl" {
+		t.Errorf("AuditPath = %s", policy.AuditPath)
+	}
+
+	codex := Agent{Model: "/usr/local/bin/codex --full-auto", Worktree: worktree}
+	claude := Agent{Model: "claude", Worktree: worktree}
+
+	if d, ok := policy.Decide("Allow Codex to run `ls`?", codex); !ok || !slices.Equal(d.Keys, []string{"a"}) {
+		t.Errorf("codex rule: %+v, %v", d, ok)
+	}
+	if _, ok := policy.Decide("Allow Codex to run `ls`?", claude); ok {
+		t.Error("codex rule applied to claude")
+	}
+	if _, ok := policy.Decide("Do you want to proceed?", claude); ok {
+		t.Error("configured rules should replace the built-in ones")
+	}
+	if _, ok := policy.Decide("Select an option\nWorking", claude); ok {
+		t.Error("unless pattern ignored")
+	}
+	if _, ok := policy.Decide("Select an option\n"+strings.Repeat("x\n", 5), claude); ok {
+		t.Error("lines limit ignored")
+	}
+	if d, _ := policy.Decide("npm publish\nSelect an option", claude); !strings.Contains(d.Escalate, "npm publish") {
+		t.Errorf("configured deny pattern: %+v", d)
+	}
+	if d, _ := policy.Decide("rm -rf dist\nSelect an option", claude); !strings.Contains(d.Escalate, "rm -rf") {
+		t.Errorf("built-in deny patterns must stay: %+v", d)
+	}
+	if d, _ := policy.Decide("write /tmp/out/log.txt\nSelect an option", claude); d.Escalate != "" {
+		t.Errorf("allowed path escalated: %s", d.Escalate)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.AutoRespond
+		want string
+	}{
+		{"missing pattern", config.AutoRespond{Rules: []config.AutoRule{{Name: "x"}}}, "pattern is required"},
+		{"bad pattern", config.AutoRespond{Rules: []config.AutoRule{{Name: "x", Pattern: "("}}}, "rule x"},
+		{"bad unless", config.AutoRespond{Rules: []config.AutoRule{{Pattern: "a", Unless: "["}}}, "rule #1: unless"},
+		{"bad deny", config.AutoRespond{Deny: []string{"("}}, "deny pattern"},
+		{"relative allow path", config.AutoRespond{AllowPaths: []string{"tmp"}}, "not an absolute path"},
+		{"negative lines", config.AutoRespond{Lines: -1}, "lines"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+
+	policy, err := Load(filepath.Join(dir, "missing.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policy.Rules) != len(defaultRules) || policy.AuditPath != filepath.Join(dir, ".local", "share", "uzi", "auto-audit.jsonl") {
+		t.Errorf("missing config: %d rules, audit %s", len(policy.Rules), policy.AuditPath)
+	}
+
+	path := filepath.Join(dir, "uzi.yaml")
+	os.WriteFile(path, []byte("autoRespond:\n  deny: ['terraform apply']\n"), 0644)
+	policy, err = Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policy.Rules) != len(defaultRules) || len(policy.Deny) != len(defaultDeny)+1 {
+		t.Errorf("deny only: %d rules, %d deny patterns", len(policy.Rules), len(policy.Deny))
+	}
+}
+
+func TestAudit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "audit.jsonl")
+	audit := NewAudit(path)
+	audit.Record(Entry{Session: "s", Agent: "john", Rule: "trust", Action: ActionSend, Prompt: "Do you trust?", Keys: []string{"Enter"}})
+	audit.Record(Entry{Session: "s", Agent: "john", Rule: "permission", Action: ActionEscalate, Prompt: "Do you want to proceed?", Reason: "rm -rf"})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines: %s", len(lines), data)
+	}
+	var entry Entry
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Action != ActionEscalate || entry.Reason != "rm -rf" || entry.Time.IsZero() || entry.Keys != nil {
+		t.Errorf("entry = %+v", entry)
+	}
+}
@@ -0,0 +1,433 @@
+// Package collector gathers the status and diff of many agents at once for
+// "uzi ls" and other overviews.
+package collector
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/devflowinc/uzi/pkg/gitdiff"
+	"github.com/devflowinc/uzi/pkg/notification"
+	"github.com/devflowinc/uzi/pkg/procstat"
+	"github.com/devflowinc/uzi/pkg/runner"
+	"github.com/devflowinc/uzi/pkg/state"
+	"github.com/devflowinc/uzi/pkg/status"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	// DefaultWorkers bounds how many agents are inspected at the same time
+	DefaultWorkers = 8
+	// DefaultMaxAge forces a fresh diff even when the worktree fingerprint
+	// is unchanged, covering edits the fingerprint cannot see
+	DefaultMaxAge = time.Minute
+	// UsageSampleGap is how long MeasureUsage waits between its first two
+	// process samples, over which CPU use is averaged
+	UsageSampleGap = 250 * time.Millisecond
+)
+
+// Window names whose processes are measured separately
+const (
+	agentWindow = "agent"
+	devWindow   = "uzi-dev"
+)
+
+// Agent is the collected view of one agent
+type Agent struct {
+	Session   string
+	State     state.AgentState
+	Status    status.DetailedStatus
+	StatusErr error
+	Diff      *gitdiff.Summary
+	DiffErr   error
+	// Drift is nil when the agent has no recorded base branch
+	Drift    *gitdiff.Drift
+	DriftErr error
+	// Dead is set by CollectAll for agents whose session no longer runs
+	Dead bool
+	// Usage is set by MeasureUsage for live agents
+	Usage    *Usage
+	UsageErr error
+}
+
+// Usage is the CPU and memory use of the processes in an agent's windows
+type Usage struct {
+	Agent procstat.Usage
+	Dev   procstat.Usage
+}
+
+type diffEntry struct {
+	fingerprint string
+	computedAt  time.Time
+	summary     *gitdiff.Summary
+}
+
+type driftEntry struct {
+	key   string
+	drift *gitdiff.Drift
+}
+
+// Collector inspects agents with a bounded worker pool and caches each
+// worktree's diff until its fingerprint changes. A Collector is safe for
+// concurrent use and is meant to live across refreshes, e.g. in "ls -w".
+type Collector struct {
+	workers int
+	maxAge  time.Duration
+
+	// Replaceable in tests
+	newTmuxClient func() status.TmuxClient
+	listSessions  func() ([]string, error)
+	diff          func(dir string) (*gitdiff.Summary, error)
+	fingerprint   func(dir string) (string, error)
+	drift         func(dir, base string) (*gitdiff.Drift, error)
+	driftKey      func(dir, base string) (string, error)
+	windowPIDs    func(ctx context.Context, session string) (map[string][]int, error)
+	notify        func(agent Agent) error
+	now           func() time.Time
+	sampler       *procstat.Sampler
+
+	mu     sync.Mutex
+	diffs  map[string]diffEntry
+	drifts map[string]driftEntry
+}
+
+// New returns a collector running at most workers inspections at once
+func New(workers int) *Collector {
+	if workers <= 0 {
+		workers = min(DefaultWorkers, runtime.NumCPU()*2)
+	}
+	return &Collector{
+		workers:       workers,
+		maxAge:        DefaultMaxAge,
+		newTmuxClient: status.DefaultTmuxClient,
+		listSessions:  func() ([]string, error) { return runner.Default().ListSessions() },
+		diff:          gitdiff.Worktree,
+		fingerprint:   gitdiff.Fingerprint,
+		drift:         gitdiff.BaseDrift,
+		driftKey:      gitdiff.DriftKey,
+		windowPIDs: func(ctx context.Context, session string) (map[string][]int, error) {
+			return runner.Default().WindowPIDs(ctx, session)
+		},
+		notify:  notify,
+		now:     time.Now,
+		sampler: procstat.NewSampler(),
+		diffs:   make(map[string]diffEntry),
+		drifts:  make(map[string]driftEntry),
+	}
+}
+
+// Collect inspects the given sessions and returns those that have state,
+// most recently updated first. The state file is read once per call.
+func (c *Collector) Collect(sm *state.StateManager, sessions []string) ([]Agent, error) {
+	states, err := sm.LoadStates()
+	if err != nil {
+		return nil, err
+	}
+
+	var agents []Agent
+	for _, session := range sessions {
+		if agentState, ok := states[session]; ok {
+			agents = append(agents, Agent{Session: session, State: agentState})
+		}
+	}
+	c.inspect(sm, states, agents)
+	return agents, nil
+}
+
+// CollectAll inspects every agent in the state file, from all repositories,
+// most recently updated first. Agents whose session has ended are returned
+// with Dead set; their worktree is still diffed but their screen is not read.
+func (c *Collector) CollectAll(sm *state.StateManager) ([]Agent, error) {
+	states, err := sm.LoadStates()
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := c.listSessions()
+	if err != nil {
+		return nil, fmt.Errorf("error listing sessions: %w", err)
+	}
+	live := make(map[string]bool, len(sessions))
+	for _, session := range sessions {
+		live[session] = true
+	}
+
+	agents := make([]Agent, 0, len(states))
+	for session, agentState := range states {
+		agents = append(agents, Agent{Session: session, State: agentState, Dead: !live[session]})
+	}
+	// Map iteration order is random; keep ties stable between refreshes
+	sort.Slice(agents, func(i, j int) bool { return agents[i].Session < agents[j].Session })
+	c.inspect(sm, states, agents)
+	return agents, nil
+}
+
+// MeasureUsage fills in the CPU and memory use of every live agent, split
+// into its agent and dev server windows. CPU use is averaged since the
+// previous call, so a long-lived collector reports use over each refresh;
+// the first call samples twice, UsageSampleGap apart.
+func (c *Collector) MeasureUsage(ctx context.Context, agents []Agent) error {
+	if !c.sampler.Ready() {
+		if err := c.sampler.Sample(); err != nil {
+			return err
+		}
+		select {
+		case <-time.After(UsageSampleGap):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if err := c.sampler.Sample(); err != nil {
+		return err
+	}
+
+	for i := range agents {
+		agent := &agents[i]
+		if agent.Dead {
+			continue
+		}
+		pids, err := c.windowPIDs(ctx, agent.Session)
+		if err != nil {
+			agent.UsageErr = err
+			continue
+		}
+		agent.Usage = &Usage{
+			Agent: c.sampler.Usage(pids[agentWindow]...),
+			Dev:   c.sampler.Usage(pids[devWindow]...),
+		}
+	}
+	return nil
+}
+
+// inspect sorts agents by UpdatedAt and fills in their status, diff and
+// drift with a bounded worker pool
+func (c *Collector) inspect(sm *state.StateManager, states map[string]state.AgentState, agents []Agent) {
+	sort.SliceStable(agents, func(i, j int) bool {
+		return agents[i].State.UpdatedAt.After(agents[j].State.UpdatedAt)
+	})
+
+	statusManager := status.NewStatusManager(c.newTmuxClient(), status.NewSnapshotAdapter(sm, states))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(c.workers, len(agents)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				agent := &agents[i]
+				if !agent.Dead {
+					agent.Status, agent.StatusErr = statusManager.GetDetailedStatus(agent.Session)
+				}
+				agent.Diff, agent.DiffErr = c.worktreeDiff(agent.State.WorktreePath)
+				if agent.State.BranchFrom != "" {
+					agent.Drift, agent.DriftErr = c.baseDrift(agent.State.WorktreePath, agent.State.BranchFrom)
+				}
+			}
+		}()
+	}
+	for i := range agents {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	c.recordStatuses(sm, agents)
+	recordReports(sm, agents)
+	c.prune(agents)
+}
+
+// recordStatuses tracks the status of every live agent in its status
+// history and saves the histories that changed in a single write. Tracking
+// runs against the histories read under the state file's lock, so that
+// other uzi processes recording at the same time do not lose each other's
+// transitions. Each agent's LastChanged becomes the time it entered its
+// current status. Agents that just started waiting for input or failed are
+// announced to the manager's notification server once, by the process that
+// records the transition. The error of a failed agent is saved with its
+// category and excerpt.
+func (c *Collector) recordStatuses(sm *state.StateManager, agents []Agent) {
+	now := c.now()
+	observed := make(map[string]string)
+	for _, agent := range agents {
+		if !agent.Dead && agent.StatusErr == nil {
+			observed[agent.Session] = agent.Status.Status
+		}
+	}
+	histories, changed, err := status.RecordStatuses(sm, observed, now)
+	if err != nil {
+		log.Debug("Failed to save status histories", "error", err)
+		return
+	}
+
+	var announce []Agent
+	errs := make(map[string]state.AgentError)
+	for i := range agents {
+		agent := &agents[i]
+		history, ok := histories[agent.Session]
+		if !ok {
+			continue
+		}
+		if changed[agent.Session] && (history.Current == status.StatusWaiting || history.Current == status.StatusError) {
+			announce = append(announce, *agent)
+		}
+		if history.Current == agent.Status.Status {
+			agent.State.StatusHistory = &history
+			agent.Status.LastChanged = history.Since
+		}
+		if class := agent.Status.Error; class != nil {
+			last := agent.State.LastError
+			if last == nil || last.Category != class.Category || last.Excerpt != class.Excerpt {
+				last = &state.AgentError{Category: class.Category, Excerpt: class.Excerpt, At: now}
+				errs[agent.Session] = *last
+			}
+			agent.State.LastError = last
+		}
+	}
+	if err := sm.SaveAgentErrors(errs); err != nil {
+		log.Debug("Failed to save agent errors", "error", err)
+	}
+	for _, agent := range announce {
+		if err := c.notify(agent); err != nil {
+			log.Debug("Failed to send notification", "session", agent.Session, "status", agent.Status.Status, "error", err)
+		}
+	}
+}
+
+// recordReports stores new or rewritten completion reports from the agents'
+// marker files in the state file, so they outlive the worktree. A report is
+// rewritten when the marker file's modification time changes.
+func recordReports(sm *state.StateManager, agents []Agent) {
+	changed := make(map[string]*state.CompletionReport)
+	for i := range agents {
+		agent := &agents[i]
+		if agent.Dead || agent.StatusErr != nil {
+			continue
+		}
+		if agent.Status.ReportErr != nil {
+			log.Debug("Failed to read completion report", "session", agent.Session, "error", agent.Status.ReportErr)
+		}
+		report := agent.Status.Report
+		if report == nil {
+			continue
+		}
+		if stored := agent.State.Report; stored == nil || !stored.CompletedAt.Equal(report.CompletedAt) {
+			changed[agent.Session] = report
+		}
+		agent.State.Report = report
+	}
+	if err := sm.SaveCompletionReports(changed); err != nil {
+		log.Debug("Failed to save completion reports", "error", err)
+	}
+}
+
+// notify tells the manager that an agent started waiting for input or failed
+func notify(agent Agent) error {
+	return Announce(agent.Session, agent.Status)
+}
+
+// Announce tells the manager that the agent in session started waiting for
+// input or failed. It is shared with "uzi auto", which records and announces
+// transitions while no ls or ui is running.
+func Announce(session string, st status.DetailedStatus) error {
+	client := notification.NewNotificationClient(notification.DefaultPort, session, state.AgentNameFromSession(session))
+	if st.Status == status.StatusError {
+		category, excerpt := status.ErrorUnknown, "The agent reported an error"
+		if class := st.Error; class != nil {
+			category, excerpt = class.Category, class.Excerpt
+		}
+		return client.NotifyAgentError(category, excerpt)
+	}
+	return notifyWaiting(client, st)
+}
+
+// notifyWaiting sends the pending question of an agent to the manager
+func notifyWaiting(client *notification.NotificationClient, st status.DetailedStatus) error {
+	question := "Waiting for input"
+	var options []string
+	if q := st.Question; q != nil {
+		question = q.Text
+		for _, option := range q.Options {
+			options = append(options, option.Key+". "+option.Label)
+		}
+	}
+	return client.NotifyWaiting(question, options)
+}
+
+// worktreeDiff returns the cached diff of dir while its fingerprint is
+// unchanged and recomputes it otherwise
+func (c *Collector) worktreeDiff(dir string) (*gitdiff.Summary, error) {
+	fingerprint, err := c.fingerprint(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	now := c.now()
+	c.mu.Lock()
+	entry, ok := c.diffs[dir]
+	c.mu.Unlock()
+	if ok && entry.fingerprint == fingerprint && now.Sub(entry.computedAt) < c.maxAge {
+		return entry.summary, nil
+	}
+
+	summary, err := c.diff(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.diffs[dir] = diffEntry{fingerprint: fingerprint, computedAt: now, summary: summary}
+	c.mu.Unlock()
+	return summary, nil
+}
+
+// baseDrift returns the cached drift of dir from base while neither its HEAD
+// nor the base tip has moved, and recomputes it otherwise
+func (c *Collector) baseDrift(dir, base string) (*gitdiff.Drift, error) {
+	// Refs that cannot be read directly, e.g. "main~2", are never cached
+	key, err := c.driftKey(dir, base)
+	if err == nil {
+		c.mu.Lock()
+		entry, ok := c.drifts[dir]
+		c.mu.Unlock()
+		if ok && entry.key == key && entry.drift.Base == base {
+			return entry.drift, nil
+		}
+	}
+
+	drift, derr := c.drift(dir, base)
+	if derr != nil {
+		return nil, derr
+	}
+	if err == nil {
+		c.mu.Lock()
+		c.drifts[dir] = driftEntry{key: key, drift: drift}
+		c.mu.Unlock()
+	}
+	return drift, nil
+}
+
+// prune drops cached diffs and drifts of worktrees that are no longer listed
+func (c *Collector) prune(agents []Agent) {
+	live := make(map[string]bool, len(agents))
+	for _, agent := range agents {
+		live[agent.State.WorktreePath] = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for dir := range c.diffs {
+		if !live[dir] {
+			delete(c.diffs, dir)
+		}
+	}
+	for dir := range c.drifts {
+		if !live[dir] {
+			delete(c.drifts, dir)
+		}
+	}
+}
@@ -0,0 +1,507 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devflowinc/uzi/pkg/gitdiff"
+	"github.com/devflowinc/uzi/pkg/state"
+	"github.com/devflowinc/uzi/pkg/status"
+)
+
+type fakeTmuxClient struct {
+	panes map[string]string
+}
+
+func (f *fakeTmuxClient) GetPaneContent(sessionName string) (string, error) {
+	content, ok := f.panes[sessionName]
+	if !ok {
+		return "", fmt.Errorf("no pane")
+	}
+	return content, nil
+}
+
+// setupStates writes a state file under a temporary HOME
+func setupStates(t *testing.T, states map[string]state.AgentState) *state.StateManager {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	sm := state.NewStateManager()
+	if err := os.MkdirAll(filepath.Dir(sm.GetStatePath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(states)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(sm.GetStatePath(), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return sm
+}
+
+func newTestCollector(workers int, panes map[string]string) *Collector {
+	c := New(workers)
+	c.newTmuxClient = func() status.TmuxClient { return &fakeTmuxClient{panes: panes} }
+	c.notify = func(agent Agent) error { return nil }
+	return c
+}
+
+func TestCollect(t *testing.T) {
+	now := time.Now()
+	sm := setupStates(t, map[string]state.AgentState{
+		"agent-p-1-old":  {WorktreePath: "/wt/old", UpdatedAt: now.Add(-time.Hour)},
+		"agent-p-1-new":  {WorktreePath: "/wt/new", UpdatedAt: now},
+		"agent-p-1-gone": {WorktreePath: "/wt/gone", UpdatedAt: now},
+	})
+
+	c := newTestCollector(4, map[string]string{
+		"agent-p-1-old": "esc to interrupt",
+		"agent-p-1-new": "$ ",
+	})
+	c.fingerprint = func(dir string) (string, error) { return "fp", nil }
+	c.diff = func(dir string) (*gitdiff.Summary, error) {
+		if dir == "/wt/old" {
+			return &gitdiff.Summary{Insertions: 5, Deletions: 1}, nil
+		}
+		return &gitdiff.Summary{}, nil
+	}
+
+	agents, err := c.Collect(sm, []string{"agent-p-1-old", "agent-p-1-new", "agent-p-1-missing"})
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if len(agents) != 2 {
+		t.Fatalf("got %d agents, want 2", len(agents))
+	}
+	if agents[0].Session != "agent-p-1-new" || agents[1].Session != "agent-p-1-old" {
+		t.Errorf("order = %s, %s; want most recently updated first", agents[0].Session, agents[1].Session)
+	}
+	if agents[1].Status.Status != status.StatusRunning || agents[0].Status.Status != status.StatusIdle {
+		t.Errorf("statuses = %s, %s", agents[0].Status.Status, agents[1].Status.Status)
+	}
+	if agents[1].Diff == nil || agents[1].Diff.Insertions != 5 {
+		t.Errorf("diff = %+v", agents[1].Diff)
+	}
+}
+
+func TestCollectCachesDiffs(t *testing.T) {
+	sm := setupStates(t, map[string]state.AgentState{
+		"agent-p-1-a": {WorktreePath: "/wt/a", UpdatedAt: time.Now()},
+	})
+
+	var diffs atomic.Int32
+	fingerprint := "one"
+	clock := time.Now()
+
+	c := newTestCollector(2, map[string]string{"agent-p-1-a": ""})
+	c.fingerprint = func(dir string) (string, error) { return fingerprint, nil }
+	c.diff = func(dir string) (*gitdiff.Summary, error) {
+		diffs.Add(1)
+		return &gitdiff.Summary{Insertions: int(diffs.Load())}, nil
+	}
+	c.now = func() time.Time { return clock }
+
+	collect := func() int {
+		t.Helper()
+		agents, err := c.Collect(sm, []string{"agent-p-1-a"})
+		if err != nil || len(agents) != 1 {
+			t.Fatalf("Collect() = %v, %v", agents, err)
+		}
+		return agents[0].Diff.Insertions
+	}
+
+	collect()
+	collect()
+	if got := diffs.Load(); got != 1 {
+		t.Errorf("diffs after unchanged refresh = %d, want 1", got)
+	}
+
+	fingerprint = "two"
+	if got := collect(); got != 2 {
+		t.Errorf("insertions after change = %d, want a fresh diff", got)
+	}
+
+	clock = clock.Add(DefaultMaxAge)
+	collect()
+	if got := diffs.Load(); got != 3 {
+		t.Errorf("diffs after max age = %d, want 3", got)
+	}
+}
+
+func TestCollectBoundsConcurrency(t *testing.T) {
+	states := make(map[string]state.AgentState)
+	var sessions []string
+	for i := 0; i < 20; i++ {
+		session := fmt.Sprintf("agent-p-1-a%d", i)
+		states[session] = state.AgentState{WorktreePath: fmt.Sprintf("/wt/%d", i), UpdatedAt: time.Now()}
+		sessions = append(sessions, session)
+	}
+	sm := setupStates(t, states)
+
+	var mu sync.Mutex
+	var running, peak int
+	c := newTestCollector(3, map[string]string{})
+	c.fingerprint = func(dir string) (string, error) { return dir, nil }
+	c.diff = func(dir string) (*gitdiff.Summary, error) {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return &gitdiff.Summary{}, nil
+	}
+
+	agents, err := c.Collect(sm, sessions)
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if len(agents) != 20 {
+		t.Fatalf("got %d agents, want 20", len(agents))
+	}
+	if peak > 3 || peak < 2 {
+		t.Errorf("peak concurrency = %d, want between 2 and 3", peak)
+	}
+}
+
+func TestCollectPrunesCache(t *testing.T) {
+	sm := setupStates(t, map[string]state.AgentState{
+		"agent-p-1-a": {WorktreePath: "/wt/a", UpdatedAt: time.Now()},
+		"agent-p-1-b": {WorktreePath: "/wt/b", UpdatedAt: time.Now()},
+	})
+
+	c := newTestCollector(2, map[string]string{})
+	c.fingerprint = func(dir string) (string, error) { return "fp", nil }
+	c.diff = func(dir string) (*gitdiff.Summary, error) { return &gitdiff.Summary{}, nil }
+
+	c.Collect(sm, []string{"agent-p-1-a", "agent-p-1-b"})
+	c.Collect(sm, []string{"agent-p-1-a"})
+	if _, ok := c.diffs["/wt/b"]; ok || len(c.diffs) != 1 {
+		t.Errorf("cache = %v, want only /wt/a", c.diffs)
+	}
+}
+
+func TestCollectCachesDrift(t *testing.T) {
+	sm := setupStates(t, map[string]state.AgentState{
+		"agent-p-1-a": {WorktreePath: "/wt/a", BranchFrom: "main", UpdatedAt: time.Now()},
+		"agent-p-1-b": {WorktreePath: "/wt/b", UpdatedAt: time.Now()},
+	})
+
+	var drifts atomic.Int32
+	key := "head|base"
+	c := newTestCollector(2, map[string]string{})
+	c.fingerprint = func(dir string) (string, error) { return "fp", nil }
+	c.diff = func(dir string) (*gitdiff.Summary, error) { return &gitdiff.Summary{}, nil }
+	c.driftKey = func(dir, base string) (string, error) { return key, nil }
+	c.drift = func(dir, base string) (*gitdiff.Drift, error) {
+		if dir != "/wt/a" || base != "main" {
+			t.Errorf("drift(%s, %s) called for an agent without a base", dir, base)
+		}
+		drifts.Add(1)
+		return &gitdiff.Drift{Base: base, Ahead: 2, Behind: int(drifts.Load())}, nil
+	}
+
+	collect := func() map[string]Agent {
+		t.Helper()
+		agents, err := c.Collect(sm, []string{"agent-p-1-a", "agent-p-1-b"})
+		if err != nil {
+			t.Fatalf("Collect() error = %v", err)
+		}
+		bySession := make(map[string]Agent)
+		for _, agent := range agents {
+			bySession[agent.Session] = agent
+		}
+		return bySession
+	}
+
+	agents := collect()
+	if agents["agent-p-1-b"].Drift != nil {
+		t.Errorf("drift of an agent without a base = %+v", agents["agent-p-1-b"].Drift)
+	}
+	if drift := agents["agent-p-1-a"].Drift; drift == nil || drift.Ahead != 2 || drift.Behind != 1 {
+		t.Errorf("drift = %+v", drift)
+	}
+
+	collect()
+	if got := drifts.Load(); got != 1 {
+		t.Errorf("drifts after unchanged refresh = %d, want 1", got)
+	}
+
+	key = "head|moved"
+	if drift := collect()["agent-p-1-a"].Drift; drift.Behind != 2 {
+		t.Errorf("drift after the base moved = %+v, want a fresh one", drift)
+	}
+}
+
+func TestCollectAll(t *testing.T) {
+	now := time.Now()
+	sm := setupStates(t, map[string]state.AgentState{
+		"agent-a-1-live": {GitRepo: "git@x:a.git", WorktreePath: "/wt/live", UpdatedAt: now},
+		"agent-b-1-dead": {GitRepo: "git@x:b.git", WorktreePath: "/wt/dead", UpdatedAt: now.Add(-time.Hour)},
+	})
+
+	c := newTestCollector(2, map[string]string{"agent-a-1-live": "esc to interrupt"})
+	c.listSessions = func() ([]string, error) { return []string{"agent-a-1-live", "unrelated"}, nil }
+	c.fingerprint = func(dir string) (string, error) { return "fp", nil }
+	c.diff = func(dir string) (*gitdiff.Summary, error) { return &gitdiff.Summary{Insertions: 1}, nil }
+
+	agents, err := c.CollectAll(sm)
+	if err != nil {
+		t.Fatalf("CollectAll() error = %v", err)
+	}
+	if len(agents) != 2 {
+		t.Fatalf("got %d agents, want 2", len(agents))
+	}
+	live, dead := agents[0], agents[1]
+	if live.Dead || StatusOf(live) != status.StatusRunning {
+		t.Errorf("live agent = %+v", live)
+	}
+	if !dead.Dead || StatusOf(dead) != status.StatusDead || dead.StatusErr != nil {
+		t.Errorf("dead agent = %+v", dead)
+	}
+	if dead.Diff == nil || dead.Diff.Insertions != 1 {
+		t.Errorf("dead agent diff = %+v, want the worktree still diffed", dead.Diff)
+	}
+}
+
+func TestCollectRecordsStatusHistory(t *testing.T) {
+	sm := setupStates(t, map[string]state.AgentState{
+		"agent-p-1-a": {WorktreePath: "/wt/a", UpdatedAt: time.Now()},
+	})
+
+	panes := map[string]string{"agent-p-1-a": "esc to interrupt"}
+	clock := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	c := newTestCollector(1, panes)
+	c.fingerprint = func(dir string) (string, error) { return "fp", nil }
+	c.diff = func(dir string) (*gitdiff.Summary, error) { return &gitdiff.Summary{}, nil }
+	c.now = func() time.Time { return clock }
+
+	collect := func() Agent {
+		t.Helper()
+		agents, err := c.Collect(sm, []string{"agent-p-1-a"})
+		if err != nil || len(agents) != 1 {
+			t.Fatalf("Collect() = %v, %v", agents, err)
+		}
+		return agents[0]
+	}
+
+	collect()
+	clock = clock.Add(12 * time.Minute)
+	collect()
+	panes["agent-p-1-a"] = "$ "
+	clock = clock.Add(time.Minute)
+	agent := collect()
+	if !agent.Status.LastChanged.Equal(clock) {
+		t.Errorf("LastChanged = %v, want %v", agent.Status.LastChanged, clock)
+	}
+
+	states, err := sm.LoadStates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := states["agent-p-1-a"].StatusHistory
+	if history == nil || history.Current != status.StatusIdle || history.Flips != 1 {
+		t.Fatalf("saved history = %+v", history)
+	}
+	if got := status.TimeInStatus(history, status.StatusRunning, clock); got != 13*time.Minute {
+		t.Errorf("running time = %v, want 13m", got)
+	}
+}
+
+func TestCollectNotifiesWaitingOnce(t *testing.T) {
+	sm := setupStates(t, map[string]state.AgentState{
+		"agent-p-1-a": {WorktreePath: "/wt/a", UpdatedAt: time.Now()},
+	})
+
+	panes := map[string]string{"agent-p-1-a": "esc to interrupt"}
+	c := newTestCollector(1, panes)
+	c.fingerprint = func(dir string) (string, error) { return "fp", nil }
+	c.diff = func(dir string) (*gitdiff.Summary, error) { return &gitdiff.Summary{}, nil }
+	var notified []*status.Question
+	c.notify = func(agent Agent) error {
+		notified = append(notified, agent.Status.Question)
+		return nil
+	}
+
+	c.Collect(sm, []string{"agent-p-1-a"})
+	panes["agent-p-1-a"] = " Bash command\n   rm -rf build\n Do you want to proceed?\n ❯ 1. Yes\n   2. No\n"
+	c.Collect(sm, []string{"agent-p-1-a"})
+	c.Collect(sm, []string{"agent-p-1-a"})
+
+	if len(notified) != 1 {
+		t.Fatalf("notified %d times, want once", len(notified))
+	}
+	if q := notified[0]; q == nil || q.Text != "Do you want to proceed?" || len(q.Options) != 2 {
+		t.Errorf("notified question = %+v", q)
+	}
+}
+
+func TestCollectKeepsTransitionsOfOtherProcesses(t *testing.T) {
+	sm := setupStates(t, map[string]state.AgentState{
+		"agent-p-1-a": {WorktreePath: "/wt/a", UpdatedAt: time.Now()},
+	})
+
+	panes := map[string]string{"agent-p-1-a": "esc to interrupt"}
+	c := newTestCollector(1, panes)
+	c.fingerprint = func(dir string) (string, error) { return "fp", nil }
+	clock := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return clock }
+	notified := 0
+	c.notify = func(agent Agent) error {
+		notified++
+		return nil
+	}
+	c.Collect(sm, []string{"agent-p-1-a"})
+
+	// Another uzi process records the same transition after this one read
+	// the state file and before it saves
+	other := clock.Add(time.Minute)
+	c.diff = func(dir string) (*gitdiff.Summary, error) {
+		if _, _, err := status.RecordStatuses(sm, map[string]string{"agent-p-1-a": status.StatusWaiting}, other); err != nil {
+			t.Error(err)
+		}
+		return &gitdiff.Summary{}, nil
+	}
+	panes["agent-p-1-a"] = " Do you want to proceed?\n ❯ 1. Yes\n   2. No\n"
+	clock = clock.Add(2 * time.Minute)
+	agents, _ := c.Collect(sm, []string{"agent-p-1-a"})
+
+	if notified != 0 {
+		t.Errorf("notified %d times, want none: the other process announced the transition", notified)
+	}
+	if since := agents[0].Status.LastChanged; !since.Equal(other) {
+		t.Errorf("LastChanged = %v, want the other process's %v", since, other)
+	}
+	states, err := sm.LoadStates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := states["agent-p-1-a"].StatusHistory
+	if history == nil || len(history.Transitions) != 1 || !history.Since.Equal(other) {
+		t.Errorf("saved history = %+v, want the one transition the other process recorded", history)
+	}
+}
+
+func TestCollectRecordsClassifiedErrors(t *testing.T) {
+	sm := setupStates(t, map[string]state.AgentState{
+		"agent-p-1-a": {WorktreePath: "/wt/a", UpdatedAt: time.Now()},
+	})
+
+	// A failing test run in the agent's own output is not an error
+	panes := map[string]string{"agent-p-1-a": "--- FAIL: TestParse (0.00s)\nError: expected 3, got 4\n> "}
+	c := newTestCollector(1, panes)
+	c.fingerprint = func(dir string) (string, error) { return "fp", nil }
+	c.diff = func(dir string) (*gitdiff.Summary, error) { return &gitdiff.Summary{}, nil }
+	var notified []Agent
+	c.notify = func(agent Agent) error {
+		notified = append(notified, agent)
+		return nil
+	}
+
+	agents, _ := c.Collect(sm, []string{"agent-p-1-a"})
+	if got := agents[0].Status.Status; got != status.StatusIdle {
+		t.Fatalf("status with a failing test = %s, want idle", got)
+	}
+
+	panes["agent-p-1-a"] = "  ⎿  API Error: 529 {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\"}}\n> "
+	c.Collect(sm, []string{"agent-p-1-a"})
+	c.Collect(sm, []string{"agent-p-1-a"})
+
+	if len(notified) != 1 || notified[0].Status.Error == nil || notified[0].Status.Error.Category != status.ErrorAPI {
+		t.Fatalf("notified = %+v, want one api error", notified)
+	}
+	states, err := sm.LoadStates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := states["agent-p-1-a"].LastError
+	if last == nil || last.Category != status.ErrorAPI || !strings.Contains(last.Excerpt, "overloaded_error") {
+		t.Errorf("saved error = %+v", last)
+	}
+}
+
+func TestMeasureUsage(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("process statistics need /proc")
+	}
+
+	c := New(1)
+	c.windowPIDs = func(ctx context.Context, session string) (map[string][]int, error) {
+		if session == "agent-p-1-broken" {
+			return nil, fmt.Errorf("no session")
+		}
+		// The test binary stands in for the agent window; there is no dev server
+		return map[string][]int{"agent": {os.Getpid()}}, nil
+	}
+
+	agents := []Agent{{Session: "agent-p-1-a"}, {Session: "agent-p-1-broken"}, {Session: "agent-p-1-dead", Dead: true}}
+	if err := c.MeasureUsage(context.Background(), agents); err != nil {
+		t.Fatalf("MeasureUsage() error = %v", err)
+	}
+
+	usage := agents[0].Usage
+	if usage == nil || usage.Agent.Processes < 1 || usage.Agent.RSS == 0 {
+		t.Errorf("agent usage = %+v", usage)
+	}
+	if usage != nil && usage.Dev.Processes != 0 {
+		t.Errorf("dev usage = %+v, want nothing without a dev window", usage.Dev)
+	}
+	if agents[1].Usage != nil || agents[1].UsageErr == nil {
+		t.Errorf("broken agent usage = %+v, %v", agents[1].Usage, agents[1].UsageErr)
+	}
+	if agents[2].Usage != nil || agents[2].UsageErr != nil {
+		t.Error("dead agent was measured")
+	}
+}
+
+func TestCollectRecordsCompletionReport(t *testing.T) {
+	worktree := t.TempDir()
+	sm := setupStates(t, map[string]state.AgentState{
+		"agent-p-1-a": {WorktreePath: worktree, UpdatedAt: time.Now()},
+	})
+	marker := filepath.Join(worktree, status.MarkerFile)
+	if err := os.WriteFile(marker, []byte(`{"summary": "Add retry", "tests": {"command": "go test ./...", "result": "passed"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestCollector(1, map[string]string{"agent-p-1-a": "$ "})
+	c.fingerprint = func(dir string) (string, error) { return "fp", nil }
+	c.diff = func(dir string) (*gitdiff.Summary, error) { return &gitdiff.Summary{}, nil }
+
+	agents, err := c.Collect(sm, []string{"agent-p-1-a"})
+	if err != nil || len(agents) != 1 {
+		t.Fatalf("Collect() = %v, %v", agents, err)
+	}
+	if agents[0].Status.Status != status.StatusReady || agents[0].State.Report == nil {
+		t.Fatalf("agent = %s, report %+v", agents[0].Status.Status, agents[0].State.Report)
+	}
+
+	states, err := sm.LoadStates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := states["agent-p-1-a"].Report
+	if report == nil || report.Summary != "Add retry" || report.Tests.Result != status.TestsPassed {
+		t.Fatalf("saved report = %+v", report)
+	}
+
+	// The report outlives the marker file
+	os.Remove(marker)
+	c.Collect(sm, []string{"agent-p-1-a"})
+	if states, _ := sm.LoadStates(); states["agent-p-1-a"].Report == nil {
+		t.Error("report was dropped when the marker file was removed")
+	}
+}
@@ -0,0 +1,152 @@
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/devflowinc/uzi/pkg/state"
+	"github.com/devflowinc/uzi/pkg/status"
+)
+
+// Sort keys understood by Sort
+const (
+	SortName    = "name"
+	SortStatus  = "status"
+	SortDiff    = "diff"
+	SortCreated = "created"
+	SortUpdated = "updated"
+)
+
+// SortKeys lists every valid sort key
+var SortKeys = []string{SortName, SortStatus, SortDiff, SortCreated, SortUpdated}
+
+// IsSortKey reports whether key is understood by Sort
+func IsSortKey(key string) bool {
+	for _, k := range SortKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// statusRank orders statuses so that agents needing attention come first
+var statusRank = map[string]int{
+	status.StatusError:   0,
+	status.StatusWaiting: 1,
+	status.StatusReady:   2,
+	status.StatusRunning: 3,
+	status.StatusIdle:    4,
+	status.StatusMerged:  5,
+	status.StatusDead:    6,
+}
+
+// Filter selects agents. Empty fields match everything.
+type Filter struct {
+	Statuses    []string
+	Model       string
+	Label       string
+	StuckOnly   bool
+	ChangedOnly bool
+}
+
+// StatusOf returns the collected status of an agent, "dead" when its session
+// has ended and "unknown" when it could not be determined
+func StatusOf(agent Agent) string {
+	if agent.Dead {
+		return status.StatusDead
+	}
+	if agent.StatusErr != nil || agent.Status.Status == "" {
+		return "unknown"
+	}
+	return agent.Status.Status
+}
+
+// Changed reports whether the agent has uncommitted changes
+func Changed(agent Agent) bool {
+	return agent.Diff != nil && (len(agent.Diff.Files) > 0 || agent.Diff.Insertions+agent.Diff.Deletions > 0)
+}
+
+// Match reports whether an agent passes the filter
+func (f Filter) Match(agent Agent) bool {
+	if len(f.Statuses) > 0 {
+		matched := false
+		for _, st := range f.Statuses {
+			if strings.EqualFold(st, StatusOf(agent)) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if f.Model != "" && !strings.EqualFold(f.Model, agent.State.Model) {
+		return false
+	}
+	if f.Label != "" && !agent.State.HasLabel(f.Label) {
+		return false
+	}
+	if f.StuckOnly && (agent.StatusErr != nil || !agent.Status.IsStuck) {
+		return false
+	}
+	if f.ChangedOnly && !Changed(agent) {
+		return false
+	}
+	return true
+}
+
+// Apply returns the agents that pass the filter, keeping their order
+func (f Filter) Apply(agents []Agent) []Agent {
+	var matched []Agent
+	for _, agent := range agents {
+		if f.Match(agent) {
+			matched = append(matched, agent)
+		}
+	}
+	return matched
+}
+
+// Sort orders agents in place by key. Names sort alphabetically, statuses
+// from error to dead, diffs and times largest/newest first; reverse flips
+// the order. Ties keep their previous order.
+func Sort(agents []Agent, key string, reverse bool) error {
+	var less func(a, b Agent) bool
+	switch key {
+	case SortName:
+		less = func(a, b Agent) bool {
+			return state.AgentNameFromSession(a.Session) < state.AgentNameFromSession(b.Session)
+		}
+	case SortStatus:
+		rank := func(agent Agent) int {
+			if r, ok := statusRank[StatusOf(agent)]; ok {
+				return r
+			}
+			return len(statusRank)
+		}
+		less = func(a, b Agent) bool { return rank(a) < rank(b) }
+	case SortDiff:
+		size := func(agent Agent) int {
+			if agent.Diff == nil {
+				return 0
+			}
+			return agent.Diff.Insertions + agent.Diff.Deletions
+		}
+		less = func(a, b Agent) bool { return size(a) > size(b) }
+	case SortCreated:
+		less = func(a, b Agent) bool { return a.State.CreatedAt.After(b.State.CreatedAt) }
+	case SortUpdated:
+		less = func(a, b Agent) bool { return a.State.UpdatedAt.After(b.State.UpdatedAt) }
+	default:
+		return fmt.Errorf("unknown sort key %q (want %s)", key, strings.Join(SortKeys, ", "))
+	}
+
+	sort.SliceStable(agents, func(i, j int) bool {
+		if reverse {
+			return less(agents[j], agents[i])
+		}
+		return less(agents[i], agents[j])
+	})
+	return nil
+}
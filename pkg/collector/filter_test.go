@@ -0,0 +1,105 @@
+package collector
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devflowinc/uzi/pkg/gitdiff"
+	"github.com/devflowinc/uzi/pkg/state"
+	"github.com/devflowinc/uzi/pkg/status"
+)
+
+func testAgents() []Agent {
+	now := time.Now()
+	return []Agent{
+		{
+			Session: "agent-p-1-bravo",
+			State:   state.AgentState{Model: "claude", Labels: []string{"backend"}, CreatedAt: now.Add(-3 * time.Hour), UpdatedAt: now},
+			Status:  status.DetailedStatus{Status: status.StatusRunning},
+			Diff:    &gitdiff.Summary{Insertions: 10, Deletions: 2, Files: []gitdiff.FileChange{{Path: "a.go"}}},
+		},
+		{
+			Session: "agent-p-1-alpha",
+			State:   state.AgentState{Model: "codex", CreatedAt: now.Add(-1 * time.Hour), UpdatedAt: now.Add(-2 * time.Hour)},
+			Status:  status.DetailedStatus{Status: status.StatusIdle},
+			Diff:    &gitdiff.Summary{},
+		},
+		{
+			Session: "agent-p-1-charlie",
+			State:   state.AgentState{Model: "Claude", Labels: []string{"frontend"}, CreatedAt: now.Add(-2 * time.Hour), UpdatedAt: now.Add(-time.Hour)},
+			Status:  status.DetailedStatus{Status: status.StatusError, IsStuck: true},
+			Diff:    &gitdiff.Summary{Insertions: 1},
+		},
+		{
+			Session:   "agent-p-1-delta",
+			State:     state.AgentState{Model: "claude", UpdatedAt: now.Add(-3 * time.Hour)},
+			StatusErr: errors.New("no pane"),
+		},
+	}
+}
+
+func names(agents []Agent) string {
+	var out []string
+	for _, agent := range agents {
+		out = append(out, state.AgentNameFromSession(agent.Session))
+	}
+	return strings.Join(out, ",")
+}
+
+func TestFilterApply(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   string
+	}{
+		{"empty", Filter{}, "bravo,alpha,charlie,delta"},
+		{"statuses", Filter{Statuses: []string{"running", "ERROR"}}, "bravo,charlie"},
+		{"unknown status", Filter{Statuses: []string{"unknown"}}, "delta"},
+		{"model", Filter{Model: "claude"}, "bravo,charlie,delta"},
+		{"label", Filter{Label: "backend"}, "bravo"},
+		{"stuck", Filter{StuckOnly: true}, "charlie"},
+		{"changed", Filter{ChangedOnly: true}, "bravo,charlie"},
+		{"combined", Filter{Model: "claude", ChangedOnly: true, Statuses: []string{"running"}}, "bravo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := names(tt.filter.Apply(testAgents())); got != tt.want {
+				t.Errorf("Apply() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSort(t *testing.T) {
+	tests := []struct {
+		key     string
+		reverse bool
+		want    string
+	}{
+		{SortName, false, "alpha,bravo,charlie,delta"},
+		{SortName, true, "delta,charlie,bravo,alpha"},
+		{SortStatus, false, "charlie,bravo,alpha,delta"},
+		{SortDiff, false, "bravo,charlie,alpha,delta"},
+		{SortCreated, false, "alpha,charlie,bravo,delta"},
+		{SortUpdated, false, "bravo,charlie,alpha,delta"},
+		{SortUpdated, true, "delta,alpha,charlie,bravo"},
+	}
+	for _, tt := range tests {
+		agents := testAgents()
+		if err := Sort(agents, tt.key, tt.reverse); err != nil {
+			t.Fatalf("Sort(%s) error = %v", tt.key, err)
+		}
+		if got := names(agents); got != tt.want {
+			t.Errorf("Sort(%s, reverse=%v) = %s, want %s", tt.key, tt.reverse, got, tt.want)
+		}
+	}
+
+	if err := Sort(testAgents(), "size", false); err == nil {
+		t.Error("Sort() with an unknown key succeeded")
+	}
+	if IsSortKey("size") || !IsSortKey(SortDiff) {
+		t.Error("IsSortKey() is wrong")
+	}
+}
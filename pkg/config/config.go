@@ -7,14 +7,137 @@ import (
 )
 
 type Config struct {
-	DevCommand *string `yaml:"devCommand"`
-	PortRange  *string `yaml:"portRange"`
+	DevCommand  *string                  `yaml:"devCommand"`
+	PortRange   *string                  `yaml:"portRange"`
+	TmuxSocket  *string                  `yaml:"tmuxSocket"`
+	Runner      *string                  `yaml:"runner"`
+	StatusRules map[string]StatusProfile `yaml:"statusRules"`
+	AutoRespond *AutoRespond             `yaml:"autoRespond"`
+}
+
+// AutoRespond is the policy "uzi auto" follows when an agent asks for
+// confirmation: which prompts it answers and with which keys, and which
+// prompts it leaves to a human because the command looks dangerous
+type AutoRespond struct {
+	// Lines limits every rule and the deny checks to the last non-blank
+	// lines of the screen; 0 means 20
+	Lines int `yaml:"lines"`
+	// Rules replace the built-in rules when set
+	Rules []AutoRule `yaml:"rules"`
+	// Deny lists regular expressions that make uzi auto escalate to a
+	// human instead of answering. They are added to the built-in patterns.
+	Deny []string `yaml:"deny"`
+	// AllowPaths lists directories outside the agent's worktree that a
+	// prompt may mention without being escalated, e.g. "/tmp"
+	AllowPaths []string `yaml:"allowPaths"`
+	// Audit is the file every automatic keystroke is appended to; empty
+	// means auto-audit.jsonl in the uzi data directory
+	Audit string `yaml:"audit"`
+}
+
+// AutoRule answers a prompt: when Pattern matches a line of the screen,
+// Keys are sent to the agent
+type AutoRule struct {
+	Name    string `yaml:"name"`
+	Pattern string `yaml:"pattern"`
+	// Keys are tmux key names or literal text; empty means Enter
+	Keys []string `yaml:"keys"`
+	// Lines overrides AutoRespond.Lines for this rule
+	Lines int `yaml:"lines"`
+	// Unless skips the rule when it matches anywhere in the rule's lines,
+	// e.g. while the agent is still thinking
+	Unless string `yaml:"unless"`
+	// Profiles limits the rule to agents started with these commands, as
+	// in statusRules; empty means every agent
+	Profiles []string `yaml:"profiles"`
+}
+
+// StatusProfile is the set of screen rules that detect the status of agents
+// running one command, e.g. "claude" or "codex". The profile named
+// "default" applies to commands without a profile of their own.
+type StatusProfile struct {
+	// Lines limits every rule to the last non-blank lines of the screen;
+	// 0 means the whole screen
+	Lines int `yaml:"lines"`
+	// A profile other than default that has no rules uses the rules of the
+	// default profile, so it can change only Stuck
+	Rules []StatusRule `yaml:"rules"`
+	Stuck StuckConfig  `yaml:"stuck"`
+	// Restart decides whether "uzi auto" relaunches the agent when its
+	// process exits
+	Restart RestartConfig `yaml:"restart"`
+	// Nudge decides whether "uzi auto" prompts an idle agent to go on
+	Nudge NudgeConfig `yaml:"nudge"`
+}
+
+// NudgeConfig decides when "uzi auto" sends a follow-up message to an agent
+// that went idle without writing its task marker file
+type NudgeConfig struct {
+	// After is how long the agent must be idle with an unchanged screen,
+	// e.g. "3m"; empty or "0" turns nudges off
+	After string `yaml:"after"`
+	// Message is a text/template for the follow-up message; empty means a
+	// built-in one asking the agent to continue and write the marker file
+	Message string `yaml:"message"`
+	// MaxNudges limits the nudges per agent, after which the agent is
+	// reported with an error notification instead; 0 means 3
+	MaxNudges int `yaml:"maxNudges"`
+}
+
+// RestartConfig is the restart policy "uzi auto" applies when the agent
+// command exits and its window drops back to the shell
+type RestartConfig struct {
+	// Policy is "never" (the default), "on-failure" (the agent exited
+	// without writing its task marker file) or "always"
+	Policy string `yaml:"policy"`
+	// MaxRetries limits the restarts of one agent; 0 means 3
+	MaxRetries int `yaml:"maxRetries"`
+	// Backoff is the wait before the first restart, doubled for each
+	// further one; empty means 10s
+	Backoff string `yaml:"backoff"`
+	// ResumePrompt is given to the relaunched agent instead of its
+	// original prompt
+	ResumePrompt string `yaml:"resumePrompt"`
+}
+
+// StuckConfig decides when a running agent is reported as stuck: when none
+// of its activity signals has changed for After
+type StuckConfig struct {
+	// After is a duration such as "10m"; empty means 5m and "0" turns stuck
+	// detection off
+	After string `yaml:"after"`
+	// Signals lists the activity that counts: "screen" (the agent window's
+	// content), "worktree" (file modification times) and "transcript"
+	// (output written to the agent's transcript). Empty means all three.
+	Signals []string `yaml:"signals"`
+	// Ignore lists regular expressions removed from every screen line
+	// before the screen is compared, e.g. a spinner's elapsed-time counter
+	Ignore []string `yaml:"ignore"`
+}
+
+// StatusRule maps a regular expression over the agent screen to a status.
+// Rules are tried by descending priority, then in the order they are listed,
+// and the first match wins.
+type StatusRule struct {
+	Name     string `yaml:"name"`
+	Pattern  string `yaml:"pattern"`
+	Status   string `yaml:"status"`
+	Priority int    `yaml:"priority"`
+	// Lines overrides the profile's Lines for this rule
+	Lines int `yaml:"lines"`
+	// Category classifies the errors an error rule finds: "crash", "api",
+	// "tool" or "build". Empty lets uzi classify each error from the
+	// matching line and its neighbours. Tool and build failures are part of
+	// the agent's work and do not put it in the error status.
+	Category string `yaml:"category"`
 }
 
 func DefaultConfig() Config {
 	return Config{
 		DevCommand: nil,
 		PortRange:  nil,
+		TmuxSocket: nil,
+		Runner:     nil,
 	}
 }
 
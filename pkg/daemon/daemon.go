@@ -0,0 +1,217 @@
+// Package daemon runs "uzi auto" in the background, one instance per
+// repository, and keeps track of it through a pidfile and a log file in the
+// uzi data directory.
+package daemon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrRunning is returned when the repository already has a watcher
+	ErrRunning = errors.New("uzi auto is already running for this repository")
+	// ErrNotRunning is returned when there is no watcher to stop
+	ErrNotRunning = errors.New("uzi auto is not running for this repository")
+)
+
+// StartTimeout is how long Start waits for the watcher to write its pidfile
+var StartTimeout = 5 * time.Second
+
+// Daemon is the background watcher of one repository
+type Daemon struct {
+	// Repo is the remote URL that identifies the repository, as recorded
+	// in each agent's state
+	Repo    string
+	PIDPath string
+	LogPath string
+}
+
+// Status describes a watcher found through its pidfile
+type Status struct {
+	Running bool
+	PID     int
+	// Since is when the watcher wrote its pidfile
+	Since time.Time
+}
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Dir returns the directory that holds the pidfiles and logs
+func Dir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".local", "share", "uzi", "auto"), nil
+}
+
+// For returns the watcher of a repository. The file names combine the
+// repository name, for people looking at the directory, with a hash of the
+// full URL, so that forks of the same name do not share a watcher.
+func For(repo string) (*Daemon, error) {
+	if repo == "" {
+		return nil, fmt.Errorf("uzi auto needs a git remote (remote.origin.url) to find the agents of this repository")
+	}
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(repo))
+	name := strings.TrimSuffix(filepath.Base(strings.TrimRight(repo, "/")), ".git")
+	name = unsafeChars.ReplaceAllString(name, "_") + "-" + hex.EncodeToString(sum[:4])
+	return &Daemon{
+		Repo:    repo,
+		PIDPath: filepath.Join(dir, name+".pid"),
+		LogPath: filepath.Join(dir, name+".log"),
+	}, nil
+}
+
+// Status reads the pidfile and checks whether its process is alive. A
+// missing, unreadable or stale pidfile means the watcher is not running.
+func (d *Daemon) Status() (Status, error) {
+	info, err := os.Stat(d.PIDPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Status{}, nil
+		}
+		return Status{}, err
+	}
+	data, err := os.ReadFile(d.PIDPath)
+	if err != nil {
+		return Status{}, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return Status{}, nil
+	}
+	return Status{Running: alive(pid), PID: pid, Since: info.ModTime()}, nil
+}
+
+// Acquire writes the pidfile for the calling process, failing with
+// ErrRunning when another live process holds it. A stale pidfile is
+// replaced. The returned function removes the pidfile again.
+func (d *Daemon) Acquire() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(d.PIDPath), 0755); err != nil {
+		return nil, err
+	}
+	pid := os.Getpid()
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(d.PIDPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_, err = fmt.Fprintf(file, "%d\n", pid)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(d.PIDPath)
+				return nil, fmt.Errorf("failed to write %s: %w", d.PIDPath, err)
+			}
+			return func() { d.release(pid) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create %s: %w", d.PIDPath, err)
+		}
+
+		status, err := d.Status()
+		if err != nil {
+			return nil, err
+		}
+		if status.Running && status.PID != pid {
+			return nil, fmt.Errorf("%w (pid %d)", ErrRunning, status.PID)
+		}
+		if err := os.Remove(d.PIDPath); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("failed to create %s: another process keeps replacing it", d.PIDPath)
+}
+
+// release removes the pidfile if it still belongs to pid
+func (d *Daemon) release(pid int) {
+	if status, err := d.Status(); err == nil && status.PID == pid {
+		os.Remove(d.PIDPath)
+	}
+}
+
+// Start runs cmd detached from the terminal, with its output appended to the
+// log file, and waits until it has acquired the pidfile. cmd is expected to
+// call Acquire; if it exits first, the error points at the log.
+func (d *Daemon) Start(cmd *exec.Cmd) (int, error) {
+	if status, err := d.Status(); err == nil && status.Running {
+		return status.PID, fmt.Errorf("%w (pid %d)", ErrRunning, status.PID)
+	}
+	if err := os.MkdirAll(filepath.Dir(d.LogPath), 0755); err != nil {
+		return 0, err
+	}
+	logFile, err := os.OpenFile(d.LogPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer logFile.Close()
+
+	cmd.Stdin = nil
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("failed to start uzi auto: %w", err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	pid := cmd.Process.Pid
+	deadline := time.After(StartTimeout)
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-exited:
+			if err == nil {
+				err = errors.New("exited")
+			}
+			return 0, fmt.Errorf("uzi auto stopped during startup (%v); see %s", err, d.LogPath)
+		case <-deadline:
+			return pid, fmt.Errorf("uzi auto (pid %d) did not write %s within %s; see %s", pid, d.PIDPath, StartTimeout, d.LogPath)
+		case <-ticker.C:
+			if status, err := d.Status(); err == nil && status.Running && status.PID == pid {
+				return pid, nil
+			}
+		}
+	}
+}
+
+// Stop asks the watcher to shut down and waits up to timeout for it to exit
+func (d *Daemon) Stop(timeout time.Duration) (int, error) {
+	status, err := d.Status()
+	if err != nil {
+		return 0, err
+	}
+	if !status.Running {
+		// Clean up after a watcher that did not remove its pidfile
+		os.Remove(d.PIDPath)
+		return 0, ErrNotRunning
+	}
+	if err := terminate(status.PID); err != nil {
+		return status.PID, fmt.Errorf("failed to stop uzi auto (pid %d): %w", status.PID, err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for alive(status.PID) {
+		if time.Now().After(deadline) {
+			return status.PID, fmt.Errorf("uzi auto (pid %d) did not stop within %s", status.PID, timeout)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	d.release(status.PID)
+	return status.PID, nil
+}
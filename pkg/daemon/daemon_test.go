@@ -0,0 +1,142 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// helperEnv makes the test binary act as a watcher: it takes the pidfile
+// named by the variable and holds it until it is terminated
+const helperEnv = "UZI_DAEMON_TEST_PIDFILE"
+
+func TestMain(m *testing.M) {
+	if path := os.Getenv(helperEnv); path != "" {
+		os.Exit(runHelper(path))
+	}
+	os.Exit(m.Run())
+}
+
+func runHelper(path string) int {
+	d := &Daemon{PIDPath: path}
+	release, err := d.Acquire()
+	if err != nil {
+		return 1
+	}
+	defer release()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Minute):
+	}
+	return 0
+}
+
+func testDaemon(t *testing.T) *Daemon {
+	dir := t.TempDir()
+	return &Daemon{Repo: "git@github.com:devflowinc/uzi.git", PIDPath: filepath.Join(dir, "uzi.pid"), LogPath: filepath.Join(dir, "uzi.log")}
+}
+
+func helperCommand(d *Daemon) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(), helperEnv+"="+d.PIDPath)
+	return cmd
+}
+
+func TestFor(t *testing.T) {
+	t.Setenv("HOME", "/home/u")
+	a, err := For("git@github.com:devflowinc/uzi.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := For("https://github.com/someone/uzi")
+	if !strings.HasPrefix(filepath.Base(a.PIDPath), "uzi-") || a.PIDPath == b.PIDPath {
+		t.Errorf("pidfiles %s and %s", a.PIDPath, b.PIDPath)
+	}
+	if filepath.Dir(a.PIDPath) != "/home/u/.local/share/uzi/auto" || !strings.HasSuffix(a.LogPath, ".log") {
+		t.Errorf("paths %s, %s", a.PIDPath, a.LogPath)
+	}
+	if _, err := For(""); err == nil {
+		t.Error("expected an error without a remote")
+	}
+}
+
+func TestAcquire(t *testing.T) {
+	d := testDaemon(t)
+
+	release, err := d.Acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := d.Status()
+	if err != nil || !status.Running || status.PID != os.Getpid() {
+		t.Fatalf("status = %+v, %v", status, err)
+	}
+	release()
+	if _, err := os.Stat(d.PIDPath); !os.IsNotExist(err) {
+		t.Errorf("pidfile not removed: %v", err)
+	}
+
+	// A pidfile left by a process that is gone is replaced
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Run()
+	os.WriteFile(d.PIDPath, []byte(strconv.Itoa(cmd.Process.Pid)+"\n"), 0644)
+	if status, _ := d.Status(); status.Running {
+		t.Fatalf("stale pidfile reported as running: %+v", status)
+	}
+	release, err = d.Acquire()
+	if err != nil {
+		t.Fatalf("stale pidfile: %v", err)
+	}
+	release()
+}
+
+func TestStartStop(t *testing.T) {
+	d := testDaemon(t)
+
+	pid, err := d.Start(helperCommand(d))
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, _ := d.Status()
+	if !status.Running || status.PID != pid {
+		t.Fatalf("status = %+v, want pid %d", status, pid)
+	}
+
+	// A second watcher refuses to run
+	if _, err := d.Acquire(); !errors.Is(err, ErrRunning) {
+		t.Errorf("Acquire = %v, want ErrRunning", err)
+	}
+	if again, err := d.Start(helperCommand(d)); !errors.Is(err, ErrRunning) || again != pid {
+		t.Errorf("Start = %d, %v", again, err)
+	}
+
+	if stopped, err := d.Stop(5 * time.Second); err != nil || stopped != pid {
+		t.Fatalf("Stop = %d, %v", stopped, err)
+	}
+	if status, _ := d.Status(); status.Running {
+		t.Errorf("still running after Stop: %+v", status)
+	}
+	if _, err := d.Stop(time.Second); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("second Stop = %v, want ErrNotRunning", err)
+	}
+}
+
+func TestStartFailure(t *testing.T) {
+	d := testDaemon(t)
+	// The helper cannot take a pidfile in a directory that is a file
+	os.WriteFile(filepath.Join(filepath.Dir(d.PIDPath), "blocker"), nil, 0644)
+	cmd := helperCommand(&Daemon{PIDPath: filepath.Join(filepath.Dir(d.PIDPath), "blocker", "uzi.pid")})
+	if _, err := d.Start(cmd); err == nil || !strings.Contains(err.Error(), d.LogPath) {
+		t.Errorf("Start = %v, want an error pointing at the log", err)
+	}
+}
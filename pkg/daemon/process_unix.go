@@ -0,0 +1,25 @@
+//go:build !windows
+
+package daemon
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+)
+
+// detach runs cmd in its own session so it outlives the calling terminal
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+// alive reports whether a process with the given pid exists
+func alive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// terminate asks the process to shut down gracefully
+func terminate(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
@@ -0,0 +1,31 @@
+//go:build windows
+
+package daemon
+
+import (
+	"os"
+	"os/exec"
+)
+
+// detach is a no-op on Windows, where processes have no session to leave
+func detach(cmd *exec.Cmd) {}
+
+// alive reports whether a process with the given pid exists. FindProcess
+// opens the process on Windows, so it fails for processes that are gone.
+func alive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
+
+// terminate stops the process; Windows has no SIGTERM to catch
+func terminate(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Kill()
+}
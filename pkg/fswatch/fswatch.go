@@ -0,0 +1,178 @@
+// Package fswatch reports that something changed under a set of files and
+// directories. It does not say what changed: callers such as "uzi ls -w"
+// only use it to decide when to look again.
+//
+// On Linux it uses inotify. Elsewhere New returns ErrUnsupported and callers
+// are expected to fall back to polling.
+package fswatch
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"sync"
+)
+
+// ErrUnsupported is returned by New on platforms without a backend
+var ErrUnsupported = errors.New("file watching is not supported on this platform")
+
+// skipDirs are never descended into by Tree
+var skipDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+}
+
+// DefaultTreeLimit bounds how many directories Tree returns for one root, so
+// a huge worktree cannot exhaust the inotify watch limit
+const DefaultTreeLimit = 1000
+
+// Watcher watches paths non-recursively. Changes are coalesced into a single
+// pending notification on Events.
+type Watcher struct {
+	// Events receives a value after one or more changes
+	Events <-chan struct{}
+
+	events  chan struct{}
+	backend backend
+
+	mu      sync.Mutex
+	watches map[string]int
+}
+
+// backend is the platform-specific part of a Watcher
+type backend interface {
+	add(path string) (int, error)
+	remove(id int) error
+	close() error
+}
+
+// New starts a watcher with no paths
+func New() (*Watcher, error) {
+	events := make(chan struct{}, 1)
+	w := &Watcher{
+		Events:  events,
+		events:  events,
+		watches: make(map[string]int),
+	}
+	b, err := newBackend(w.notify, w.forget)
+	if err != nil {
+		return nil, err
+	}
+	w.backend = b
+	return w, nil
+}
+
+// notify records a change without blocking the backend
+func (w *Watcher) notify() {
+	select {
+	case w.events <- struct{}{}:
+	default:
+	}
+}
+
+// forget drops a watch the backend reports as gone, e.g. because its
+// directory was deleted
+func (w *Watcher) forget(id int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for path, wid := range w.watches {
+		if wid == id {
+			delete(w.watches, path)
+		}
+	}
+}
+
+// Add watches a file or the direct entries of a directory
+func (w *Watcher) Add(path string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.watches[path]; ok {
+		return nil
+	}
+	id, err := w.backend.add(path)
+	if err != nil {
+		return err
+	}
+	w.watches[path] = id
+	return nil
+}
+
+// Remove stops watching path
+func (w *Watcher) Remove(path string) error {
+	w.mu.Lock()
+	id, ok := w.watches[path]
+	delete(w.watches, path)
+	w.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return w.backend.remove(id)
+}
+
+// Sync makes the watched set equal to paths, adding new paths and removing
+// those no longer listed. Paths that cannot be watched, e.g. because they do
+// not exist yet, are skipped; the first such error is returned.
+func (w *Watcher) Sync(paths []string) error {
+	want := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		want[path] = true
+	}
+
+	w.mu.Lock()
+	var stale []string
+	for path := range w.watches {
+		if !want[path] {
+			stale = append(stale, path)
+		}
+	}
+	w.mu.Unlock()
+	for _, path := range stale {
+		w.Remove(path)
+	}
+
+	var firstErr error
+	for _, path := range paths {
+		if err := w.Add(path); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Len returns the number of watched paths
+func (w *Watcher) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.watches)
+}
+
+// Close stops the watcher. Events is not closed.
+func (w *Watcher) Close() error {
+	return w.backend.close()
+}
+
+// Tree returns root and the directories below it, skipping .git and
+// node_modules, stopping after limit directories
+func Tree(root string, limit int) []string {
+	var dirs []string
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && skipDirs[d.Name()] {
+			return filepath.SkipDir
+		}
+		if len(dirs) >= limit {
+			return filepath.SkipAll
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+	return dirs
+}
@@ -0,0 +1,127 @@
+package fswatch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func expectEvent(t *testing.T, w *Watcher, what string) {
+	t.Helper()
+	select {
+	case <-w.Events:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("no event after %s", what)
+	}
+}
+
+func expectQuiet(t *testing.T, w *Watcher, what string) {
+	t.Helper()
+	select {
+	case <-w.Events:
+		t.Fatalf("unexpected event after %s", what)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	w, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer w.Close()
+
+	dir := t.TempDir()
+	if err := w.Add(dir); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	file := filepath.Join(dir, "state.json")
+	os.WriteFile(file, []byte("{}"), 0644)
+	expectEvent(t, w, "creating a file")
+	// Drain the coalesced events of the write
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case <-w.Events:
+	default:
+	}
+
+	now := time.Now()
+	os.Chtimes(file, now, now)
+	expectEvent(t, w, "touching a file")
+
+	if err := w.Remove(dir); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case <-w.Events:
+	default:
+	}
+	os.WriteFile(file, []byte("[]"), 0644)
+	expectQuiet(t, w, "writing to an unwatched directory")
+}
+
+func TestWatcherSync(t *testing.T) {
+	w, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer w.Close()
+
+	a, b := t.TempDir(), t.TempDir()
+	missing := filepath.Join(a, "missing")
+	if err := w.Sync([]string{a, b, missing}); err == nil {
+		t.Error("Sync() with a missing path returned no error")
+	}
+	if w.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", w.Len())
+	}
+
+	w.Sync([]string{b})
+	if w.Len() != 1 {
+		t.Errorf("Len() after shrinking = %d, want 1", w.Len())
+	}
+
+	// Deleting a watched directory drops its watch
+	os.RemoveAll(b)
+	deadline := time.Now().Add(2 * time.Second)
+	for w.Len() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if w.Len() != 0 {
+		t.Errorf("Len() after deleting the directory = %d, want 0", w.Len())
+	}
+}
+
+func TestTree(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"src/pkg", ".git/objects", "node_modules/x", "docs"} {
+		os.MkdirAll(filepath.Join(root, dir), 0755)
+	}
+	os.WriteFile(filepath.Join(root, "src", "main.go"), nil, 0644)
+
+	dirs := Tree(root, DefaultTreeLimit)
+	want := map[string]bool{
+		root:                              true,
+		filepath.Join(root, "src"):        true,
+		filepath.Join(root, "src", "pkg"): true,
+		filepath.Join(root, "docs"):       true,
+	}
+	if len(dirs) != len(want) {
+		t.Fatalf("Tree() = %v", dirs)
+	}
+	for _, dir := range dirs {
+		if !want[dir] {
+			t.Errorf("unexpected directory %s", dir)
+		}
+	}
+
+	if got := Tree(root, 2); len(got) != 2 {
+		t.Errorf("Tree() with limit 2 returned %d directories", len(got))
+	}
+	if got := Tree(filepath.Join(root, "missing"), DefaultTreeLimit); len(got) != 0 {
+		t.Errorf("Tree() of a missing root = %v", got)
+	}
+}
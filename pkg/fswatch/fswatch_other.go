@@ -0,0 +1,7 @@
+//go:build !linux
+
+package fswatch
+
+func newBackend(notify func(), forget func(id int)) (backend, error) {
+	return nil, ErrUnsupported
+}
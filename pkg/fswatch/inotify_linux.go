@@ -0,0 +1,78 @@
+package fswatch
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// watchMask selects the changes that matter to a listing: content writes,
+// metadata updates such as touch, and entries appearing or disappearing
+const watchMask = syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_CLOSE_WRITE |
+	syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+type inotify struct {
+	fd   int
+	file *os.File
+}
+
+func newBackend(notify func(), forget func(id int)) (backend, error) {
+	// A non-blocking descriptor lets the runtime poller wake the reader
+	// and makes Close interrupt a pending Read
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify_init1: %w", err)
+	}
+	in := &inotify{fd: fd, file: os.NewFile(uintptr(fd), "inotify")}
+	go in.read(notify, forget)
+	return in, nil
+}
+
+func (in *inotify) read(notify func(), forget func(id int)) {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := in.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		changed := false
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			offset += syscall.SizeofInotifyEvent + int(event.Len)
+
+			if event.Mask&syscall.IN_IGNORED != 0 {
+				// The watch was removed, explicitly or because the
+				// path went away
+				forget(int(event.Wd))
+				continue
+			}
+			changed = true
+		}
+		if changed {
+			notify()
+		}
+	}
+}
+
+func (in *inotify) add(path string) (int, error) {
+	wd, err := syscall.InotifyAddWatch(in.fd, path, watchMask)
+	if err != nil {
+		return 0, &os.PathError{Op: "inotify_add_watch", Path: path, Err: err}
+	}
+	return wd, nil
+}
+
+func (in *inotify) remove(id int) error {
+	_, err := syscall.InotifyRmWatch(in.fd, uint32(id))
+	if err != nil && err != syscall.EINVAL {
+		return fmt.Errorf("inotify_rm_watch: %w", err)
+	}
+	return nil
+}
+
+func (in *inotify) close() error {
+	return in.file.Close()
+}
@@ -0,0 +1,169 @@
+package gitdiff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Drift compares an agent branch with the branch it was created from. Only
+// commits are considered; uncommitted changes are reported by Worktree.
+type Drift struct {
+	Base string
+	// Ahead is the number of commits on the agent branch that the base
+	// does not have
+	Ahead int
+	// Behind is the number of commits the base has gained since the fork
+	Behind int
+	// Conflicts is true when merging the base into the agent branch would
+	// conflict
+	Conflicts bool
+	// ConflictsUnknown is true when git is too old for the conflict check,
+	// which needs "git merge-tree --write-tree" from git 2.38
+	ConflictsUnknown bool
+}
+
+var (
+	mergeTreeOnce      sync.Once
+	mergeTreeSupported bool
+	// gitVersion returns the output of "git version"; replaced in tests
+	gitVersion = func() (string, error) {
+		out, err := exec.Command("git", "version").Output()
+		return string(out), err
+	}
+)
+
+// canMergeTree reports whether the installed git has "merge-tree
+// --write-tree". Versions that cannot be parsed are assumed to be new enough.
+func canMergeTree() bool {
+	mergeTreeOnce.Do(func() {
+		mergeTreeSupported = true
+		out, err := gitVersion()
+		if err != nil {
+			return
+		}
+		// "git version 2.39.5" or "git version 2.39.5.windows.1"
+		fields := strings.Fields(out)
+		if len(fields) < 3 {
+			return
+		}
+		parts := strings.SplitN(fields[2], ".", 3)
+		if len(parts) < 2 {
+			return
+		}
+		major, err1 := strconv.Atoi(parts[0])
+		minor, err2 := strconv.Atoi(parts[1])
+		if err1 != nil || err2 != nil {
+			return
+		}
+		mergeTreeSupported = major > 2 || major == 2 && minor >= 38
+	})
+	return mergeTreeSupported
+}
+
+// resolveBase returns the commit base points to, following git's ref
+// lookup order without forking, or "" when it cannot be resolved that way
+func resolveBase(gitDir, base string) string {
+	common := commonDir(gitDir)
+	for _, ref := range []string{
+		base,
+		"refs/" + base,
+		"refs/tags/" + base,
+		"refs/heads/" + base,
+		"refs/remotes/" + base,
+		"refs/remotes/" + base + "/HEAD",
+	} {
+		sha := lookupRef(common, ref)
+		if target, ok := strings.CutPrefix(sha, "ref: "); ok {
+			sha = lookupRef(common, target)
+		}
+		if sha != "" {
+			return sha
+		}
+	}
+	return ""
+}
+
+// DriftKey identifies the inputs of BaseDrift: the commits HEAD and base
+// point to. While it is unchanged a previously computed Drift is still valid.
+func DriftKey(dir, base string) (string, error) {
+	gd, err := GitDir(dir)
+	if err != nil {
+		return "", err
+	}
+	baseSHA := resolveBase(gd, base)
+	if baseSHA == "" {
+		return "", fmt.Errorf("cannot resolve %s in %s", base, dir)
+	}
+	return resolveHead(gd) + "|" + baseSHA, nil
+}
+
+// BaseDrift counts the commits between HEAD of the worktree at dir and base,
+// and uses "git merge-tree" to find out whether merging them would conflict.
+// Nothing in the repository is changed: not the worktree, the index, the refs
+// or the object store.
+func BaseDrift(dir, base string) (*Drift, error) {
+	if dir == "" || base == "" {
+		return nil, fmt.Errorf("no worktree or base branch")
+	}
+
+	out, err := git(dir, nil, "rev-list", "--left-right", "--count", base+"...HEAD")
+	if err != nil {
+		return nil, err
+	}
+	counts := strings.Fields(string(out))
+	if len(counts) != 2 {
+		return nil, fmt.Errorf("unexpected rev-list output %q", out)
+	}
+	behind, err := strconv.Atoi(counts[0])
+	if err != nil {
+		return nil, fmt.Errorf("unexpected rev-list output %q", out)
+	}
+	ahead, err := strconv.Atoi(counts[1])
+	if err != nil {
+		return nil, fmt.Errorf("unexpected rev-list output %q", out)
+	}
+
+	drift := &Drift{Base: base, Ahead: ahead, Behind: behind}
+	if ahead == 0 || behind == 0 {
+		// One side contains the other, so the merge is a fast-forward
+		return drift, nil
+	}
+
+	if !canMergeTree() {
+		drift.ConflictsUnknown = true
+		return drift, nil
+	}
+
+	// merge-tree never touches the worktree, index or refs but writes the
+	// merged blobs and trees
+	scratch, cleanup, err := scratchObjects(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	// merge-tree exits with 1 when the merge has conflicts
+	cmd := exec.Command("git", "merge-tree", "--write-tree", "--name-only", "--no-messages", "HEAD", base)
+	cmd.Dir = dir
+	cmd.Env = append(append(os.Environ(), "GIT_OPTIONAL_LOCKS=0"), scratch...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+		drift.Conflicts = true
+	case stderr.Len() > 0:
+		return nil, fmt.Errorf("git merge-tree: %s", strings.TrimSpace(stderr.String()))
+	default:
+		return nil, fmt.Errorf("git merge-tree: %w", err)
+	}
+	return drift, nil
+}
@@ -0,0 +1,144 @@
+package gitdiff
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestBaseDrift(t *testing.T) {
+	dir := setupRepo(t)
+	base := strings.TrimSpace(run(t, dir, "branch", "--show-current"))
+	linked := filepath.Join(t.TempDir(), "agent")
+	run(t, dir, "worktree", "add", "-q", "-b", "agent", linked)
+
+	check := func(want Drift) {
+		t.Helper()
+		drift, err := BaseDrift(linked, base)
+		if err != nil {
+			t.Fatalf("BaseDrift() error = %v", err)
+		}
+		if *drift != want {
+			t.Errorf("BaseDrift() = %+v, want %+v", *drift, want)
+		}
+	}
+
+	check(Drift{Base: base})
+
+	write(t, linked, "agent.txt", "agent\n")
+	run(t, linked, "add", "agent.txt")
+	run(t, linked, "commit", "-q", "-m", "agent work")
+	check(Drift{Base: base, Ahead: 1})
+
+	write(t, dir, "base.txt", "base\n")
+	run(t, dir, "add", "base.txt")
+	run(t, dir, "commit", "-q", "-m", "base work")
+	check(Drift{Base: base, Ahead: 1, Behind: 1})
+
+	// Both sides change the same line
+	write(t, linked, "old.txt", "agent\ntwo\n")
+	run(t, linked, "commit", "-q", "-am", "agent edit")
+	write(t, dir, "old.txt", "base\ntwo\n")
+	run(t, dir, "commit", "-q", "-am", "base edit")
+	objects := looseObjects(t, dir)
+	check(Drift{Base: base, Ahead: 2, Behind: 2, Conflicts: true})
+	if after := looseObjects(t, dir); after != objects {
+		t.Errorf("BaseDrift() wrote objects into the repository: %d before, %d after", objects, after)
+	}
+
+	// Uncommitted changes and the agent's index are not affected
+	if status := run(t, linked, "status", "--porcelain"); status != "" {
+		t.Errorf("worktree status = %q, want clean", status)
+	}
+}
+
+func TestBaseDriftOldGit(t *testing.T) {
+	dir := setupRepo(t)
+	base := strings.TrimSpace(run(t, dir, "branch", "--show-current"))
+	linked := filepath.Join(t.TempDir(), "agent")
+	run(t, dir, "worktree", "add", "-q", "-b", "agent", linked)
+	write(t, linked, "agent.txt", "agent\n")
+	run(t, linked, "add", "agent.txt")
+	run(t, linked, "commit", "-q", "-m", "agent work")
+	write(t, dir, "base.txt", "base\n")
+	run(t, dir, "add", "base.txt")
+	run(t, dir, "commit", "-q", "-m", "base work")
+
+	restore := gitVersion
+	t.Cleanup(func() {
+		gitVersion = restore
+		mergeTreeOnce = sync.Once{}
+	})
+	gitVersion = func() (string, error) { return "git version 2.37.1\n", nil }
+	mergeTreeOnce = sync.Once{}
+
+	drift, err := BaseDrift(linked, base)
+	if err != nil {
+		t.Fatalf("BaseDrift() error = %v", err)
+	}
+	want := Drift{Base: base, Ahead: 1, Behind: 1, ConflictsUnknown: true}
+	if *drift != want {
+		t.Errorf("BaseDrift() = %+v, want %+v", *drift, want)
+	}
+}
+
+func TestCanMergeTree(t *testing.T) {
+	restore := gitVersion
+	t.Cleanup(func() {
+		gitVersion = restore
+		mergeTreeOnce = sync.Once{}
+	})
+	for version, want := range map[string]bool{
+		"git version 2.37.1":           false,
+		"git version 2.38.0":           true,
+		"git version 2.39.5.windows.1": true,
+		"git version 3.0.0":            true,
+		"git version 1.9.5":            false,
+		"something else":               true,
+	} {
+		gitVersion = func() (string, error) { return version + "\n", nil }
+		mergeTreeOnce = sync.Once{}
+		if got := canMergeTree(); got != want {
+			t.Errorf("canMergeTree() with %q = %v, want %v", version, got, want)
+		}
+	}
+}
+
+// looseObjects counts the loose objects in the repository at dir
+func looseObjects(t *testing.T, dir string) int {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, ".git", "objects", "??", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(matches)
+}
+
+func TestDriftKey(t *testing.T) {
+	dir := setupRepo(t)
+	base := strings.TrimSpace(run(t, dir, "branch", "--show-current"))
+	linked := filepath.Join(t.TempDir(), "agent")
+	run(t, dir, "worktree", "add", "-q", "-b", "agent", linked)
+
+	first, err := DriftKey(linked, base)
+	if err != nil {
+		t.Fatalf("DriftKey() error = %v", err)
+	}
+
+	write(t, dir, "base.txt", "base\n")
+	run(t, dir, "add", "base.txt")
+	run(t, dir, "commit", "-q", "-m", "base work")
+	run(t, dir, "pack-refs", "--all")
+	moved, err := DriftKey(linked, base)
+	if err != nil {
+		t.Fatalf("DriftKey() with packed refs error = %v", err)
+	}
+	if moved == first {
+		t.Error("DriftKey() did not change when the base moved")
+	}
+
+	if _, err := DriftKey(linked, "no-such-branch"); err == nil {
+		t.Error("DriftKey() of a missing base succeeded")
+	}
+}
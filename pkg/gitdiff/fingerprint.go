@@ -0,0 +1,164 @@
+package gitdiff
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// skipDirs are never walked when fingerprinting; they are either git's own
+// data or dependency trees that are almost always ignored
+var skipDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+}
+
+// GitDir returns the git directory of a worktree. Linked worktrees have a
+// ".git" file pointing at their directory under the main repository.
+func GitDir(dir string) (string, error) {
+	path := filepath.Join(dir, ".git")
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return path, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	target, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return "", fmt.Errorf("unrecognized .git file in %s", dir)
+	}
+	target = strings.TrimSpace(target)
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(dir, target)
+	}
+	return target, nil
+}
+
+// commonDir returns the directory that holds refs shared by all worktrees
+func commonDir(gitDir string) string {
+	data, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+	common := strings.TrimSpace(string(data))
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(gitDir, common)
+	}
+	return common
+}
+
+// resolveHead returns the commit HEAD points to, reading refs directly so no
+// git process is needed
+func resolveHead(gitDir string) string {
+	data, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	head := strings.TrimSpace(string(data))
+	ref, ok := strings.CutPrefix(head, "ref: ")
+	if !ok {
+		return head
+	}
+	if sha := lookupRef(commonDir(gitDir), ref); sha != "" {
+		return sha
+	}
+	return ref
+}
+
+// lookupRef returns the commit a fully qualified ref points to, checking the
+// loose ref before packed-refs, or "" when it does not exist
+func lookupRef(common, ref string) string {
+	if data, err := os.ReadFile(filepath.Join(common, ref)); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+
+	file, err := os.Open(filepath.Join(common, "packed-refs"))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if sha, name, ok := strings.Cut(scanner.Text(), " "); ok && name == ref {
+			return sha
+		}
+	}
+	return ""
+}
+
+// Fingerprint summarizes everything a worktree diff depends on: the HEAD
+// commit, the index, and the modification times of the files in the
+// worktree. It only stats files, so it is much cheaper than a diff; when it
+// is unchanged a previously computed Summary is still valid.
+func Fingerprint(dir string) (string, error) {
+	gd, err := GitDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	var index string
+	if info, err := os.Stat(filepath.Join(gd, "index")); err == nil {
+		index = fmt.Sprintf("%d:%d", info.ModTime().UnixNano(), info.Size())
+	}
+
+	latest, entries, err := scanTree(dir)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s|%s|%d|%d", resolveHead(gd), index, latest, entries), nil
+}
+
+// LastModified returns the most recent modification time of the files in
+// the worktree at dir and of its index, which changes when the agent stages
+// or commits
+func LastModified(dir string) (time.Time, error) {
+	gd, err := GitDir(dir)
+	if err != nil {
+		return time.Time{}, err
+	}
+	latest, _, err := scanTree(dir)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if info, err := os.Stat(filepath.Join(gd, "index")); err == nil && info.ModTime().UnixNano() > latest {
+		latest = info.ModTime().UnixNano()
+	}
+	return time.Unix(0, latest), nil
+}
+
+// scanTree returns the latest modification time, in nanoseconds, and the
+// number of entries in the tree at dir, skipping skipDirs
+func scanTree(dir string) (int64, int, error) {
+	var latest int64
+	var entries int
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Files can vanish while the agent works; skip them
+			return nil
+		}
+		if d.IsDir() && path != dir && skipDirs[d.Name()] {
+			return filepath.SkipDir
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		entries++
+		if mtime := info.ModTime().UnixNano(); mtime > latest {
+			latest = mtime
+		}
+		return nil
+	})
+	return latest, entries, err
+}
@@ -0,0 +1,223 @@
+// Package gitdiff measures the uncommitted changes in an agent worktree and
+// how far its branch has drifted from its base, without touching the
+// worktree's own index.
+package gitdiff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// FileChange is a single changed file
+type FileChange struct {
+	Path       string
+	Status     string // git status letter: A, M, D, T
+	Insertions int
+	Deletions  int
+	IsBinary   bool
+}
+
+// Summary is every uncommitted change in a worktree relative to HEAD,
+// including untracked files
+type Summary struct {
+	Insertions int
+	Deletions  int
+	Files      []FileChange
+}
+
+func git(dir string, env []string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	// Never take index.lock or write back refreshed stat info
+	cmd.Env = append(append(os.Environ(), "GIT_OPTIONAL_LOCKS=0"), env...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// scratchObjects returns the environment that sends the objects a git
+// command writes to a throwaway directory, which reads the repository's
+// objects as an alternate, and a function that removes the directory
+func scratchObjects(dir string) ([]string, func(), error) {
+	gd, err := GitDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	// git runs in dir, so the alternate must not be relative to ours
+	objects, err := filepath.Abs(filepath.Join(commonDir(gd), "objects"))
+	if err != nil {
+		return nil, nil, err
+	}
+	scratch, err := os.MkdirTemp("", "uzi-objects-")
+	if err != nil {
+		return nil, nil, err
+	}
+	env := []string{
+		"GIT_OBJECT_DIRECTORY=" + scratch,
+		"GIT_ALTERNATE_OBJECT_DIRECTORIES=" + objects,
+	}
+	return env, func() { os.RemoveAll(scratch) }, nil
+}
+
+// copyIndex copies the worktree index to a temporary file so untracked files
+// can be registered without changing what the agent has staged. The copy
+// keeps the cached stat data, which lets git skip rehashing unchanged files.
+func copyIndex(dir string) (string, error) {
+	out, err := git(dir, nil, "rev-parse", "--git-path", "index")
+	if err != nil {
+		return "", err
+	}
+	indexPath := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(indexPath) {
+		indexPath = filepath.Join(dir, indexPath)
+	}
+
+	tmp, err := os.CreateTemp("", "uzi-index-*")
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
+
+	src, err := os.Open(indexPath)
+	if os.IsNotExist(err) {
+		// No index yet; git starts from an empty one
+		os.Remove(tmp.Name())
+		return tmp.Name(), nil
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	defer src.Close()
+
+	if _, err := io.Copy(tmp, src); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// Worktree computes the changes in the worktree at dir against HEAD in a
+// single diff. Untracked files are added to a throwaway index as
+// intent-to-add entries, and the objects git writes on the way go to a
+// throwaway object directory, so the agent's index, staged changes and
+// object database are left exactly as they were.
+func Worktree(dir string) (*Summary, error) {
+	out, err := diffWorktree(dir, "--no-renames", "-z", "--raw", "--numstat", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+	return parse(out)
+}
+
+// Patch returns the unified diff of the worktree at dir against HEAD,
+// including untracked files, limited to paths when any are given. Like
+// Worktree it leaves the agent's index untouched.
+func Patch(dir string, paths ...string) (string, error) {
+	args := append([]string{"--no-renames", "--no-color", "--"}, paths...)
+	if len(paths) == 0 {
+		args = append(args, ".")
+	}
+	out, err := diffWorktree(dir, args...)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// diffWorktree runs "git diff HEAD" with args against a throwaway index in
+// which untracked files are registered as intent-to-add. "git add" hashes
+// modified files and the empty blob, so it writes to scratch objects.
+func diffWorktree(dir string, args ...string) ([]byte, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("no worktree path")
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+
+	indexPath, err := copyIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(indexPath)
+
+	scratch, cleanup, err := scratchObjects(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	env := append([]string{"GIT_INDEX_FILE=" + indexPath}, scratch...)
+	if _, err := git(dir, env, "add", "--intent-to-add", "--all", "--", "."); err != nil {
+		return nil, err
+	}
+
+	return git(dir, env, append([]string{"diff", "HEAD", "--no-ext-diff"}, args...)...)
+}
+
+// parse reads the output of "git diff -z --raw --numstat". The raw section
+// gives each file's status and the numstat section its line counts.
+func parse(out []byte) (*Summary, error) {
+	summary := &Summary{}
+	index := make(map[string]int)
+
+	fields := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if field == "" {
+			continue
+		}
+
+		if strings.HasPrefix(field, ":") {
+			// ":<mode> <mode> <sha> <sha> <status>" followed by the path
+			meta := strings.Fields(field)
+			if len(meta) < 5 || i+1 >= len(fields) {
+				return nil, fmt.Errorf("malformed raw diff entry: %q", field)
+			}
+			i++
+			path := fields[i]
+			index[path] = len(summary.Files)
+			summary.Files = append(summary.Files, FileChange{
+				Path:   path,
+				Status: meta[4][:1],
+			})
+			continue
+		}
+
+		// "<insertions>\t<deletions>\t<path>", with "-" counts for binary files
+		parts := strings.SplitN(field, "\t", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("malformed numstat entry: %q", field)
+		}
+		pos, ok := index[parts[2]]
+		if !ok {
+			continue
+		}
+		file := &summary.Files[pos]
+		if parts[0] == "-" && parts[1] == "-" {
+			file.IsBinary = true
+			continue
+		}
+		file.Insertions, _ = strconv.Atoi(parts[0])
+		file.Deletions, _ = strconv.Atoi(parts[1])
+		summary.Insertions += file.Insertions
+		summary.Deletions += file.Deletions
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,240 @@
+package gitdiff
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func run(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return string(out)
+}
+
+func write(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func setupRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	dir := t.TempDir()
+	run(t, dir, "init", "-q")
+	write(t, dir, "main.go", "package main\n\nfunc main() {}\n")
+	write(t, dir, "old.txt", "one\ntwo\n")
+	write(t, dir, "logo.png", "\x00\x01\x02")
+	run(t, dir, "add", ".")
+	run(t, dir, "commit", "-q", "-m", "initial")
+	return dir
+}
+
+func TestWorktree(t *testing.T) {
+	dir := setupRepo(t)
+
+	write(t, dir, "main.go", "package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n")
+	os.Remove(filepath.Join(dir, "old.txt"))
+	write(t, dir, "logo.png", "\x00\x03")
+	write(t, dir, "new file.txt", "a\nb\nc\n")
+	write(t, dir, "staged.txt", "staged\n")
+	run(t, dir, "add", "staged.txt")
+
+	statusBefore := run(t, dir, "status", "--porcelain")
+	objects := looseObjects(t, dir)
+
+	summary, err := Worktree(dir)
+	if err != nil {
+		t.Fatalf("Worktree() error = %v", err)
+	}
+
+	want := map[string]FileChange{
+		"main.go":      {Path: "main.go", Status: "M", Insertions: 3, Deletions: 1},
+		"old.txt":      {Path: "old.txt", Status: "D", Deletions: 2},
+		"logo.png":     {Path: "logo.png", Status: "M", IsBinary: true},
+		"new file.txt": {Path: "new file.txt", Status: "A", Insertions: 3},
+		"staged.txt":   {Path: "staged.txt", Status: "A", Insertions: 1},
+	}
+	if len(summary.Files) != len(want) {
+		t.Fatalf("got %d files, want %d: %+v", len(summary.Files), len(want), summary.Files)
+	}
+	for _, file := range summary.Files {
+		if file != want[file.Path] {
+			t.Errorf("file %q = %+v, want %+v", file.Path, file, want[file.Path])
+		}
+	}
+	if summary.Insertions != 7 || summary.Deletions != 3 {
+		t.Errorf("totals = +%d/-%d, want +7/-3", summary.Insertions, summary.Deletions)
+	}
+
+	// The agent's index must be left alone: staged stays staged and
+	// untracked stays untracked
+	if statusAfter := run(t, dir, "status", "--porcelain"); statusAfter != statusBefore {
+		t.Errorf("git status changed:\nbefore:\n%s\nafter:\n%s", statusBefore, statusAfter)
+	}
+	if !strings.Contains(statusBefore, "?? \"new file.txt\"") || !strings.Contains(statusBefore, "A  staged.txt") {
+		t.Errorf("unexpected status:\n%s", statusBefore)
+	}
+	// Nor may the modified and untracked files be hashed into its objects
+	if after := looseObjects(t, dir); after != objects {
+		t.Errorf("Worktree() wrote objects into the repository: %d before, %d after", objects, after)
+	}
+}
+
+func TestWorktreeClean(t *testing.T) {
+	dir := setupRepo(t)
+
+	summary, err := Worktree(dir)
+	if err != nil {
+		t.Fatalf("Worktree() error = %v", err)
+	}
+	if len(summary.Files) != 0 || summary.Insertions != 0 || summary.Deletions != 0 {
+		t.Errorf("summary = %+v, want no changes", summary)
+	}
+}
+
+func TestWorktreeLinked(t *testing.T) {
+	dir := setupRepo(t)
+	linked := filepath.Join(t.TempDir(), "agent")
+	run(t, dir, "worktree", "add", "-q", "-b", "agent", linked)
+
+	write(t, linked, "extra.txt", "x\n")
+	summary, err := Worktree(linked)
+	if err != nil {
+		t.Fatalf("Worktree() error = %v", err)
+	}
+	if len(summary.Files) != 1 || summary.Files[0].Path != "extra.txt" || summary.Insertions != 1 {
+		t.Errorf("summary = %+v", summary)
+	}
+	if status := run(t, linked, "status", "--porcelain"); status != "?? extra.txt\n" {
+		t.Errorf("linked worktree status = %q", status)
+	}
+}
+
+func TestWorktreeMissing(t *testing.T) {
+	if _, err := Worktree(""); err == nil {
+		t.Error("Worktree(\"\") succeeded")
+	}
+	if _, err := Worktree(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Worktree() of a missing directory succeeded")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	dir := setupRepo(t)
+	linked := filepath.Join(t.TempDir(), "agent")
+	run(t, dir, "worktree", "add", "-q", "-b", "agent", linked)
+
+	for _, wt := range []string{dir, linked} {
+		first, err := Fingerprint(wt)
+		if err != nil {
+			t.Fatalf("Fingerprint(%s) error = %v", wt, err)
+		}
+		if again, _ := Fingerprint(wt); again != first {
+			t.Errorf("fingerprint of an unchanged worktree changed: %q != %q", again, first)
+		}
+
+		// Make sure the new mtime is distinguishable on coarse filesystems
+		future := time.Now().Add(time.Hour)
+		write(t, wt, "main.go", "package main\n")
+		os.Chtimes(filepath.Join(wt, "main.go"), future, future)
+		edited, _ := Fingerprint(wt)
+		if edited == first {
+			t.Errorf("fingerprint did not change after an edit in %s", wt)
+		}
+
+		run(t, wt, "commit", "-q", "-am", "edit")
+		committed, _ := Fingerprint(wt)
+		if committed == edited {
+			t.Errorf("fingerprint did not change after a commit in %s", wt)
+		}
+	}
+}
+
+func TestLastModified(t *testing.T) {
+	dir := setupRepo(t)
+	past := time.Now().Add(-time.Hour)
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil {
+			os.Chtimes(path, past, past)
+		}
+		return nil
+	})
+	gd, _ := GitDir(dir)
+	os.Chtimes(filepath.Join(gd, "index"), past, past)
+
+	before, err := LastModified(dir)
+	if err != nil {
+		t.Fatalf("LastModified() error = %v", err)
+	}
+	if d := before.Sub(past); d < -time.Second || d > time.Second {
+		t.Errorf("LastModified() = %v, want about %v", before, past)
+	}
+
+	write(t, dir, "main.go", "package main\n")
+	after, _ := LastModified(dir)
+	if !after.After(before) {
+		t.Errorf("LastModified() = %v after an edit, want later than %v", after, before)
+	}
+
+	if _, err := LastModified(t.TempDir()); err == nil {
+		t.Error("LastModified() outside a git worktree succeeded")
+	}
+}
+
+func TestResolveHeadPackedRefs(t *testing.T) {
+	dir := setupRepo(t)
+	want := strings.TrimSpace(run(t, dir, "rev-parse", "HEAD"))
+	run(t, dir, "pack-refs", "--all")
+
+	gd, err := GitDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resolveHead(gd); got != want {
+		t.Errorf("resolveHead() = %q, want %q", got, want)
+	}
+}
+
+func TestPatch(t *testing.T) {
+	dir := setupRepo(t)
+	write(t, dir, "main.go", "package main\n\nfunc main() { println() }\n")
+	write(t, dir, "untracked.txt", "hello\n")
+
+	patch, err := Patch(dir)
+	if err != nil {
+		t.Fatalf("Patch() error = %v", err)
+	}
+	for _, want := range []string{"diff --git a/main.go b/main.go", "+func main() { println() }", "+hello"} {
+		if !strings.Contains(patch, want) {
+			t.Errorf("patch is missing %q:\n%s", want, patch)
+		}
+	}
+
+	only, err := Patch(dir, "untracked.txt")
+	if err != nil {
+		t.Fatalf("Patch(untracked.txt) error = %v", err)
+	}
+	if strings.Contains(only, "main.go") || !strings.Contains(only, "+hello") {
+		t.Errorf("Patch(untracked.txt) = %s", only)
+	}
+	if status := run(t, dir, "status", "--porcelain"); !strings.Contains(status, "?? untracked.txt") {
+		t.Errorf("untracked file was staged: %q", status)
+	}
+}
@@ -0,0 +1,380 @@
+// Package listing defines the machine-readable form of "uzi ls".
+//
+// Output is a Document holding one Record per agent. SchemaVersion is bumped
+// whenever a field is removed, renamed or changes meaning; adding a field
+// does not change the version, so consumers should ignore unknown fields.
+//
+// Schema version 1:
+//
+//	schema_version  int       always 1
+//	generated_at    time      when the listing was produced (RFC 3339)
+//	agents          []Record  one record per agent, most recently updated first
+//	watcher         Watcher   the "uzi auto" watcher of the current repository,
+//	                          omitted outside a repository with a remote
+//
+// Record:
+//
+//	name              string    agent name, e.g. "penelope"
+//	session           string    session name, e.g. "agent-uzi-1a2b3c4-penelope"
+//	model             string    command the agent runs, e.g. "claude"
+//	status            string    idle, running, waiting, ready, merged, error or unknown;
+//	                            dead for agents whose session ended (ls -a)
+//	stuck             bool      true when the agent appears stuck
+//	insertions        int       lines added in the worktree relative to HEAD
+//	deletions         int       lines deleted in the worktree relative to HEAD
+//	files             []File    per-file changes
+//	port              int       dev server port, 0 when none
+//	url               string    dev server URL, empty when none
+//	worktree          string    worktree path
+//	branch            string    agent branch
+//	base_ref          string    branch the agent was created from
+//	labels            []string  user-defined labels
+//	prompt            string    prompt the agent was started with
+//	created_at        time      when the agent was created
+//	updated_at        time      when the agent state last changed
+//	last_worked_at    time      when the agent last finished work, omitted if never
+//	last_merged_at    time      when the agent was last merged, omitted if never
+//	ahead             int       commits on the agent branch that base_ref lacks
+//	behind            int       commits base_ref gained since the agent forked
+//	conflicts         bool      true when merging base_ref would conflict
+//	conflicts_unknown bool      true when git is too old to check for conflicts,
+//	                            omitted otherwise
+//	repo              string    remote URL of the repository the agent works on
+//	cpu_percent       float     CPU use of the agent window's processes, % of one core
+//	rss_bytes         int       resident memory of the agent window's processes
+//	dev_cpu_percent   float     CPU use of the uzi-dev window's processes
+//	dev_rss_bytes     int       resident memory of the uzi-dev window's processes
+//	status_since      time      when the agent entered its current status, omitted
+//	                            when no status has been recorded
+//	status_seconds    float     seconds spent in the current status so far
+//	running_seconds   float     total seconds the agent has spent running
+//	flips             int       times the agent went straight between running and idle
+//	question          string    what a waiting agent is asking, omitted otherwise
+//	options           []Option  choices offered by the question, omitted when free-form
+//	report            Report    completion report the agent wrote to its marker
+//	                            file, omitted when it has not written one
+//	error             Error     what put the agent in the error status, omitted
+//	                            for other statuses
+//
+// ahead, behind and conflicts compare commits only and are 0/false when the
+// base is unknown. CPU use is averaged over a short sampling interval; usage
+// fields are 0 for dead agents and where process statistics are unavailable.
+// Status times come from the history uzi records whenever it observes an
+// agent's status change, so they are only as precise as the observations.
+//
+// Option:
+//
+//	key             string    what "uzi answer" sends to pick it, e.g. "1" or "y"
+//	label           string    text of the choice, e.g. "Yes"
+//
+// Watcher:
+//
+//	running         bool      true while the watcher process is alive
+//	pid             int       its process id, omitted when not running
+//	since           time      when it started, omitted when not running
+//
+// Error:
+//
+//	category        string    crash, api or unknown
+//	excerpt         string    the error line of the agent's screen and the
+//	                          lines after it
+//	at              time      when the error was first seen
+//
+// Report:
+//
+//	summary         string    what the agent did
+//	files_touched   []string  files the agent says it changed, omitted if none
+//	tests           Tests     tests the agent ran, omitted if none
+//	open_questions  []string  questions left for the reviewer, omitted if none
+//	follow_ups      []string  suggested follow-up work, omitted if none
+//	completed_at    time      when the marker file was written
+//
+// Tests:
+//
+//	command         string    command the agent ran, e.g. "go test ./..."
+//	result          string    passed, failed or skipped
+//	details         string    free-form details, omitted if empty
+//
+// File:
+//
+//	path            string    path relative to the worktree root
+//	status          string    git status letter (A, M, D, R, ...)
+//	insertions      int       lines added, 0 for binary files
+//	deletions       int       lines deleted, 0 for binary files
+//	binary          bool      true for binary files
+package listing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaVersion is the version of the Document layout
+const SchemaVersion = 1
+
+// Output formats understood by Write
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTSV  = "tsv"
+)
+
+// File is a single changed file in an agent worktree
+type File struct {
+	Path       string `json:"path" yaml:"path"`
+	Status     string `json:"status" yaml:"status"`
+	Insertions int    `json:"insertions" yaml:"insertions"`
+	Deletions  int    `json:"deletions" yaml:"deletions"`
+	Binary     bool   `json:"binary" yaml:"binary"`
+}
+
+// Option is one choice offered by a waiting agent's question
+type Option struct {
+	Key   string `json:"key" yaml:"key"`
+	Label string `json:"label" yaml:"label"`
+}
+
+// Error describes the error an agent is in
+type Error struct {
+	Category string    `json:"category" yaml:"category"`
+	Excerpt  string    `json:"excerpt" yaml:"excerpt"`
+	At       time.Time `json:"at" yaml:"at"`
+}
+
+// Report is the completion report an agent wrote to its marker file
+type Report struct {
+	Summary       string    `json:"summary" yaml:"summary"`
+	FilesTouched  []string  `json:"files_touched,omitempty" yaml:"files_touched,omitempty"`
+	Tests         *Tests    `json:"tests,omitempty" yaml:"tests,omitempty"`
+	OpenQuestions []string  `json:"open_questions,omitempty" yaml:"open_questions,omitempty"`
+	FollowUps     []string  `json:"follow_ups,omitempty" yaml:"follow_ups,omitempty"`
+	CompletedAt   time.Time `json:"completed_at" yaml:"completed_at"`
+}
+
+// Tests is the test run described by a completion report
+type Tests struct {
+	Command string `json:"command" yaml:"command"`
+	Result  string `json:"result" yaml:"result"`
+	Details string `json:"details,omitempty" yaml:"details,omitempty"`
+}
+
+// Record describes one agent
+type Record struct {
+	Name             string     `json:"name" yaml:"name"`
+	Session          string     `json:"session" yaml:"session"`
+	Model            string     `json:"model" yaml:"model"`
+	Status           string     `json:"status" yaml:"status"`
+	Stuck            bool       `json:"stuck" yaml:"stuck"`
+	Insertions       int        `json:"insertions" yaml:"insertions"`
+	Deletions        int        `json:"deletions" yaml:"deletions"`
+	Files            []File     `json:"files" yaml:"files"`
+	Port             int        `json:"port" yaml:"port"`
+	URL              string     `json:"url" yaml:"url"`
+	Worktree         string     `json:"worktree" yaml:"worktree"`
+	Branch           string     `json:"branch" yaml:"branch"`
+	BaseRef          string     `json:"base_ref" yaml:"base_ref"`
+	Labels           []string   `json:"labels" yaml:"labels"`
+	Prompt           string     `json:"prompt" yaml:"prompt"`
+	CreatedAt        time.Time  `json:"created_at" yaml:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at" yaml:"updated_at"`
+	LastWorkedAt     *time.Time `json:"last_worked_at,omitempty" yaml:"last_worked_at,omitempty"`
+	LastMergedAt     *time.Time `json:"last_merged_at,omitempty" yaml:"last_merged_at,omitempty"`
+	Ahead            int        `json:"ahead" yaml:"ahead"`
+	Behind           int        `json:"behind" yaml:"behind"`
+	Conflicts        bool       `json:"conflicts" yaml:"conflicts"`
+	ConflictsUnknown bool       `json:"conflicts_unknown,omitempty" yaml:"conflicts_unknown,omitempty"`
+	Repo             string     `json:"repo" yaml:"repo"`
+	CPUPercent       float64    `json:"cpu_percent" yaml:"cpu_percent"`
+	RSSBytes         uint64     `json:"rss_bytes" yaml:"rss_bytes"`
+	DevCPU           float64    `json:"dev_cpu_percent" yaml:"dev_cpu_percent"`
+	DevRSSBytes      uint64     `json:"dev_rss_bytes" yaml:"dev_rss_bytes"`
+	StatusSince      *time.Time `json:"status_since,omitempty" yaml:"status_since,omitempty"`
+	StatusSeconds    float64    `json:"status_seconds" yaml:"status_seconds"`
+	RunningSeconds   float64    `json:"running_seconds" yaml:"running_seconds"`
+	Flips            int        `json:"flips" yaml:"flips"`
+	Question         string     `json:"question,omitempty" yaml:"question,omitempty"`
+	Options          []Option   `json:"options,omitempty" yaml:"options,omitempty"`
+	Report           *Report    `json:"report,omitempty" yaml:"report,omitempty"`
+	Error            *Error     `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Watcher is the state of a repository's "uzi auto" watcher
+type Watcher struct {
+	Running bool       `json:"running" yaml:"running"`
+	PID     int        `json:"pid,omitempty" yaml:"pid,omitempty"`
+	Since   *time.Time `json:"since,omitempty" yaml:"since,omitempty"`
+}
+
+// Document is the top-level object written for json and yaml output
+type Document struct {
+	SchemaVersion int       `json:"schema_version" yaml:"schema_version"`
+	GeneratedAt   time.Time `json:"generated_at" yaml:"generated_at"`
+	Agents        []Record  `json:"agents" yaml:"agents"`
+	Watcher       *Watcher  `json:"watcher,omitempty" yaml:"watcher,omitempty"`
+}
+
+// NewDocument wraps records in a Document of the current schema version
+func NewDocument(records []Record) Document {
+	if records == nil {
+		records = []Record{}
+	}
+	for i := range records {
+		if records[i].Files == nil {
+			records[i].Files = []File{}
+		}
+		if records[i].Labels == nil {
+			records[i].Labels = []string{}
+		}
+	}
+	return Document{
+		SchemaVersion: SchemaVersion,
+		GeneratedAt:   time.Now().UTC(),
+		Agents:        records,
+	}
+}
+
+// IsFormat reports whether format is understood by Write
+func IsFormat(format string) bool {
+	switch format {
+	case FormatJSON, FormatYAML, FormatTSV:
+		return true
+	}
+	return false
+}
+
+// Write encodes doc to w in the given format
+func Write(w io.Writer, format string, doc Document) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(doc)
+	case FormatYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(doc); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case FormatTSV:
+		return writeTSV(w, doc)
+	default:
+		return fmt.Errorf("unknown output format %q (want json, yaml or tsv)", format)
+	}
+}
+
+// TSVColumns is the header row of tsv output. Within a schema version new
+// columns are only ever appended. Files are written as
+// "status:insertions:deletions:path" entries separated by commas, with "-"
+// counts for binary files; labels are separated by commas; options are
+// "key:label" entries separated by "|", since labels may contain commas;
+// summary and tests_result come from the completion report; error_category
+// and error_excerpt are empty unless the agent is in the error status. Times are RFC 3339 and empty when unset.
+var TSVColumns = []string{
+	"name", "session", "model", "status", "stuck", "insertions", "deletions",
+	"files", "port", "url", "worktree", "branch", "base_ref", "labels",
+	"created_at", "updated_at", "last_worked_at", "last_merged_at", "prompt",
+	"ahead", "behind", "conflicts", "repo", "cpu_percent", "rss_bytes",
+	"dev_cpu_percent", "dev_rss_bytes", "status_since", "status_seconds",
+	"running_seconds", "flips", "question", "options", "summary", "tests_result",
+	"error_category", "error_excerpt",
+}
+
+func writeTSV(w io.Writer, doc Document) error {
+	if _, err := fmt.Fprintln(w, strings.Join(TSVColumns, "\t")); err != nil {
+		return err
+	}
+
+	for _, r := range doc.Agents {
+		files := make([]string, 0, len(r.Files))
+		for _, f := range r.Files {
+			ins, del := strconv.Itoa(f.Insertions), strconv.Itoa(f.Deletions)
+			if f.Binary {
+				ins, del = "-", "-"
+			}
+			files = append(files, fmt.Sprintf("%s:%s:%s:%s", f.Status, ins, del, f.Path))
+		}
+		var summary, testsResult string
+		if r.Report != nil {
+			summary = r.Report.Summary
+			if r.Report.Tests != nil {
+				testsResult = r.Report.Tests.Result
+			}
+		}
+		var errorCategory, errorExcerpt string
+		if r.Error != nil {
+			errorCategory, errorExcerpt = r.Error.Category, r.Error.Excerpt
+		}
+		options := make([]string, 0, len(r.Options))
+		for _, o := range r.Options {
+			options = append(options, o.Key+":"+o.Label)
+		}
+
+		fields := []string{
+			r.Name,
+			r.Session,
+			r.Model,
+			r.Status,
+			strconv.FormatBool(r.Stuck),
+			strconv.Itoa(r.Insertions),
+			strconv.Itoa(r.Deletions),
+			strings.Join(files, ","),
+			strconv.Itoa(r.Port),
+			r.URL,
+			r.Worktree,
+			r.Branch,
+			r.BaseRef,
+			strings.Join(r.Labels, ","),
+			formatTime(&r.CreatedAt),
+			formatTime(&r.UpdatedAt),
+			formatTime(r.LastWorkedAt),
+			formatTime(r.LastMergedAt),
+			r.Prompt,
+			strconv.Itoa(r.Ahead),
+			strconv.Itoa(r.Behind),
+			strconv.FormatBool(r.Conflicts),
+			r.Repo,
+			strconv.FormatFloat(r.CPUPercent, 'f', 1, 64),
+			strconv.FormatUint(r.RSSBytes, 10),
+			strconv.FormatFloat(r.DevCPU, 'f', 1, 64),
+			strconv.FormatUint(r.DevRSSBytes, 10),
+			formatTime(r.StatusSince),
+			strconv.FormatFloat(r.StatusSeconds, 'f', 0, 64),
+			strconv.FormatFloat(r.RunningSeconds, 'f', 0, 64),
+			strconv.Itoa(r.Flips),
+			r.Question,
+			strings.Join(options, "|"),
+			summary,
+			testsResult,
+			errorCategory,
+			errorExcerpt,
+		}
+		for i, field := range fields {
+			fields[i] = escapeTSV(field)
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(fields, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// escapeTSV keeps a field on one line and inside its column
+func escapeTSV(s string) string {
+	return tsvEscaper.Replace(s)
+}
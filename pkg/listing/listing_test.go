@@ -0,0 +1,230 @@
+package listing
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func sampleRecords() []Record {
+	created := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	worked := created.Add(time.Hour)
+	return []Record{
+		{
+			Name:       "penelope",
+			Session:    "agent-uzi-abc1234-penelope",
+			Model:      "claude",
+			Status:     "running",
+			Insertions: 12,
+			Deletions:  3,
+			Files: []File{
+				{Path: "main.go", Status: "M", Insertions: 10, Deletions: 3},
+				{Path: "logo.png", Status: "A", Binary: true},
+			},
+			Port:           3000,
+			URL:            "http://localhost:3000",
+			Worktree:       "/tmp/worktrees/penelope",
+			Branch:         "penelope-uzi-abc1234",
+			BaseRef:        "main",
+			Labels:         []string{"backend"},
+			Prompt:         "fix the\tbug\nplease",
+			CreatedAt:      created,
+			UpdatedAt:      created,
+			LastWorkedAt:   &worked,
+			Ahead:          2,
+			Behind:         5,
+			Conflicts:      true,
+			CPUPercent:     87.25,
+			RSSBytes:       512 << 20,
+			StatusSince:    &worked,
+			StatusSeconds:  720,
+			RunningSeconds: 1500,
+			Flips:          3,
+			Report: &Report{
+				Summary:     "Fix the nil check",
+				Tests:       &Tests{Command: "go test ./...", Result: "passed"},
+				CompletedAt: worked,
+			},
+		},
+		{
+			Name:     "george",
+			Session:  "agent-uzi-abc1234-george",
+			Model:    "codex",
+			Status:   "waiting",
+			Stuck:    true,
+			Question: "Do you want to proceed?",
+			Options:  []Option{{Key: "1", Label: "Yes"}, {Key: "2", Label: "Yes, and don't ask again"}},
+			Error:    &Error{Category: "api", Excerpt: "API Error: 529\noverloaded"},
+		},
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatJSON, NewDocument(sampleRecords())); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if doc["schema_version"] != float64(SchemaVersion) {
+		t.Errorf("schema_version = %v, want %d", doc["schema_version"], SchemaVersion)
+	}
+
+	agents := doc["agents"].([]any)
+	if len(agents) != 2 {
+		t.Fatalf("got %d agents, want 2", len(agents))
+	}
+	first := agents[0].(map[string]any)
+	for _, key := range []string{"name", "session", "model", "status", "stuck", "insertions", "deletions",
+		"files", "port", "url", "worktree", "branch", "base_ref", "created_at", "updated_at", "last_worked_at", "cpu_percent", "rss_bytes",
+		"dev_cpu_percent", "dev_rss_bytes", "status_since", "status_seconds", "running_seconds", "flips"} {
+		if _, ok := first[key]; !ok {
+			t.Errorf("record is missing %q", key)
+		}
+	}
+	if _, ok := first["last_merged_at"]; ok {
+		t.Error("unset last_merged_at should be omitted")
+	}
+	if _, ok := agents[1].(map[string]any)["status_since"]; ok {
+		t.Error("unset status_since should be omitted")
+	}
+	if _, ok := first["question"]; ok {
+		t.Error("question should be omitted for agents that are not waiting")
+	}
+	if report, ok := first["report"].(map[string]any); !ok || report["tests"].(map[string]any)["result"] != "passed" {
+		t.Errorf("report = %v", first["report"])
+	}
+	if _, ok := agents[1].(map[string]any)["report"]; ok {
+		t.Error("report should be omitted for agents that have not written one")
+	}
+
+	// Empty lists are encoded as [] rather than null
+	second := agents[1].(map[string]any)
+	if files, ok := second["files"].([]any); !ok || len(files) != 0 {
+		t.Errorf("files = %v, want []", second["files"])
+	}
+}
+
+func TestWriteJSONNoAgents(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatJSON, NewDocument(nil)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), `"agents": []`) {
+		t.Errorf("output = %s, want an empty agents list", buf.String())
+	}
+	if strings.Contains(buf.String(), "watcher") {
+		t.Errorf("output = %s, want no watcher when it is unknown", buf.String())
+	}
+
+	buf.Reset()
+	doc := NewDocument(nil)
+	doc.Watcher = &Watcher{}
+	if err := Write(&buf, FormatJSON, doc); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), `"watcher": {
+    "running": false
+  }`) {
+		t.Errorf("output = %s, want a stopped watcher without pid and since", buf.String())
+	}
+}
+
+func TestWriteYAML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatYAML, NewDocument(sampleRecords())); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	var doc Document
+	if err := yaml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("output is not valid YAML: %v", err)
+	}
+	if doc.SchemaVersion != SchemaVersion || len(doc.Agents) != 2 {
+		t.Fatalf("doc = %+v", doc)
+	}
+	if got := doc.Agents[0].Files[1]; !got.Binary || got.Path != "logo.png" {
+		t.Errorf("files[1] = %+v", got)
+	}
+}
+
+func TestWriteTSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatTSV, NewDocument(sampleRecords())); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want header and 2 rows:\n%s", len(lines), buf.String())
+	}
+	if lines[0] != strings.Join(TSVColumns, "\t") {
+		t.Errorf("header = %q", lines[0])
+	}
+
+	row := strings.Split(lines[1], "\t")
+	if len(row) != len(TSVColumns) {
+		t.Fatalf("row has %d fields, want %d: %q", len(row), len(TSVColumns), lines[1])
+	}
+	field := func(name string) string {
+		for i, column := range TSVColumns {
+			if column == name {
+				return row[i]
+			}
+		}
+		t.Fatalf("unknown column %s", name)
+		return ""
+	}
+
+	if got := field("files"); got != "M:10:3:main.go,A:-:-:logo.png" {
+		t.Errorf("files = %q", got)
+	}
+	if got := field("prompt"); got != `fix the\tbug\nplease` {
+		t.Errorf("prompt = %q, want tabs and newlines escaped", got)
+	}
+	if got := field("created_at"); got != "2025-06-01T10:00:00Z" {
+		t.Errorf("created_at = %q", got)
+	}
+	if got := field("last_merged_at"); got != "" {
+		t.Errorf("last_merged_at = %q, want empty", got)
+	}
+	if field("ahead") != "2" || field("behind") != "5" || field("conflicts") != "true" {
+		t.Errorf("drift = %s/%s/%s", field("ahead"), field("behind"), field("conflicts"))
+	}
+	if field("cpu_percent") != "87.2" || field("rss_bytes") != "536870912" || field("dev_cpu_percent") != "0.0" {
+		t.Errorf("usage = %s/%s/%s", field("cpu_percent"), field("rss_bytes"), field("dev_cpu_percent"))
+	}
+	if field("error_category") != "" {
+		t.Errorf("error_category = %q for an agent without an error", field("error_category"))
+	}
+	if field("summary") != "Fix the nil check" || field("tests_result") != "passed" {
+		t.Errorf("report = %s/%s", field("summary"), field("tests_result"))
+	}
+
+	row = strings.Split(lines[2], "\t")
+	if got := field("options"); got != "1:Yes|2:Yes, and don't ask again" {
+		t.Errorf("options = %q", got)
+	}
+	if got := field("question"); got != "Do you want to proceed?" {
+		t.Errorf("question = %q", got)
+	}
+	if field("error_category") != "api" || field("error_excerpt") != `API Error: 529\noverloaded` {
+		t.Errorf("error = %s/%s", field("error_category"), field("error_excerpt"))
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	if IsFormat("xml") {
+		t.Error("IsFormat(xml) = true")
+	}
+	if err := Write(&bytes.Buffer{}, "xml", NewDocument(nil)); err == nil {
+		t.Error("Write() with unknown format succeeded")
+	}
+}
@@ -45,6 +45,13 @@ func (nc *NotificationClient) NotifyError(message string, err error) error {
 	return nc.sendNotification(NotificationError, message, metadata)
 }
 
+// NotifyAgentError tells the manager that the agent hit an error, with its
+// category (crash, api, ...) and the excerpt of the screen that shows it
+func (nc *NotificationClient) NotifyAgentError(category, excerpt string) error {
+	metadata := map[string]any{"category": category}
+	return nc.sendNotification(NotificationError, excerpt, metadata)
+}
+
 // NotifyProgress sends a progress update to the manager
 func (nc *NotificationClient) NotifyProgress(message string, progress int) error {
 	metadata := map[string]any{
@@ -53,6 +60,23 @@ func (nc *NotificationClient) NotifyProgress(message string, progress int) error
 	return nc.sendNotification(NotificationProgress, message, metadata)
 }
 
+// NotifyWaiting tells the manager that the agent is blocked on a question,
+// with the choices it offers if any
+func (nc *NotificationClient) NotifyWaiting(question string, options []string) error {
+	var metadata map[string]any
+	if len(options) > 0 {
+		metadata = map[string]any{"options": options}
+	}
+	return nc.sendNotification(NotificationWaiting, question, metadata)
+}
+
+// NotifyEscalation tells the manager that the agent is waiting on a prompt
+// that uzi auto declined to answer, and why
+func (nc *NotificationClient) NotifyEscalation(prompt, reason string) error {
+	metadata := map[string]any{"escalated": true, "reason": reason}
+	return nc.sendNotification(NotificationWaiting, prompt, metadata)
+}
+
 // sendNotification sends a notification to the manager
 func (nc *NotificationClient) sendNotification(notifType NotificationType, message string, metadata map[string]any) error {
 	notification := Notification{
@@ -44,13 +44,18 @@ func TestNotificationServerClient(t *testing.T) {
 		t.Errorf("Failed to send progress notification: %v", err)
 	}
 	
+	// Test sending waiting notification
+	if err := client.NotifyWaiting("Do you want to proceed?", []string{"1. Yes", "2. No"}); err != nil {
+		t.Errorf("Failed to send waiting notification: %v", err)
+	}
+	
 	// Give some time for processing
 	time.Sleep(100 * time.Millisecond)
 	
 	// Check received notifications
 	count, logs := server.GetStats()
-	if count != 3 {
-		t.Errorf("Expected 3 notifications, got %d", count)
+	if count != 4 {
+		t.Errorf("Expected 4 notifications, got %d", count)
 	}
 	
 	// Verify notification types
@@ -58,6 +63,7 @@ func TestNotificationServerClient(t *testing.T) {
 		NotificationComplete,
 		NotificationError,
 		NotificationProgress,
+		NotificationWaiting,
 	}
 	
 	for i, notif := range logs {
@@ -68,6 +74,9 @@ func TestNotificationServerClient(t *testing.T) {
 			t.Errorf("Expected notification type %s, got %s", expectedTypes[i], notif.Type)
 		}
 	}
+	if len(logs) == 4 && logs[3].Metadata["options"] == nil {
+		t.Errorf("Waiting notification lost its options: %+v", logs[3])
+	}
 }
 
 func TestNotificationChannel(t *testing.T) {
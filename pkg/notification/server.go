@@ -18,8 +18,12 @@ const (
 	NotificationComplete NotificationType = "complete"
 	NotificationError    NotificationType = "error"
 	NotificationProgress NotificationType = "progress"
+	NotificationWaiting  NotificationType = "waiting"
 )
 
+// DefaultPort is the port the manager's notification server listens on
+const DefaultPort = 9999
+
 // Notification represents a notification from a worker
 type Notification struct {
 	SessionName string           `json:"session_name"`
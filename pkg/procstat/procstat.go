@@ -0,0 +1,158 @@
+// Package procstat measures the CPU and memory use of process trees, such as
+// everything started from an agent's terminal window.
+package procstat
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrUnsupported is returned where process statistics cannot be read
+var ErrUnsupported = errors.New("process statistics are only available on linux")
+
+// ClockTicks is the unit of the CPU times in /proc, USER_HZ, which Linux
+// fixes at 100 on every architecture
+const ClockTicks = 100
+
+// Process is one entry of the process table
+type Process struct {
+	PID  int
+	PPID int
+	// CPUTicks is the user and system time used so far, in ClockTicks
+	CPUTicks uint64
+	// StartTicks is when the process started after boot, in ClockTicks.
+	// Together with PID it identifies a process across samples.
+	StartTicks uint64
+	// RSS is the resident memory in bytes
+	RSS uint64
+}
+
+// Table is a snapshot of every process on the machine
+type Table struct {
+	At       time.Time
+	procs    map[int]Process
+	children map[int][]int
+}
+
+// NewTable indexes processes read at the given time
+func NewTable(procs []Process, at time.Time) *Table {
+	t := &Table{At: at, procs: make(map[int]Process, len(procs)), children: make(map[int][]int)}
+	for _, p := range procs {
+		t.procs[p.PID] = p
+		t.children[p.PPID] = append(t.children[p.PPID], p.PID)
+	}
+	return t
+}
+
+// Read takes a snapshot of the process table
+func Read() (*Table, error) {
+	procs, err := readProcesses()
+	if err != nil {
+		return nil, err
+	}
+	return NewTable(procs, time.Now()), nil
+}
+
+// Tree returns roots that still exist and all of their descendants
+func (t *Table) Tree(roots ...int) []int {
+	var pids []int
+	seen := make(map[int]bool)
+	queue := append([]int(nil), roots...)
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		if seen[pid] {
+			continue
+		}
+		seen[pid] = true
+		if _, ok := t.procs[pid]; !ok {
+			continue
+		}
+		pids = append(pids, pid)
+		queue = append(queue, t.children[pid]...)
+	}
+	sort.Ints(pids)
+	return pids
+}
+
+// Usage is the combined resource use of a process tree
+type Usage struct {
+	// CPUPercent is the CPU time used between two samples as a percentage
+	// of one core, so a busy tree can exceed 100
+	CPUPercent float64
+	// RSS is the resident memory in bytes
+	RSS uint64
+	// Processes is the number of processes in the tree
+	Processes int
+}
+
+// Sampler keeps the last two process tables so CPU use can be measured
+// between them. It is safe for concurrent use.
+type Sampler struct {
+	read func() (*Table, error)
+
+	mu   sync.Mutex
+	prev *Table
+	cur  *Table
+}
+
+// NewSampler returns a sampler reading the live process table
+func NewSampler() *Sampler {
+	return &Sampler{read: Read}
+}
+
+// Sample reads a new process table
+func (s *Sampler) Sample() error {
+	t, err := s.read()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.prev, s.cur = s.cur, t
+	s.mu.Unlock()
+	return nil
+}
+
+// Ready reports whether two samples have been taken, which CPU use needs
+func (s *Sampler) Ready() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.prev != nil
+}
+
+// Usage returns the resource use of the trees under roots in the latest
+// sample. CPU time of processes that exited between the samples is lost.
+func (s *Sampler) Usage(roots ...int) Usage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var u Usage
+	if s.cur == nil {
+		return u
+	}
+	var ticks uint64
+	for _, pid := range s.cur.Tree(roots...) {
+		p := s.cur.procs[pid]
+		u.RSS += p.RSS
+		u.Processes++
+
+		// A process missing from the previous sample started after it,
+		// so all of its CPU time falls between the samples
+		used := p.CPUTicks
+		if s.prev != nil {
+			if old, ok := s.prev.procs[pid]; ok && old.StartTicks == p.StartTicks && old.CPUTicks <= used {
+				used -= old.CPUTicks
+			}
+		}
+		ticks += used
+	}
+
+	if s.prev != nil {
+		if elapsed := s.cur.At.Sub(s.prev.At).Seconds(); elapsed > 0 {
+			u.CPUPercent = float64(ticks) / ClockTicks / elapsed * 100
+		}
+	}
+	return u
+}
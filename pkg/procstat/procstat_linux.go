@@ -0,0 +1,63 @@
+package procstat
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// readProcesses reads /proc/<pid>/stat of every process. Processes that
+// exit while the table is read are skipped.
+func readProcesses() ([]Process, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read /proc: %w", err)
+	}
+
+	pageSize := uint64(os.Getpagesize())
+	var procs []Process
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		if p, err := parseStat(pid, data, pageSize); err == nil {
+			procs = append(procs, p)
+		}
+	}
+	return procs, nil
+}
+
+// parseStat decodes the fields of /proc/<pid>/stat that Usage needs; see
+// proc(5). The command name may contain spaces and parentheses, so fields
+// are counted from its closing parenthesis.
+func parseStat(pid int, data []byte, pageSize uint64) (Process, error) {
+	end := bytes.LastIndexByte(data, ')')
+	if end < 0 {
+		return Process{}, fmt.Errorf("malformed stat for pid %d", pid)
+	}
+	fields := bytes.Fields(data[end+1:])
+	// fields[0] is field 3 (state); rss is field 24
+	if len(fields) < 22 {
+		return Process{}, fmt.Errorf("malformed stat for pid %d", pid)
+	}
+
+	number := func(field int) uint64 {
+		n, _ := strconv.ParseUint(string(fields[field-3]), 10, 64)
+		return n
+	}
+	ppid, _ := strconv.Atoi(string(fields[4-3]))
+	return Process{
+		PID:        pid,
+		PPID:       ppid,
+		CPUTicks:   number(14) + number(15),
+		StartTicks: number(22),
+		RSS:        number(24) * pageSize,
+	}, nil
+}
@@ -0,0 +1,57 @@
+package procstat
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestParseStat(t *testing.T) {
+	stat := "4242 (a (weird) name) S 17 4242 4242 0 -1 4194560 100 0 0 0 250 75 0 0 20 0 1 0 12345 1000000 300 18446744073709551615\n"
+	p, err := parseStat(4242, []byte(stat), 4096)
+	if err != nil {
+		t.Fatalf("parseStat() error = %v", err)
+	}
+	want := Process{PID: 4242, PPID: 17, CPUTicks: 325, StartTicks: 12345, RSS: 300 * 4096}
+	if p != want {
+		t.Errorf("parseStat() = %+v, want %+v", p, want)
+	}
+
+	if _, err := parseStat(1, []byte("1 (sh S 0"), 4096); err == nil {
+		t.Error("parseStat() of a truncated line returned no error")
+	}
+}
+
+func TestSamplerMeasuresChildren(t *testing.T) {
+	// A shell whose child spins on the CPU
+	cmd := exec.Command("sh", "-c", "while :; do :; done & wait")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start sh: %v", err)
+	}
+	defer func() {
+		if table, err := Read(); err == nil {
+			for _, pid := range table.Tree(cmd.Process.Pid) {
+				if p, err := os.FindProcess(pid); err == nil {
+					p.Kill()
+				}
+			}
+		}
+		cmd.Wait()
+	}()
+
+	s := NewSampler()
+	s.Sample()
+	time.Sleep(300 * time.Millisecond)
+	if err := s.Sample(); err != nil {
+		t.Fatalf("Sample() error = %v", err)
+	}
+
+	u := s.Usage(cmd.Process.Pid)
+	if u.Processes < 2 || u.RSS == 0 {
+		t.Errorf("Usage() = %+v, want the shell and its child", u)
+	}
+	if u.CPUPercent < 20 {
+		t.Errorf("Usage().CPUPercent = %.1f for a spinning child", u.CPUPercent)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package procstat
+
+func readProcesses() ([]Process, error) {
+	return nil, ErrUnsupported
+}
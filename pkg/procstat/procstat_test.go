@@ -0,0 +1,72 @@
+package procstat
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTree(t *testing.T) {
+	table := NewTable([]Process{
+		{PID: 1, PPID: 0},
+		{PID: 10, PPID: 1},
+		{PID: 11, PPID: 10},
+		{PID: 12, PPID: 11},
+		{PID: 20, PPID: 1},
+	}, time.Now())
+
+	if got := table.Tree(10); !reflect.DeepEqual(got, []int{10, 11, 12}) {
+		t.Errorf("Tree(10) = %v", got)
+	}
+	if got := table.Tree(11, 20, 99); !reflect.DeepEqual(got, []int{11, 12, 20}) {
+		t.Errorf("Tree(11, 20, 99) = %v", got)
+	}
+	if got := table.Tree(); len(got) != 0 {
+		t.Errorf("Tree() = %v", got)
+	}
+}
+
+func TestSamplerUsage(t *testing.T) {
+	start := time.Now()
+	tables := []*Table{
+		NewTable([]Process{
+			{PID: 10, PPID: 1, CPUTicks: 100, StartTicks: 5, RSS: 1000},
+			{PID: 11, PPID: 10, CPUTicks: 50, StartTicks: 6, RSS: 2000},
+		}, start),
+		NewTable([]Process{
+			{PID: 10, PPID: 1, CPUTicks: 150, StartTicks: 5, RSS: 1000},
+			// PID 11 was reused by a new process
+			{PID: 11, PPID: 10, CPUTicks: 20, StartTicks: 90, RSS: 500},
+			{PID: 12, PPID: 11, CPUTicks: 30, StartTicks: 91, RSS: 4000},
+			{PID: 30, PPID: 1, CPUTicks: 500, StartTicks: 7, RSS: 8000},
+		}, start.Add(2*time.Second)),
+	}
+	s := &Sampler{read: func() (*Table, error) {
+		table := tables[0]
+		tables = tables[1:]
+		return table, nil
+	}}
+
+	if err := s.Sample(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Ready() {
+		t.Error("Ready() after one sample")
+	}
+	if u := s.Usage(10); u.CPUPercent != 0 || u.RSS != 3000 || u.Processes != 2 {
+		t.Errorf("Usage() after one sample = %+v", u)
+	}
+
+	s.Sample()
+	if !s.Ready() {
+		t.Error("not Ready() after two samples")
+	}
+	// 50 + 20 + 30 ticks over 2 seconds at 100 ticks per second
+	u := s.Usage(10)
+	if u.CPUPercent != 50 || u.RSS != 5500 || u.Processes != 3 {
+		t.Errorf("Usage(10) = %+v, want 50%% of 5500 bytes in 3 processes", u)
+	}
+	if u := s.Usage(99); u != (Usage{}) {
+		t.Errorf("Usage() of a missing root = %+v", u)
+	}
+}
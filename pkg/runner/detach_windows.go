@@ -0,0 +1,8 @@
+//go:build windows
+
+package runner
+
+import "os/exec"
+
+// detach is a no-op on Windows, where the PTY supervisor is unsupported
+func detach(cmd *exec.Cmd) {}
@@ -0,0 +1,38 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// EventsPath returns the file whose modification time runners bump when a
+// session or window starts or ends, so watchers such as "uzi ls -w" notice
+// lifecycle changes without polling the runner
+func EventsPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".local", "share", "uzi", "events"), nil
+}
+
+// NotifyEvent touches the events file, creating it if needed
+func NotifyEvent() error {
+	path, err := EventsPath()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,47 @@
+package runner
+
+import "strings"
+
+var namedKeys = map[string]string{
+	"Enter":    "\r",
+	"C-m":      "\r",
+	"Tab":      "\t",
+	"C-i":      "\t",
+	"Escape":   "\x1b",
+	"Space":    " ",
+	"BSpace":   "\x7f",
+	"Up":       "\x1b[A",
+	"Down":     "\x1b[B",
+	"Right":    "\x1b[C",
+	"Left":     "\x1b[D",
+	"Home":     "\x1b[H",
+	"End":      "\x1b[F",
+	"PageUp":   "\x1b[5~",
+	"PageDown": "\x1b[6~",
+	"DC":       "\x1b[3~",
+}
+
+// KeysToBytes converts tmux send-keys style arguments to the bytes a
+// terminal would send. Key names and C-<letter> chords are translated;
+// every other argument is typed literally.
+func KeysToBytes(keys ...string) []byte {
+	var out []byte
+	for _, key := range keys {
+		if seq, ok := namedKeys[key]; ok {
+			out = append(out, seq...)
+			continue
+		}
+		if len(key) == 3 && strings.HasPrefix(key, "C-") {
+			c := key[2]
+			if c >= 'A' && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			if c >= 'a' && c <= 'z' {
+				out = append(out, c-'a'+1)
+				continue
+			}
+		}
+		out = append(out, key...)
+	}
+	return out
+}
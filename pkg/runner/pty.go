@@ -0,0 +1,232 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// Operations understood by the PTY supervisor
+const (
+	OpStart      = "start"
+	OpNewWindow  = "new-window"
+	OpSend       = "send"
+	OpRead       = "read"
+	OpTranscribe = "transcribe"
+	OpCommand    = "command"
+	OpPIDs       = "pids"
+	OpKillWindow = "kill-window"
+	OpStop       = "stop"
+	OpHas        = "has"
+	OpList       = "list"
+	OpShutdown   = "shutdown"
+)
+
+// Request is a single call to the PTY supervisor. Each connection carries
+// exactly one request and one response, both JSON encoded.
+type Request struct {
+	Op      string   `json:"op"`
+	Session string   `json:"session,omitempty"`
+	Window  string   `json:"window,omitempty"`
+	Dir     string   `json:"dir,omitempty"`
+	Keys    []string `json:"keys,omitempty"`
+	Path    string   `json:"path,omitempty"`
+}
+
+// Response is the supervisor's answer to a Request
+type Response struct {
+	Error    string           `json:"error,omitempty"`
+	Screen   string           `json:"screen,omitempty"`
+	Exists   bool             `json:"exists,omitempty"`
+	Sessions []string         `json:"sessions,omitempty"`
+	PIDs     map[string][]int `json:"pids,omitempty"`
+	Command  string           `json:"command,omitempty"`
+}
+
+// PTYSocketPath returns the unix socket of the PTY supervisor
+func PTYSocketPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".local", "share", "uzi", "pty.sock"), nil
+}
+
+// ptyRunner talks to the "uzi pty-server" supervisor, starting it on demand
+type ptyRunner struct {
+	socketPath string
+}
+
+// NewPTYRunner returns a Runner backed by the uzi PTY supervisor
+func NewPTYRunner() Runner {
+	socketPath, _ := PTYSocketPath()
+	return &ptyRunner{socketPath: socketPath}
+}
+
+// NewPTYRunnerWithSocket returns a PTY runner that uses a specific socket
+func NewPTYRunnerWithSocket(socketPath string) Runner {
+	return &ptyRunner{socketPath: socketPath}
+}
+
+func (r *ptyRunner) Name() string {
+	return RunnerPTY
+}
+
+var errNotRunning = errors.New("pty supervisor is not running")
+
+func (r *ptyRunner) call(ctx context.Context, req Request) (Response, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", r.socketPath)
+	if err != nil {
+		return Response{}, errNotRunning
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+	}
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return Response{}, fmt.Errorf("pty supervisor: %w", err)
+	}
+
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return Response{}, fmt.Errorf("pty supervisor: %w", err)
+	}
+	if resp.Error != "" {
+		return resp, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+// ensureServer starts "uzi pty-server" in the background if it is not
+// already listening, and waits for its socket to come up
+func (r *ptyRunner) ensureServer(ctx context.Context) error {
+	if _, err := r.call(ctx, Request{Op: OpList}); err == nil {
+		return nil
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("could not resolve uzi executable: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.socketPath), 0755); err != nil {
+		return err
+	}
+
+	logFile, err := os.OpenFile(filepath.Join(filepath.Dir(r.socketPath), "pty-server.log"),
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(executable, "pty-server", "-socket", r.socketPath)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start pty supervisor: %w", err)
+	}
+	cmd.Process.Release()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := r.call(ctx, Request{Op: OpList}); err == nil {
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return fmt.Errorf("pty supervisor did not start listening on %s", r.socketPath)
+}
+
+func (r *ptyRunner) Start(ctx context.Context, session, dir string) error {
+	if err := r.ensureServer(ctx); err != nil {
+		return err
+	}
+	_, err := r.call(ctx, Request{Op: OpStart, Session: session, Window: "agent", Dir: dir})
+	return err
+}
+
+func (r *ptyRunner) NewWindow(ctx context.Context, session, window, dir string) error {
+	_, err := r.call(ctx, Request{Op: OpNewWindow, Session: session, Window: window, Dir: dir})
+	return err
+}
+
+func (r *ptyRunner) SendKeys(ctx context.Context, session, window string, keys ...string) error {
+	_, err := r.call(ctx, Request{Op: OpSend, Session: session, Window: window, Keys: keys})
+	return err
+}
+
+func (r *ptyRunner) ReadScreen(ctx context.Context, session, window string) (string, error) {
+	resp, err := r.call(ctx, Request{Op: OpRead, Session: session, Window: window})
+	if err != nil {
+		return "", err
+	}
+	return resp.Screen, nil
+}
+
+func (r *ptyRunner) Transcribe(ctx context.Context, session, window, path string) error {
+	_, err := r.call(ctx, Request{Op: OpTranscribe, Session: session, Window: window, Path: path})
+	return err
+}
+
+func (r *ptyRunner) CurrentCommand(ctx context.Context, session, window string) (string, error) {
+	resp, err := r.call(ctx, Request{Op: OpCommand, Session: session, Window: window})
+	if err != nil {
+		return "", err
+	}
+	return resp.Command, nil
+}
+
+func (r *ptyRunner) WindowPIDs(ctx context.Context, session string) (map[string][]int, error) {
+	resp, err := r.call(ctx, Request{Op: OpPIDs, Session: session})
+	if err != nil {
+		return nil, err
+	}
+	return resp.PIDs, nil
+}
+
+func (r *ptyRunner) KillWindow(ctx context.Context, session, window string) error {
+	_, err := r.call(ctx, Request{Op: OpKillWindow, Session: session, Window: window})
+	return err
+}
+
+func (r *ptyRunner) Stop(ctx context.Context, session string) error {
+	_, err := r.call(ctx, Request{Op: OpStop, Session: session})
+	return err
+}
+
+func (r *ptyRunner) HasSession(session string) bool {
+	resp, err := r.call(context.Background(), Request{Op: OpHas, Session: session})
+	return err == nil && resp.Exists
+}
+
+func (r *ptyRunner) ListSessions() ([]string, error) {
+	resp, err := r.call(context.Background(), Request{Op: OpList})
+	if errors.Is(err, errNotRunning) {
+		// No supervisor means no sessions
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.Sessions, nil
+}
+
+func (r *ptyRunner) Shutdown(ctx context.Context) error {
+	_, err := r.call(ctx, Request{Op: OpShutdown})
+	if errors.Is(err, errNotRunning) {
+		return nil
+	}
+	return err
+}
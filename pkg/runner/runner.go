@@ -0,0 +1,116 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/devflowinc/uzi/pkg/config"
+)
+
+const (
+	// RunnerTmux runs agents in sessions on the dedicated uzi tmux server
+	RunnerTmux = "tmux"
+	// RunnerPTY runs agents in pseudo-terminals owned by "uzi pty-server"
+	RunnerPTY = "pty"
+
+	// RunnerEnv overrides the runner selection from the environment
+	RunnerEnv = "UZI_RUNNER"
+)
+
+// Runner starts agent sessions and gives access to their terminals. A
+// session holds one or more named windows, each running a shell; the first
+// window of every session is "agent".
+//
+// Keys passed to SendKeys follow tmux send-keys conventions: names such as
+// "Enter", "C-m", "C-c" or "Escape" are sent as keys, anything else is typed
+// literally.
+type Runner interface {
+	// Name returns the runner identifier ("tmux" or "pty")
+	Name() string
+	// Start creates a session whose "agent" window runs a shell in dir
+	Start(ctx context.Context, session, dir string) error
+	// NewWindow adds a window running a shell in dir to an existing session
+	NewWindow(ctx context.Context, session, window, dir string) error
+	// SendKeys types keys into a window
+	SendKeys(ctx context.Context, session, window string, keys ...string) error
+	// ReadScreen returns the visible contents of a window
+	ReadScreen(ctx context.Context, session, window string) (string, error)
+	// Transcribe streams all output of a window into the transcript at path
+	Transcribe(ctx context.Context, session, window, path string) error
+	// CurrentCommand returns the name of the process in the foreground of a
+	// window: the agent while it runs, the shell once it has exited
+	CurrentCommand(ctx context.Context, session, window string) (string, error)
+	// WindowPIDs returns the process IDs of the shells running in each
+	// window of a session, keyed by window name
+	WindowPIDs(ctx context.Context, session string) (map[string][]int, error)
+	// KillWindow closes a single window
+	KillWindow(ctx context.Context, session, window string) error
+	// Stop terminates a session and every process in it
+	Stop(ctx context.Context, session string) error
+	// HasSession reports whether the session is running
+	HasSession(session string) bool
+	// ListSessions returns the names of all running sessions
+	ListSessions() ([]string, error)
+	// Shutdown terminates every session owned by the runner
+	Shutdown(ctx context.Context) error
+}
+
+// shells are the command names reported for a window sitting at its prompt
+var shells = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "fish": true, "dash": true, "ksh": true,
+	"mksh": true, "tcsh": true, "csh": true, "nu": true, "pwsh": true,
+}
+
+// IsShell reports whether a command returned by CurrentCommand is a shell,
+// meaning that nothing else runs in the foreground of the window
+func IsShell(command string) bool {
+	command = strings.TrimPrefix(strings.TrimSpace(command), "-")
+	if command == "" {
+		return false
+	}
+	if shells[command] {
+		return true
+	}
+	shell := os.Getenv("SHELL")
+	return shell != "" && filepath.Base(shell) == command
+}
+
+var (
+	defaultOnce   sync.Once
+	defaultRunner Runner
+)
+
+// Default returns the runner selected by $UZI_RUNNER or "runner" in uzi.yaml.
+// Without either, tmux is used when installed and the PTY supervisor otherwise.
+func Default() Runner {
+	defaultOnce.Do(func() {
+		defaultRunner = New(selectedName())
+	})
+	return defaultRunner
+}
+
+// New returns the runner with the given name, falling back to tmux
+func New(name string) Runner {
+	if name == RunnerPTY {
+		return NewPTYRunner()
+	}
+	return NewTmuxRunner()
+}
+
+func selectedName() string {
+	if env := os.Getenv(RunnerEnv); env != "" {
+		return env
+	}
+	if cfg, err := config.LoadConfig(config.GetDefaultConfigPath()); err == nil &&
+		cfg.Runner != nil && *cfg.Runner != "" {
+		return *cfg.Runner
+	}
+	if _, err := exec.LookPath("tmux"); err != nil {
+		return RunnerPTY
+	}
+	return RunnerTmux
+}
@@ -0,0 +1,53 @@
+package runner
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestKeysToBytes(t *testing.T) {
+	tests := []struct {
+		keys []string
+		want []byte
+	}{
+		{[]string{"echo hi", "Enter"}, []byte("echo hi\r")},
+		{[]string{"C-m"}, []byte("\r")},
+		{[]string{"C-c"}, []byte{0x03}},
+		{[]string{"C-D"}, []byte{0x04}},
+		{[]string{"Escape", "Up"}, []byte("\x1b\x1b[A")},
+		{[]string{"BSpace"}, []byte{0x7f}},
+		{[]string{"Enterprise"}, []byte("Enterprise")},
+		{[]string{"C-"}, []byte("C-")},
+	}
+
+	for _, tt := range tests {
+		if got := KeysToBytes(tt.keys...); !bytes.Equal(got, tt.want) {
+			t.Errorf("KeysToBytes(%q) = %q, want %q", tt.keys, got, tt.want)
+		}
+	}
+}
+
+func TestIsShell(t *testing.T) {
+	t.Setenv("SHELL", "/usr/local/bin/elvish")
+	for command, want := range map[string]bool{
+		"bash": true, "-zsh": true, "sh\n": true, "elvish": true,
+		"claude": false, "node": false, "": false,
+	} {
+		if got := IsShell(command); got != want {
+			t.Errorf("IsShell(%q) = %v, want %v", command, got, want)
+		}
+	}
+}
+
+func TestSelectedNameFromEnv(t *testing.T) {
+	t.Setenv(RunnerEnv, RunnerPTY)
+	if got := selectedName(); got != RunnerPTY {
+		t.Errorf("selectedName() = %q, want %q", got, RunnerPTY)
+	}
+	if got := New(selectedName()).Name(); got != RunnerPTY {
+		t.Errorf("New().Name() = %q, want %q", got, RunnerPTY)
+	}
+	if got := New("unknown").Name(); got != RunnerTmux {
+		t.Errorf("New(unknown).Name() = %q, want %q", got, RunnerTmux)
+	}
+}
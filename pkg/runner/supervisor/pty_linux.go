@@ -0,0 +1,88 @@
+//go:build linux
+
+package supervisor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// openPTY allocates a pseudo-terminal pair through /dev/ptmx
+func openPTY() (*os.File, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var unlock int32
+	if err := ioctl(master.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("unlock pty: %w", err)
+	}
+
+	var ptyNumber uint32
+	if err := ioctl(master.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&ptyNumber))); err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("get pty number: %w", err)
+	}
+
+	slave, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", ptyNumber), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	return master, slave, nil
+}
+
+type winsize struct {
+	rows, cols, x, y uint16
+}
+
+// setSize sets the terminal size seen by processes on the pty
+func setSize(f *os.File, cols, rows int) error {
+	ws := winsize{rows: uint16(rows), cols: uint16(cols)}
+	return ioctl(f.Fd(), syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&ws)))
+}
+
+// setControllingTerminal makes the pty on stdin the controlling terminal of
+// a new session for cmd
+func setControllingTerminal(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
+}
+
+// foregroundCommand returns the name of the foreground process group
+// leader on the pty, as tmux reports it in #{pane_current_command}
+func foregroundCommand(master *os.File) (string, error) {
+	var pgid int32
+	if err := ioctl(master.Fd(), syscall.TIOCGPGRP, uintptr(unsafe.Pointer(&pgid))); err != nil {
+		return "", fmt.Errorf("get foreground process group: %w", err)
+	}
+	comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pgid))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(comm)), nil
+}
+
+func ioctl(fd, request, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// terminate hangs up the process group of cmd and kills it if it lingers
+func terminate(cmd *exec.Cmd, done <-chan struct{}) {
+	pgid := cmd.Process.Pid
+	syscall.Kill(-pgid, syscall.SIGHUP)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		syscall.Kill(-pgid, syscall.SIGKILL)
+	}
+}
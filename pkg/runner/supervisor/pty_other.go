@@ -0,0 +1,29 @@
+//go:build !linux
+
+package supervisor
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+var errUnsupported = errors.New("the pty runner is only supported on linux")
+
+func openPTY() (*os.File, *os.File, error) {
+	return nil, nil, errUnsupported
+}
+
+func setSize(f *os.File, cols, rows int) error {
+	return errUnsupported
+}
+
+func foregroundCommand(master *os.File) (string, error) {
+	return "", errUnsupported
+}
+
+func setControllingTerminal(cmd *exec.Cmd) {}
+
+func terminate(cmd *exec.Cmd, done <-chan struct{}) {
+	cmd.Process.Kill()
+}
@@ -0,0 +1,494 @@
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/devflowinc/uzi/pkg/runner"
+	"github.com/devflowinc/uzi/pkg/runner/vt"
+	"github.com/devflowinc/uzi/pkg/transcript"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	// DefaultWidth is the terminal width given to every window
+	DefaultWidth = 200
+	// DefaultHeight is the terminal height given to every window
+	DefaultHeight = 50
+)
+
+// window is one shell running on its own pseudo-terminal
+type window struct {
+	name       string
+	cmd        *exec.Cmd
+	master     *os.File
+	screen     *vt.Screen
+	mu         sync.Mutex
+	transcript io.Writer
+	closer     io.Closer
+	done       chan struct{}
+}
+
+func (w *window) setTranscript(path string) error {
+	writer, err := transcript.NewRotatingWriter(path, transcript.DefaultMaxSize, transcript.DefaultMaxBackups)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closer != nil {
+		w.closer.Close()
+	}
+	w.transcript = transcript.NewStripWriter(writer)
+	w.closer = writer
+	return nil
+}
+
+// pump copies terminal output into the screen buffer and the transcript
+// until the pty is closed
+func (w *window) pump() {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := w.master.Read(buf)
+		if n > 0 {
+			w.screen.Write(buf[:n])
+			w.mu.Lock()
+			if w.transcript != nil {
+				w.transcript.Write(buf[:n])
+			}
+			w.mu.Unlock()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (w *window) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closer != nil {
+		w.closer.Close()
+		w.closer = nil
+		w.transcript = nil
+	}
+}
+
+type session struct {
+	name    string
+	windows map[string]*window
+	// stopping is set once the session is being killed; no windows are
+	// added to it after that
+	stopping bool
+}
+
+// Server owns the pseudo-terminals of every session and serves runner
+// requests on a unix socket. It exits once its last session has ended.
+type Server struct {
+	socketPath string
+	shell      string
+	mu         sync.Mutex
+	sessions   map[string]*session
+	listener   net.Listener
+	conns      sync.WaitGroup
+	stopOnce   sync.Once
+	stopped    chan struct{}
+}
+
+// New returns a server that will listen on socketPath
+func New(socketPath string) *Server {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	return &Server{
+		socketPath: socketPath,
+		shell:      shell,
+		sessions:   make(map[string]*session),
+		stopped:    make(chan struct{}),
+	}
+}
+
+// Serve accepts requests until ctx is cancelled, a shutdown request
+// arrives, or the last session ends
+func (s *Server) Serve(ctx context.Context) error {
+	if conn, err := net.Dial("unix", s.socketPath); err == nil {
+		conn.Close()
+		return fmt.Errorf("pty supervisor already running on %s", s.socketPath)
+	}
+	os.Remove(s.socketPath)
+
+	if err := os.MkdirAll(filepath.Dir(s.socketPath), 0755); err != nil {
+		return err
+	}
+	listener, err := net.Listen("unix", s.socketPath)
+	if err != nil {
+		return err
+	}
+	os.Chmod(s.socketPath, 0600)
+	s.listener = listener
+	log.Info("PTY supervisor listening", "socket", s.socketPath)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.stopped:
+		}
+		s.stop()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			// Let in-flight requests, such as the stop that ended the last
+			// session, send their responses before exiting
+			s.conns.Wait()
+			select {
+			case <-s.stopped:
+				return nil
+			default:
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		s.conns.Add(1)
+		go s.handle(conn)
+	}
+}
+
+// stop terminates every session and closes the listener
+func (s *Server) stop() {
+	s.stopOnce.Do(func() {
+		s.mu.Lock()
+		var sessions []*session
+		for _, sess := range s.sessions {
+			sessions = append(sessions, sess)
+		}
+		s.mu.Unlock()
+
+		for _, sess := range sessions {
+			s.stopSession(sess.name)
+		}
+
+		log.Info("PTY supervisor stopped")
+		close(s.stopped)
+		if s.listener != nil {
+			s.listener.Close()
+		}
+		os.Remove(s.socketPath)
+	})
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer s.conns.Done()
+	defer conn.Close()
+
+	var req runner.Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		json.NewEncoder(conn).Encode(runner.Response{Error: fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+
+	resp := s.dispatch(req)
+	json.NewEncoder(conn).Encode(resp)
+
+	if req.Op == runner.OpShutdown {
+		go s.stop()
+	}
+}
+
+func errorResponse(err error) runner.Response {
+	if err == nil {
+		return runner.Response{}
+	}
+	return runner.Response{Error: err.Error()}
+}
+
+func (s *Server) dispatch(req runner.Request) runner.Response {
+	switch req.Op {
+	case runner.OpStart:
+		return errorResponse(s.startSession(req.Session, req.Window, req.Dir))
+	case runner.OpNewWindow:
+		return errorResponse(s.newWindow(req.Session, req.Window, req.Dir))
+	case runner.OpSend:
+		w, err := s.window(req.Session, req.Window)
+		if err != nil {
+			return errorResponse(err)
+		}
+		_, err = w.master.Write(runner.KeysToBytes(req.Keys...))
+		return errorResponse(err)
+	case runner.OpRead:
+		w, err := s.window(req.Session, req.Window)
+		if err != nil {
+			return errorResponse(err)
+		}
+		return runner.Response{Screen: w.screen.String()}
+	case runner.OpTranscribe:
+		w, err := s.window(req.Session, req.Window)
+		if err != nil {
+			return errorResponse(err)
+		}
+		return errorResponse(w.setTranscript(req.Path))
+	case runner.OpCommand:
+		w, err := s.window(req.Session, req.Window)
+		if err != nil {
+			return errorResponse(err)
+		}
+		command, err := foregroundCommand(w.master)
+		if err != nil {
+			return errorResponse(err)
+		}
+		return runner.Response{Command: command}
+	case runner.OpPIDs:
+		pids, err := s.windowPIDs(req.Session)
+		if err != nil {
+			return errorResponse(err)
+		}
+		return runner.Response{PIDs: pids}
+	case runner.OpKillWindow:
+		w, err := s.window(req.Session, req.Window)
+		if err != nil {
+			return errorResponse(err)
+		}
+		terminate(w.cmd, w.done)
+		return runner.Response{}
+	case runner.OpStop:
+		return errorResponse(s.stopSession(req.Session))
+	case runner.OpHas:
+		s.mu.Lock()
+		_, ok := s.sessions[req.Session]
+		s.mu.Unlock()
+		return runner.Response{Exists: ok}
+	case runner.OpList:
+		return runner.Response{Sessions: s.sessionNames()}
+	case runner.OpShutdown:
+		return runner.Response{}
+	default:
+		return runner.Response{Error: fmt.Sprintf("unknown operation: %s", req.Op)}
+	}
+}
+
+func (s *Server) sessionNames() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.sessions))
+	for name := range s.sessions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// windowPIDs returns the shell process ID of every window in a session
+func (s *Server) windowPIDs(sessionName string) (map[string][]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[sessionName]
+	if !ok {
+		return nil, fmt.Errorf("can't find session: %s", sessionName)
+	}
+	pids := make(map[string][]int, len(sess.windows))
+	for name, w := range sess.windows {
+		pids[name] = []int{w.cmd.Process.Pid}
+	}
+	return pids, nil
+}
+
+func (s *Server) window(sessionName, windowName string) (*window, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[sessionName]
+	if !ok {
+		return nil, fmt.Errorf("can't find session: %s", sessionName)
+	}
+	w, ok := sess.windows[windowName]
+	if !ok {
+		return nil, fmt.Errorf("can't find window: %s:%s", sessionName, windowName)
+	}
+	return w, nil
+}
+
+func (s *Server) startSession(sessionName, windowName, dir string) error {
+	s.mu.Lock()
+	if _, exists := s.sessions[sessionName]; exists {
+		s.mu.Unlock()
+		return fmt.Errorf("duplicate session: %s", sessionName)
+	}
+	s.sessions[sessionName] = &session{name: sessionName, windows: make(map[string]*window)}
+	s.mu.Unlock()
+
+	if err := s.newWindow(sessionName, windowName, dir); err != nil {
+		s.mu.Lock()
+		delete(s.sessions, sessionName)
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (s *Server) newWindow(sessionName, windowName, dir string) error {
+	s.mu.Lock()
+	sess, ok := s.sessions[sessionName]
+	if !ok || sess.stopping {
+		s.mu.Unlock()
+		return fmt.Errorf("can't find session: %s", sessionName)
+	}
+	if _, exists := sess.windows[windowName]; exists {
+		s.mu.Unlock()
+		return fmt.Errorf("duplicate window: %s:%s", sessionName, windowName)
+	}
+	s.mu.Unlock()
+
+	w, err := s.spawn(sessionName, windowName, dir)
+	if err != nil {
+		return err
+	}
+
+	// The shell started without the lock held: another request may have
+	// taken the name or killed the session in the meantime
+	s.mu.Lock()
+	if s.sessions[sessionName] != sess || sess.stopping {
+		s.mu.Unlock()
+		discard(w)
+		return fmt.Errorf("can't find session: %s", sessionName)
+	}
+	if _, exists := sess.windows[windowName]; exists {
+		s.mu.Unlock()
+		discard(w)
+		return fmt.Errorf("duplicate window: %s:%s", sessionName, windowName)
+	}
+	sess.windows[windowName] = w
+	s.mu.Unlock()
+	runner.NotifyEvent()
+
+	go w.pump()
+	go func() {
+		w.cmd.Wait()
+		close(w.done)
+		w.master.Close()
+		w.close()
+		s.removeWindow(sessionName, windowName)
+	}()
+	return nil
+}
+
+// spawn starts a shell on a new pseudo-terminal
+func (s *Server) spawn(sessionName, windowName, dir string) (*window, error) {
+	master, slave, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+	defer slave.Close()
+
+	if err := setSize(master, DefaultWidth, DefaultHeight); err != nil {
+		master.Close()
+		return nil, err
+	}
+
+	cmd := exec.Command(s.shell)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"TERM=xterm-256color",
+		"UZI_SESSION="+sessionName,
+		"UZI_WINDOW="+windowName,
+	)
+	cmd.Stdin = slave
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+	setControllingTerminal(cmd)
+
+	if err := cmd.Start(); err != nil {
+		master.Close()
+		return nil, fmt.Errorf("failed to start shell: %w", err)
+	}
+
+	screen := vt.New(DefaultWidth, DefaultHeight)
+	screen.SetReply(master)
+
+	log.Debug("Started window", "session", sessionName, "window", windowName, "pid", cmd.Process.Pid)
+	return &window{
+		name:   windowName,
+		cmd:    cmd,
+		master: master,
+		screen: screen,
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// discard stops the shell of a window that was never added to its session
+func discard(w *window) {
+	go func() {
+		w.cmd.Wait()
+		close(w.done)
+	}()
+	terminate(w.cmd, w.done)
+	<-w.done
+	w.master.Close()
+}
+
+// removeWindow forgets an exited window. Sessions end with their last
+// window, and the server stops with its last session.
+func (s *Server) removeWindow(sessionName, windowName string) {
+	s.mu.Lock()
+	sess, ok := s.sessions[sessionName]
+	if ok {
+		delete(sess.windows, windowName)
+		if len(sess.windows) == 0 {
+			delete(s.sessions, sessionName)
+		}
+	}
+	empty := len(s.sessions) == 0
+	s.mu.Unlock()
+	runner.NotifyEvent()
+
+	if empty {
+		select {
+		case <-s.stopped:
+		default:
+			go s.stop()
+		}
+	}
+}
+
+func (s *Server) stopSession(sessionName string) error {
+	s.mu.Lock()
+	sess, ok := s.sessions[sessionName]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("can't find session: %s", sessionName)
+	}
+	sess.stopping = true
+	var windows []*window
+	for _, w := range sess.windows {
+		windows = append(windows, w)
+	}
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, w := range windows {
+		wg.Add(1)
+		go func(w *window) {
+			defer wg.Done()
+			terminate(w.cmd, w.done)
+			<-w.done
+		}(w)
+	}
+	wg.Wait()
+	return nil
+}
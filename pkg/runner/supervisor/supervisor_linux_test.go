@@ -0,0 +1,255 @@
+//go:build linux
+
+package supervisor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devflowinc/uzi/pkg/runner"
+)
+
+func startServer(t *testing.T) (runner.Runner, context.CancelFunc, chan error) {
+	t.Helper()
+	t.Setenv("SHELL", "/bin/sh")
+	t.Setenv("HOME", t.TempDir())
+
+	socket := filepath.Join(t.TempDir(), "pty.sock")
+	server := New(socket)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(ctx) }()
+
+	r := runner.NewPTYRunnerWithSocket(socket)
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(socket); err == nil {
+			return r, cancel, done
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	t.Fatal("server did not start listening")
+	return nil, nil, nil
+}
+
+func waitForScreen(t *testing.T, r runner.Runner, session, window, want string) string {
+	t.Helper()
+	var screen string
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var err error
+		screen, err = r.ReadScreen(context.Background(), session, window)
+		if err == nil && strings.Contains(screen, want) {
+			return screen
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("screen never contained %q:\n%s", want, screen)
+	return ""
+}
+
+func TestSupervisorRunsShell(t *testing.T) {
+	r, cancel, done := startServer(t)
+	defer cancel()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	if err := r.Start(ctx, "agent-test", dir); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if !r.HasSession("agent-test") {
+		t.Fatal("HasSession() = false after Start")
+	}
+	if events, _ := runner.EventsPath(); !fileExists(events) {
+		t.Error("starting a session did not touch the events file")
+	}
+
+	transcriptPath := filepath.Join(dir, "logs", "agent.log")
+	if err := r.Transcribe(ctx, "agent-test", "agent", transcriptPath); err != nil {
+		t.Fatalf("Transcribe() error = %v", err)
+	}
+
+	if err := r.SendKeys(ctx, "agent-test", "agent", "echo hello-$((40+2))", "Enter"); err != nil {
+		t.Fatalf("SendKeys() error = %v", err)
+	}
+	waitForScreen(t, r, "agent-test", "agent", "hello-42")
+	if command, err := r.CurrentCommand(ctx, "agent-test", "agent"); err != nil || command != "sh" {
+		t.Errorf("CurrentCommand() = %q, %v; want the shell", command, err)
+	}
+	r.SendKeys(ctx, "agent-test", "agent", "sleep 30", "Enter")
+	deadline := time.Now().Add(5 * time.Second)
+	for command, _ := r.CurrentCommand(ctx, "agent-test", "agent"); command != "sleep"; command, _ = r.CurrentCommand(ctx, "agent-test", "agent") {
+		if time.Now().After(deadline) {
+			t.Fatalf("CurrentCommand() = %q, want sleep", command)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	r.SendKeys(ctx, "agent-test", "agent", "C-c")
+
+	data, err := os.ReadFile(transcriptPath)
+	if err != nil || !strings.Contains(string(data), "hello-42") {
+		t.Errorf("transcript = %q, %v; want it to contain output", data, err)
+	}
+
+	if err := r.NewWindow(ctx, "agent-test", "run", dir); err != nil {
+		t.Fatalf("NewWindow() error = %v", err)
+	}
+	r.SendKeys(ctx, "agent-test", "run", "pwd", "Enter")
+	waitForScreen(t, r, "agent-test", "run", dir)
+	pids, err := r.WindowPIDs(ctx, "agent-test")
+	if err != nil || len(pids["agent"]) != 1 || len(pids["run"]) != 1 || pids["agent"][0] == pids["run"][0] {
+		t.Errorf("WindowPIDs() = %v, %v", pids, err)
+	}
+	if err := r.KillWindow(ctx, "agent-test", "run"); err != nil {
+		t.Fatalf("KillWindow() error = %v", err)
+	}
+
+	sessions, err := r.ListSessions()
+	if err != nil || len(sessions) != 1 || sessions[0] != "agent-test" {
+		t.Errorf("ListSessions() = %v, %v", sessions, err)
+	}
+
+	// Stopping the last session shuts the supervisor down
+	if err := r.Stop(ctx, "agent-test"); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Serve() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor did not exit after its last session ended")
+	}
+
+	if r.HasSession("agent-test") {
+		t.Error("HasSession() = true after Stop")
+	}
+	if sessions, err := r.ListSessions(); err != nil || len(sessions) != 0 {
+		t.Errorf("ListSessions() after shutdown = %v, %v", sessions, err)
+	}
+}
+
+func TestSupervisorShutdown(t *testing.T) {
+	r, cancel, done := startServer(t)
+	defer cancel()
+	ctx := context.Background()
+
+	if err := r.Start(ctx, "agent-a", t.TempDir()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := r.Start(ctx, "agent-a", t.TempDir()); err == nil {
+		t.Error("Start() of a duplicate session succeeded")
+	}
+	if err := r.SendKeys(ctx, "agent-missing", "agent", "x"); err == nil {
+		t.Error("SendKeys() to a missing session succeeded")
+	}
+
+	if err := r.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor did not exit after shutdown")
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// children returns the live child processes of the test
+func children(t *testing.T) map[int]bool {
+	t.Helper()
+	stats, err := filepath.Glob("/proc/[0-9]*/stat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pids := make(map[int]bool)
+	for _, path := range stats {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		// "pid (comm) state ppid ...", where comm may contain spaces
+		fields := strings.Fields(string(data[strings.LastIndexByte(string(data), ')')+1:]))
+		if len(fields) < 2 || fields[0] == "Z" || fields[1] != strconv.Itoa(os.Getpid()) {
+			continue
+		}
+		pid, _ := strconv.Atoi(filepath.Base(filepath.Dir(path)))
+		pids[pid] = true
+	}
+	return pids
+}
+
+func TestSupervisorConcurrentNewWindow(t *testing.T) {
+	t.Setenv("SHELL", "/bin/sh")
+	t.Setenv("HOME", t.TempDir())
+	server := New(filepath.Join(t.TempDir(), "pty.sock"))
+	before := children(t)
+
+	dir := t.TempDir()
+	if err := server.startSession("agent-x", "agent", dir); err != nil {
+		t.Fatal(err)
+	}
+	defer server.stopSession("agent-x")
+
+	const requests = 8
+	errs := make(chan error, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- server.newWindow("agent-x", "uzi-dev", dir)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !strings.Contains(err.Error(), "duplicate window"):
+			t.Errorf("newWindow() error = %v", err)
+		}
+	}
+	if created != 1 {
+		t.Fatalf("%d concurrent new-window requests succeeded, want 1", created)
+	}
+
+	pids, err := server.windowPIDs("agent-x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := make(map[int]bool)
+	for _, windowPIDs := range pids {
+		want[windowPIDs[0]] = true
+	}
+	if len(want) != 2 {
+		t.Fatalf("session has windows %v, want agent and uzi-dev", pids)
+	}
+	// The shells of the requests that lost are gone
+	for pid := range children(t) {
+		if !before[pid] && !want[pid] {
+			t.Errorf("shell %d of a discarded window is still running", pid)
+		}
+	}
+
+	// A session being killed takes no new windows
+	server.stopSession("agent-x")
+	if err := server.newWindow("agent-x", "late", dir); err == nil {
+		t.Error("newWindow() added a window to a stopped session")
+	}
+}
@@ -0,0 +1,114 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/devflowinc/uzi/pkg/tmux"
+
+	"github.com/charmbracelet/log"
+)
+
+// tmuxRunner runs sessions on the dedicated uzi tmux server
+type tmuxRunner struct{}
+
+// NewTmuxRunner returns a Runner backed by tmux
+func NewTmuxRunner() Runner {
+	return &tmuxRunner{}
+}
+
+func (r *tmuxRunner) Name() string {
+	return RunnerTmux
+}
+
+func target(session, window string) string {
+	return session + ":" + window
+}
+
+func runTmux(ctx context.Context, args ...string) error {
+	output, err := tmux.CommandContext(ctx, args...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("tmux %s: %s", args[0], msg)
+		}
+		return fmt.Errorf("tmux %s: %w", args[0], err)
+	}
+	return nil
+}
+
+func (r *tmuxRunner) Start(ctx context.Context, session, dir string) error {
+	if err := runTmux(ctx, "new-session", "-d", "-s", session, "-n", "agent", "-c", dir); err != nil {
+		return err
+	}
+
+	// Let watchers hear about sessions and windows coming and going. The
+	// server may be new, so the hooks are set on every start.
+	if events, err := EventsPath(); err == nil {
+		NotifyEvent()
+		if err := tmux.SetEventHooks(ctx, "touch "+tmux.ShellQuote(events)); err != nil {
+			log.Debug("Could not set tmux event hooks", "error", err)
+		}
+	}
+	return nil
+}
+
+func (r *tmuxRunner) NewWindow(ctx context.Context, session, window, dir string) error {
+	return runTmux(ctx, "new-window", "-t", session, "-n", window, "-c", dir)
+}
+
+func (r *tmuxRunner) SendKeys(ctx context.Context, session, window string, keys ...string) error {
+	args := append([]string{"send-keys", "-t", target(session, window)}, keys...)
+	return runTmux(ctx, args...)
+}
+
+func (r *tmuxRunner) ReadScreen(ctx context.Context, session, window string) (string, error) {
+	output, err := tmux.CommandContext(ctx, "capture-pane", "-p", "-t", target(session, window)).Output()
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+func (r *tmuxRunner) Transcribe(ctx context.Context, session, window, path string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("could not resolve uzi executable: %w", err)
+	}
+
+	pipeCmd := fmt.Sprintf("exec %s logs -pipe %s", tmux.ShellQuote(executable), tmux.ShellQuote(path))
+	return runTmux(ctx, "pipe-pane", "-o", "-t", target(session, window), pipeCmd)
+}
+
+func (r *tmuxRunner) CurrentCommand(ctx context.Context, session, window string) (string, error) {
+	output, err := tmux.CommandContext(ctx, "display-message", "-p", "-t", target(session, window), "#{pane_current_command}").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (r *tmuxRunner) WindowPIDs(ctx context.Context, session string) (map[string][]int, error) {
+	return tmux.PanePIDs(ctx, session)
+}
+
+func (r *tmuxRunner) KillWindow(ctx context.Context, session, window string) error {
+	return runTmux(ctx, "kill-window", "-t", target(session, window))
+}
+
+func (r *tmuxRunner) Stop(ctx context.Context, session string) error {
+	return runTmux(ctx, "kill-session", "-t", session)
+}
+
+func (r *tmuxRunner) HasSession(session string) bool {
+	return tmux.HasSession(session)
+}
+
+func (r *tmuxRunner) ListSessions() ([]string, error) {
+	return tmux.ListSessions()
+}
+
+func (r *tmuxRunner) Shutdown(ctx context.Context) error {
+	return tmux.KillServer(ctx)
+}
@@ -0,0 +1,504 @@
+package vt
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// wideTail marks the second cell of a double-width character
+const wideTail rune = -1
+
+type parserState int
+
+const (
+	stateGround parserState = iota
+	stateEscape
+	stateEscapeIntermediate
+	stateCSI
+	stateString
+	stateStringEscape
+)
+
+// Screen is a minimal VT100/xterm emulator that keeps the visible character
+// grid of a terminal, the way "tmux capture-pane" sees it. It understands the
+// cursor movement, erase, scroll and alternate screen sequences used by
+// interactive CLIs and ignores colors and other attributes.
+type Screen struct {
+	mu            sync.Mutex
+	width, height int
+	primary       [][]rune
+	alternate     [][]rune
+	cells         [][]rune
+	altActive     bool
+	x, y          int
+	wrapPending   bool
+	savedX        int
+	savedY        int
+	top, bottom   int
+	state         parserState
+	params        []byte
+	pending       []byte
+	reply         io.Writer
+}
+
+// New returns a blank screen of the given size
+func New(width, height int) *Screen {
+	s := &Screen{width: width, height: height}
+	s.primary = blankGrid(width, height)
+	s.alternate = blankGrid(width, height)
+	s.cells = s.primary
+	s.bottom = height - 1
+	return s
+}
+
+// SetReply sets where answers to terminal queries (cursor position, device
+// attributes) are written, normally the PTY master
+func (s *Screen) SetReply(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reply = w
+}
+
+func blankGrid(width, height int) [][]rune {
+	grid := make([][]rune, height)
+	for i := range grid {
+		grid[i] = make([]rune, width)
+	}
+	return grid
+}
+
+// Size returns the screen width and height
+func (s *Screen) Size() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.width, s.height
+}
+
+// Resize changes the screen size, keeping the top-left content
+func (s *Screen) Resize(width, height int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resize := func(old [][]rune) [][]rune {
+		grid := blankGrid(width, height)
+		for y := 0; y < height && y < len(old); y++ {
+			copy(grid[y], old[y])
+		}
+		return grid
+	}
+	s.primary = resize(s.primary)
+	s.alternate = resize(s.alternate)
+	if s.altActive {
+		s.cells = s.alternate
+	} else {
+		s.cells = s.primary
+	}
+	s.width, s.height = width, height
+	s.top, s.bottom = 0, height-1
+	s.x = min(s.x, width-1)
+	s.y = min(s.y, height-1)
+	s.wrapPending = false
+}
+
+// String returns the visible contents with one line per row and trailing
+// spaces removed
+func (s *Screen) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var b strings.Builder
+	for _, row := range s.cells {
+		var line strings.Builder
+		for _, r := range row {
+			switch r {
+			case wideTail:
+			case 0:
+				line.WriteByte(' ')
+			default:
+				line.WriteRune(r)
+			}
+		}
+		b.WriteString(strings.TrimRight(line.String(), " "))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Cursor returns the zero-based cursor column and row
+func (s *Screen) Cursor() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.x, s.y
+}
+
+// Write feeds terminal output into the emulator
+func (s *Screen) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, b := range p {
+		s.feed(b)
+	}
+	return len(p), nil
+}
+
+func (s *Screen) feed(b byte) {
+	switch s.state {
+	case stateGround:
+		s.ground(b)
+	case stateEscape:
+		s.escape(b)
+	case stateEscapeIntermediate:
+		s.state = stateGround
+	case stateCSI:
+		if b >= 0x40 && b <= 0x7e {
+			s.csi(b)
+			s.state = stateGround
+		} else if b == 0x1b {
+			s.state = stateEscape
+		} else {
+			s.params = append(s.params, b)
+		}
+	case stateString:
+		switch b {
+		case 0x07:
+			s.state = stateGround
+		case 0x1b:
+			s.state = stateStringEscape
+		}
+	case stateStringEscape:
+		if b == '\\' {
+			s.state = stateGround
+		} else {
+			s.state = stateString
+		}
+	}
+}
+
+func (s *Screen) ground(b byte) {
+	if len(s.pending) > 0 || b >= 0x80 {
+		s.pending = append(s.pending, b)
+		if utf8.FullRune(s.pending) {
+			r, _ := utf8.DecodeRune(s.pending)
+			s.pending = s.pending[:0]
+			s.put(r)
+		} else if len(s.pending) >= utf8.UTFMax {
+			s.pending = s.pending[:0]
+		}
+		return
+	}
+
+	switch b {
+	case 0x1b:
+		s.state = stateEscape
+	case '\r':
+		s.x = 0
+		s.wrapPending = false
+	case '\n', '\v', '\f':
+		s.lineFeed()
+	case '\b':
+		if s.x > 0 {
+			s.x--
+		}
+		s.wrapPending = false
+	case '\t':
+		s.x = min((s.x/8+1)*8, s.width-1)
+	default:
+		if b >= 0x20 && b != 0x7f {
+			s.put(rune(b))
+		}
+	}
+}
+
+func (s *Screen) escape(b byte) {
+	s.state = stateGround
+	switch b {
+	case '[':
+		s.params = s.params[:0]
+		s.state = stateCSI
+	case ']', 'P', '_', '^', 'X':
+		s.state = stateString
+	case '(', ')', '*', '+', '#', '%':
+		s.state = stateEscapeIntermediate
+	case '7':
+		s.saveCursor()
+	case '8':
+		s.restoreCursor()
+	case 'D':
+		s.lineFeed()
+	case 'E':
+		s.x = 0
+		s.lineFeed()
+	case 'M':
+		s.reverseIndex()
+	case 'c':
+		s.reset()
+	}
+}
+
+func (s *Screen) put(r rune) {
+	w := runewidth.RuneWidth(r)
+	if w == 0 {
+		return
+	}
+
+	if s.wrapPending {
+		s.x = 0
+		s.lineFeed()
+		s.wrapPending = false
+	}
+	if w == 2 && s.x == s.width-1 {
+		s.cells[s.y][s.x] = 0
+		s.x = 0
+		s.lineFeed()
+	}
+
+	s.cells[s.y][s.x] = r
+	if w == 2 && s.x+1 < s.width {
+		s.cells[s.y][s.x+1] = wideTail
+	}
+
+	s.x += w
+	if s.x >= s.width {
+		s.x = s.width - 1
+		s.wrapPending = true
+	}
+}
+
+func (s *Screen) lineFeed() {
+	s.wrapPending = false
+	if s.y == s.bottom {
+		s.scrollUp(1)
+	} else if s.y < s.height-1 {
+		s.y++
+	}
+}
+
+func (s *Screen) reverseIndex() {
+	s.wrapPending = false
+	if s.y == s.top {
+		s.scrollDown(1)
+	} else if s.y > 0 {
+		s.y--
+	}
+}
+
+// scrollUp moves the lines of the scroll region up by n, blanking the bottom
+func (s *Screen) scrollUp(n int) {
+	region := s.cells[s.top : s.bottom+1]
+	n = min(n, len(region))
+	copy(region, region[n:])
+	for i := len(region) - n; i < len(region); i++ {
+		region[i] = make([]rune, s.width)
+	}
+}
+
+// scrollDown moves the lines of the scroll region down by n, blanking the top
+func (s *Screen) scrollDown(n int) {
+	region := s.cells[s.top : s.bottom+1]
+	n = min(n, len(region))
+	copy(region[n:], region)
+	for i := 0; i < n; i++ {
+		region[i] = make([]rune, s.width)
+	}
+}
+
+func (s *Screen) saveCursor() {
+	s.savedX, s.savedY = s.x, s.y
+}
+
+func (s *Screen) restoreCursor() {
+	s.x, s.y = s.savedX, s.savedY
+	s.wrapPending = false
+}
+
+func (s *Screen) reset() {
+	s.primary = blankGrid(s.width, s.height)
+	s.alternate = blankGrid(s.width, s.height)
+	s.cells = s.primary
+	s.altActive = false
+	s.x, s.y = 0, 0
+	s.top, s.bottom = 0, s.height-1
+	s.wrapPending = false
+}
+
+func (s *Screen) moveTo(x, y int) {
+	s.x = max(0, min(x, s.width-1))
+	s.y = max(0, min(y, s.height-1))
+	s.wrapPending = false
+}
+
+func (s *Screen) clearCells(y, from, to int) {
+	row := s.cells[y]
+	for x := max(from, 0); x < to && x < s.width; x++ {
+		row[x] = 0
+	}
+}
+
+func (s *Screen) setAlternate(on bool) {
+	if on == s.altActive {
+		return
+	}
+	s.altActive = on
+	if on {
+		s.saveCursor()
+		s.alternate = blankGrid(s.width, s.height)
+		s.cells = s.alternate
+	} else {
+		s.cells = s.primary
+		s.restoreCursor()
+	}
+}
+
+func (s *Screen) respond(format string, args ...any) {
+	if s.reply != nil {
+		fmt.Fprintf(s.reply, format, args...)
+	}
+}
+
+// csiParams splits the collected parameter bytes into a private marker and
+// numeric arguments
+func csiParams(raw []byte) (byte, []int) {
+	var private byte
+	if len(raw) > 0 && (raw[0] == '?' || raw[0] == '>' || raw[0] == '<' || raw[0] == '=') {
+		private = raw[0]
+		raw = raw[1:]
+	}
+
+	var params []int
+	for _, part := range strings.Split(string(raw), ";") {
+		part = strings.SplitN(part, ":", 2)[0]
+		n, _ := strconv.Atoi(strings.TrimSpace(part))
+		params = append(params, n)
+	}
+	return private, params
+}
+
+func param(params []int, i, def int) int {
+	if i < len(params) && params[i] > 0 {
+		return params[i]
+	}
+	return def
+}
+
+func (s *Screen) csi(final byte) {
+	private, params := csiParams(s.params)
+	n := param(params, 0, 1)
+
+	switch final {
+	case 'A':
+		s.moveTo(s.x, s.y-n)
+	case 'B':
+		s.moveTo(s.x, s.y+n)
+	case 'C':
+		s.moveTo(s.x+n, s.y)
+	case 'D':
+		s.moveTo(s.x-n, s.y)
+	case 'E':
+		s.moveTo(0, s.y+n)
+	case 'F':
+		s.moveTo(0, s.y-n)
+	case 'G', '`':
+		s.moveTo(n-1, s.y)
+	case 'd':
+		s.moveTo(s.x, n-1)
+	case 'H', 'f':
+		s.moveTo(param(params, 1, 1)-1, param(params, 0, 1)-1)
+	case 'J':
+		switch param(params, 0, 0) {
+		case 0:
+			s.clearCells(s.y, s.x, s.width)
+			for y := s.y + 1; y < s.height; y++ {
+				s.clearCells(y, 0, s.width)
+			}
+		case 1:
+			for y := 0; y < s.y; y++ {
+				s.clearCells(y, 0, s.width)
+			}
+			s.clearCells(s.y, 0, s.x+1)
+		case 2, 3:
+			for y := 0; y < s.height; y++ {
+				s.clearCells(y, 0, s.width)
+			}
+		}
+	case 'K':
+		switch param(params, 0, 0) {
+		case 0:
+			s.clearCells(s.y, s.x, s.width)
+		case 1:
+			s.clearCells(s.y, 0, s.x+1)
+		case 2:
+			s.clearCells(s.y, 0, s.width)
+		}
+	case 'L':
+		if s.y >= s.top && s.y <= s.bottom {
+			top := s.top
+			s.top = s.y
+			s.scrollDown(n)
+			s.top = top
+		}
+	case 'M':
+		if s.y >= s.top && s.y <= s.bottom {
+			top := s.top
+			s.top = s.y
+			s.scrollUp(n)
+			s.top = top
+		}
+	case 'P':
+		row := s.cells[s.y]
+		n = min(n, s.width-s.x)
+		copy(row[s.x:], row[s.x+n:])
+		s.clearCells(s.y, s.width-n, s.width)
+	case '@':
+		row := s.cells[s.y]
+		n = min(n, s.width-s.x)
+		copy(row[s.x+n:], row[s.x:])
+		s.clearCells(s.y, s.x, s.x+n)
+	case 'X':
+		s.clearCells(s.y, s.x, s.x+n)
+	case 'S':
+		s.scrollUp(n)
+	case 'T':
+		s.scrollDown(n)
+	case 'r':
+		top := param(params, 0, 1) - 1
+		bottom := param(params, 1, s.height) - 1
+		if top < bottom && bottom < s.height {
+			s.top, s.bottom = top, bottom
+		}
+		s.moveTo(0, 0)
+	case 's':
+		s.saveCursor()
+	case 'u':
+		s.restoreCursor()
+	case 'h', 'l':
+		if private == '?' {
+			for _, mode := range params {
+				switch mode {
+				case 47, 1047, 1049:
+					s.setAlternate(final == 'h')
+				}
+			}
+		}
+	case 'n':
+		switch param(params, 0, 0) {
+		case 5:
+			s.respond("\x1b[0n")
+		case 6:
+			s.respond("\x1b[%d;%dR", s.y+1, s.x+1)
+		}
+	case 'c':
+		switch private {
+		case 0:
+			s.respond("\x1b[?1;2c")
+		case '>':
+			s.respond("\x1b[>0;0;0c")
+		}
+	}
+}
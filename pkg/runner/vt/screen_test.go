@@ -0,0 +1,140 @@
+package vt
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func lines(s *Screen) []string {
+	return strings.Split(strings.TrimSuffix(s.String(), "\n"), "\n")
+}
+
+func TestScreenPlainText(t *testing.T) {
+	s := New(20, 3)
+	s.Write([]byte("hello\r\nworld"))
+
+	got := lines(s)
+	want := []string{"hello", "world", ""}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestScreenScrollsAtBottom(t *testing.T) {
+	s := New(10, 2)
+	s.Write([]byte("one\r\ntwo\r\nthree"))
+
+	got := lines(s)
+	if got[0] != "two" || got[1] != "three" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestScreenWrapsLongLines(t *testing.T) {
+	s := New(4, 3)
+	s.Write([]byte("abcdefg"))
+
+	got := lines(s)
+	if got[0] != "abcd" || got[1] != "efg" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestScreenCursorMovementAndErase(t *testing.T) {
+	s := New(40, 3)
+	// Draw a spinner line, then redraw it in place the way Ink-based CLIs do
+	s.Write([]byte("Thinking... esc to interrupt\r\n> "))
+	s.Write([]byte("\x1b[1A\x1b[2K\rDone"))
+
+	got := lines(s)
+	if got[0] != "Done" {
+		t.Errorf("line 0 = %q, want %q", got[0], "Done")
+	}
+	if got[1] != ">" {
+		t.Errorf("line 1 = %q, want %q", got[1], ">")
+	}
+}
+
+func TestScreenAbsolutePositioningAndClear(t *testing.T) {
+	s := New(10, 3)
+	s.Write([]byte("xxxxxxxxxx\r\nyyyyyyyyyy"))
+	s.Write([]byte("\x1b[2J\x1b[2;3HZ"))
+
+	got := lines(s)
+	if got[0] != "" || got[1] != "  Z" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestScreenIgnoresColorsAndTitles(t *testing.T) {
+	s := New(20, 1)
+	s.Write([]byte("\x1b]0;title\x07\x1b[1;32mgreen\x1b[0m"))
+
+	if got := lines(s)[0]; got != "green" {
+		t.Errorf("got %q, want %q", got, "green")
+	}
+}
+
+func TestScreenAlternateBuffer(t *testing.T) {
+	s := New(10, 2)
+	s.Write([]byte("shell"))
+	s.Write([]byte("\x1b[?1049h\x1b[Hfull"))
+	if got := lines(s)[0]; got != "full" {
+		t.Errorf("alternate screen = %q, want %q", got, "full")
+	}
+
+	s.Write([]byte("\x1b[?1049l"))
+	if got := lines(s)[0]; got != "shell" {
+		t.Errorf("primary screen = %q, want %q", got, "shell")
+	}
+}
+
+func TestScreenWideCharacters(t *testing.T) {
+	s := New(6, 2)
+	s.Write([]byte("日本語x"))
+
+	got := lines(s)
+	if got[0] != "日本語" || got[1] != "x" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestScreenUTF8AcrossWrites(t *testing.T) {
+	s := New(10, 1)
+	data := []byte("✓ ok")
+	s.Write(data[:2])
+	s.Write(data[2:])
+
+	if got := lines(s)[0]; got != "✓ ok" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestScreenCursorPositionReport(t *testing.T) {
+	var reply bytes.Buffer
+	s := New(10, 5)
+	s.SetReply(&reply)
+	s.Write([]byte("\x1b[3;4H\x1b[6n"))
+
+	if got := reply.String(); got != "\x1b[3;4R" {
+		t.Errorf("reply = %q, want %q", got, "\x1b[3;4R")
+	}
+}
+
+func TestScreenInsertAndDeleteLines(t *testing.T) {
+	s := New(5, 3)
+	s.Write([]byte("a\r\nb\r\nc"))
+	s.Write([]byte("\x1b[2;1H\x1b[1M"))
+
+	got := lines(s)
+	if strings.Join(got, "|") != "a|c|" {
+		t.Errorf("after delete line got %q", got)
+	}
+
+	s.Write([]byte("\x1b[1;1H\x1b[1L"))
+	got = lines(s)
+	if strings.Join(got, "|") != "|a|c" {
+		t.Errorf("after insert line got %q", got)
+	}
+}
@@ -2,6 +2,7 @@ package state
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -9,6 +10,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/devflowinc/uzi/pkg/runner"
+
 	"github.com/charmbracelet/log"
 )
 
@@ -26,6 +29,77 @@ type AgentState struct {
 	WorkCount    int        `json:"work_count"`              // 作業回数カウント
 	LastWorkedAt *time.Time `json:"last_worked_at,omitempty"` // 最後に作業した時刻
 	LastMergedAt *time.Time `json:"last_merged_at,omitempty"` // 最後にマージした時刻
+	Labels       []string   `json:"labels,omitempty"`         // ユーザー定義のラベル
+	// StatusHistory - 記録済みのステータスと遷移の履歴（uzi ls などが観測したときに更新）
+	StatusHistory *StatusHistory `json:"status_history,omitempty"`
+	// Report - エージェントがマーカーファイルに書いた完了報告（uzi ls などが読んだときに更新）
+	Report *CompletionReport `json:"report,omitempty"`
+	// LastError - 最後に観測したエラー（uzi ls などが error を観測したときに更新）
+	LastError *AgentError `json:"last_error,omitempty"`
+	// Restarts - uzi auto がエージェントを再起動した記録（古い順）
+	Restarts []AgentRestart `json:"restarts,omitempty"`
+	// Nudges - uzi auto が idle のエージェントに送った催促の記録（古い順）
+	Nudges []AgentNudge `json:"nudges,omitempty"`
+}
+
+// AgentNudge - idle のエージェントへの催促、または上限に達したあとのエスカレーション
+type AgentNudge struct {
+	At        time.Time `json:"at"`
+	Message   string    `json:"message"`             // 送ったメッセージ（エスカレーションでは通知の内容）
+	Escalated bool      `json:"escalated,omitempty"` // 催促せずにエラー通知を送った
+}
+
+// AgentRestart - 終了したエージェントの再起動
+type AgentRestart struct {
+	At     time.Time `json:"at"`
+	Reason string    `json:"reason"` // 再起動した理由（例: "exited without a completion report"）
+	Prompt string    `json:"prompt"` // 再起動したエージェントに渡したプロンプト
+}
+
+// AgentError - エージェントの画面で見つけたエラー
+type AgentError struct {
+	Category string    `json:"category"` // crash, api または unknown
+	Excerpt  string    `json:"excerpt"`  // エラーの行とそれに続く数行
+	At       time.Time `json:"at"`       // 最初に観測した時刻
+}
+
+// CompletionReport - .uzi-task-completed に書かれた完了報告
+type CompletionReport struct {
+	Summary       string      `json:"summary"`
+	FilesTouched  []string    `json:"files_touched,omitempty"`
+	Tests         *TestReport `json:"tests,omitempty"`
+	OpenQuestions []string    `json:"open_questions,omitempty"`
+	FollowUps     []string    `json:"follow_ups,omitempty"`
+	// CompletedAt - マーカーファイルの更新時刻
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// TestReport - 完了報告のテスト結果
+type TestReport struct {
+	Command string `json:"command"`
+	Result  string `json:"result"` // passed, failed または skipped
+	Details string `json:"details,omitempty"`
+}
+
+// StatusHistory - エージェントのステータスの記録
+type StatusHistory struct {
+	Current string    `json:"current"` // 最後に記録したステータス
+	Since   time.Time `json:"since"`   // Current になった時刻
+	// Seconds - 終わった区間のステータスごとの累計秒数（現在の区間は含まない）
+	Seconds map[string]float64 `json:"seconds,omitempty"`
+	// Flips - running と idle の間を直接行き来した回数
+	Flips int `json:"flips"`
+	// Transitions - 直近の遷移（古いものから捨てる）
+	Transitions []StatusTransition `json:"transitions,omitempty"`
+}
+
+// StatusTransition - ステータスの遷移1回分
+type StatusTransition struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+	// Inferred - 観測と観測の間に起きたはずの遷移を補ったもの
+	Inferred bool `json:"inferred,omitempty"`
 }
 
 type StateManager struct {
@@ -48,6 +122,12 @@ func (sm *StateManager) ensureStateDir() error {
 	return os.MkdirAll(dir, 0755)
 }
 
+// CurrentRepo returns the remote URL of the repository in the current
+// directory, which agents record as GitRepo; empty when there is no remote
+func (sm *StateManager) CurrentRepo() string {
+	return sm.getGitRepo()
+}
+
 func (sm *StateManager) getGitRepo() string {
 	cmd := exec.Command("git", "config", "--get", "remote.origin.url")
 	output, err := cmd.Output()
@@ -76,8 +156,7 @@ func (sm *StateManager) getBranchFrom() string {
 }
 
 func (sm *StateManager) isActiveInTmux(sessionName string) bool {
-	cmd := exec.Command("tmux", "has-session", "-t", sessionName)
-	return cmd.Run() == nil
+	return runner.Default().HasSession(sessionName)
 }
 
 func (sm *StateManager) GetActiveSessionsForRepo() ([]string, error) {
@@ -114,57 +193,55 @@ func (sm *StateManager) SaveState(prompt, branchName, sessionName, worktreePath,
 }
 
 func (sm *StateManager) SaveStateWithPort(prompt, branchName, sessionName, worktreePath, model string, port int) error {
-	if err := sm.ensureStateDir(); err != nil {
-		return err
-	}
+	// git はロックの外で呼ぶ
+	gitRepo, branchFrom := sm.getGitRepo(), sm.getBranchFrom()
+
+	err := sm.modify(true, func(states map[string]AgentState) error {
+		// Create new state entry
+		now := time.Now()
+		agentState := AgentState{
+			GitRepo:      gitRepo,
+			BranchFrom:   branchFrom,
+			BranchName:   branchName,
+			Prompt:       prompt,
+			WorktreePath: worktreePath,
+			Port:         port,
+			Model:        model,
+			UpdatedAt:    now,
+			HasWorked:    false, // 初期状態では作業未実施
+			WorkCount:    0,     // 作業回数0で初期化
+			LastWorkedAt: nil,   // 最後の作業時刻は未設定
+		}
 
-	// Load existing state
-	states := make(map[string]AgentState)
-	if data, err := os.ReadFile(sm.statePath); err == nil {
-		json.Unmarshal(data, &states)
-	}
-
-	// Create new state entry
-	now := time.Now()
-	agentState := AgentState{
-		GitRepo:      sm.getGitRepo(),
-		BranchFrom:   sm.getBranchFrom(),
-		BranchName:   branchName,
-		Prompt:       prompt,
-		WorktreePath: worktreePath,
-		Port:         port,
-		Model:        model,
-		UpdatedAt:    now,
-		HasWorked:    false, // 初期状態では作業未実施
-		WorkCount:    0,     // 作業回数0で初期化
-		LastWorkedAt: nil,   // 最後の作業時刻は未設定
-	}
-
-	// Set created time if this is a new entry
-	if existing, exists := states[sessionName]; exists {
-		agentState.CreatedAt = existing.CreatedAt
-		// 既存エージェントの場合は作業履歴を保持
-		agentState.HasWorked = existing.HasWorked
-		agentState.WorkCount = existing.WorkCount
-		agentState.LastWorkedAt = existing.LastWorkedAt
-	} else {
-		agentState.CreatedAt = now
-	}
+		// Set created time if this is a new entry
+		if existing, exists := states[sessionName]; exists {
+			agentState.CreatedAt = existing.CreatedAt
+			// 既存エージェントの場合は作業履歴を保持
+			agentState.HasWorked = existing.HasWorked
+			agentState.WorkCount = existing.WorkCount
+			agentState.LastWorkedAt = existing.LastWorkedAt
+			agentState.Labels = existing.Labels
+			agentState.StatusHistory = existing.StatusHistory
+			agentState.Report = existing.Report
+			agentState.LastError = existing.LastError
+			agentState.Restarts = existing.Restarts
+			agentState.Nudges = existing.Nudges
+		} else {
+			agentState.CreatedAt = now
+		}
 
-	states[sessionName] = agentState
+		states[sessionName] = agentState
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
 	// Store the worktree branch in agent-specific file
 	if err := sm.storeWorktreeBranch(sessionName); err != nil {
 		log.Error("Error storing worktree branch", "error", err)
 	}
-
-	// Save to file
-	data, err := json.MarshalIndent(states, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	return os.WriteFile(sm.statePath, data, 0644)
+	return nil
 }
 
 func (sm *StateManager) getCurrentBranch() string {
@@ -177,13 +254,21 @@ func (sm *StateManager) getCurrentBranch() string {
 	return strings.TrimSpace(string(output))
 }
 
-func (sm *StateManager) storeWorktreeBranch(sessionName string) error {
+// AgentDir returns the directory that holds per-agent files such as the
+// branch the agent was started from; "uzi kill" removes it
+func AgentDir(sessionName string) (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		return err
+		return "", err
 	}
+	return filepath.Join(homeDir, ".local", "share", "uzi", "worktree", sessionName), nil
+}
 
-	agentDir := filepath.Join(homeDir, ".local", "share", "uzi", "worktree", sessionName)
+func (sm *StateManager) storeWorktreeBranch(sessionName string) error {
+	agentDir, err := AgentDir(sessionName)
+	if err != nil {
+		return err
+	}
 	if err := os.MkdirAll(agentDir, 0755); err != nil {
 		return err
 	}
@@ -201,30 +286,33 @@ func (sm *StateManager) GetStatePath() string {
 	return sm.statePath
 }
 
-func (sm *StateManager) RemoveState(sessionName string) error {
-	// Load existing state
+// LoadStates reads every agent state in a single pass over the state file
+func (sm *StateManager) LoadStates() (map[string]AgentState, error) {
 	states := make(map[string]AgentState)
-	if data, err := os.ReadFile(sm.statePath); err != nil {
+	data, err := os.ReadFile(sm.statePath)
+	if err != nil {
 		if os.IsNotExist(err) {
-			return nil // No state file, nothing to remove
-		}
-		return err
-	} else {
-		if err := json.Unmarshal(data, &states); err != nil {
-			return err
+			return states, nil
 		}
+		return nil, fmt.Errorf("error reading state file: %w", err)
 	}
-
-	// Remove the session from the state
-	delete(states, sessionName)
-
-	// Save updated state to file
-	data, err := json.MarshalIndent(states, "", "  ")
-	if err != nil {
-		return err
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, fmt.Errorf("error parsing state file: %w", err)
 	}
+	return states, nil
+}
 
-	return os.WriteFile(sm.statePath, data, 0644)
+func (sm *StateManager) RemoveState(sessionName string) error {
+	if _, err := os.Stat(sm.statePath); os.IsNotExist(err) {
+		return nil // No state file, nothing to remove
+	}
+	return sm.modify(true, func(states map[string]AgentState) error {
+		if _, exists := states[sessionName]; !exists {
+			return errUnchanged
+		}
+		delete(states, sessionName)
+		return nil
+	})
 }
 
 // GetWorktreeInfo returns the worktree information for a given session
@@ -249,82 +337,249 @@ func (sm *StateManager) GetWorktreeInfo(sessionName string) (*AgentState, error)
 
 // MarkWorkCompleted marks that an agent has completed work
 func (sm *StateManager) MarkWorkCompleted(sessionName string) error {
-	if err := sm.ensureStateDir(); err != nil {
-		return err
-	}
+	return sm.updateAgent(sessionName, func(state *AgentState) {
+		now := time.Now()
+		state.HasWorked = true
+		state.WorkCount++
+		state.LastWorkedAt = &now
+		state.UpdatedAt = now
+	})
+}
 
-	// Load existing state
-	states := make(map[string]AgentState)
-	if data, err := os.ReadFile(sm.statePath); err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("no state file found")
+// MarkAsMerged marks that an agent's changes have been merged
+func (sm *StateManager) MarkAsMerged(sessionName string) error {
+	return sm.updateAgent(sessionName, func(state *AgentState) {
+		now := time.Now()
+		state.LastMergedAt = &now
+		state.UpdatedAt = now
+	})
+}
+
+// SetLabels replaces the labels of an agent
+func (sm *StateManager) SetLabels(sessionName string, labels []string) error {
+	return sm.updateAgent(sessionName, func(state *AgentState) {
+		state.Labels = labels
+	})
+}
+
+// UpdateStatusHistories - ロックを持ったまま各エージェントの最新のステータス記録を
+// update に渡し、update が true を返した記録を1回の書き込みで保存する
+// 読み込みから書き込みまでロックしているので、他のプロセスが記録した遷移を上書きしない
+func (sm *StateManager) UpdateStatusHistories(update func(sessionName string, history *StatusHistory) (StatusHistory, bool)) error {
+	return sm.modify(false, func(states map[string]AgentState) error {
+		changed := false
+		for sessionName, state := range states {
+			history, ok := update(sessionName, state.StatusHistory)
+			if !ok {
+				continue
+			}
+			state.StatusHistory = &history
+			states[sessionName] = state
+			changed = true
 		}
-		return err
-	} else {
-		if err := json.Unmarshal(data, &states); err != nil {
-			return err
+		if !changed {
+			return errUnchanged
 		}
+		return nil
+	})
+}
+
+// SaveCompletionReports - 複数エージェントの完了報告を1回の書き込みで保存する
+// 状態ファイルにないセッションは無視する
+func (sm *StateManager) SaveCompletionReports(reports map[string]*CompletionReport) error {
+	if len(reports) == 0 {
+		return nil
 	}
+	return sm.updateExisting(func(states map[string]AgentState) {
+		for sessionName, report := range reports {
+			state, exists := states[sessionName]
+			if !exists {
+				continue
+			}
+			state.Report = report
+			states[sessionName] = state
+		}
+	})
+}
 
-	// Update the specific session
-	state, exists := states[sessionName]
-	if !exists {
-		return fmt.Errorf("session %s not found", sessionName)
+// SaveAgentErrors - 複数エージェントのエラーを1回の書き込みで保存する
+// 状態ファイルにないセッションと、分類と抜粋が記録済みのものと同じエラーは無視する
+func (sm *StateManager) SaveAgentErrors(errs map[string]AgentError) error {
+	if len(errs) == 0 {
+		return nil
 	}
+	return sm.modify(false, func(states map[string]AgentState) error {
+		changed := false
+		for sessionName, agentErr := range errs {
+			state, exists := states[sessionName]
+			if !exists {
+				continue
+			}
+			if last := state.LastError; last != nil && last.Category == agentErr.Category && last.Excerpt == agentErr.Excerpt {
+				continue
+			}
+			agentErr := agentErr
+			state.LastError = &agentErr
+			states[sessionName] = state
+			changed = true
+		}
+		if !changed {
+			return errUnchanged
+		}
+		return nil
+	})
+}
 
-	now := time.Now()
-	state.HasWorked = true
-	state.WorkCount++
-	state.LastWorkedAt = &now
-	state.UpdatedAt = now
+// RecordRestart - エージェントの再起動を記録する
+func (sm *StateManager) RecordRestart(sessionName string, restart AgentRestart) error {
+	return sm.updateAgent(sessionName, func(state *AgentState) {
+		state.Restarts = append(state.Restarts, restart)
+	})
+}
 
-	states[sessionName] = state
+// RecordNudge - idle のエージェントへの催促を記録する
+func (sm *StateManager) RecordNudge(sessionName string, nudge AgentNudge) error {
+	return sm.updateAgent(sessionName, func(state *AgentState) {
+		state.Nudges = append(state.Nudges, nudge)
+	})
+}
 
-	// Save to file
-	data, err := json.MarshalIndent(states, "", "  ")
-	if err != nil {
-		return err
-	}
+// updateAgent - 1つのエージェントの状態を update で変更して保存する
+func (sm *StateManager) updateAgent(sessionName string, update func(state *AgentState)) error {
+	return sm.modify(false, func(states map[string]AgentState) error {
+		state, exists := states[sessionName]
+		if !exists {
+			return fmt.Errorf("session %s not found", sessionName)
+		}
+		update(&state)
+		states[sessionName] = state
+		return nil
+	})
+}
 
-	return os.WriteFile(sm.statePath, data, 0644)
+// updateExisting - 状態ファイルを読み込み、update で変更して書き戻す
+func (sm *StateManager) updateExisting(update func(states map[string]AgentState)) error {
+	return sm.modify(false, func(states map[string]AgentState) error {
+		update(states)
+		return nil
+	})
 }
 
-// MarkAsMerged marks that an agent's changes have been merged
-func (sm *StateManager) MarkAsMerged(sessionName string) error {
+// errUnchanged - modify の update が返すと、状態ファイルを書き換えずに終わる
+var errUnchanged = errors.New("state unchanged")
+
+// modify - 状態ファイルをロックして読み込み、update で変更して書き戻す
+// uzi auto と uzi ls / uzi ui が同時に書いても互いの変更を失わないよう、
+// 読み込みから書き込みまで state.json.lock の排他ロックを持つ。
+// 書き込みは一時ファイルに書いてから置き換えるので、読む側が途中の内容を見ることはない。
+// missing が true ならファイルがなくても空の状態から始める
+func (sm *StateManager) modify(missing bool, update func(states map[string]AgentState) error) error {
 	if err := sm.ensureStateDir(); err != nil {
 		return err
 	}
+	unlock, err := sm.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	// Load existing state
 	states := make(map[string]AgentState)
 	if data, err := os.ReadFile(sm.statePath); err != nil {
-		if os.IsNotExist(err) {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if !missing {
 			return fmt.Errorf("no state file found")
 		}
+	} else if err := json.Unmarshal(data, &states); err != nil {
 		return err
-	} else {
-		if err := json.Unmarshal(data, &states); err != nil {
-			return err
-		}
 	}
 
-	// Update the specific session
-	state, exists := states[sessionName]
-	if !exists {
-		return fmt.Errorf("session %s not found", sessionName)
+	if err := update(states); err != nil {
+		if errors.Is(err, errUnchanged) {
+			return nil
+		}
+		return err
 	}
+	return sm.writeStates(states)
+}
 
-	now := time.Now()
-	state.LastMergedAt = &now
-	state.UpdatedAt = now
-
-	states[sessionName] = state
+// lock - 状態ファイルの隣のロックファイルで排他ロックを取り、外す関数を返す
+func (sm *StateManager) lock() (func(), error) {
+	lockPath := sm.statePath + ".lock"
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", lockPath, err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", lockPath, err)
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
 
-	// Save to file
+// writeStates - 状態を一時ファイルに書き、状態ファイルに置き換える
+func (sm *StateManager) writeStates(states map[string]AgentState) error {
 	data, err := json.MarshalIndent(states, "", "  ")
 	if err != nil {
 		return err
 	}
+	tmp, err := os.CreateTemp(filepath.Dir(sm.statePath), filepath.Base(sm.statePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), sm.statePath)
+}
+
+// HasLabel reports whether the agent carries the given label
+func (s AgentState) HasLabel(label string) bool {
+	for _, l := range s.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// AgentNameFromSession extracts the agent name from a session name of the
+// form agent-<project>-<hash>-<agent>
+func AgentNameFromSession(sessionName string) string {
+	parts := strings.Split(sessionName, "-")
+	if len(parts) >= 4 && parts[0] == "agent" {
+		return strings.Join(parts[3:], "-")
+	}
+	return sessionName
+}
+
+// FindSessionForAgent returns the active session in the current repository
+// that belongs to the given agent name
+func (sm *StateManager) FindSessionForAgent(agentName string) (string, error) {
+	activeSessions, err := sm.GetActiveSessionsForRepo()
+	if err != nil {
+		return "", err
+	}
+
+	for _, session := range activeSessions {
+		if session == agentName || AgentNameFromSession(session) == agentName {
+			return session, nil
+		}
+	}
 
-	return os.WriteFile(sm.statePath, data, 0644)
+	return "", fmt.Errorf("no active session found for agent: %s", agentName)
 }
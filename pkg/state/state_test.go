@@ -0,0 +1,70 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentUpdatesKeepEveryChange(t *testing.T) {
+	dir := t.TempDir()
+	sm := &StateManager{statePath: filepath.Join(dir, "state.json")}
+
+	sessions := []string{"agent-a", "agent-b"}
+	initial := make(map[string]AgentState)
+	for _, name := range sessions {
+		initial[name] = AgentState{Prompt: name}
+	}
+	if err := sm.writeStates(initial); err != nil {
+		t.Fatal(err)
+	}
+
+	const perSession = 20
+	var wg sync.WaitGroup
+	for _, name := range sessions {
+		for i := 0; i < perSession; i++ {
+			wg.Add(1)
+			go func(name string, i int) {
+				defer wg.Done()
+				nudge := AgentNudge{At: time.Now(), Message: fmt.Sprintf("nudge %d", i)}
+				if err := sm.RecordNudge(name, nudge); err != nil {
+					t.Error(err)
+				}
+			}(name, i)
+		}
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(sm.statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var states map[string]AgentState
+	if err := json.Unmarshal(data, &states); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range sessions {
+		if got := len(states[name].Nudges); got != perSession {
+			t.Errorf("%s has %d nudges, want %d", name, got, perSession)
+		}
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(dir, "state.json.*.tmp"))
+	if len(leftovers) > 0 {
+		t.Errorf("temp files left behind: %v", leftovers)
+	}
+}
+
+func TestRemoveStateWithoutFile(t *testing.T) {
+	sm := &StateManager{statePath: filepath.Join(t.TempDir(), "state.json")}
+	if err := sm.RemoveState("agent-a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(sm.statePath); !os.IsNotExist(err) {
+		t.Errorf("RemoveState created %s", sm.statePath)
+	}
+}
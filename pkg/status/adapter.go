@@ -37,6 +37,7 @@ func (sa *StateAdapter) GetWorktreeInfo(sessionName string) (*AgentState, error)
 			WorktreePath: agentState.WorktreePath,
 			UpdatedAt:    agentState.UpdatedAt,
 			IsMerged:     isMerged,
+			Model:        agentState.Model,
 		}, nil
 	}
 
@@ -49,6 +50,37 @@ func (sa *StateAdapter) MarkAsMerged(sessionName string) error {
 	return sa.stateManager.MarkAsMerged(sessionName)
 }
 
+// SnapshotAdapter - 読み込み済みの状態を使うStateManager実装
+// 1回の一覧表示でエージェントごとにstate.jsonを読み直さないために使う
+type SnapshotAdapter struct {
+	stateManager *state.StateManager
+	states       map[string]state.AgentState
+}
+
+// NewSnapshotAdapter - SnapshotAdapterのコンストラクタ
+func NewSnapshotAdapter(sm *state.StateManager, states map[string]state.AgentState) StateManager {
+	return &SnapshotAdapter{stateManager: sm, states: states}
+}
+
+// GetWorktreeInfo - スナップショットからAgentStateを取得
+func (sa *SnapshotAdapter) GetWorktreeInfo(sessionName string) (*AgentState, error) {
+	agentState, ok := sa.states[sessionName]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return &AgentState{
+		WorktreePath: agentState.WorktreePath,
+		UpdatedAt:    agentState.UpdatedAt,
+		IsMerged:     agentState.LastMergedAt != nil,
+		Model:        agentState.Model,
+	}, nil
+}
+
+// MarkAsMerged - 状態ファイルに書き込む
+func (sa *SnapshotAdapter) MarkAsMerged(sessionName string) error {
+	return sa.stateManager.MarkAsMerged(sessionName)
+}
+
 // DefaultTmuxClient - デフォルトのTmuxClient実装を取得
 func DefaultTmuxClient() TmuxClient {
 	return &defaultTmuxClient{}
@@ -0,0 +1,153 @@
+package status
+
+import (
+	"regexp"
+	"strings"
+)
+
+// エラーの分類
+const (
+	ErrorCrash   = "crash"   // エージェントのプロセスが異常終了した
+	ErrorAPI     = "api"     // レート制限、過負荷、認証などの API エラー
+	ErrorTool    = "tool"    // エージェントが使ったツールの失敗
+	ErrorBuild   = "build"   // エージェントが実行したビルドやテストの失敗
+	ErrorUnknown = "unknown" // どれにも当てはまらない
+)
+
+// ErrorCategories - ルールの category に書ける分類
+var ErrorCategories = []string{ErrorCrash, ErrorAPI, ErrorTool, ErrorBuild}
+
+// ErrorClass - エラーの分類と画面からの抜粋
+type ErrorClass struct {
+	Category string
+	// Excerpt - マッチした行とそれに続く数行
+	Excerpt string
+}
+
+// Fatal - エージェントが自分では先に進めないエラーか
+// ツールやテストの失敗はエージェントが対処する作業の一部なので error にしない
+func (c ErrorClass) Fatal() bool {
+	return c.Category != ErrorTool && c.Category != ErrorBuild
+}
+
+// errorClassifier - 分類を決めるパターン（順に試す）
+type errorClassifier struct {
+	category string
+	pattern  *regexp.Regexp
+}
+
+var errorClassifiers = []errorClassifier{
+	{ErrorAPI, regexp.MustCompile(`(?i:API Error|rate[ _-]?limit|overloaded|authentication_error|invalid (?:x-)?api[ -]?key|credit balance)|\b(?:429|529)\b|Please run /login`)},
+	{ErrorCrash, regexp.MustCompile(`^panic: |Traceback \(most recent call last\)|Segmentation fault|core dumped|FATAL ERROR|fatal error: (?:runtime|all goroutines)|Unhandled(?:Promise)?Rejection|uncaught exception|command not found`)},
+	{ErrorTool, regexp.MustCompile(`^\s*⎿\s+(?:Error|error)|<tool_use_error>|(?i:tool (?:use |call )?(?:failed|error))`)},
+	buildClassifier,
+}
+
+var buildClassifier = errorClassifier{ErrorBuild, regexp.MustCompile(`^\s*(?:⎿\s*)?(?:--- )?FAIL\b|\berror TS\d+|npm ERR!|make: \*\*\*|(?i:build failed|compilation failed|tests? failed)|cannot find package|undefined: |AssertionError|\b\d+ (?:tests? )?failed\b|expected .* (?:got|received|but was)`)}
+
+// inToolOutput - lines[index] がツールの出力の中の行か
+// ツールの出力は ⎿ で始まるインデントされたブロックで、その上の行（折り返していれば
+// 最初の行）がツール呼び出しの見出しになっている。見出しのない ⎿ の行（"⎿  API Error: ..."）
+// や、前のツールの出力のすぐ下の ⎿ の行はエージェントの UI のメッセージ
+func inToolOutput(lines []string, index int) bool {
+	start := -1
+	for i := index; i >= 0; i-- {
+		if isOutputStart(lines[i]) {
+			start = i
+			break
+		}
+		if atColumnZero(lines[i]) {
+			return false
+		}
+	}
+	for i := start - 1; i >= 0 && start > 0; i-- {
+		if isOutputStart(lines[i]) || strings.TrimSpace(lines[i]) == "" {
+			return false
+		}
+		if atColumnZero(lines[i]) {
+			return toolCall.MatchString(lines[i])
+		}
+	}
+	return false
+}
+
+// isOutputStart - ⎿ で始まる行か
+func isOutputStart(line string) bool {
+	return strings.HasPrefix(strings.TrimLeft(line, " \t"), "⎿")
+}
+
+// atColumnZero - 行頭から書かれた行か（インデントされた行と空行は違う）
+func atColumnZero(line string) bool {
+	return line != "" && line[0] != ' ' && line[0] != '\t'
+}
+
+// toolCall - ツール呼び出しの見出し（"● Bash(go test ./...)"）
+var toolCall = regexp.MustCompile(`^●\s*[A-Za-z][\w.:-]*\(`)
+
+// excerptLines - 抜粋に含める行数（マッチした行を含む）
+const excerptLines = 3
+
+// classifyError - lines[index] のエラーを分類する
+// ツールの出力（"● Bash(...)" の下の ⎿ のブロック）の中の行は、ツールが出力した
+// "API Error" やトレースバックでもエージェント自身のエラーではないので tool か build にする。
+// それ以外は category が指定されていればそれを使う。マッチした行で決まらなければ前後の数行も見る
+// （"FAIL" の下の "Error:" や "API Error" の続きの行など）。ツールの出力の行はそのとき使わない。
+func classifyError(category string, lines []string, index int) ErrorClass {
+	class := ErrorClass{Category: category, Excerpt: excerpt(lines, index)}
+	if inToolOutput(lines, index) {
+		if category == ErrorBuild {
+			return class
+		}
+		class.Category = ErrorTool
+		for _, i := range contextOrder(index, len(lines)) {
+			if inToolOutput(lines, i) && buildClassifier.pattern.MatchString(lines[i]) {
+				class.Category = ErrorBuild
+				break
+			}
+		}
+		return class
+	}
+	if class.Category != "" {
+		return class
+	}
+	for _, i := range contextOrder(index, len(lines)) {
+		if i != index && inToolOutput(lines, i) {
+			continue
+		}
+		for _, c := range errorClassifiers {
+			if c.pattern.MatchString(lines[i]) {
+				class.Category = c.category
+				return class
+			}
+		}
+	}
+	class.Category = ErrorUnknown
+	return class
+}
+
+// contextOrder - マッチした行、その後の行、前の行の順に調べる行番号
+func contextOrder(index, n int) []int {
+	order := []int{index}
+	for i := index + 1; i < min(index+excerptLines, n); i++ {
+		order = append(order, i)
+	}
+	for i := index - 1; i >= max(index-5, 0); i-- {
+		order = append(order, i)
+	}
+	return order
+}
+
+// excerpt - マッチした行から excerptLines 行を枠線を除いて取り出す
+func excerpt(lines []string, index int) string {
+	var kept []string
+	for i := index; i < min(index+excerptLines, len(lines)); i++ {
+		if line := strings.Trim(lines[i], frame); line != "" {
+			kept = append(kept, line)
+		}
+	}
+	text := strings.Join(kept, "\n")
+	if runes := []rune(text); len(runes) > 300 {
+		text = string(runes[:299]) + "…"
+	}
+	return text
+}
@@ -0,0 +1,75 @@
+package status
+
+import (
+	"testing"
+
+	"github.com/devflowinc/uzi/pkg/config"
+)
+
+// TestClassifyError - エラーの行とその前後からの分類
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name     string
+		screen   string
+		index    int
+		category string
+		excerpt  string
+	}{
+		{"レート制限", "⎿  API Error: 429 Rate limit reached\n> ", 0, ErrorAPI, "⎿  API Error: 429 Rate limit reached\n>"},
+		{"認証", "Error: Invalid API key · Please run /login", 0, ErrorAPI, "Error: Invalid API key · Please run /login"},
+		{"続きの行で分かるクラッシュ", "Error: agent exited\nSegmentation fault (core dumped)", 0, ErrorCrash, "Error: agent exited\nSegmentation fault (core dumped)"},
+		{"ツールの失敗", "● Bash(ls missing)\n  ⎿  Error: ls: missing: No such file", 1, ErrorTool, "⎿  Error: ls: missing: No such file"},
+		{"前の行で分かるテストの失敗", "--- FAIL: TestParse (0.00s)\n    parse_test.go:12:\nError: expected 3, got 4", 2, ErrorBuild, "Error: expected 3, got 4"},
+		{"TypeScript", "src/a.ts(3,1): error TS2304: Cannot find name 'x'.", 0, ErrorBuild, "src/a.ts(3,1): error TS2304: Cannot find name 'x'."},
+		{"分類できない", "Error: something odd", 0, ErrorUnknown, "Error: something odd"},
+		{"ツールの出力のAPI Error", "● Bash(curl api)\n  ⎿  API Error: 429 Rate limit reached", 1, ErrorTool, "⎿  API Error: 429 Rate limit reached"},
+		{"ツールの出力のトレースバック", "● Bash(python x.py)\n  ⎿  Traceback (most recent call last):\n     Error: boom", 2, ErrorTool, "Error: boom"},
+		{"ツールの出力のビルドの失敗", "● Bash(go test)\n  ⎿  --- FAIL: TestX\n     panic: boom", 2, ErrorBuild, "panic: boom"},
+		{"ツールの出力は周りの分類に使わない", "● Bash(./run.sh)\n  ⎿  Segmentation fault\nError: agent stopped", 2, ErrorUnknown, "Error: agent stopped"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			class := classifyError("", screenLines(tt.screen), tt.index)
+			if class.Category != tt.category {
+				t.Errorf("Category = %q, want %q", class.Category, tt.category)
+			}
+			if class.Excerpt != tt.excerpt {
+				t.Errorf("Excerpt = %q, want %q", class.Excerpt, tt.excerpt)
+			}
+		})
+	}
+
+	if class := classifyError(ErrorCrash, []string{"Error: rate limit"}, 0); class.Category != ErrorCrash {
+		t.Errorf("the rule's category was not used: %+v", class)
+	}
+	if class := classifyError(ErrorCrash, screenLines("● Bash(./x)\n  ⎿  Segmentation fault"), 1); class.Category != ErrorTool {
+		t.Errorf("tool output was classified as %+v", class)
+	}
+}
+
+// TestErrorRuleCategory - ルールの category はそのまま使い、tool と build は error にしない
+func TestErrorRuleCategory(t *testing.T) {
+	rules, err := CompileRules(map[string]config.StatusProfile{
+		"aider": {Rules: []config.StatusRule{
+			{Name: "lint", Pattern: `^lint: `, Status: StatusError, Category: ErrorBuild, Priority: 10},
+			{Name: "boom", Pattern: `^boom`, Status: StatusError},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile := rules.ProfileFor("aider")
+
+	if result, ok := profile.Match("lint: unused variable\n"); ok {
+		t.Errorf("build failure decided the status: %+v", result)
+	}
+	result, ok := profile.Match("lint: unused variable\nboom\n")
+	if !ok || result.Rule.Name != "boom" || result.Error == nil || result.Error.Category != ErrorUnknown {
+		t.Errorf("Match() = %+v, %v", result, ok)
+	}
+
+	explained := profile.Explain("lint: unused variable\n")
+	if !explained[0].Matched || explained[0].Decides() || explained[0].Error.Category != ErrorBuild {
+		t.Errorf("Explain() = %+v", explained[0])
+	}
+}
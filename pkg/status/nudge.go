@@ -0,0 +1,93 @@
+package status
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/devflowinc/uzi/pkg/config"
+)
+
+const (
+	// DefaultMaxNudges - 1つのエージェントに送る既定の催促の上限回数
+	DefaultMaxNudges = 3
+	// DefaultNudgeMessage - 設定がないときの催促のテンプレート
+	DefaultNudgeMessage = "Continue with the task. Run the tests, and when you are done write a summary to {{.Marker}}."
+)
+
+// NudgePolicy - コンパイル済みの催促の設定
+type NudgePolicy struct {
+	// After - この時間 idle のまま画面が変わらなければ催促する（0は催促しない）
+	After     time.Duration
+	MaxNudges int
+	message   *template.Template
+}
+
+// NudgeData - 催促のテンプレートに渡す値
+type NudgeData struct {
+	Agent     string // エージェント名
+	Prompt    string // 元のプロンプト
+	Nudge     int    // 何回目の催促か（1始まり）
+	MaxNudges int
+	Idle      time.Duration // idle になってからの時間
+	Marker    string        // マーカーファイルの名前
+}
+
+func defaultNudgePolicy() NudgePolicy {
+	return NudgePolicy{MaxNudges: DefaultMaxNudges, message: template.Must(template.New("nudge").Parse(DefaultNudgeMessage))}
+}
+
+func compileNudge(cfg config.NudgeConfig) (NudgePolicy, error) {
+	policy := defaultNudgePolicy()
+	if cfg.After != "" {
+		after, err := time.ParseDuration(cfg.After)
+		if err != nil {
+			return NudgePolicy{}, fmt.Errorf("nudge.after: %w", err)
+		}
+		if after < 0 {
+			return NudgePolicy{}, fmt.Errorf("nudge.after must not be negative")
+		}
+		policy.After = after
+	}
+	if cfg.MaxNudges < 0 {
+		return NudgePolicy{}, fmt.Errorf("nudge.maxNudges must not be negative")
+	}
+	if cfg.MaxNudges > 0 {
+		policy.MaxNudges = cfg.MaxNudges
+	}
+	if cfg.Message != "" {
+		message, err := template.New("nudge").Option("missingkey=error").Parse(cfg.Message)
+		if err != nil {
+			return NudgePolicy{}, fmt.Errorf("nudge.message: %w", err)
+		}
+		// 不正なフィールドは設定を読んだ時点で見つける
+		if _, err := renderNudge(message, NudgeData{}); err != nil {
+			return NudgePolicy{}, fmt.Errorf("nudge.message: %w", err)
+		}
+		policy.message = message
+	}
+	return policy, nil
+}
+
+// Enabled - idle のエージェントを催促するか
+func (p NudgePolicy) Enabled() bool {
+	return p.After > 0
+}
+
+// Message - テンプレートから催促のメッセージを作る
+// 改行はエージェントへの送信を途中で確定させてしまうので空白にする
+func (p NudgePolicy) Message(data NudgeData) (string, error) {
+	if data.Marker == "" {
+		data.Marker = MarkerFile
+	}
+	return renderNudge(p.message, data)
+}
+
+func renderNudge(message *template.Template, data NudgeData) (string, error) {
+	var b strings.Builder
+	if err := message.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(b.String()), " "), nil
+}
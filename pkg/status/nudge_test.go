@@ -0,0 +1,62 @@
+package status
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devflowinc/uzi/pkg/config"
+)
+
+// TestNudgeProfile - プロファイルごとの催促の設定とメッセージ
+func TestNudgeProfile(t *testing.T) {
+	rules, err := CompileRules(map[string]config.StatusProfile{
+		"claude": {Nudge: config.NudgeConfig{
+			After:     "3m",
+			MaxNudges: 2,
+			Message:   "{{.Agent}}: nudge {{.Nudge}}/{{.MaxNudges}}.\nKeep going with: {{.Prompt}}",
+		}},
+		"codex": {Nudge: config.NudgeConfig{After: "1m"}},
+	})
+	if err != nil {
+		t.Fatalf("CompileRules() error = %v", err)
+	}
+
+	claude := rules.ProfileFor("claude").Nudge
+	if !claude.Enabled() || claude.After != 3*time.Minute || claude.MaxNudges != 2 {
+		t.Errorf("claude nudge = %+v", claude)
+	}
+	message, err := claude.Message(NudgeData{Agent: "john", Prompt: "fix the bug", Nudge: 1, MaxNudges: 2})
+	if err != nil || message != "john: nudge 1/2. Keep going with: fix the bug" {
+		t.Errorf("Message() = %q, %v", message, err)
+	}
+
+	codex := rules.ProfileFor("codex").Nudge
+	if message, _ := codex.Message(NudgeData{}); !strings.Contains(message, MarkerFile) {
+		t.Errorf("default message = %q, want it to mention the marker file", message)
+	}
+	// 設定しなければ催促しない
+	if nudge := rules.ProfileFor("aider").Nudge; nudge.Enabled() || nudge.MaxNudges != DefaultMaxNudges {
+		t.Errorf("default nudge = %+v", nudge)
+	}
+}
+
+// TestNudgeConfigErrors - 不正な催促の設定
+func TestNudgeConfigErrors(t *testing.T) {
+	tests := []struct {
+		nudge config.NudgeConfig
+		want  string
+	}{
+		{config.NudgeConfig{After: "later"}, "nudge.after"},
+		{config.NudgeConfig{After: "-1m"}, "nudge.after"},
+		{config.NudgeConfig{MaxNudges: -1}, "maxNudges"},
+		{config.NudgeConfig{Message: "{{.Agent"}, "nudge.message"},
+		{config.NudgeConfig{Message: "{{.Branch}}"}, "nudge.message"},
+	}
+	for _, tt := range tests {
+		_, err := CompileRules(map[string]config.StatusProfile{"claude": {Nudge: tt.nudge}})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("CompileRules(%+v) error = %v, want %q", tt.nudge, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package status
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Question - waiting のエージェントが画面で尋ねていること
+type Question struct {
+	Text string
+	// Options - 選択肢（自由入力の質問では空）
+	Options []Option
+	// Menu - 番号の選択肢で、キーを押すだけで決まる（Enter が不要）
+	Menu bool
+}
+
+// Option - 質問の選択肢
+type Option struct {
+	Key   string // "1" や "y"
+	Label string
+}
+
+var (
+	// numberedOption - "❯ 1. Yes" や "│   2) No │" のような番号付きの選択肢
+	numberedOption = regexp.MustCompile(`^[\s│]*(?:[❯>›]\s*)?(\d+)[.)]\s+(.*?)[\s│]*$`)
+	// letterChoice - "(Y/n)" や "[y/N]" のような1文字の選択肢
+	letterChoice = regexp.MustCompile(`[(\[]([A-Za-z])/([A-Za-z])[)\]]`)
+	// frame - 行の前後の枠線と空白
+	frame = "│╭╮╰╯─ \t"
+)
+
+// ExtractQuestion - 画面の lineNumber 行目（1始まり、ルールがマッチした行）から質問を取り出す
+// マッチした行を質問文とし、その下に続く番号付きの行を選択肢とする
+func ExtractQuestion(screen string, lineNumber int) Question {
+	lines := screenLines(screen)
+	if lineNumber < 1 || lineNumber > len(lines) {
+		return Question{}
+	}
+
+	question := Question{Text: strings.Trim(lines[lineNumber-1], frame)}
+	for _, line := range lines[lineNumber:] {
+		if strings.Trim(line, frame) == "" {
+			if len(question.Options) > 0 {
+				break
+			}
+			continue
+		}
+		match := numberedOption.FindStringSubmatch(line)
+		if match == nil {
+			if len(question.Options) > 0 {
+				break
+			}
+			continue
+		}
+		question.Options = append(question.Options, Option{Key: match[1], Label: match[2]})
+	}
+	if len(question.Options) > 0 {
+		question.Menu = true
+		return question
+	}
+
+	if match := letterChoice.FindStringSubmatch(question.Text); match != nil {
+		for _, key := range match[1:] {
+			label := "No"
+			if strings.EqualFold(key, "y") {
+				label = "Yes"
+			}
+			question.Options = append(question.Options, Option{Key: strings.ToLower(key), Label: label})
+		}
+	}
+	return question
+}
+
+// Choose - 回答に対応する選択肢を返す
+// キー（"1"、"y"）、ラベルの完全一致、ラベルの一意な前方一致の順に探す
+func (q Question) Choose(answer string) (Option, bool) {
+	answer = strings.TrimSpace(answer)
+	for _, option := range q.Options {
+		if strings.EqualFold(option.Key, answer) {
+			return option, true
+		}
+	}
+	for _, option := range q.Options {
+		if strings.EqualFold(option.Label, answer) {
+			return option, true
+		}
+	}
+	var found []Option
+	for _, option := range q.Options {
+		if answer != "" && strings.HasPrefix(strings.ToLower(option.Label), strings.ToLower(answer)) {
+			found = append(found, option)
+		}
+	}
+	if len(found) == 1 {
+		return found[0], true
+	}
+	return Option{}, false
+}
+
+// Keys - answer を送るためのキー入力
+// 番号の選択肢はキーだけで決まり、y/n と自由入力は Enter で確定する
+// 選択肢がある質問で該当するものがなければエラーを返す
+func (q Question) Keys(answer string) ([]string, error) {
+	if len(q.Options) == 0 {
+		return []string{answer, "Enter"}, nil
+	}
+	option, ok := q.Choose(answer)
+	if !ok {
+		choices := make([]string, len(q.Options))
+		for i, o := range q.Options {
+			choices[i] = o.Key + " (" + o.Label + ")"
+		}
+		return nil, fmt.Errorf("%q does not match any option: %s", answer, strings.Join(choices, ", "))
+	}
+	if q.Menu {
+		return []string{option.Key}, nil
+	}
+	return []string{option.Key, "Enter"}, nil
+}
+
+// String - "Do you want to proceed? [1. Yes / 2. No]" の形
+// 1文字の選択肢は質問文に含まれているのでそのまま返す
+func (q Question) String() string {
+	if !q.Menu {
+		return q.Text
+	}
+	options := make([]string, len(q.Options))
+	for i, option := range q.Options {
+		options[i] = option.Key + ". " + option.Label
+	}
+	return q.Text + " [" + strings.Join(options, " / ") + "]"
+}
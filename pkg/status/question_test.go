@@ -0,0 +1,175 @@
+package status
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestExtractQuestion - 画面からの質問と選択肢の取り出し
+func TestExtractQuestion(t *testing.T) {
+	tests := []struct {
+		name    string
+		screen  string
+		want    string
+		options []Option
+		menu    bool
+	}{
+		{
+			name: "枠付きのメニュー",
+			screen: "╭──────────────────────────────╮\n" +
+				"│ Bash command                 │\n" +
+				"│   go test ./...              │\n" +
+				"│ Do you want to proceed?      │\n" +
+				"│ ❯ 1. Yes                     │\n" +
+				"│   2. Yes, and don't ask again │\n" +
+				"│   3. No (esc)                │\n" +
+				"╰──────────────────────────────╯\n",
+			want:    "Do you want to proceed?",
+			options: []Option{{"1", "Yes"}, {"2", "Yes, and don't ask again"}, {"3", "No (esc)"}},
+			menu:    true,
+		},
+		{
+			name:    "y/n の確認",
+			screen:  "Applying 3 edits\nContinue? (Y/n)\n",
+			want:    "Continue? (Y/n)",
+			options: []Option{{"y", "Yes"}, {"n", "No"}},
+		},
+		{
+			name:   "自由入力の質問",
+			screen: "I found two config files.\nWhich one should I edit?\n\n> \n",
+			want:   "Which one should I edit?",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := DefaultRules().ProfileFor("claude").Match(tt.screen)
+			if !ok || result.Rule.Status != StatusWaiting {
+				t.Fatalf("Match() = %+v, %v; want waiting", result, ok)
+			}
+			question := ExtractQuestion(tt.screen, result.LineNumber)
+			if question.Text != tt.want {
+				t.Errorf("Text = %q, want %q", question.Text, tt.want)
+			}
+			if len(question.Options) != len(tt.options) {
+				t.Fatalf("Options = %+v, want %+v", question.Options, tt.options)
+			}
+			for i, option := range tt.options {
+				if question.Options[i] != option {
+					t.Errorf("Options[%d] = %+v, want %+v", i, question.Options[i], option)
+				}
+			}
+			if question.Menu != tt.menu {
+				t.Errorf("Menu = %v, want %v", question.Menu, tt.menu)
+			}
+		})
+	}
+
+	if q := ExtractQuestion("one line", 5); q.Text != "" {
+		t.Errorf("out of range line = %+v", q)
+	}
+}
+
+// TestQuestionKeys - 回答から送るキーを決める
+func TestQuestionKeys(t *testing.T) {
+	menu := Question{
+		Text:    "Do you want to proceed?",
+		Options: []Option{{"1", "Yes"}, {"2", "Yes, and don't ask again"}, {"3", "No"}},
+		Menu:    true,
+	}
+	yesNo := Question{Text: "Continue? (Y/n)", Options: []Option{{"y", "Yes"}, {"n", "No"}}}
+
+	tests := []struct {
+		question Question
+		answer   string
+		want     string
+		wantErr  bool
+	}{
+		{menu, "2", "2", false},
+		{menu, "no", "3", false},
+		{menu, "Yes", "1", false}, // 完全一致は前方一致より優先
+		{menu, "yes,", "2", false},
+		{menu, "maybe", "", true},
+		{yesNo, "Y", "y Enter", false},
+		{yesNo, "no", "n Enter", false},
+		{Question{Text: "Which one?"}, "the first", "the first Enter", false},
+	}
+	for _, tt := range tests {
+		keys, err := tt.question.Keys(tt.answer)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Keys(%q) error = %v, wantErr %v", tt.answer, err, tt.wantErr)
+			continue
+		}
+		if got := strings.Join(keys, " "); got != tt.want {
+			t.Errorf("Keys(%q) = %q, want %q", tt.answer, got, tt.want)
+		}
+	}
+}
+
+func TestQuestionString(t *testing.T) {
+	menu := Question{Text: "Proceed?", Options: []Option{{"1", "Yes"}, {"2", "No"}}, Menu: true}
+	if got := menu.String(); got != "Proceed? [1. Yes / 2. No]" {
+		t.Errorf("String() = %q", got)
+	}
+	yesNo := Question{Text: "Continue? (Y/n)", Options: []Option{{"y", "Yes"}, {"n", "No"}}}
+	if got := yesNo.String(); got != "Continue? (Y/n)" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+// TestDetailedStatusWaiting - waiting のときは質問を取り出す
+func TestDetailedStatusWaiting(t *testing.T) {
+	screen := "Edit main.go\nDo you want to make this edit to main.go?\n❯ 1. Yes\n  2. No\n"
+	sm := NewStatusManagerWithRules(
+		&mockTmuxClient{paneContent: map[string]string{"agent-1": screen}},
+		&fakeStateManager{states: map[string]*AgentState{"agent-1": {WorktreePath: "/wt"}}},
+		DefaultRules(),
+	).(*statusManager)
+	sm.activity = &fakeProbe{}
+
+	detailed, err := sm.GetDetailedStatus("agent-1")
+	if err != nil {
+		t.Fatalf("GetDetailedStatus() error = %v", err)
+	}
+	if detailed.Status != StatusWaiting || detailed.Icon != Icons[StatusWaiting] {
+		t.Fatalf("GetDetailedStatus() = %s %s, want waiting", detailed.Status, detailed.Icon)
+	}
+	if detailed.Question == nil || detailed.Question.String() != "Do you want to make this edit to main.go? [1. Yes / 2. No]" {
+		t.Errorf("Question = %+v", detailed.Question)
+	}
+}
+
+// TestCompletedAgentEndingInQuestion - 作業を終えたエージェントの最後の行が質問でも ready / merged
+func TestCompletedAgentEndingInQuestion(t *testing.T) {
+	worktree := t.TempDir()
+	if err := os.WriteFile(filepath.Join(worktree, MarkerFile), []byte("done"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	screen := "● All tests pass. Anything else you'd like me to change?\n\n> \n"
+	states := map[string]*AgentState{
+		"done":     {WorktreePath: worktree},
+		"merged":   {WorktreePath: t.TempDir(), IsMerged: true},
+		"question": {WorktreePath: t.TempDir()},
+	}
+	sm := NewStatusManagerWithRules(
+		&mockTmuxClient{paneContent: map[string]string{"done": screen, "merged": screen, "question": screen}},
+		&fakeStateManager{states: states},
+		DefaultRules(),
+	)
+	for session, want := range map[string]string{"done": StatusReady, "merged": StatusMerged, "question": StatusWaiting} {
+		if got, _ := sm.GetStatus(session); got != want {
+			t.Errorf("GetStatus(%s) = %s, want %s", session, got, want)
+		}
+	}
+
+	// 優先度の高い確認は完了していても waiting
+	sm = NewStatusManagerWithRules(
+		&mockTmuxClient{paneContent: map[string]string{"done": "Do you want to proceed?\n❯ 1. Yes\n"}},
+		&fakeStateManager{states: states},
+		DefaultRules(),
+	)
+	if got, _ := sm.GetStatus("done"); got != StatusWaiting {
+		t.Errorf("GetStatus(done) with a permission prompt = %s, want waiting", got)
+	}
+}
@@ -0,0 +1,153 @@
+package status
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/devflowinc/uzi/pkg/state"
+)
+
+// MarkerFile - エージェントが作業を終えたときにワークツリーに作るファイル
+// 空のファイル（以前の形式）か、完了報告の JSON を書く:
+//
+//	{
+//	  "summary": "Add retry to the HTTP client",
+//	  "files_touched": ["client.go", "client_test.go"],
+//	  "tests": {"command": "go test ./...", "result": "passed"},
+//	  "open_questions": ["Should the retry count be configurable?"],
+//	  "follow_ups": ["Use the client in the CLI"]
+//	}
+//
+// JSON でない内容は summary として扱う
+const MarkerFile = ".uzi-task-completed"
+
+// テスト結果
+const (
+	TestsPassed  = "passed"
+	TestsFailed  = "failed"
+	TestsSkipped = "skipped"
+)
+
+// ParseReport - マーカーファイルの内容を完了報告にする
+// 空のファイルは報告なし（nil）とする
+func ParseReport(data []byte) (*state.CompletionReport, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if data[0] != '{' {
+		return &state.CompletionReport{Summary: string(data)}, nil
+	}
+
+	var report state.CompletionReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("invalid completion report: %w", err)
+	}
+	report.Summary = strings.TrimSpace(report.Summary)
+	if report.Summary == "" {
+		return nil, fmt.Errorf("invalid completion report: summary is required")
+	}
+	if tests := report.Tests; tests != nil {
+		switch tests.Result {
+		case TestsPassed, TestsFailed, TestsSkipped:
+		default:
+			return nil, fmt.Errorf("invalid completion report: tests.result %q (want passed, failed or skipped)", tests.Result)
+		}
+	}
+	return &report, nil
+}
+
+// ReadReport - ワークツリーのマーカーファイルから完了報告を読む
+// マーカーファイルがない場合と空の場合は nil を返す
+func ReadReport(worktreePath string) (*state.CompletionReport, error) {
+	if worktreePath == "" {
+		return nil, nil
+	}
+	path := getMarkerFilePath(worktreePath)
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	report, err := ParseReport(data)
+	if report != nil {
+		report.CompletedAt = info.ModTime()
+	}
+	return report, err
+}
+
+// CommitMessage - 完了報告からコミットメッセージを作る
+// summary の1行目を件名とし、残りの行、テスト結果、未解決の質問とフォローアップを本文にする
+func CommitMessage(report *state.CompletionReport) string {
+	subject, rest, _ := strings.Cut(report.Summary, "\n")
+	var body []string
+	if rest = strings.TrimSpace(rest); rest != "" {
+		body = append(body, rest)
+	}
+	if tests := report.Tests; tests != nil && tests.Command != "" {
+		body = append(body, fmt.Sprintf("Tests: %s (%s)", tests.Command, tests.Result))
+	}
+	if len(report.OpenQuestions) > 0 {
+		body = append(body, "Open questions:\n- "+strings.Join(report.OpenQuestions, "\n- "))
+	}
+	if len(report.FollowUps) > 0 {
+		body = append(body, "Follow-ups:\n- "+strings.Join(report.FollowUps, "\n- "))
+	}
+
+	message := strings.TrimSpace(subject)
+	if len(body) > 0 {
+		message += "\n\n" + strings.Join(body, "\n\n")
+	}
+	return message
+}
+
+// ExcludeMarkerFile - マーカーファイルをリポジトリの info/exclude に加え、コミットされないようにする
+// ワークツリーは info/exclude を共有するので、どのワークツリーから呼んでも同じファイルに書く
+func ExcludeMarkerFile(worktreePath string) error {
+	cmd := exec.Command("git", "rev-parse", "--git-path", "info/exclude")
+	cmd.Dir = worktreePath
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to find info/exclude: %w", err)
+	}
+	path := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(worktreePath, path)
+	}
+
+	pattern := "/" + MarkerFile
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == pattern {
+			return nil
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		pattern = "\n" + pattern
+	}
+	_, err = fmt.Fprintf(file, "%s\n", pattern)
+	return err
+}
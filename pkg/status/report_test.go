@@ -0,0 +1,140 @@
+package status
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/devflowinc/uzi/pkg/state"
+)
+
+// TestParseReport - マーカーファイルの内容の解釈
+func TestParseReport(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		summary string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "空のファイルは報告なし", data: "\n", wantNil: true},
+		{name: "JSONの報告", data: `{"summary": " Add retry ", "files_touched": ["a.go"], "tests": {"command": "go test ./...", "result": "failed"}, "extra": 1}`, summary: "Add retry"},
+		{name: "JSONでない内容はsummary", data: "Fixed the login bug\n", summary: "Fixed the login bug"},
+		{name: "summaryは必須", data: `{"files_touched": ["a.go"]}`, wantErr: true},
+		{name: "不明なテスト結果", data: `{"summary": "x", "tests": {"command": "make", "result": "ok"}}`, wantErr: true},
+		{name: "壊れたJSON", data: `{"summary": `, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := ParseReport([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseReport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (report == nil) != tt.wantNil {
+				t.Fatalf("ParseReport() = %+v", report)
+			}
+			if report != nil && report.Summary != tt.summary {
+				t.Errorf("Summary = %q, want %q", report.Summary, tt.summary)
+			}
+		})
+	}
+}
+
+// TestReadReport - マーカーファイルの更新時刻を完了時刻にする
+func TestReadReport(t *testing.T) {
+	dir := t.TempDir()
+	if report, err := ReadReport(dir); report != nil || err != nil {
+		t.Fatalf("without marker = %+v, %v", report, err)
+	}
+
+	path := filepath.Join(dir, MarkerFile)
+	if err := os.WriteFile(path, []byte(`{"summary": "done"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(path)
+	report, err := ReadReport(dir)
+	if err != nil || report == nil {
+		t.Fatalf("ReadReport() = %+v, %v", report, err)
+	}
+	if !report.CompletedAt.Equal(info.ModTime()) {
+		t.Errorf("CompletedAt = %v, want %v", report.CompletedAt, info.ModTime())
+	}
+}
+
+func TestCommitMessage(t *testing.T) {
+	report := &state.CompletionReport{
+		Summary:       "Add retry to the HTTP client\nRetries idempotent requests up to 3 times.",
+		Tests:         &state.TestReport{Command: "go test ./...", Result: TestsPassed},
+		OpenQuestions: []string{"Should the retry count be configurable?"},
+		FollowUps:     []string{"Use the client in the CLI", "Add metrics"},
+	}
+	want := `Add retry to the HTTP client
+
+Retries idempotent requests up to 3 times.
+
+Tests: go test ./... (passed)
+
+Open questions:
+- Should the retry count be configurable?
+
+Follow-ups:
+- Use the client in the CLI
+- Add metrics`
+	if got := CommitMessage(report); got != want {
+		t.Errorf("CommitMessage() =\n%s\nwant\n%s", got, want)
+	}
+	if got := CommitMessage(&state.CompletionReport{Summary: "Fix typo"}); got != "Fix typo" {
+		t.Errorf("CommitMessage() = %q", got)
+	}
+}
+
+// TestExcludeMarkerFile - ワークツリーからもマーカーファイルがコミットされない
+func TestExcludeMarkerFile(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	git := func(dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return string(out)
+	}
+
+	repo := t.TempDir()
+	git(repo, "init", "-q")
+	git(repo, "commit", "-q", "--allow-empty", "-m", "initial")
+	worktree := filepath.Join(t.TempDir(), "wt")
+	git(repo, "worktree", "add", "-q", "-b", "agent", worktree)
+
+	for i := 0; i < 2; i++ {
+		if err := ExcludeMarkerFile(worktree); err != nil {
+			t.Fatalf("ExcludeMarkerFile() error = %v", err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(repo, ".git", "info", "exclude"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "/"+MarkerFile); n != 1 {
+		t.Errorf("exclude has %d marker entries:\n%s", n, data)
+	}
+
+	os.WriteFile(filepath.Join(worktree, MarkerFile), []byte(`{"summary": "done"}`), 0644)
+	os.WriteFile(filepath.Join(worktree, "main.go"), []byte("package main\n"), 0644)
+	git(worktree, "add", "-A")
+	if staged := git(worktree, "diff", "--cached", "--name-only"); strings.TrimSpace(staged) != "main.go" {
+		t.Errorf("staged files = %q, want only main.go", staged)
+	}
+}
@@ -0,0 +1,93 @@
+package status
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/devflowinc/uzi/pkg/config"
+)
+
+// 再起動のポリシー
+const (
+	RestartNever     = "never"      // 再起動しない
+	RestartOnFailure = "on-failure" // マーカーファイルを書かずに終了したときだけ再起動する
+	RestartAlways    = "always"     // 終了したら常に再起動する
+)
+
+const (
+	// DefaultMaxRestarts - 1つのエージェントを再起動する既定の上限回数
+	DefaultMaxRestarts = 3
+	// DefaultRestartBackoff - 最初の再起動までの既定の待ち時間
+	DefaultRestartBackoff = 10 * time.Second
+	// maxRestartBackoff - 倍にしていく待ち時間の上限
+	maxRestartBackoff = 10 * time.Minute
+)
+
+// RestartPolicy - コンパイル済みの再起動の設定
+type RestartPolicy struct {
+	Policy     string
+	MaxRetries int
+	// Backoff - 最初の再起動までの待ち時間（再起動のたびに倍になる）
+	Backoff time.Duration
+	// ResumePrompt - 再起動したエージェントに渡すプロンプト（空なら元のプロンプト）
+	ResumePrompt string
+}
+
+func defaultRestartPolicy() RestartPolicy {
+	return RestartPolicy{Policy: RestartNever, MaxRetries: DefaultMaxRestarts, Backoff: DefaultRestartBackoff}
+}
+
+func compileRestart(cfg config.RestartConfig) (RestartPolicy, error) {
+	policy := defaultRestartPolicy()
+	switch cfg.Policy {
+	case "":
+	case RestartNever, RestartOnFailure, RestartAlways:
+		policy.Policy = cfg.Policy
+	default:
+		return RestartPolicy{}, fmt.Errorf("restart.policy: unknown policy %q (want never, on-failure or always)", cfg.Policy)
+	}
+	if cfg.MaxRetries < 0 {
+		return RestartPolicy{}, fmt.Errorf("restart.maxRetries must not be negative")
+	}
+	if cfg.MaxRetries > 0 {
+		policy.MaxRetries = cfg.MaxRetries
+	}
+	if cfg.Backoff != "" {
+		backoff, err := time.ParseDuration(cfg.Backoff)
+		if err != nil {
+			return RestartPolicy{}, fmt.Errorf("restart.backoff: %w", err)
+		}
+		if backoff < 0 {
+			return RestartPolicy{}, fmt.Errorf("restart.backoff must not be negative")
+		}
+		policy.Backoff = backoff
+	}
+	policy.ResumePrompt = cfg.ResumePrompt
+	return policy, nil
+}
+
+// Enabled - エージェントの終了を監視する必要があるか
+func (p RestartPolicy) Enabled() bool {
+	return p.Policy == RestartOnFailure || p.Policy == RestartAlways
+}
+
+// Allows - 終了したエージェントを再起動するか
+// completed はエージェントがマーカーファイルを書いてから終了したかどうか
+func (p RestartPolicy) Allows(completed bool) bool {
+	switch p.Policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return !completed
+	}
+	return false
+}
+
+// Delay - restarts 回再起動したエージェントを次に再起動するまでの待ち時間
+func (p RestartPolicy) Delay(restarts int) time.Duration {
+	delay := p.Backoff
+	for i := 0; i < restarts && delay < maxRestartBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRestartBackoff)
+}
@@ -0,0 +1,69 @@
+package status
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devflowinc/uzi/pkg/config"
+)
+
+// TestRestartProfile - プロファイルごとの再起動のポリシー
+func TestRestartProfile(t *testing.T) {
+	rules, err := CompileRules(map[string]config.StatusProfile{
+		"claude": {Restart: config.RestartConfig{
+			Policy:       RestartOnFailure,
+			MaxRetries:   5,
+			Backoff:      "1m",
+			ResumePrompt: "Continue where you left off",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("CompileRules() error = %v", err)
+	}
+
+	claude := rules.ProfileFor("claude --model opus").Restart
+	if !claude.Enabled() || claude.MaxRetries != 5 || claude.ResumePrompt != "Continue where you left off" {
+		t.Errorf("claude restart = %+v", claude)
+	}
+	if !claude.Allows(false) || claude.Allows(true) {
+		t.Error("on-failure should restart only agents that did not complete")
+	}
+	if got := claude.Delay(0); got != time.Minute {
+		t.Errorf("Delay(0) = %v, want 1m", got)
+	}
+	if got := claude.Delay(2); got != 4*time.Minute {
+		t.Errorf("Delay(2) = %v, want 4m", got)
+	}
+	if got := claude.Delay(20); got != maxRestartBackoff {
+		t.Errorf("Delay(20) = %v, want the cap", got)
+	}
+
+	// 設定しなければ再起動しない
+	codex := rules.ProfileFor("codex").Restart
+	if codex.Enabled() || codex.Allows(false) || codex.MaxRetries != DefaultMaxRestarts || codex.Backoff != DefaultRestartBackoff {
+		t.Errorf("default restart = %+v", codex)
+	}
+	if always := (RestartPolicy{Policy: RestartAlways}); !always.Allows(true) {
+		t.Error("always should restart completed agents")
+	}
+}
+
+// TestRestartConfigErrors - 不正な再起動の設定
+func TestRestartConfigErrors(t *testing.T) {
+	tests := []struct {
+		restart config.RestartConfig
+		want    string
+	}{
+		{config.RestartConfig{Policy: "sometimes"}, "unknown policy"},
+		{config.RestartConfig{MaxRetries: -1}, "maxRetries"},
+		{config.RestartConfig{Backoff: "soon"}, "restart.backoff"},
+		{config.RestartConfig{Backoff: "-1s"}, "restart.backoff"},
+	}
+	for _, tt := range tests {
+		_, err := CompileRules(map[string]config.StatusProfile{"claude": {Restart: tt.restart}})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("CompileRules(%+v) error = %v, want %q", tt.restart, err, tt.want)
+		}
+	}
+}
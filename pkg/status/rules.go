@@ -0,0 +1,315 @@
+package status
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/devflowinc/uzi/pkg/config"
+
+	"github.com/charmbracelet/log"
+)
+
+// DefaultProfile - 専用プロファイルがないコマンドに使うプロファイル名
+const DefaultProfile = "default"
+
+// ruleStatuses - 画面ルールが返せるステータス
+// merged とマーカーファイルによる ready は状態ファイルから判定する
+var ruleStatuses = map[string]bool{
+	StatusIdle:    true,
+	StatusRunning: true,
+	StatusReady:   true,
+	StatusError:   true,
+	StatusWaiting: true,
+}
+
+// defaultProfiles - uzi.yaml にルールがないときの組み込みルール
+// 以前のハードコード判定と同じ文字列を使うが、エラーは行頭の "Error:" のみ、
+// かつ画面末尾の数行だけを見る。実行中の表示はエラーより優先する。
+// 許可を求める確認（uzi auto が Enter を押していたもの）は実行中の表示より優先し、
+// 末尾が "?" の行はどのルールにも当たらないときだけ質問とみなす。
+// エラーは分類し、ツールやテストの失敗（⎿ Error: や FAIL の下の Error:）では error にしない。
+// API エラーはエージェントの UI が出す行頭（⎿ の直後）のものだけ、クラッシュはシェルや
+// ランタイムが行頭から書くものだけを見る。ツールの出力の中の同じ文字列は tool か build になる。
+var defaultProfiles = map[string]config.StatusProfile{
+	DefaultProfile: {
+		Rules: []config.StatusRule{
+			{Name: "permission", Pattern: `Do you want to .*\?`, Status: StatusWaiting, Priority: 30, Lines: 20},
+			{Name: "trust", Pattern: `Do you trust the files in this folder\?`, Status: StatusWaiting, Priority: 30, Lines: 20},
+			{Name: "allow-command", Pattern: `^\W*Allow command`, Status: StatusWaiting, Priority: 30, Lines: 20},
+			{Name: "confirm", Pattern: `Press Enter to continue|Continue\? \(Y/n\)|Proceed\? \(y/N\)`, Status: StatusWaiting, Priority: 30, Lines: 20},
+			{Name: "interrupt-hint", Pattern: `esc to interrupt`, Status: StatusRunning, Priority: 20},
+			{Name: "thinking", Pattern: `Thinking`, Status: StatusRunning, Priority: 20},
+			{Name: "api-error", Pattern: `^\s*(?:⎿\s*)?(?:API Error|Rate limit reached|Invalid API key|Please run /login|Credit balance is too low)`, Status: StatusError, Priority: 15, Lines: 10, Category: ErrorAPI},
+			{Name: "crash", Pattern: `^(?:panic: |Traceback \(most recent call last\)|Segmentation fault|FATAL ERROR|\S+: (?:\S+: )?command not found|\S+: (?i:segmentation fault))`, Status: StatusError, Priority: 15, Lines: 10, Category: ErrorCrash},
+			{Name: "error-line", Pattern: `^[\s⎿]*(?:Error|error):`, Status: StatusError, Priority: 10, Lines: 10},
+			{Name: "question", Pattern: `\?[\s│]*$`, Status: StatusWaiting, Priority: 5, Lines: 8},
+		},
+	},
+}
+
+// Rule - コンパイル済みの画面ルール
+type Rule struct {
+	Name     string
+	Pattern  *regexp.Regexp
+	Status   string
+	Priority int
+	// Lines - 画面末尾の何行を見るか（0は画面全体）
+	Lines int
+	// Category - error のルールが見つけたエラーの分類（空なら行から判定する）
+	Category string
+}
+
+// Profile - 1つのエージェントコマンド用のルール一式（評価順に並んでいる）
+type Profile struct {
+	Name    string
+	Rules   []Rule
+	Stuck   StuckPolicy
+	Restart RestartPolicy
+	Nudge   NudgePolicy
+}
+
+// Rules - プロファイル名からルールを引く
+type Rules struct {
+	profiles map[string]*Profile
+}
+
+// RuleResult - 1つのルールを画面に当てた結果
+type RuleResult struct {
+	Rule    *Rule
+	Matched bool
+	// Line - マッチした行とその画面上の行番号（1始まり）
+	Line       string
+	LineNumber int
+	// Scanned - ルールが見た行数
+	Scanned int
+	// Error - error のルールがマッチした場合のエラーの分類
+	Error *ErrorClass
+}
+
+// Decides - ルールがステータスを決めるか
+// error のルールはツールやテストの失敗にしかマッチしなければ次のルールに進む
+func (r RuleResult) Decides() bool {
+	return r.Matched && (r.Error == nil || r.Error.Fatal())
+}
+
+// DefaultRules - 組み込みルールを返す
+func DefaultRules() *Rules {
+	rules, err := CompileRules(nil)
+	if err != nil {
+		panic(err)
+	}
+	return rules
+}
+
+// CompileRules - uzi.yaml の statusRules をコンパイルする
+// 設定にないプロファイルは組み込みルールを使う。同名のプロファイルは置き換える。
+func CompileRules(profiles map[string]config.StatusProfile) (*Rules, error) {
+	merged := make(map[string]config.StatusProfile, len(defaultProfiles)+len(profiles))
+	for name, profile := range defaultProfiles {
+		merged[name] = profile
+	}
+	for name, profile := range profiles {
+		merged[name] = profile
+	}
+
+	rules := &Rules{profiles: make(map[string]*Profile, len(merged))}
+	for name, profile := range merged {
+		// ルールのないプロファイルは default のルールを使う（stuck の設定だけを変える場合）
+		if len(profile.Rules) == 0 && name != DefaultProfile {
+			profile.Rules = merged[DefaultProfile].Rules
+		}
+		compiled, err := compileProfile(name, profile)
+		if err != nil {
+			return nil, err
+		}
+		rules.profiles[name] = compiled
+	}
+	return rules, nil
+}
+
+func compileProfile(name string, profile config.StatusProfile) (*Profile, error) {
+	if profile.Lines < 0 {
+		return nil, fmt.Errorf("status profile %q: lines must not be negative", name)
+	}
+
+	stuck, err := compileStuck(profile.Stuck)
+	if err != nil {
+		return nil, fmt.Errorf("status profile %q: %w", name, err)
+	}
+
+	restart, err := compileRestart(profile.Restart)
+	if err != nil {
+		return nil, fmt.Errorf("status profile %q: %w", name, err)
+	}
+
+	nudge, err := compileNudge(profile.Nudge)
+	if err != nil {
+		return nil, fmt.Errorf("status profile %q: %w", name, err)
+	}
+
+	compiled := &Profile{Name: name, Stuck: stuck, Restart: restart, Nudge: nudge}
+	for i, rule := range profile.Rules {
+		ruleName := rule.Name
+		if ruleName == "" {
+			ruleName = fmt.Sprintf("rule %d", i+1)
+		}
+		if !ruleStatuses[rule.Status] {
+			return nil, fmt.Errorf("status profile %q, %s: unknown status %q (want idle, running, ready, waiting or error)", name, ruleName, rule.Status)
+		}
+		if rule.Pattern == "" {
+			return nil, fmt.Errorf("status profile %q, %s: pattern is required", name, ruleName)
+		}
+		// 行単位で評価するので ^ と $ は各行の先頭と末尾にマッチする
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("status profile %q, %s: invalid pattern: %w", name, ruleName, err)
+		}
+		if rule.Lines < 0 {
+			return nil, fmt.Errorf("status profile %q, %s: lines must not be negative", name, ruleName)
+		}
+		if rule.Category != "" {
+			if rule.Status != StatusError {
+				return nil, fmt.Errorf("status profile %q, %s: category is only allowed with status error", name, ruleName)
+			}
+			if !slices.Contains(ErrorCategories, rule.Category) {
+				return nil, fmt.Errorf("status profile %q, %s: unknown category %q (want %s)", name, ruleName, rule.Category, strings.Join(ErrorCategories, ", "))
+			}
+		}
+		lines := rule.Lines
+		if lines == 0 {
+			lines = profile.Lines
+		}
+		compiled.Rules = append(compiled.Rules, Rule{
+			Name:     ruleName,
+			Pattern:  pattern,
+			Status:   rule.Status,
+			Priority: rule.Priority,
+			Lines:    lines,
+			Category: rule.Category,
+		})
+	}
+
+	// 優先度の高い順、同じ優先度なら記述順
+	sort.SliceStable(compiled.Rules, func(i, j int) bool {
+		return compiled.Rules[i].Priority > compiled.Rules[j].Priority
+	})
+	return compiled, nil
+}
+
+// ProfileName - エージェントのコマンドからプロファイル名を決める
+// "claude --model opus" や "/usr/local/bin/claude" は "claude" になる
+func ProfileName(model string) string {
+	fields := strings.Fields(model)
+	if len(fields) == 0 {
+		return ""
+	}
+	return filepath.Base(fields[0])
+}
+
+// ProfileFor - コマンドに対応するプロファイルを返す
+// 専用のプロファイルがなければ default を使う
+func (r *Rules) ProfileFor(model string) *Profile {
+	if profile, ok := r.profiles[ProfileName(model)]; ok {
+		return profile
+	}
+	if profile, ok := r.profiles[DefaultProfile]; ok {
+		return profile
+	}
+	return &Profile{Name: DefaultProfile, Stuck: defaultStuckPolicy(), Restart: defaultRestartPolicy(), Nudge: defaultNudgePolicy()}
+}
+
+// Explain - すべてのルールを評価順に画面へ当てた結果を返す
+func (p *Profile) Explain(screen string) []RuleResult {
+	lines := screenLines(screen)
+	results := make([]RuleResult, len(p.Rules))
+	for i := range p.Rules {
+		results[i] = p.Rules[i].apply(lines)
+	}
+	return results
+}
+
+// Match - 最初にステータスを決めたルールを返す
+func (p *Profile) Match(screen string) (RuleResult, bool) {
+	lines := screenLines(screen)
+	for i := range p.Rules {
+		if result := p.Rules[i].apply(lines); result.Decides() {
+			return result, true
+		}
+	}
+	return RuleResult{}, false
+}
+
+func (r *Rule) apply(lines []string) RuleResult {
+	start := 0
+	if r.Lines > 0 && len(lines) > r.Lines {
+		start = len(lines) - r.Lines
+	}
+	result := RuleResult{Rule: r, Scanned: len(lines) - start}
+	for i := start; i < len(lines); i++ {
+		if !r.Pattern.MatchString(lines[i]) {
+			continue
+		}
+		result.Matched = true
+		result.Line = lines[i]
+		result.LineNumber = i + 1
+		if r.Status != StatusError {
+			break
+		}
+		// ツールやテストの失敗なら、その下に致命的なエラーがないか探し続ける
+		class := classifyError(r.Category, lines, i)
+		result.Error = &class
+		if class.Fatal() {
+			break
+		}
+	}
+	return result
+}
+
+// screenLines - 画面を行に分け、末尾の空行を取り除く
+// tmux は画面の高さぶんの空行を出力するので、「末尾N行」は内容のある行で数える
+func screenLines(screen string) []string {
+	lines := strings.Split(strings.ReplaceAll(screen, "\r\n", "\n"), "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// LoadRules - 設定ファイルのルールを読み込む
+// ファイルがなければ組み込みルールを返す
+func LoadRules(configPath string) (*Rules, error) {
+	cfg, err := config.LoadConfig(configPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return DefaultRules(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", configPath, err)
+	}
+	return CompileRules(cfg.StatusRules)
+}
+
+var (
+	configuredOnce  sync.Once
+	configuredRules *Rules
+)
+
+// ConfiguredRules - カレントディレクトリの uzi.yaml のルールを返す（プロセス内でキャッシュ）
+// 設定が不正な場合は警告を出して組み込みルールを使う
+func ConfiguredRules() *Rules {
+	configuredOnce.Do(func() {
+		rules, err := LoadRules(config.GetDefaultConfigPath())
+		if err != nil {
+			log.Warn("Invalid statusRules in uzi.yaml, using built-in rules", "error", err)
+			rules = DefaultRules()
+		}
+		configuredRules = rules
+	})
+	return configuredRules
+}
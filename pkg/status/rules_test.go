@@ -0,0 +1,210 @@
+package status
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/devflowinc/uzi/pkg/config"
+)
+
+// fakeStateManager - GetWorktreeInfoが固定の状態を返すStateManager
+type fakeStateManager struct {
+	states map[string]*AgentState
+}
+
+func (f *fakeStateManager) GetWorktreeInfo(sessionName string) (*AgentState, error) {
+	if state, ok := f.states[sessionName]; ok {
+		return state, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+func (f *fakeStateManager) MarkAsMerged(sessionName string) error {
+	return nil
+}
+
+// TestDefaultRules - 組み込みルールの判定
+func TestDefaultRules(t *testing.T) {
+	profile := DefaultRules().ProfileFor("claude")
+	tests := []struct {
+		name   string
+		screen string
+		want   string
+	}{
+		{"実行中の表示", "✻ Working… (12s · esc to interrupt)\n\n\n", StatusRunning},
+		{"Thinking", "Thinking about the solution...", StatusRunning},
+		{"行頭のエラー", "$ ./deploy.sh\nError: connection refused\n$ ", StatusError},
+		{"ビルドの失敗はエラーではない", "$ go build\nError: cannot find package\n$ ", ""},
+		{"テストの失敗はエラーではない", "--- FAIL: TestParse (0.00s)\nError: expected 3, got 4\n> ", ""},
+		{"ツールの失敗はエラーではない", "● Read(missing.go)\n  ⎿  Error: File does not exist.\n> ", ""},
+		{"テストの失敗の下のAPIエラー", "FAIL\nError: boom\n  ⎿  API Error: 529 {\"type\":\"overloaded_error\"}\n", StatusError},
+		{"クラッシュ", "panic: runtime error: index out of range\n\ngoroutine 1 [running]:\n$ ", StatusError},
+		{"見つからないコマンドはクラッシュ", "bash: claude: command not found\n$ ", StatusError},
+		{"UIのAPIエラー", "> fix the bug\n  ⎿  API Error: 429 Rate limit reached\n", StatusError},
+		{"前のツールの出力の下のAPIエラー", "● Bash(ls)\n  ⎿  main.go\n     go.mod\n  ⎿  API Error: 529 overloaded\n", StatusError},
+		{"ツールの出力のトレースバック", "● Bash(python app.py)\n  ⎿  Traceback (most recent call last):\n       File \"app.py\", line 3\n     ZeroDivisionError: division by zero\n", ""},
+		{"ツールの出力のcommand not found", "● Bash(jq . x.json)\n  ⎿  Error: bash: jq: command not found\n", ""},
+		{"ツールの出力のAPI Error", "● Bash(cat client.log)\n  ⎿  API Error: 500 upstream\n     Rate limit reached\n", ""},
+		{"ツールの出力のソースのAPI Error", "● Read(src/client.ts)\n  ⎿  throw new Error(\"API Error\")\n     Invalid API key\n", ""},
+		{"折り返した見出しのツールの出力", "● Bash(go run ./cmd/server --config\n      dev.yaml)\n  ⎿  panic: nil map\n     Segmentation fault\n", ""},
+		{"ツールの出力のテストの失敗", "● Bash(go test ./...)\n  ⎿  --- FAIL: TestX (0.00s)\n     FATAL ERROR: x\n", ""},
+		{"実行中はエラーより優先", "Error: failed\nThinking...", StatusRunning},
+		{"文中のerror:はエラーではない", "I fixed the error: nil pointer in main.go\n> ", ""},
+		{"古いエラーは見ない", "Error: old\n" + strings.Repeat("line\n", 12), ""},
+		{"許可の確認", "│ Bash command │\n│ Do you want to proceed? │\n│ ❯ 1. Yes │\n│   2. No │\n", StatusWaiting},
+		{"確認は実行中の表示より優先", "✻ Working… (esc to interrupt)\nDo you want to make this edit to main.go?\n❯ 1. Yes\n", StatusWaiting},
+		{"末尾が?の行は質問", "Should I also update the tests?\n\n> ", StatusWaiting},
+		{"文中の?は質問ではない", "Fixed the nil check (was it ever set?) in main.go\n> ", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := profile.Match(tt.screen)
+			got := ""
+			if ok {
+				got = result.Rule.Status
+			}
+			if got != tt.want {
+				t.Errorf("Match() = %q (rule %+v), want %q", got, result.Rule, tt.want)
+			}
+		})
+	}
+}
+
+// TestCompileRules - プロファイルの選択、優先度と行数の指定
+func TestCompileRules(t *testing.T) {
+	rules, err := CompileRules(map[string]config.StatusProfile{
+		"aider": {
+			Lines: 3,
+			Rules: []config.StatusRule{
+				{Name: "prompt", Pattern: `^> $`, Status: StatusIdle},
+				{Name: "waiting", Pattern: `Waiting for`, Status: StatusRunning, Priority: 5},
+				{Name: "traceback", Pattern: `^Traceback`, Status: StatusError, Priority: 5, Lines: 10},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CompileRules() error = %v", err)
+	}
+
+	aider := rules.ProfileFor("/usr/bin/aider --model sonnet")
+	if aider.Name != "aider" {
+		t.Fatalf("ProfileFor() = %q, want aider", aider.Name)
+	}
+	// 優先度の高い順、同じ優先度なら記述順
+	var order []string
+	for _, rule := range aider.Rules {
+		order = append(order, rule.Name)
+	}
+	if strings.Join(order, ",") != "waiting,traceback,prompt" {
+		t.Errorf("rule order = %v", order)
+	}
+
+	screen := "Traceback (most recent call last):\n  x\n  y\n  z\nWaiting for sonnet\n> \n\n"
+	result, ok := aider.Match(screen)
+	if !ok || result.Rule.Name != "waiting" || result.LineNumber != 5 {
+		t.Errorf("Match() = %+v", result)
+	}
+
+	results := aider.Explain(screen)
+	if !results[1].Matched || results[1].Scanned != 6 {
+		t.Errorf("traceback result = %+v, want a match within 10 lines", results[1])
+	}
+	if !results[2].Matched || results[2].Scanned != 3 {
+		t.Errorf("prompt result = %+v, want a match within the profile's 3 lines", results[2])
+	}
+
+	// 専用プロファイルがないコマンドは default
+	if got := rules.ProfileFor("codex").Name; got != DefaultProfile {
+		t.Errorf("ProfileFor(codex) = %q", got)
+	}
+}
+
+// TestCompileRulesErrors - 不正なルールはエラー
+func TestCompileRulesErrors(t *testing.T) {
+	tests := map[string]config.StatusRule{
+		"unknown status":   {Pattern: "x", Status: "sleeping"},
+		"merged status":    {Pattern: "x", Status: StatusMerged},
+		"missing pattern":  {Status: StatusIdle},
+		"invalid pattern":  {Pattern: "(", Status: StatusIdle},
+		"negative lines":   {Pattern: "x", Status: StatusIdle, Lines: -1},
+		"unknown category": {Pattern: "x", Status: StatusError, Category: "disk"},
+		"category on idle": {Pattern: "x", Status: StatusIdle, Category: ErrorAPI},
+	}
+	for name, rule := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := CompileRules(map[string]config.StatusProfile{"x": {Rules: []config.StatusRule{rule}}})
+			if err == nil {
+				t.Error("CompileRules() succeeded")
+			}
+		})
+	}
+}
+
+// TestLoadRules - uzi.yamlからの読み込み
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "uzi.yaml")
+
+	rules, err := LoadRules(path)
+	if err != nil || rules.ProfileFor("claude").Name != DefaultProfile {
+		t.Fatalf("LoadRules() without a file = %v, %v", rules, err)
+	}
+
+	os.WriteFile(path, []byte(`
+devCommand: npm run dev
+statusRules:
+  default:
+    rules:
+      - name: spinner
+        pattern: 'Working'
+        status: running
+`), 0644)
+	rules, err = LoadRules(path)
+	if err != nil {
+		t.Fatalf("LoadRules() error = %v", err)
+	}
+	if _, ok := rules.ProfileFor("claude").Match("esc to interrupt"); ok {
+		t.Error("a configured default profile should replace the built-in rules")
+	}
+	if _, ok := rules.ProfileFor("claude").Match("Working"); !ok {
+		t.Error("configured rule did not match")
+	}
+
+	os.WriteFile(path, []byte("statusRules:\n  default:\n    rules:\n      - pattern: '['\n        status: idle\n"), 0644)
+	if _, err := LoadRules(path); err == nil {
+		t.Error("LoadRules() with an invalid pattern succeeded")
+	}
+}
+
+// TestGetStatusUsesProfile - GetStatusはエージェントのコマンドのプロファイルを使う
+func TestGetStatusUsesProfile(t *testing.T) {
+	rules, err := CompileRules(map[string]config.StatusProfile{
+		"codex": {Rules: []config.StatusRule{{Pattern: `Working \(`, Status: StatusRunning}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmuxClient := &mockTmuxClient{paneContent: map[string]string{
+		"agent-codex":  "• Working (3s)",
+		"agent-claude": "• Working (3s)",
+		"agent-merged": "$ ",
+	}}
+	states := &fakeStateManager{states: map[string]*AgentState{
+		"agent-codex":  {Model: "codex"},
+		"agent-claude": {Model: "claude"},
+		"agent-merged": {Model: "claude", IsMerged: true},
+	}}
+	sm := NewStatusManagerWithRules(tmuxClient, states, rules)
+
+	for session, want := range map[string]string{
+		"agent-codex":  StatusRunning,
+		"agent-claude": StatusIdle,
+		"agent-merged": StatusMerged,
+	} {
+		if got, _ := sm.GetStatus(session); got != want {
+			t.Errorf("GetStatus(%s) = %s, want %s", session, got, want)
+		}
+	}
+}
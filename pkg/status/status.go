@@ -1,11 +1,13 @@
 package status
 
 import (
+	"context"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strings"
 	"time"
+
+	"github.com/devflowinc/uzi/pkg/runner"
+	"github.com/devflowinc/uzi/pkg/state"
 )
 
 // ステータス定数
@@ -15,6 +17,8 @@ const (
 	StatusReady   = "ready"
 	StatusMerged  = "merged"
 	StatusError   = "error"
+	StatusWaiting = "waiting" // 許可や質問への回答を待っている
+	StatusDead    = "dead" // セッションが終了している（uzi ls -a のみ）
 )
 
 // DetailedStatus - 詳細モード用のステータス情報
@@ -23,6 +27,26 @@ type DetailedStatus struct {
 	Icon        string
 	LastChanged time.Time
 	IsStuck     bool
+	// LastActivity - 調べた活動のシグナルのうち最も新しい時刻
+	LastActivity time.Time
+	// Question - waiting のときに画面から取り出した質問
+	Question *Question
+	// Error - error のときのエラーの分類と抜粋（画面ルールで決まった場合のみ）
+	Error *ErrorClass
+	// Report - マーカーファイルの完了報告（ないか空の場合は nil）
+	Report *state.CompletionReport
+	// ReportErr - マーカーファイルを読めなかったか、報告が不正な場合のエラー
+	ReportErr error
+}
+
+// Icons - ステータスのアイコン
+var Icons = map[string]string{
+	StatusIdle:    "💤",
+	StatusRunning: "🏃",
+	StatusReady:   "✅",
+	StatusMerged:  "🔀",
+	StatusError:   "❌",
+	StatusWaiting: "❓",
 }
 
 // StatusManager - ステータス管理のインターフェース
@@ -37,6 +61,9 @@ type StatusManager interface {
 type statusManager struct {
 	tmuxClient   TmuxClient
 	stateManager StateManager
+	rules        *Rules
+	activity     ActivityProbe
+	now          func() time.Time
 }
 
 // TmuxClient - tmuxとの通信インターフェース
@@ -55,85 +82,136 @@ type AgentState struct {
 	WorktreePath string
 	UpdatedAt    time.Time
 	IsMerged     bool
+	Model        string // 画面ルールのプロファイル選択に使うコマンド
 }
 
 // NewStatusManager - StatusManagerのコンストラクタ
+// 画面の判定には uzi.yaml の statusRules（なければ組み込みルール）を使う
 func NewStatusManager(tmux TmuxClient, state StateManager) StatusManager {
+	return NewStatusManagerWithRules(tmux, state, ConfiguredRules())
+}
+
+// NewStatusManagerWithRules - 判定ルールを指定するコンストラクタ
+func NewStatusManagerWithRules(tmux TmuxClient, state StateManager, rules *Rules) StatusManager {
 	return &statusManager{
 		tmuxClient:   tmux,
 		stateManager: state,
+		rules:        rules,
+		activity:     DefaultActivityProbe(),
+		now:          time.Now,
 	}
 }
 
 // GetStatus - ステータス判定の実装
 func (sm *statusManager) GetStatus(sessionName string) (string, error) {
+	return sm.detect(sessionName).status, nil
+}
+
+// detection - ステータスと判定に使った情報
+type detection struct {
+	status  string
+	screen  string
+	state   *AgentState
+	profile *Profile
+	// match - 判定したルール（ルールで決まらなかった場合は nil）
+	match *RuleResult
+}
+
+// FallbackPriority - この優先度以下のルールはマージ済みとマーカーファイルの判定の後に使う
+// 作業を終えたエージェントの最後の行が "?" で終わっていても ready にするため
+const FallbackPriority = 5
+
+// detect - ステータスを判定する
+func (sm *statusManager) detect(sessionName string) detection {
 	// 優先順位1: tmuxペイン内容を取得
 	paneContent, err := sm.tmuxClient.GetPaneContent(sessionName)
 	if err != nil {
 		// tmuxエラーの場合はerror
-		return StatusError, nil
+		return detection{status: StatusError}
 	}
-	
-	// 優先順位2: エラーメッセージチェック
-	if strings.Contains(paneContent, "Error:") || strings.Contains(paneContent, "error:") {
-		return StatusError, nil
-	}
-	
-	// 優先順位3: 実行中チェック
-	if strings.Contains(paneContent, "esc to interrupt") || strings.Contains(paneContent, "Thinking") {
-		return StatusRunning, nil
+
+	var agent *AgentState
+	if state, err := sm.stateManager.GetWorktreeInfo(sessionName); err == nil {
+		agent = state
 	}
-	
-	// 優先順位4: マージ済みチェック
-	state, err := sm.stateManager.GetWorktreeInfo(sessionName)
-	if err == nil && state.IsMerged {
-		return StatusMerged, nil
+	return classify(sm.rules, paneContent, agent)
+}
+
+// Classify - 読み込み済みの画面とエージェントの状態からステータスを判定する
+// waiting の質問と error の分類も埋めるが、stuck と完了報告は調べない
+func Classify(rules *Rules, screen string, agent *AgentState) DetailedStatus {
+	return classify(rules, screen, agent).detailed()
+}
+
+// classify - 画面と状態からステータスを判定する（agent は nil でもよい）
+func classify(rules *Rules, paneContent string, agent *AgentState) detection {
+	d := detection{screen: paneContent, state: agent}
+
+	// 優先順位2: エージェントのプロファイルの画面ルール（優先度順、最初にマッチしたもの）
+	model := ""
+	if d.state != nil {
+		model = d.state.Model
 	}
-	
-	// 優先順位5: マーカーファイルチェック
-	if err == nil && hasMarkerFile(state.WorktreePath) {
-		return StatusReady, nil
+	d.profile = rules.ProfileFor(model)
+	result, matched := d.profile.Match(paneContent)
+	if matched && result.Rule.Priority > FallbackPriority {
+		d.status = result.Rule.Status
+		d.match = &result
+		return d
 	}
-	
+
+	switch {
+	// 優先順位3: マージ済みチェック
+	case d.state != nil && d.state.IsMerged:
+		d.status = StatusMerged
+	// 優先順位4: マーカーファイルチェック
+	case d.state != nil && hasMarkerFile(d.state.WorktreePath):
+		d.status = StatusReady
+	// 優先順位5: 優先度の低いルール（末尾が "?" の行など）
+	case matched:
+		d.status = result.Rule.Status
+		d.match = &result
 	// デフォルト: idle
-	return StatusIdle, nil
+	default:
+		d.status = StatusIdle
+	}
+	return d
 }
 
 // GetDetailedStatus - 詳細ステータスの実装
+// running のエージェントは、画面・ワークツリー・トランスクリプトのどれも
+// プロファイルの stuck.after の間変化していなければ stuck とする
 func (sm *statusManager) GetDetailedStatus(sessionName string) (DetailedStatus, error) {
-	status, err := sm.GetStatus(sessionName)
-	if err != nil {
-		return DetailedStatus{}, err
+	d := sm.detect(sessionName)
+
+	detailed := d.detailed()
+	detailed.LastChanged = sm.now()
+	if d.state == nil || d.profile == nil {
+		return detailed, nil
 	}
-	
-	// アイコンのマッピング
-	iconMap := map[string]string{
-		StatusIdle:    "💤",
-		StatusRunning: "🏃",
-		StatusReady:   "✅",
-		StatusMerged:  "🔀",
-		StatusError:   "❌",
+	detailed.LastChanged = d.state.UpdatedAt
+	detailed.Report, detailed.ReportErr = ReadReport(d.state.WorktreePath)
+
+	stuck, activity := CheckStuck(sm.activity, d.profile.Stuck, sessionName, d.state.WorktreePath, d.status, d.screen, sm.now())
+	detailed.IsStuck = stuck
+	detailed.LastActivity = activity.Last()
+	return detailed, nil
+}
+
+// detailed - 判定結果から、ステータス・質問・エラーの分類を埋めた詳細ステータスを作る
+func (d detection) detailed() DetailedStatus {
+	detailed := DetailedStatus{
+		Status: d.status,
+		Icon:   Icons[d.status],
 	}
-	
-	// UpdatedAtを取得してstuck判定
-	state, err := sm.stateManager.GetWorktreeInfo(sessionName)
-	lastChanged := time.Now()
-	isStuck := false
-	
-	if err == nil {
-		lastChanged = state.UpdatedAt
-		// 5分以上更新がない場合はstuck
-		if time.Since(state.UpdatedAt) > 5*time.Minute {
-			isStuck = true
-		}
+	if d.status == StatusWaiting && d.match != nil {
+		question := ExtractQuestion(d.screen, d.match.LineNumber)
+		detailed.Question = &question
 	}
-	
-	return DetailedStatus{
-		Status:      status,
-		Icon:        iconMap[status],
-		LastChanged: lastChanged,
-		IsStuck:     isStuck,
-	}, nil
+	if d.status == StatusError && d.match != nil {
+		detailed.Error = d.match.Error
+	}
+	return detailed
 }
 
 // MarkAsMerged - マージ済みとしてマーク
@@ -156,17 +234,12 @@ func (sm *statusManager) ClearMarkerFile(sessionName string) error {
 type defaultTmuxClient struct{}
 
 func (c *defaultTmuxClient) GetPaneContent(sessionName string) (string, error) {
-	cmd := exec.Command("tmux", "capture-pane", "-t", sessionName+":agent", "-p")
-	output, err := cmd.Output()
-	if err != nil {
-		return "", err
-	}
-	return string(output), nil
+	return runner.Default().ReadScreen(context.Background(), sessionName, "agent")
 }
 
 // マーカーファイルのパスを取得
 func getMarkerFilePath(worktreePath string) string {
-	return filepath.Join(worktreePath, ".uzi-task-completed")
+	return filepath.Join(worktreePath, MarkerFile)
 }
 
 // マーカーファイルの存在確認
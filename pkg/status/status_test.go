@@ -181,48 +181,88 @@ func TestStatusTransitions(t *testing.T) {
 
 // TestDetailedStatusWithStuckDetection - 詳細モードのstuck検出テスト
 func TestDetailedStatusWithStuckDetection(t *testing.T) {
+	now := time.Now()
 	tests := []struct {
 		name           string
-		sessionName    string
 		paneContent    string
-		lastUpdate     time.Time
+		screenChanged  time.Time
+		worktree       time.Time
+		transcript     time.Time
 		expectedStatus string
-		expectedIcon   string
 		expectedStuck  bool
 	}{
 		{
-			name:           "5分以内の更新はstuckではない",
-			sessionName:    "agent-1",
-			paneContent:    "Working on task",
-			lastUpdate:     time.Now().Add(-3 * time.Minute),
-			expectedStatus: StatusIdle,
-			expectedIcon:   "💤",
+			name:           "画面が変化していればstuckではない",
+			paneContent:    "Thinking...",
+			screenChanged:  now.Add(-time.Minute),
+			worktree:       now.Add(-time.Hour),
+			transcript:     now.Add(-time.Hour),
+			expectedStatus: StatusRunning,
 			expectedStuck:  false,
 		},
 		{
-			name:           "5分以上更新がない場合はstuck",
-			sessionName:    "agent-2",
-			paneContent:    "Working on task",
-			lastUpdate:     time.Now().Add(-6 * time.Minute),
-			expectedStatus: StatusIdle,
-			expectedIcon:   "💤",
-			expectedStuck:  true,
+			name:           "UpdatedAtが古くてもファイルが更新されていればstuckではない",
+			paneContent:    "Thinking...",
+			screenChanged:  now.Add(-time.Hour),
+			worktree:       now.Add(-2 * time.Minute),
+			transcript:     now.Add(-time.Hour),
+			expectedStatus: StatusRunning,
+			expectedStuck:  false,
 		},
 		{
-			name:           "runningでも5分以上ならstuck",
-			sessionName:    "agent-3",
+			name:           "トランスクリプトが伸びていればstuckではない",
 			paneContent:    "Thinking...",
-			lastUpdate:     time.Now().Add(-10 * time.Minute),
+			screenChanged:  now.Add(-time.Hour),
+			worktree:       now.Add(-time.Hour),
+			transcript:     now.Add(-30 * time.Second),
+			expectedStatus: StatusRunning,
+			expectedStuck:  false,
+		},
+		{
+			name:           "runningで5分以上どのシグナルも変化しなければstuck",
+			paneContent:    "Thinking...",
+			screenChanged:  now.Add(-10 * time.Minute),
+			worktree:       now.Add(-10 * time.Minute),
+			transcript:     now.Add(-6 * time.Minute),
 			expectedStatus: StatusRunning,
-			expectedIcon:   "🏃",
 			expectedStuck:  true,
 		},
+		{
+			name:           "idleは長く止まっていてもstuckではない",
+			paneContent:    "$ ",
+			screenChanged:  now.Add(-time.Hour),
+			worktree:       now.Add(-time.Hour),
+			transcript:     now.Add(-time.Hour),
+			expectedStatus: StatusIdle,
+			expectedStuck:  false,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// このテストも失敗するはず（RED phase）
-			t.Skip("DetailedStatus not implemented yet")
+			sm := NewStatusManagerWithRules(
+				&mockTmuxClient{paneContent: map[string]string{"agent-1": tt.paneContent}},
+				&fakeStateManager{states: map[string]*AgentState{
+					// 作成時から更新されていない
+					"agent-1": {WorktreePath: "/wt", UpdatedAt: now.Add(-time.Hour)},
+				}},
+				DefaultRules(),
+			).(*statusManager)
+			probe := &fakeProbe{screen: tt.screenChanged, worktree: tt.worktree, transcript: tt.transcript}
+			sm.activity = probe
+			sm.now = func() time.Time { return now }
+
+			detailed, err := sm.GetDetailedStatus("agent-1")
+			if err != nil {
+				t.Fatalf("GetDetailedStatus() error = %v", err)
+			}
+			if detailed.Status != tt.expectedStatus || detailed.IsStuck != tt.expectedStuck {
+				t.Errorf("GetDetailedStatus() = %s stuck %v, want %s stuck %v",
+					detailed.Status, detailed.IsStuck, tt.expectedStatus, tt.expectedStuck)
+			}
+			if probe.screenHash == "" {
+				t.Error("the screen was not recorded")
+			}
 		})
 	}
 }
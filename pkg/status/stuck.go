@@ -0,0 +1,222 @@
+package status
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/devflowinc/uzi/pkg/config"
+	"github.com/devflowinc/uzi/pkg/gitdiff"
+	"github.com/devflowinc/uzi/pkg/state"
+	"github.com/devflowinc/uzi/pkg/transcript"
+)
+
+// DefaultStuckAfter - stuck と判定するまでの既定の無活動時間
+const DefaultStuckAfter = 5 * time.Minute
+
+// 活動のシグナル
+const (
+	SignalScreen     = "screen"     // エージェントの画面の内容
+	SignalWorktree   = "worktree"   // ワークツリーのファイルの更新時刻
+	SignalTranscript = "transcript" // トランスクリプトへの書き込み
+)
+
+// allSignals - 既定で使うシグナル
+var allSignals = []string{SignalScreen, SignalWorktree, SignalTranscript}
+
+// StuckPolicy - コンパイル済みの stuck 判定の設定
+type StuckPolicy struct {
+	// After - この時間どのシグナルも変化しなければ stuck（0は判定しない）
+	After   time.Duration
+	Signals []string
+	// Ignore - 画面を比較する前に各行から取り除くパターン
+	Ignore []*regexp.Regexp
+}
+
+func defaultStuckPolicy() StuckPolicy {
+	return StuckPolicy{After: DefaultStuckAfter, Signals: allSignals}
+}
+
+func compileStuck(cfg config.StuckConfig) (StuckPolicy, error) {
+	policy := defaultStuckPolicy()
+	if cfg.After != "" {
+		after, err := time.ParseDuration(cfg.After)
+		if err != nil {
+			return StuckPolicy{}, fmt.Errorf("stuck.after: %w", err)
+		}
+		if after < 0 {
+			return StuckPolicy{}, fmt.Errorf("stuck.after must not be negative")
+		}
+		policy.After = after
+	}
+	if len(cfg.Signals) > 0 {
+		policy.Signals = nil
+		for _, signal := range cfg.Signals {
+			switch signal {
+			case SignalScreen, SignalWorktree, SignalTranscript:
+				policy.Signals = append(policy.Signals, signal)
+			default:
+				return StuckPolicy{}, fmt.Errorf("stuck.signals: unknown signal %q (want screen, worktree or transcript)", signal)
+			}
+		}
+	}
+	for _, pattern := range cfg.Ignore {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return StuckPolicy{}, fmt.Errorf("stuck.ignore: invalid pattern: %w", err)
+		}
+		policy.Ignore = append(policy.Ignore, re)
+	}
+	return policy, nil
+}
+
+// uses - シグナルを使うか
+func (p StuckPolicy) uses(signal string) bool {
+	for _, s := range p.Signals {
+		if s == signal {
+			return true
+		}
+	}
+	return false
+}
+
+// ScreenHash - Ignore のパターンを取り除いた画面のハッシュ
+func (p StuckPolicy) ScreenHash(screen string) string {
+	lines := screenLines(screen)
+	for i, line := range lines {
+		for _, re := range p.Ignore {
+			line = re.ReplaceAllString(line, "")
+		}
+		lines[i] = line
+	}
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// ActivityProbe - 活動のシグナルを調べるインターフェース
+type ActivityProbe interface {
+	// ScreenChanged - 画面のハッシュを記録し、画面が最後に変化した時刻を返す
+	ScreenChanged(sessionName, hash string, now time.Time) (time.Time, error)
+	// WorktreeModified - ワークツリーのファイルが最後に更新された時刻
+	WorktreeModified(worktreePath string) (time.Time, error)
+	// TranscriptModified - トランスクリプトに最後に書き込まれた時刻
+	TranscriptModified(sessionName string) (time.Time, error)
+}
+
+// Activity - 判定に使った活動の時刻（調べなかったシグナルはゼロ値）
+type Activity struct {
+	Screen     time.Time
+	Worktree   time.Time
+	Transcript time.Time
+}
+
+// Last - 最も新しい活動の時刻
+func (a Activity) Last() time.Time {
+	last := a.Screen
+	for _, t := range []time.Time{a.Worktree, a.Transcript} {
+		if t.After(last) {
+			last = t
+		}
+	}
+	return last
+}
+
+// CheckStuck - running のエージェントが policy.After の間まったく活動していないか調べる
+// 画面は毎回記録し、ワークツリーとトランスクリプトは画面が止まっているときだけ調べる
+func CheckStuck(probe ActivityProbe, policy StuckPolicy, sessionName, worktreePath, status, screen string, now time.Time) (bool, Activity) {
+	var activity Activity
+	recent := func(t time.Time) bool {
+		return now.Sub(t) < policy.After
+	}
+
+	if policy.uses(SignalScreen) {
+		if changed, err := probe.ScreenChanged(sessionName, policy.ScreenHash(screen), now); err == nil {
+			activity.Screen = changed
+		}
+	}
+	if status != StatusRunning || policy.After == 0 || recent(activity.Screen) {
+		return false, activity
+	}
+
+	if policy.uses(SignalTranscript) {
+		if modified, err := probe.TranscriptModified(sessionName); err == nil {
+			activity.Transcript = modified
+			if recent(modified) {
+				return false, activity
+			}
+		}
+	}
+	if policy.uses(SignalWorktree) && worktreePath != "" {
+		if modified, err := probe.WorktreeModified(worktreePath); err == nil {
+			activity.Worktree = modified
+			if recent(modified) {
+				return false, activity
+			}
+		}
+	}
+	// どのシグナルも読めなかった場合は判定しない
+	return !activity.Last().IsZero(), activity
+}
+
+// fileActivityProbe - 画面のハッシュをエージェントのディレクトリに保存する ActivityProbe
+// uzi ls は毎回別のプロセスなので、前回の画面はファイルで覚えておく
+type fileActivityProbe struct{}
+
+// DefaultActivityProbe - デフォルトの ActivityProbe を取得
+func DefaultActivityProbe() ActivityProbe {
+	return fileActivityProbe{}
+}
+
+// screenRecord - 保存する画面のハッシュと変化した時刻
+type screenRecord struct {
+	Hash      string    `json:"hash"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+func (fileActivityProbe) ScreenChanged(sessionName, hash string, now time.Time) (time.Time, error) {
+	dir, err := state.AgentDir(sessionName)
+	if err != nil {
+		return time.Time{}, err
+	}
+	path := filepath.Join(dir, "screen.json")
+
+	var record screenRecord
+	if data, err := os.ReadFile(path); err == nil {
+		if json.Unmarshal(data, &record) == nil && record.Hash == hash {
+			return record.ChangedAt, nil
+		}
+	}
+
+	// 初めて見た画面は今変化したものとする
+	record = screenRecord{Hash: hash, ChangedAt: now}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return now, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return now, err
+	}
+	return now, os.WriteFile(path, data, 0644)
+}
+
+func (fileActivityProbe) WorktreeModified(worktreePath string) (time.Time, error) {
+	return gitdiff.LastModified(worktreePath)
+}
+
+func (fileActivityProbe) TranscriptModified(sessionName string) (time.Time, error) {
+	path, err := transcript.Path(sessionName, "agent")
+	if err != nil {
+		return time.Time{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
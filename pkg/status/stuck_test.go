@@ -0,0 +1,119 @@
+package status
+
+import (
+	"testing"
+	"time"
+
+	"github.com/devflowinc/uzi/pkg/config"
+)
+
+// fakeProbe - 固定の時刻を返す ActivityProbe
+type fakeProbe struct {
+	screen, worktree, transcript time.Time
+	screenHash                   string
+	worktreeCalls                int
+}
+
+func (f *fakeProbe) ScreenChanged(sessionName, hash string, now time.Time) (time.Time, error) {
+	f.screenHash = hash
+	return f.screen, nil
+}
+
+func (f *fakeProbe) WorktreeModified(worktreePath string) (time.Time, error) {
+	f.worktreeCalls++
+	return f.worktree, nil
+}
+
+func (f *fakeProbe) TranscriptModified(sessionName string) (time.Time, error) {
+	return f.transcript, nil
+}
+
+// TestStuckProfile - プロファイルごとの閾値、シグナル、無視するパターン
+func TestStuckProfile(t *testing.T) {
+	rules, err := CompileRules(map[string]config.StatusProfile{
+		"claude": {Stuck: config.StuckConfig{
+			After:   "15m",
+			Signals: []string{SignalScreen, SignalWorktree},
+			Ignore:  []string{`\(\d+s`},
+		}},
+		"codex": {Stuck: config.StuckConfig{After: "0"}},
+	})
+	if err != nil {
+		t.Fatalf("CompileRules() error = %v", err)
+	}
+
+	claude := rules.ProfileFor("claude")
+	// ルールのないプロファイルは default のルールを使う
+	if _, ok := claude.Match("esc to interrupt"); !ok {
+		t.Error("profile without rules should use the default rules")
+	}
+	if claude.Stuck.ScreenHash("✻ Working (12s)") != claude.Stuck.ScreenHash("✻ Working (13s)") {
+		t.Error("ignored patterns should not change the screen hash")
+	}
+	if rules.ProfileFor("aider").Stuck.After != DefaultStuckAfter {
+		t.Errorf("default stuck.after = %v", rules.ProfileFor("aider").Stuck.After)
+	}
+
+	now := time.Now()
+	probe := &fakeProbe{
+		screen:     now.Add(-20 * time.Minute),
+		worktree:   now.Add(-20 * time.Minute),
+		transcript: now, // claude のプロファイルではトランスクリプトを見ない
+	}
+	stuck, activity := CheckStuck(probe, claude.Stuck, "s", "/wt", StatusRunning, "x", now)
+	if !stuck || !activity.Transcript.IsZero() {
+		t.Errorf("CheckStuck() = %v, %+v; want stuck without reading the transcript", stuck, activity)
+	}
+	probe.worktree = now.Add(-10 * time.Minute)
+	if stuck, _ := CheckStuck(probe, claude.Stuck, "s", "/wt", StatusRunning, "x", now); stuck {
+		t.Error("an edit within 15m should not be stuck")
+	}
+
+	if stuck, _ := CheckStuck(probe, rules.ProfileFor("codex").Stuck, "s", "/wt", StatusRunning, "x", now); stuck {
+		t.Error("stuck.after 0 should turn detection off")
+	}
+
+	// 画面が変化していればワークツリーは調べない
+	probe = &fakeProbe{screen: now}
+	CheckStuck(probe, claude.Stuck, "s", "/wt", StatusRunning, "x", now)
+	if probe.worktreeCalls != 0 {
+		t.Error("the worktree was walked although the screen changed")
+	}
+
+	for name, stuck := range map[string]config.StuckConfig{
+		"invalid after":   {After: "soon"},
+		"negative after":  {After: "-1m"},
+		"unknown signal":  {Signals: []string{"cpu"}},
+		"invalid pattern": {Ignore: []string{"("}},
+	} {
+		if _, err := CompileRules(map[string]config.StatusProfile{"x": {Stuck: stuck}}); err == nil {
+			t.Errorf("%s: CompileRules() succeeded", name)
+		}
+	}
+}
+
+// TestFileActivityProbe - 画面のハッシュはプロセスをまたいで保存される
+func TestFileActivityProbe(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	probe := DefaultActivityProbe()
+	start := time.Now()
+
+	for i, step := range []struct {
+		hash string
+		want time.Time
+	}{
+		{"a", start},
+		{"a", start},
+		{"b", start.Add(2 * time.Minute)},
+		{"b", start.Add(2 * time.Minute)},
+	} {
+		now := start.Add(time.Duration(i) * time.Minute)
+		got, err := probe.ScreenChanged("agent-p-1-a", step.hash, now)
+		if err != nil {
+			t.Fatalf("ScreenChanged() error = %v", err)
+		}
+		if !got.Equal(step.want) {
+			t.Errorf("step %d: ScreenChanged() = %v, want %v", i, got, step.want)
+		}
+	}
+}
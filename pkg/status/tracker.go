@@ -0,0 +1,195 @@
+package status
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/devflowinc/uzi/pkg/state"
+)
+
+// MaxTransitions - 状態ファイルに残す遷移の数
+const MaxTransitions = 50
+
+// transitions - 許可する遷移
+// 基本は idle → running → ready → merged。running と idle は行き来し、
+// ready や merged のエージェントに追加の指示を出すと running に戻る。
+// waiting は idle か running から入り、回答すると running に戻る（取り消すと idle）。
+// error にはどこからでも入り、idle か running で抜ける。
+var transitions = map[string][]string{
+	StatusIdle:    {StatusRunning, StatusWaiting, StatusError},
+	StatusRunning: {StatusIdle, StatusReady, StatusWaiting, StatusError},
+	StatusWaiting: {StatusRunning, StatusIdle, StatusError},
+	StatusReady:   {StatusRunning, StatusMerged, StatusError},
+	StatusMerged:  {StatusRunning, StatusError},
+	StatusError:   {StatusIdle, StatusRunning},
+}
+
+// ValidTransition - from から to へ直接遷移できるか
+func ValidTransition(from, to string) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transitionPath - from から to への最短の遷移列（from は含まない）
+// 観測の間隔より速く状態が変わった場合（idle → ready など）の途中の遷移を補うのに使う
+func transitionPath(from, to string) []string {
+	previous := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == to {
+			var path []string
+			for st := to; st != from; st = previous[st] {
+				path = append([]string{st}, path...)
+			}
+			return path
+		}
+		for _, next := range transitions[current] {
+			if _, seen := previous[next]; !seen {
+				previous[next] = current
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
+// Track - 観測したステータスを記録に反映する
+// 記録が変わった場合は新しい記録と true を返す。history は変更しない。
+// 許可されていない遷移は最短の正しい遷移列に置き換え、途中の遷移は Inferred とする。
+func Track(history *state.StatusHistory, observed string, now time.Time) (state.StatusHistory, bool) {
+	if _, ok := transitions[observed]; !ok {
+		// dead や unknown は記録しない
+		if history == nil {
+			return state.StatusHistory{}, false
+		}
+		return *history, false
+	}
+	if history == nil || history.Current == "" {
+		return state.StatusHistory{Current: observed, Since: now}, true
+	}
+	if history.Current == observed {
+		return *history, false
+	}
+
+	next := state.StatusHistory{
+		Current:     history.Current,
+		Since:       history.Since,
+		Seconds:     make(map[string]float64, len(history.Seconds)+1),
+		Flips:       history.Flips,
+		Transitions: append([]state.StatusTransition(nil), history.Transitions...),
+	}
+	for st, seconds := range history.Seconds {
+		next.Seconds[st] = seconds
+	}
+	if now.After(next.Since) {
+		next.Seconds[next.Current] += now.Sub(next.Since).Seconds()
+	}
+
+	path := transitionPath(next.Current, observed)
+	if path == nil {
+		// 記録が壊れている（未知のステータス）場合はそのまま置き換える
+		path = []string{observed}
+	}
+	for i, st := range path {
+		inferred := i < len(path)-1
+		if len(path) == 1 && isFlip(next.Current, st) {
+			next.Flips++
+		}
+		next.Transitions = append(next.Transitions, state.StatusTransition{
+			From:     next.Current,
+			To:       st,
+			At:       now,
+			Inferred: inferred,
+		})
+		next.Current = st
+	}
+	next.Since = now
+
+	if len(next.Transitions) > MaxTransitions {
+		next.Transitions = next.Transitions[len(next.Transitions)-MaxTransitions:]
+	}
+	return next, true
+}
+
+// HistoryStore - ステータス記録の保存先（*state.StateManager）
+type HistoryStore interface {
+	UpdateStatusHistories(update func(sessionName string, history *state.StatusHistory) (state.StatusHistory, bool)) error
+}
+
+// RecordStatuses - 観測したステータスを保存先の記録に反映する
+// Track は保存先がロック中に読み込んだ最新の記録に対して行うので、ls・ui・auto が
+// 同時に記録しても互いの遷移を上書きせず、同じ遷移を記録するのは一つの呼び出しだけになる。
+// observed にあるセッションの記録と、この呼び出しが記録を変えたセッションを返す
+func RecordStatuses(store HistoryStore, observed map[string]string, now time.Time) (map[string]state.StatusHistory, map[string]bool, error) {
+	histories := make(map[string]state.StatusHistory, len(observed))
+	changed := make(map[string]bool)
+	if len(observed) == 0 {
+		return histories, changed, nil
+	}
+	err := store.UpdateStatusHistories(func(sessionName string, history *state.StatusHistory) (state.StatusHistory, bool) {
+		st, ok := observed[sessionName]
+		if !ok {
+			return state.StatusHistory{}, false
+		}
+		next, ok := Track(history, st, now)
+		histories[sessionName] = next
+		if ok {
+			changed[sessionName] = true
+		}
+		return next, ok
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return histories, changed, nil
+}
+
+func isFlip(from, to string) bool {
+	return (from == StatusRunning && to == StatusIdle) || (from == StatusIdle && to == StatusRunning)
+}
+
+// TimeInStatus - status で過ごした累計時間（現在の区間を含む）
+func TimeInStatus(history *state.StatusHistory, status string, now time.Time) time.Duration {
+	if history == nil {
+		return 0
+	}
+	total := time.Duration(history.Seconds[status] * float64(time.Second))
+	if history.Current == status && now.After(history.Since) {
+		total += now.Sub(history.Since)
+	}
+	return total
+}
+
+// DescribeDuration - 現在のステータスの継続時間を "running for 12m" や "ready since 3m" の形にする
+func DescribeDuration(status string, d time.Duration) string {
+	if status == StatusRunning || status == StatusWaiting || status == StatusError {
+		return fmt.Sprintf("%s for %s", status, FormatDuration(d))
+	}
+	return fmt.Sprintf("%s since %s", status, FormatDuration(d))
+}
+
+// FormatDuration - 経過時間を "45s" "12m" "3h5m" "2d4h" のように短く表す
+func FormatDuration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		if m := int(d.Minutes()) % 60; m != 0 {
+			return fmt.Sprintf("%dh%dm", int(d.Hours()), m)
+		}
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		if h := int(d.Hours()) % 24; h != 0 {
+			return fmt.Sprintf("%dd%dh", int(d.Hours())/24, h)
+		}
+		return fmt.Sprintf("%dd", int(d.Hours())/24)
+	}
+}
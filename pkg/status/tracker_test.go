@@ -0,0 +1,115 @@
+package status
+
+import (
+	"testing"
+	"time"
+
+	"github.com/devflowinc/uzi/pkg/state"
+)
+
+// TestTrack - 遷移の記録と継続時間
+func TestTrack(t *testing.T) {
+	start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	history, changed := Track(nil, StatusIdle, start)
+	if !changed || history.Current != StatusIdle || !history.Since.Equal(start) {
+		t.Fatalf("first observation = %+v, %v", history, changed)
+	}
+	if _, changed := Track(&history, StatusIdle, start.Add(time.Minute)); changed {
+		t.Error("the same status should not change the history")
+	}
+
+	history, _ = Track(&history, StatusRunning, start.Add(2*time.Minute))
+	history, _ = Track(&history, StatusIdle, start.Add(5*time.Minute))
+	history, _ = Track(&history, StatusRunning, start.Add(6*time.Minute))
+	history, _ = Track(&history, StatusReady, start.Add(16*time.Minute))
+
+	if history.Current != StatusReady || !history.Since.Equal(start.Add(16*time.Minute)) {
+		t.Errorf("current = %s since %v", history.Current, history.Since)
+	}
+	if history.Flips != 3 {
+		t.Errorf("flips = %d, want 3", history.Flips)
+	}
+	if got := TimeInStatus(&history, StatusRunning, start.Add(20*time.Minute)); got != 13*time.Minute {
+		t.Errorf("running time = %v, want 13m", got)
+	}
+	if got := TimeInStatus(&history, StatusReady, start.Add(20*time.Minute)); got != 4*time.Minute {
+		t.Errorf("ready time = %v, want 4m", got)
+	}
+	if len(history.Transitions) != 4 {
+		t.Errorf("transitions = %+v", history.Transitions)
+	}
+}
+
+// TestTrackInfersSkippedTransitions - 許可されていない遷移は途中の遷移を補う
+func TestTrackInfersSkippedTransitions(t *testing.T) {
+	now := time.Now()
+	idle := state.StatusHistory{Current: StatusIdle, Since: now.Add(-time.Minute)}
+
+	history, changed := Track(&idle, StatusMerged, now)
+	if !changed {
+		t.Fatal("Track() did not change the history")
+	}
+	var path []string
+	for _, tr := range history.Transitions {
+		if !ValidTransition(tr.From, tr.To) {
+			t.Errorf("recorded invalid transition %s → %s", tr.From, tr.To)
+		}
+		path = append(path, tr.To)
+		if tr.Inferred != (tr.To != StatusMerged) {
+			t.Errorf("transition to %s inferred = %v", tr.To, tr.Inferred)
+		}
+	}
+	if len(path) != 3 || path[2] != StatusMerged {
+		t.Errorf("path = %v, want idle → running → ready → merged", path)
+	}
+	if history.Flips != 0 {
+		t.Errorf("inferred steps counted as flips: %d", history.Flips)
+	}
+	// 元の記録は変更しない
+	if idle.Current != StatusIdle || len(idle.Transitions) != 0 {
+		t.Errorf("input history was modified: %+v", idle)
+	}
+
+	if _, changed := Track(&idle, StatusDead, now); changed {
+		t.Error("dead should not be recorded")
+	}
+}
+
+// TestTrackKeepsRecentTransitions - 遷移は MaxTransitions 件まで残す
+func TestTrackKeepsRecentTransitions(t *testing.T) {
+	now := time.Now()
+	var history state.StatusHistory
+	for i := 0; i < MaxTransitions+10; i++ {
+		st := StatusRunning
+		if i%2 == 1 {
+			st = StatusIdle
+		}
+		history, _ = Track(&history, st, now.Add(time.Duration(i)*time.Second))
+	}
+	if len(history.Transitions) != MaxTransitions {
+		t.Errorf("kept %d transitions, want %d", len(history.Transitions), MaxTransitions)
+	}
+	if history.Flips != MaxTransitions+9 {
+		t.Errorf("flips = %d", history.Flips)
+	}
+}
+
+func TestDescribeDuration(t *testing.T) {
+	tests := []struct {
+		status string
+		d      time.Duration
+		want   string
+	}{
+		{StatusRunning, 12 * time.Minute, "running for 12m"},
+		{StatusReady, 3*time.Minute + 20*time.Second, "ready since 3m"},
+		{StatusIdle, 45 * time.Second, "idle since 45s"},
+		{StatusError, 3*time.Hour + 5*time.Minute, "error for 3h5m"},
+		{StatusMerged, 50 * time.Hour, "merged since 2d2h"},
+	}
+	for _, tt := range tests {
+		if got := DescribeDuration(tt.status, tt.d); got != tt.want {
+			t.Errorf("DescribeDuration(%s, %v) = %q, want %q", tt.status, tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package tmux
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/devflowinc/uzi/pkg/config"
+)
+
+// DefaultSocket is the name of the dedicated tmux server socket used by uzi
+const DefaultSocket = "uzi"
+
+// SocketEnv overrides the socket name from the environment
+const SocketEnv = "UZI_TMUX_SOCKET"
+
+var (
+	socketOnce sync.Once
+	socketName string
+)
+
+// Socket returns the tmux socket name for uzi sessions. It is read from
+// $UZI_TMUX_SOCKET, then tmuxSocket in uzi.yaml, and defaults to "uzi".
+func Socket() string {
+	socketOnce.Do(func() {
+		socketName = DefaultSocket
+		if cfg, err := config.LoadConfig(config.GetDefaultConfigPath()); err == nil &&
+			cfg.TmuxSocket != nil && *cfg.TmuxSocket != "" {
+			socketName = *cfg.TmuxSocket
+		}
+		if env := os.Getenv(SocketEnv); env != "" {
+			socketName = env
+		}
+	})
+	return socketName
+}
+
+// Args prefixes args with the socket selection flag
+func Args(args ...string) []string {
+	return append([]string{"-L", Socket()}, args...)
+}
+
+// Command returns a tmux command that runs against the uzi socket
+func Command(args ...string) *exec.Cmd {
+	return exec.Command("tmux", Args(args...)...)
+}
+
+// CommandContext is like Command but bound to ctx
+func CommandContext(ctx context.Context, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, "tmux", Args(args...)...)
+}
+
+// ShellCommand returns the tmux invocation as a shell string, for use in
+// commands that tmux itself runs (pipe-pane, run-shell, nested clients)
+func ShellCommand(args ...string) string {
+	quoted := make([]string, 0, len(args)+1)
+	quoted = append(quoted, "tmux")
+	for _, arg := range Args(args...) {
+		quoted = append(quoted, ShellQuote(arg))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// ShellQuote quotes s for safe use as a single POSIX shell word
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// CommandQuote quotes s as a single argument in tmux's own command syntax,
+// e.g. for commands stored in hooks
+func CommandQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`).Replace(s) + `"`
+}
+
+// eventHooks fire whenever a session, window or pane comes or goes
+var eventHooks = []string{
+	"session-created", "session-closed", "window-linked", "window-unlinked",
+	"pane-exited", "pane-died",
+}
+
+// eventHookIndex is the hook array slot owned by uzi, so repeated calls
+// replace the hooks instead of adding more
+const eventHookIndex = 42
+
+// SetEventHooks makes the uzi server run shellCommand in the background on
+// every session, window or pane lifecycle change
+func SetEventHooks(ctx context.Context, shellCommand string) error {
+	hookCommand := "run-shell -b " + CommandQuote(shellCommand)
+	var args []string
+	for i, hook := range eventHooks {
+		if i > 0 {
+			args = append(args, ";")
+		}
+		args = append(args, "set-hook", "-g", fmt.Sprintf("%s[%d]", hook, eventHookIndex), hookCommand)
+	}
+	return CommandContext(ctx, args...).Run()
+}
+
+// HasSession reports whether the session exists on the uzi server
+func HasSession(sessionName string) bool {
+	return Command("has-session", "-t", sessionName).Run() == nil
+}
+
+// ListSessions returns the names of all sessions on the uzi server
+func ListSessions() ([]string, error) {
+	output, err := Command("list-sessions", "-F", "#{session_name}").Output()
+	if err != nil {
+		// No server running means no sessions
+		return nil, nil
+	}
+
+	var sessions []string
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if line != "" {
+			sessions = append(sessions, line)
+		}
+	}
+	return sessions, nil
+}
+
+// PanePIDs returns the process IDs of the pane shells in every window of a
+// session, keyed by window name
+func PanePIDs(ctx context.Context, sessionName string) (map[string][]int, error) {
+	output, err := CommandContext(ctx, "list-panes", "-s", "-t", sessionName, "-F", "#{window_name}\t#{pane_pid}").Output()
+	if err != nil {
+		return nil, fmt.Errorf("tmux list-panes: %w", err)
+	}
+
+	pids := make(map[string][]int)
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		window, pid, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(pid); err == nil {
+			pids[window] = append(pids[window], n)
+		}
+	}
+	return pids, nil
+}
+
+// KillServer stops the uzi tmux server and every session on it
+func KillServer(ctx context.Context) error {
+	return CommandContext(ctx, "kill-server").Run()
+}
+
+// SocketPath returns the filesystem path of the uzi tmux socket, following
+// the same rules tmux uses for -L
+func SocketPath() string {
+	dir := os.Getenv("TMUX_TMPDIR")
+	if dir == "" {
+		dir = "/tmp"
+	}
+	return filepath.Join(dir, fmt.Sprintf("tmux-%d", os.Getuid()), Socket())
+}
+
+// InsideUziServer reports whether the current process runs in a client of
+// the uzi tmux server, as opposed to the user's own tmux server
+func InsideUziServer() bool {
+	current := os.Getenv("TMUX")
+	if current == "" {
+		return false
+	}
+	return strings.SplitN(current, ",", 2)[0] == SocketPath()
+}
@@ -0,0 +1,132 @@
+package tmux
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv(SocketEnv, "uzi-test")
+	os.Exit(m.Run())
+}
+
+func TestArgsUseDedicatedSocket(t *testing.T) {
+	args := Args("has-session", "-t", "agent-repo-abc-penelope")
+	expected := []string{"-L", "uzi-test", "has-session", "-t", "agent-repo-abc-penelope"}
+
+	if strings.Join(args, " ") != strings.Join(expected, " ") {
+		t.Errorf("Args() = %v, want %v", args, expected)
+	}
+
+	cmd := Command("list-sessions")
+	if got := strings.Join(cmd.Args, " "); got != "tmux -L uzi-test list-sessions" {
+		t.Errorf("Command() args = %q", got)
+	}
+}
+
+func TestShellCommand(t *testing.T) {
+	got := ShellCommand("send-keys", "-t", "s:agent", "it's done")
+	want := `tmux '-L' 'uzi-test' 'send-keys' '-t' 's:agent' 'it'\''s done'`
+	if got != want {
+		t.Errorf("ShellCommand() = %s, want %s", got, want)
+	}
+}
+
+func TestInsideUziServer(t *testing.T) {
+	original := os.Getenv("TMUX")
+	defer os.Setenv("TMUX", original)
+
+	os.Setenv("TMUX", "")
+	if InsideUziServer() {
+		t.Error("expected false outside tmux")
+	}
+
+	os.Setenv("TMUX", "/tmp/tmux-1000/default,1234,0")
+	if InsideUziServer() {
+		t.Error("expected false inside the default server")
+	}
+
+	os.Setenv("TMUX", SocketPath()+",1234,0")
+	if !InsideUziServer() {
+		t.Error("expected true inside the uzi server")
+	}
+}
+
+// newTestSession starts a detached session, retrying while the server of
+// a previous test is still shutting down
+func newTestSession(t *testing.T, name string, args ...string) {
+	t.Helper()
+	args = append([]string{"new-session", "-d", "-s", name}, args...)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		out, err := Command(args...).CombinedOutput()
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("new-session: %v: %s", err, out)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestSetEventHooks(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed")
+	}
+	t.Cleanup(func() { KillServer(context.Background()) })
+	newTestSession(t, "hooks")
+
+	marker := filepath.Join(t.TempDir(), "it's $HOME")
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := SetEventHooks(ctx, "touch "+ShellQuote(marker)); err != nil {
+			t.Fatalf("SetEventHooks() error = %v", err)
+		}
+	}
+	out, _ := Command("show-hooks", "-g", "window-linked").Output()
+	if n := strings.Count(string(out), "run-shell"); n != 1 {
+		t.Errorf("window-linked has %d uzi hooks after two calls, want 1:\n%s", n, out)
+	}
+
+	if err := Command("new-window", "-t", "hooks").Run(); err != nil {
+		t.Fatalf("new-window: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(marker); err == nil {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Errorf("hook did not create %s", marker)
+}
+
+func TestPanePIDs(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed")
+	}
+	t.Cleanup(func() { KillServer(context.Background()) })
+	newTestSession(t, "pids", "-n", "agent")
+	if err := Command("new-window", "-t", "pids", "-n", "uzi-dev").Run(); err != nil {
+		t.Fatalf("new-window: %v", err)
+	}
+
+	pids, err := PanePIDs(context.Background(), "pids")
+	if err != nil {
+		t.Fatalf("PanePIDs() error = %v", err)
+	}
+	for _, window := range []string{"agent", "uzi-dev"} {
+		if len(pids[window]) != 1 || pids[window][0] <= 0 {
+			t.Errorf("PanePIDs()[%q] = %v", window, pids[window])
+		}
+	}
+	if _, err := PanePIDs(context.Background(), "missing"); err == nil {
+		t.Error("PanePIDs() of a missing session returned no error")
+	}
+}
@@ -0,0 +1,95 @@
+package transcript
+
+import (
+	"bytes"
+	"io"
+)
+
+type stripState int
+
+const (
+	stateText stripState = iota
+	stateEscape
+	stateCharset
+	stateCSI
+	stateOSC
+	stateOSCEscape
+)
+
+// StripWriter removes ANSI escape sequences and control characters from the
+// bytes written to it. It keeps state between writes, so sequences split
+// across reads of a pipe are still removed.
+type StripWriter struct {
+	w     io.Writer
+	state stripState
+	buf   []byte
+}
+
+// NewStripWriter wraps w with ANSI stripping
+func NewStripWriter(w io.Writer) *StripWriter {
+	return &StripWriter{w: w}
+}
+
+// Write implements io.Writer
+func (s *StripWriter) Write(p []byte) (int, error) {
+	s.buf = s.buf[:0]
+	for _, b := range p {
+		switch s.state {
+		case stateText:
+			switch {
+			case b == 0x1b:
+				s.state = stateEscape
+			case b == '\n' || b == '\t':
+				s.buf = append(s.buf, b)
+			case b < 0x20 || b == 0x7f:
+				// Drop carriage returns, bells, backspaces and other controls
+			default:
+				s.buf = append(s.buf, b)
+			}
+		case stateEscape:
+			switch b {
+			case '[':
+				s.state = stateCSI
+			case ']', 'P', '_', '^':
+				s.state = stateOSC
+			case '(', ')', '*', '+':
+				s.state = stateCharset
+			default:
+				s.state = stateText
+			}
+		case stateCharset:
+			s.state = stateText
+		case stateCSI:
+			if b >= 0x40 && b <= 0x7e {
+				s.state = stateText
+			}
+		case stateOSC:
+			switch b {
+			case 0x07:
+				s.state = stateText
+			case 0x1b:
+				s.state = stateOSCEscape
+			}
+		case stateOSCEscape:
+			if b == '\\' {
+				s.state = stateText
+			} else {
+				s.state = stateOSC
+			}
+		}
+	}
+
+	if len(s.buf) > 0 {
+		if _, err := s.w.Write(s.buf); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// StripANSI returns s with ANSI escape sequences and control characters removed
+func StripANSI(s string) string {
+	var out bytes.Buffer
+	NewStripWriter(&out).Write([]byte(s))
+	return out.String()
+}
@@ -0,0 +1,93 @@
+package transcript
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/devflowinc/uzi/pkg/state"
+)
+
+const (
+	// DefaultMaxSize is the size in bytes at which a transcript is rotated
+	DefaultMaxSize = 10 * 1024 * 1024
+	// DefaultMaxBackups is the number of rotated transcripts kept per window
+	DefaultMaxBackups = 3
+)
+
+// Dir returns the directory that holds the transcripts of every session
+func Dir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".local", "share", "uzi", "logs"), nil
+}
+
+// Path returns the transcript file for a window of the given session
+func Path(sessionName, window string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, sessionName, window+".log"), nil
+}
+
+// Files returns the rotated backups of a transcript, oldest first, followed
+// by the transcript itself. Missing files are skipped.
+func Files(path string) []string {
+	var files []string
+	for i := DefaultMaxBackups; i >= 1; i-- {
+		backup := fmt.Sprintf("%s.%d", path, i)
+		if _, err := os.Stat(backup); err == nil {
+			files = append(files, backup)
+		}
+	}
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	}
+	return files
+}
+
+// FindSession returns the most recently written transcript session for an
+// agent. It works after the tmux session has been killed.
+func FindSession(agentName string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("no transcripts found for agent: %s", agentName)
+	}
+
+	type candidate struct {
+		name    string
+		modTime int64
+	}
+	var candidates []candidate
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if entry.Name() != agentName && state.AgentNameFromSession(entry.Name()) != agentName {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, candidate{name: entry.Name(), modTime: info.ModTime().UnixNano()})
+	}
+
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no transcripts found for agent: %s", agentName)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].modTime > candidates[j].modTime
+	})
+	return candidates[0].name, nil
+}
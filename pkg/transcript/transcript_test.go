@@ -0,0 +1,98 @@
+package transcript
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStripANSI(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"plain text", "hello world\n", "hello world\n"},
+		{"color codes", "\033[32m+10\033[0m/\033[31m-2\033[0m", "+10/-2"},
+		{"cursor movement", "\033[2J\033[H\033[?25lready", "ready"},
+		{"window title", "\033]2;agent\007done", "done"},
+		{"title with ST", "\033]0;agent\033\\done", "done"},
+		{"charset selection", "\033(Bbox", "box"},
+		{"carriage returns", "line\r\n", "line\n"},
+		{"tabs kept", "a\tb", "a\tb"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripANSI(tt.input); got != tt.expected {
+				t.Errorf("StripANSI(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStripWriterAcrossWrites(t *testing.T) {
+	var out bytes.Buffer
+	w := NewStripWriter(&out)
+
+	chunks := []string{"ab\033", "[3", "1mcd\033]2;ti", "tle\007ef"}
+	for _, chunk := range chunks {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := out.String(); got != "abcdef" {
+		t.Errorf("got %q, want %q", got, "abcdef")
+	}
+}
+
+func TestRotatingWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.log")
+
+	w, err := NewRotatingWriter(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, chunk := range []string{"aaaaaaaa", "bbbbbbbb", "cccccccc", "dddddddd"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+
+	expected := map[string]string{
+		path:        "dddddddd",
+		path + ".1": "cccccccc",
+		path + ".2": "bbbbbbbb",
+	}
+	for file, content := range expected {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("reading %s: %v", file, err)
+		}
+		if string(data) != content {
+			t.Errorf("%s = %q, want %q", file, data, content)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 backups, found %s.3", path)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.log")
+	for _, file := range []string{path, path + ".1", path + ".2"} {
+		os.WriteFile(file, []byte("x"), 0644)
+	}
+
+	files := Files(path)
+	got := strings.Join(files, ",")
+	want := strings.Join([]string{path + ".2", path + ".1", path}, ",")
+	if got != want {
+		t.Errorf("Files() = %s, want %s", got, want)
+	}
+}
@@ -7,11 +7,11 @@ echo ""
 
 # 1. uzi autoの確認
 echo "1. uzi autoプロセスの確認..."
-if ps aux | grep "uzi auto" | grep -v grep > /dev/null; then
+if ./uzi auto status > /dev/null 2>&1; then
     echo "   ✅ uzi autoが起動しています"
 else
     echo "   ❌ uzi autoが起動していません！"
-    echo "   実行: nohup ./uzi auto > /dev/null 2>&1 &"
+    echo "   実行: ./uzi auto start"
 fi
 echo ""
 
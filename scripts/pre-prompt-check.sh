@@ -12,9 +12,9 @@ echo ""
 ERROR_COUNT=0
 
 # 1. uzi autoが起動しているかチェック
-if ! pgrep -f "uzi auto" > /dev/null; then
+if ! ./uzi auto status > /dev/null 2>&1; then
     echo "❌ エラー: uzi autoが起動していません"
-    echo "   実行してください: ./uzi auto &"
+    echo "   実行してください: ./uzi auto start"
     ((ERROR_COUNT++))
 else
     echo "✅ uzi autoは起動しています"
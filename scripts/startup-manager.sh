@@ -10,12 +10,11 @@ echo "==================================="
 echo ""
 
 # 1. uzi autoが既に起動しているか確認
-if pgrep -f "uzi auto" > /dev/null; then
+if ./uzi auto status > /dev/null 2>&1; then
     echo "✅ uzi autoは既に起動しています"
 else
     echo "1. uzi autoを起動します..."
-    ./uzi auto &
-    sleep 2
+    ./uzi auto start
     echo "✅ uzi autoを起動しました"
 fi
 
@@ -17,7 +17,7 @@ AGENT_NAME=$1
 COMMIT_MSG=${2:-""}
 
 # エージェントの存在確認
-if ! ./uzi ls | grep -q "^$AGENT_NAME"; then
+if ! ./uzi ls -o tsv | awk -F'\t' -v agent="$AGENT_NAME" 'NR > 1 && $1 == agent { found = 1 } END { exit !found }'; then
     echo "❌ エラー: エージェント '$AGENT_NAME' が見つかりません"
     echo ""
     echo "アクティブなエージェント:"
@@ -31,7 +31,7 @@ if [ -z "$COMMIT_MSG" ]; then
     echo "エージェントの変更内容を確認中..."
     
     # git diffから変更内容を推測
-    DIFF_SUMMARY=$(./uzi ls -d | grep "^$AGENT_NAME" | awk '{print $4}')
+    DIFF_SUMMARY=$(./uzi ls -o tsv | awk -F'\t' -v agent="$AGENT_NAME" 'NR > 1 && $1 == agent { print "+" $6 "/-" $7 }')
     
     echo ""
     echo "推奨されるコミットメッセージ:"
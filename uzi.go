@@ -13,13 +13,20 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/devflowinc/uzi/cmd/answer"
+	"github.com/devflowinc/uzi/cmd/attach"
 	"github.com/devflowinc/uzi/cmd/broadcast"
 	"github.com/devflowinc/uzi/cmd/checkpoint"
+	"github.com/devflowinc/uzi/cmd/grid"
 	"github.com/devflowinc/uzi/cmd/kill"
+	"github.com/devflowinc/uzi/cmd/logs"
 	"github.com/devflowinc/uzi/cmd/ls"
 	"github.com/devflowinc/uzi/cmd/prompt"
+	"github.com/devflowinc/uzi/cmd/ptyserver"
 	"github.com/devflowinc/uzi/cmd/reset"
 	"github.com/devflowinc/uzi/cmd/run"
+	"github.com/devflowinc/uzi/cmd/status"
+	"github.com/devflowinc/uzi/cmd/ui"
 	"github.com/devflowinc/uzi/cmd/watch"
 
 	"github.com/peterbourgon/ff/v3/ffcli"
@@ -34,6 +41,14 @@ var subcommands = []*ffcli.Command{
 	checkpoint.CmdCheckpoint,
 	watch.CmdWatch,
 	broadcast.CmdBroadcast,
+	attach.CmdAttach,
+	attach.CmdSwitch,
+	logs.CmdLogs,
+	grid.CmdGrid,
+	ptyserver.CmdPtyServer,
+	ui.CmdUI,
+	status.CmdStatus,
+	answer.CmdAnswer,
 }
 
 var commandAliases = map[string]*regexp.Regexp{
@@ -46,6 +61,10 @@ var commandAliases = map[string]*regexp.Regexp{
 	"watch":      regexp.MustCompile(`^w(atch)?$`),
 	"broadcast":  regexp.MustCompile(`^b(roadcast)?$`),
 	"attach":     regexp.MustCompile(`^a(ttach)?$`),
+	"switch":     regexp.MustCompile(`^sw(itch)?$`),
+	"grid":       regexp.MustCompile(`^g(rid)?$`),
+	"status":     regexp.MustCompile(`^st(atus)?$`),
+	"answer":     regexp.MustCompile(`^an(swer)?$`),
 }
 
 func main() {